@@ -9,7 +9,14 @@ import (
 var reader = bytes.NewReader
 
 func parseBytes(bytes []byte) ([]OperationCommon, []ControlBlock) {
+	ops, controlBlocks, _ := parseBytesWithOffsets(bytes)
+	return ops, controlBlocks
+}
+
+// parseBytesWithOffsets also returns the offset in bytes of each operation, for tools mapping ops back to the code.
+func parseBytesWithOffsets(bytes []byte) ([]OperationCommon, []ControlBlock, []int) {
 	ansOps := []OperationCommon{}
+	opOffsets := []int{}
 	pointInBytes := 0
 
 	// The first control here marks the beginning of the function
@@ -21,6 +28,7 @@ func parseBytes(bytes []byte) ([]OperationCommon, []ControlBlock) {
 	index := 0
 
 	for pointInBytes < len(bytes) {
+		opStart, opCount := pointInBytes, len(ansOps)
 		switch bytes[pointInBytes] {
 
 		case Op_i32_const:
@@ -737,7 +745,10 @@ func parseBytes(bytes []byte) ([]OperationCommon, []ControlBlock) {
 			pointInBytes += 1
 		}
 
+		if len(ansOps) > opCount {
+			opOffsets = append(opOffsets, opStart)
+		}
 	}
 
-	return ansOps, controlBlocks
+	return ansOps, controlBlocks, opOffsets
 }
@@ -15,42 +15,34 @@ import (
 	"github.com/adamnite/go-adamnite/params"
 )
 
-func NewVirtualMachineWithContract(apiEndpoint string, contract *common.Address) (*Machine, error) {
+func NewVirtualMachineWithContract(store CodeStore, contract *common.Address) (*Machine, error) {
 	vm := NewVirtualMachine([]byte{}, []uint64{}, nil, 1000)
+	vm.config.CodeStore = store
 
 	if contract == nil {
 		return vm, nil
 	}
-	if err := vm.ResetToContract(apiEndpoint, *contract); err != nil {
+	if err := vm.ResetToContract(store, *contract); err != nil {
 		return nil, err
 	}
 
 	return vm, nil
 }
-func (vm *Machine) ResetToContract(apiEndpoint string, contract common.Address) error {
+// ResetToContract loads the contract from the store, and uses that store for the code of its methods.
+func (vm *Machine) ResetToContract(store CodeStore, contract common.Address) error {
 	vm.Reset()
-	con, err := GetContractData(apiEndpoint, contract.Hex())
+	con, err := store.GetContract(contract)
 	if err != nil {
 		return err
 	}
 	vm.contract = *con
+	vm.config.CodeStore = store
 	return nil
 }
-func (vm *Machine) CallWith(apiEndpoint string, rt *RuntimeChanges) (*RuntimeChanges, error) {
-	if err := vm.ResetToContract(apiEndpoint, rt.ContractCalled); err != nil {
+func (vm *Machine) CallWith(store CodeStore, rt *RuntimeChanges) (*RuntimeChanges, error) {
+	if err := vm.ResetToContract(store, rt.ContractCalled); err != nil {
 		return nil, err
 	}
-	spoofer := NewDBSpoofer() //this is either smart, or *very* stupid, i cant honestly tell
-	//TODO: either way, cleanup here
-	vm.config.CodeGetter = func(hash []byte) (FunctionType, []OperationCommon, []ControlBlock) {
-		stored, err := GetMethodCode(apiEndpoint, hex.EncodeToString(hash))
-		if err != nil {
-			panic(err) //TODO: handle this better.
-		}
-		spoofer.AddSpoofedCode(hex.EncodeToString(hash), *stored)
-
-		return spoofer.GetCode(hash)
-	}
 	return vm.CallOnContractWith(rt)
 }
 func (vm *Machine) CallOnContractWith(rt *RuntimeChanges) (*RuntimeChanges, error) {
@@ -63,14 +55,6 @@ func newContract(caller common.Address, value *big.Int, input []byte, gas uint64
 	return c
 }
 
-func GetCodeBytes2(uri string, hash string) ([]byte, error) {
-	code, err := GetMethodCode(uri, hash)
-	if err != nil {
-		return nil, err
-	}
-	return code.CodeBytes, nil
-}
-
 func GetDefaultConfig() VMConfig {
 	return VMConfig{
 		maxCallStackDepth:        1024,
@@ -78,49 +62,105 @@ func GetDefaultConfig() VMConfig {
 		returnOnGasLimitExceeded: true,
 		debugStack:               false,
 		CodeGetter:               defaultCodeGetter,
-		CodeBytesGetter:          GetCodeBytes2,
-		Uri:                      "https//default.uri",
 	}
 }
 
 func (m *Machine) run() error {
+	m.frameEntered = false
+	for {
+		done, err := m.Step()
+		if done || err != nil {
+			return err
+		}
+	}
+}
 
+// Step runs the next operation of the active frame, entering and leaving frames as needed.
+// Returns true once there is nothing left to run, or an error was encountered.
+func (m *Machine) Step() (bool, error) {
 	for m.currentFrame >= 0 {
 		currentFrame := m.callStack[m.currentFrame]
 
-		if currentFrame.Continuation != -1 {
-			m.pointInCode = uint64(currentFrame.Continuation)
+		if !m.frameEntered {
+			if currentFrame.Continuation != -1 {
+				m.pointInCode = uint64(currentFrame.Continuation)
+			}
+
+			currentFrame.Ip = m.pointInCode
+			m.vmCode = currentFrame.Code
+			m.locals = currentFrame.Locals
+			m.frameEntered = true
+		}
+		if uint64(currentFrame.Ip) >= uint64(len(currentFrame.Code)) {
+			m.currentFrame--
+			m.frameEntered = false
+			continue
 		}
 
-		currentFrame.Ip = m.pointInCode
-		m.vmCode = currentFrame.Code
-		m.locals = currentFrame.Locals
-		for uint64(currentFrame.Ip) < uint64(len(currentFrame.Code)) {
-			oldFrameNum := m.currentFrame
-			op := currentFrame.Code[currentFrame.Ip]
-			err := op.doOp(m)
+		oldFrameNum := m.currentFrame
+		op := currentFrame.Code[currentFrame.Ip]
+		err := op.doOp(m)
 
-			if m.stopSignal {
-				m.stopSignal = false
-			}
-			m.debugOutputStack()
+		if m.stopSignal {
+			m.stopSignal = false
+		}
+		m.debugOutputStack()
 
-			if err != nil {
-				return err
-			}
-			currentFrame.Ip++
-
-			// Activate the new frame
-			if m.currentFrame > oldFrameNum {
-				if m.currentFrame > int(m.config.maxCallStackDepth) {
-					return ErrDepth
-				}
-				currentFrame = m.callStack[m.currentFrame]
+		if err != nil {
+			return true, m.trap(err)
+		}
+		currentFrame.Ip++
+
+		// The new frame has been activated by the call
+		if m.currentFrame > oldFrameNum && m.currentFrame > int(m.config.maxCallStackDepth) {
+			return true, m.trap(ErrDepth)
+		}
+		return false, nil
+	}
+	return true, nil
+}
+
+// Backtrace returns the position of every active frame, starting at the innermost one.
+func (m *Machine) Backtrace() []TraceEntry {
+	trace := []TraceEntry{}
+	for i := m.currentFrame; i >= 0 && i < len(m.callStack); i-- {
+		frame := m.callStack[i]
+		offset := frame.Ip
+		switch {
+		case i != m.currentFrame:
+			//the outer frames are waiting on the call that they made
+			offset = uint64(frame.Continuation - 1)
+		case !m.frameEntered && frame.Continuation != -1:
+			offset = uint64(frame.Continuation)
+		case !m.frameEntered:
+			offset = m.pointInCode
+		}
+		trace = append(trace, TraceEntry{Function: frame.Function, Offset: offset})
+	}
+	return trace
+}
+
+// trap wraps the error with a backtrace of the active frames
+func (m *Machine) trap(err error) error {
+	return &TrapError{Err: err, Trace: m.Backtrace()}
+}
+
+// functionName finds the debug name for the function with that hash, falling back to its hash
+func (m *Machine) functionName(hash []byte) string {
+	hexHash := hex.EncodeToString(hash)
+	if len(m.contract.CodeNames) == len(m.contract.CodeHashes) {
+		for i, h := range m.contract.CodeHashes {
+			if h == hexHash && m.contract.CodeNames[i] != "" {
+				return m.contract.CodeNames[i]
 			}
 		}
-		m.currentFrame--
 	}
-	return nil
+	if m.config.NameGetter != nil {
+		if name := m.config.NameGetter(hash); name != "" {
+			return name
+		}
+	}
+	return hexHash
 }
 
 func (m *Machine) debugOutputStack() {
@@ -140,6 +180,54 @@ func (m *Machine) OutputStack() string {
 	return ans
 }
 
+// Stack returns a copy of the values currently on the stack, with the top of the stack last.
+func (m *Machine) Stack() []uint64 {
+	return append([]uint64{}, m.vmStack...)
+}
+
+// Locals returns a copy of the locals of the active frame.
+func (m *Machine) Locals() []uint64 {
+	if m.currentFrame >= 0 && m.currentFrame < len(m.callStack) && !m.frameEntered {
+		return append([]uint64{}, m.callStack[m.currentFrame].Locals...)
+	}
+	return append([]uint64{}, m.locals...)
+}
+
+// Memory returns a copy of length bytes of memory starting at start, cut short at the end of memory.
+func (m *Machine) Memory(start uint64, length uint64) []byte {
+	if start >= uint64(len(m.vmMemory)) {
+		return []byte{}
+	}
+	end := start + length
+	if end > uint64(len(m.vmMemory)) || end < start {
+		end = uint64(len(m.vmMemory))
+	}
+	return append([]byte{}, m.vmMemory[start:end]...)
+}
+
+// Storage returns a copy of the storage of the contract being ran.
+func (m *Machine) Storage() []uint64 {
+	return append([]uint64{}, m.contractStorage...)
+}
+
+// GasRemaining returns the gas left for the current call.
+func (m *Machine) GasRemaining() uint64 {
+	return m.gas
+}
+
+// NextOperation returns the operation that the next Step will run, or nil if the call is finished.
+func (m *Machine) NextOperation() OperationCommon {
+	trace := m.Backtrace()
+	if len(trace) == 0 {
+		return nil
+	}
+	code := m.callStack[m.currentFrame].Code
+	if trace[0].Offset >= uint64(len(code)) {
+		return nil
+	}
+	return code[trace[0].Offset]
+}
+
 func (m *Machine) OutputMemory() string {
 	ans := ""
 	for _, v := range m.vmMemory {
@@ -204,6 +292,7 @@ func initVMState(machine *Machine) {
 	mainFrame.CtrlStack = machine.controlBlockStack
 	mainFrame.Locals = machine.locals
 	machine.callStack = append(machine.callStack, mainFrame)
+	machine.frameEntered = false
 
 	capacity := 20 * defaultPageSize
 	machine.vmMemory = make([]byte, capacity) // Initialize empty memory. (make creates array of 0)
@@ -323,8 +412,31 @@ func defaultCodeGetter(hash []byte) (FunctionType, []OperationCommon, []ControlB
 	panic(fmt.Errorf("virtual machine does not have a code getter setup"))
 }
 
+// getCode finds the code of a method, from the code store if there is one, otherwise from the code getter.
+func (m *Machine) getCode(hash []byte) (FunctionType, []OperationCommon, []ControlBlock, error) {
+	if m.config.CodeStore == nil {
+		funcType, ops, blocks := m.config.CodeGetter(hash)
+		return funcType, ops, blocks, nil
+	}
+	code, err := m.config.CodeStore.GetMethod(hash)
+	if err != nil {
+		return FunctionType{}, nil, nil, fmt.Errorf("unable to get code %x: %w", hash, err)
+	}
+	funcType, ops, blocks := code.parse(hash)
+	return funcType, ops, blocks, nil
+}
+
 // Called when invoking specific function inside the contract
 func (m *Machine) Call2(callBytes interface{}, gas uint64) error {
+	if err := m.PrepareCall(callBytes, gas); err != nil {
+		return err
+	}
+	return m.run()
+}
+
+// PrepareCall sets up the machine to call the function in callBytes (same format as Call2) without running it.
+// The call can then be ran one operation at a time with Step.
+func (m *Machine) PrepareCall(callBytes interface{}, gas uint64) error {
 	// Structure: 0x[16 bytes func identifier][param1..][param2...][param3]
 	// Note: The callbytes is following the wasm encoding scheme. can be passed as string or byte array
 	var bytes []byte
@@ -342,7 +454,10 @@ func (m *Machine) Call2(callBytes interface{}, gas uint64) error {
 	}
 
 	funcIdentifier := bytes[:16]
-	funcTypes, funcCode, controlStack := m.config.CodeGetter(funcIdentifier)
+	funcTypes, funcCode, controlStack, err := m.getCode(funcIdentifier)
+	if err != nil {
+		return err
+	}
 	var params []uint64
 	//get the params from the bytes passed.
 	for i := uint64(len(funcIdentifier)); i < uint64(len(bytes)); i++ {
@@ -410,7 +525,9 @@ func (m *Machine) Call2(callBytes interface{}, gas uint64) error {
 	currentFrame.Locals = m.locals
 	currentFrame.Code = m.vmCode
 	currentFrame.CtrlStack = m.controlBlockStack
-	return m.run()
+	currentFrame.Function = m.functionName(funcIdentifier)
+	m.frameEntered = false
+	return nil
 }
 
 // Call executes the contract associated with the addr with the given input as
@@ -439,6 +556,7 @@ func (m *Machine) Call(caller common.Address, addr common.Address, input []byte,
 	}
 
 	contract := newContract(caller, value, input, gas)
+	contract.Address = addr
 	m.contract = *contract
 
 	err = m.Call2(input, gas)
@@ -480,8 +598,12 @@ func (m *Machine) create(caller common.Address, codeBytes []byte, gas uint64, va
 
 	m.Statedb.SetNonce(caller, nonce+1)
 
+	if m.config.CodeStore == nil {
+		return common.Address{}, gas, ErrNoCodeStore
+	}
+
 	// Ensure there's no existing contract already at the designated address
-	_, err := GetContractData(m.config.Uri, address.Hex())
+	_, err := m.config.CodeStore.GetContract(address)
 
 	if err == nil || err != ERR_CONTRACT_NOT_STORED {
 		//either theres a strange error, or the contract already exists.
@@ -524,7 +646,7 @@ func (m *Machine) create(caller common.Address, codeBytes []byte, gas uint64, va
 	}
 
 	// Upload the module here
-	codeStored, hashesOfMethods, err := UploadModuleFunctions(m.config.Uri, module)
+	codeStored, hashesOfMethods, err := UploadModuleFunctions(m.config.CodeStore, module)
 
 	if err != nil {
 		m.Statedb.RevertToSnapshot(snapshot)
@@ -535,6 +657,12 @@ func (m *Machine) create(caller common.Address, codeBytes []byte, gas uint64, va
 	for i, hashBytes := range hashesOfMethods {
 		m.contract.CodeHashes[i] = hex.EncodeToString(hashBytes)
 	}
+	if module.Names() != nil {
+		m.contract.CodeNames = make([]string, len(hashesOfMethods))
+		for i := range hashesOfMethods {
+			m.contract.CodeNames[i] = module.FunctionName(i)
+		}
+	}
 
 	m.gas -= createModuleGas
 	return address, contract.Gas, err
@@ -563,6 +691,7 @@ func (m *Machine) Reset() {
 	m.callStack[0].CtrlStack = m.controlBlockStack
 	m.callStack[0].Locals = m.locals
 	m.callStack = []*Frame{m.callStack[0]}
+	m.frameEntered = false
 }
 
 func (m *Machine) AddLocal(n interface{}) {
@@ -604,9 +733,9 @@ func (m *Machine) GetContractHash() common.Hash {
 	return m.contract.Hash()
 }
 
-func (m *Machine) UploadContract(APIEndpoint string) error {
-	//takes the contract (or just its changes) and uploads that to the DB at the APIEndpoint link
-	return UploadContract(APIEndpoint, m.contract)
+func (m *Machine) UploadContract(store CodeStore) error {
+	//takes the contract (or just its changes) and uploads that to the store
+	return store.PutContract(m.contract)
 }
 
 // sort the changes from the method being ran, allowing this to be passed along the chain and to the OffChainDB efficiently
@@ -171,3 +171,26 @@ func TestGettingFinalDataChanges(t *testing.T) {
 	}
 	// changes.OutputChanges()
 }
+
+func TestTrapBacktrace(t *testing.T) {
+	// same module as TestCall2, with the functions named add, sub and mul
+	wasmBytes, _ := hex.DecodeString("0061736d0100000001070160027f7f017f0304030000000a19030700200020016a0b0700200020016b0b0700200020016c0b0020046e616d650110030003616464010373756202036d756c020703000001000200")
+	spoofer := NewDBSpoofer()
+	hashes, err := spoofer.AddModuleToSpoofedCode(wasmBytes)
+	assert.NoError(t, err)
+
+	config := GetDefaultConfig()
+	config.CodeGetter = spoofer.GetCode
+	config.NameGetter = spoofer.GetFunctionName
+	vm := NewVirtualMachine([]byte{}, []uint64{}, &config, 1000)
+
+	callCode := hex.EncodeToString(hashes[1]) + "7f0a7f02"
+	// only enough gas for the first local.get
+	err = vm.Call2(callCode, 1)
+
+	assert.ErrorIs(t, err, ErrOutOfGas)
+	var trap *TrapError
+	assert.ErrorAs(t, err, &trap)
+	assert.Equal(t, []TraceEntry{{Function: "sub", Offset: 1}}, trap.Trace)
+	assert.Equal(t, ErrOutOfGas, TrapCause(err))
+}
@@ -0,0 +1,417 @@
+package VM
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// asmFunction is a function read from the text form, before being encoded
+type asmFunction struct {
+	name       string
+	params     []ValueType
+	results    []ValueType
+	locals     []ValueType
+	localNames map[Index]string
+	body       []byte
+}
+
+type asmParser struct {
+	tokens []string
+	pos    int
+}
+
+// stripComments removes ";;" line comments and "(; ;)" block comments
+func stripComments(text string) string {
+	var sb strings.Builder
+	for i := 0; i < len(text); i++ {
+		switch {
+		case strings.HasPrefix(text[i:], ";;"):
+			end := strings.IndexByte(text[i:], '\n')
+			if end == -1 {
+				return sb.String()
+			}
+			i += end - 1
+		case strings.HasPrefix(text[i:], "(;"):
+			end := strings.Index(text[i:], ";)")
+			if end == -1 {
+				return sb.String()
+			}
+			i += end + 1
+			sb.WriteByte(' ')
+		default:
+			sb.WriteByte(text[i])
+		}
+	}
+	return sb.String()
+}
+
+func tokenize(text string) []string {
+	text = strings.ReplaceAll(stripComments(text), "(", " ( ")
+	text = strings.ReplaceAll(text, ")", " ) ")
+	return strings.Fields(text)
+}
+
+func (p *asmParser) peek() string {
+	if p.pos >= len(p.tokens) {
+		return ""
+	}
+	return p.tokens[p.pos]
+}
+
+func (p *asmParser) next() string {
+	token := p.peek()
+	p.pos++
+	return token
+}
+
+func (p *asmParser) expect(token string) error {
+	if got := p.next(); got != token {
+		return fmt.Errorf("expected %q, got %q", token, got)
+	}
+	return nil
+}
+
+// peekGroup returns the keyword of the parenthesised group that starts at the current token, if any
+func (p *asmParser) peekGroup() string {
+	if p.peek() != "(" || p.pos+1 >= len(p.tokens) {
+		return ""
+	}
+	return p.tokens[p.pos+1]
+}
+
+func parseValueType(token string) (ValueType, error) {
+	switch token {
+	case "i32":
+		return Op_i32, nil
+	case "i64":
+		return Op_i64, nil
+	case "f32":
+		return Op_f32, nil
+	case "f64":
+		return Op_f64, nil
+	}
+	return 0, fmt.Errorf("unknown value type %q", token)
+}
+
+// parseTypedGroup reads the inside of (param ...), (result ...) or (local ...) groups, names are only allowed
+// with a single type.
+func (p *asmParser) parseTypedGroup() (string, []ValueType, error) {
+	name := ""
+	types := []ValueType{}
+	if strings.HasPrefix(p.peek(), "$") {
+		name = p.next()[1:]
+	}
+	for p.peek() != ")" && p.peek() != "" {
+		t, err := parseValueType(p.next())
+		if err != nil {
+			return "", nil, err
+		}
+		types = append(types, t)
+	}
+	if name != "" && len(types) != 1 {
+		return "", nil, fmt.Errorf("named declaration $%v should have exactly one type", name)
+	}
+	return name, types, p.expect(")")
+}
+
+func (p *asmParser) parseFunction() (*asmFunction, error) {
+	f := &asmFunction{localNames: map[Index]string{}}
+	if strings.HasPrefix(p.peek(), "$") {
+		f.name = p.next()[1:]
+	}
+
+	for {
+		group := p.peekGroup()
+		if group != "param" && group != "result" && group != "local" {
+			break
+		}
+		p.pos += 2
+		name, types, err := p.parseTypedGroup()
+		if err != nil {
+			return nil, fmt.Errorf("%v: %w", group, err)
+		}
+		switch group {
+		case "param":
+			if len(f.locals) != 0 {
+				return nil, fmt.Errorf("params must be declared before locals")
+			}
+			if name != "" {
+				f.localNames[Index(len(f.params))] = name
+			}
+			f.params = append(f.params, types...)
+		case "result":
+			f.results = append(f.results, types...)
+		case "local":
+			if name != "" {
+				f.localNames[Index(len(f.params)+len(f.locals))] = name
+			}
+			f.locals = append(f.locals, types...)
+		}
+	}
+
+	depth := 0
+	for p.peek() != ")" {
+		if p.peek() == "" {
+			return nil, fmt.Errorf("unexpected end of text in function body")
+		}
+		instruction := p.next()
+		opcode, known := opcodesByName[instruction]
+		if !known {
+			return nil, fmt.Errorf("unknown instruction %q", instruction)
+		}
+		info := opcodeInfos[opcode]
+		f.body = append(f.body, opcode)
+		immediates, err := p.parseImmediates(info)
+		if err != nil {
+			return nil, fmt.Errorf("%v: %w", instruction, err)
+		}
+		f.body = append(f.body, immediates...)
+
+		switch {
+		case info.immediate == immBlockType:
+			depth++
+		case opcode == Op_end:
+			depth--
+			if depth < 0 {
+				return nil, fmt.Errorf("end without a matching block")
+			}
+		}
+	}
+	if depth != 0 {
+		return nil, fmt.Errorf("%d blocks are not closed with end", depth)
+	}
+	f.body = append(f.body, Op_end)
+	return f, p.expect(")")
+}
+
+func parseUint32(token string) (uint32, error) {
+	v, err := strconv.ParseUint(token, 0, 32)
+	return uint32(v), err
+}
+
+func parseFloat(token string, bitSize int) (float64, error) {
+	switch token {
+	case "inf", "+inf":
+		return math.Inf(1), nil
+	case "-inf":
+		return math.Inf(-1), nil
+	case "nan":
+		return math.NaN(), nil
+	}
+	return strconv.ParseFloat(token, bitSize)
+}
+
+func (p *asmParser) parseImmediates(info opcodeInfo) ([]byte, error) {
+	switch info.immediate {
+	case immBlockType:
+		if p.peekGroup() != "result" {
+			return []byte{Op_empty}, nil
+		}
+		p.pos += 2
+		t, err := parseValueType(p.next())
+		if err != nil {
+			return nil, err
+		}
+		return []byte{t}, p.expect(")")
+	case immLabel, immIndex:
+		v, err := parseUint32(p.next())
+		return EncodeUint32(v), err
+	case immBrTable:
+		labels := []uint32{}
+		for {
+			v, err := parseUint32(p.peek())
+			if err != nil {
+				break
+			}
+			p.next()
+			labels = append(labels, v)
+		}
+		if len(labels) == 0 {
+			return nil, fmt.Errorf("needs at least a default label")
+		}
+		ans := EncodeUint32(uint32(len(labels) - 1))
+		for _, label := range labels {
+			ans = append(ans, EncodeUint32(label)...)
+		}
+		return ans, nil
+	case immCallIndirect:
+		if p.peekGroup() != "type" {
+			return nil, fmt.Errorf("expected (type index)")
+		}
+		p.pos += 2
+		v, err := parseUint32(p.next())
+		if err != nil {
+			return nil, err
+		}
+		return append(EncodeUint32(v), 0x00), p.expect(")")
+	case immMemArg:
+		align, offset := info.align, uint32(0)
+		for {
+			token := p.peek()
+			if strings.HasPrefix(token, "offset=") {
+				v, err := parseUint32(strings.TrimPrefix(token, "offset="))
+				if err != nil {
+					return nil, err
+				}
+				offset = v
+			} else if strings.HasPrefix(token, "align=") {
+				v, err := parseUint32(strings.TrimPrefix(token, "align="))
+				if err != nil {
+					return nil, err
+				}
+				if v == 0 || v&(v-1) != 0 {
+					return nil, fmt.Errorf("alignment %d is not a power of 2", v)
+				}
+				align = 0
+				for v > 1 {
+					v >>= 1
+					align++
+				}
+			} else {
+				break
+			}
+			p.next()
+		}
+		return append(EncodeUint32(align), EncodeUint32(offset)...), nil
+	case immReserved:
+		return []byte{0x00}, nil
+	case immI32:
+		v, err := strconv.ParseInt(p.next(), 0, 32)
+		return EncodeInt32(int32(v)), err
+	case immI64:
+		v, err := strconv.ParseInt(p.next(), 0, 64)
+		return EncodeInt64(v), err
+	case immF32:
+		v, err := parseFloat(p.next(), 32)
+		return LE.AppendUint32([]byte{}, math.Float32bits(float32(v))), err
+	case immF64:
+		v, err := parseFloat(p.next(), 64)
+		return LE.AppendUint64([]byte{}, math.Float64bits(v)), err
+	}
+	return []byte{}, nil
+}
+
+func encodeSection(id SectionID, content []byte) []byte {
+	ans := append([]byte{id}, EncodeUint32(uint32(len(content)))...)
+	return append(ans, content...)
+}
+
+func encodeVector(count int, content []byte) []byte {
+	return append(EncodeUint32(uint32(count)), content...)
+}
+
+func encodeName(name string) []byte {
+	return append(EncodeUint32(uint32(len(name))), name...)
+}
+
+func encodeNameMap(names map[Index]string, count int) []byte {
+	content := []byte{}
+	entries := 0
+	for i := 0; i < count; i++ { // name maps must be sorted by index
+		if name, exists := names[Index(i)]; exists {
+			content = append(content, EncodeUint32(uint32(i))...)
+			content = append(content, encodeName(name)...)
+			entries++
+		}
+	}
+	return encodeVector(entries, content)
+}
+
+func encodeLocals(locals []ValueType) []byte {
+	content := []byte{}
+	groups := 0
+	for i := 0; i < len(locals); {
+		j := i
+		for j < len(locals) && locals[j] == locals[i] {
+			j++
+		}
+		content = append(content, EncodeUint32(uint32(j-i))...)
+		content = append(content, locals[i])
+		groups++
+		i = j
+	}
+	return encodeVector(groups, content)
+}
+
+func encodeModule(functions []*asmFunction) []byte {
+	ans := append(append([]byte{}, Magic...), version...)
+
+	typeIndexes := map[string]uint32{}
+	types, typeCount := []byte{}, 0
+	funcs := []byte{}
+	codes := []byte{}
+	for _, f := range functions {
+		funcType := append([]byte{Op_func}, encodeVector(len(f.params), f.params)...)
+		funcType = append(funcType, encodeVector(len(f.results), f.results)...)
+		index, exists := typeIndexes[string(funcType)]
+		if !exists {
+			index = uint32(typeCount)
+			typeIndexes[string(funcType)] = index
+			types = append(types, funcType...)
+			typeCount++
+		}
+		funcs = append(funcs, EncodeUint32(index)...)
+
+		code := append(encodeLocals(f.locals), f.body...)
+		codes = append(codes, EncodeUint32(uint32(len(code)))...)
+		codes = append(codes, code...)
+	}
+	ans = append(ans, encodeSection(sectionIDType, encodeVector(typeCount, types))...)
+	ans = append(ans, encodeSection(sectionIDFunction, encodeVector(len(functions), funcs))...)
+	ans = append(ans, encodeSection(sectionIDCode, encodeVector(len(functions), codes))...)
+
+	functionNames := map[Index]string{}
+	localNames, localNameCount := []byte{}, 0
+	for i, f := range functions {
+		if f.name != "" {
+			functionNames[Index(i)] = f.name
+		}
+		if len(f.localNames) != 0 {
+			localNames = append(localNames, EncodeUint32(uint32(i))...)
+			localNames = append(localNames, encodeNameMap(f.localNames, len(f.params)+len(f.locals))...)
+			localNameCount++
+		}
+	}
+	if len(functionNames) == 0 && localNameCount == 0 {
+		return ans
+	}
+	names := encodeName(nameSectionName)
+	if len(functionNames) != 0 {
+		names = append(names, encodeSection(nameSubsectionFunction, encodeNameMap(functionNames, len(functions)))...)
+	}
+	if localNameCount != 0 {
+		names = append(names, encodeSection(nameSubsectionLocal, encodeVector(localNameCount, localNames))...)
+	}
+	return append(ans, encodeSection(sectionIDCustom, names)...)
+}
+
+// Assemble reads the text form written by Disassemble and encodes it as a module. Only functions are supported,
+// which is all that is needed to write VM tests without a compiler. Function and local names are kept in a name section.
+func Assemble(text string) ([]byte, error) {
+	p := &asmParser{tokens: tokenize(text)}
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	if err := p.expect("module"); err != nil {
+		return nil, err
+	}
+
+	functions := []*asmFunction{}
+	for p.peekGroup() == "func" {
+		p.pos += 2
+		f, err := p.parseFunction()
+		if err != nil {
+			return nil, fmt.Errorf("function %d: %w", len(functions), err)
+		}
+		functions = append(functions, f)
+	}
+	if err := p.expect(")"); err != nil {
+		return nil, err
+	}
+	if p.peek() != "" {
+		return nil, fmt.Errorf("unexpected %q after the module", p.peek())
+	}
+	return encodeModule(functions), nil
+}
@@ -0,0 +1,359 @@
+package cmd
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/adamnite/go-adamnite/VM"
+	"github.com/spf13/cobra"
+)
+
+var (
+	breakpointSpecs []string
+	watchSpecs      []string
+)
+
+var dbgCmd = &cobra.Command{
+	Use:   "dbg",
+	Short: "step through the execution of a function of a compiled contract",
+	Long: `Loads a compiled contract and stops before the first operation of the function called.
+Breakpoints are written as function+offset, where function is either the name from the
+name section or the hash of the function, and offset is the index of the operation in its body.
+
+Commands:
+  s, step [n]             run the next n operations (1 by default)
+  c, continue             run until the next breakpoint, or the end of the call
+  b, break <func[+off]>   add a breakpoint
+  d, delete [func[+off]]  remove a breakpoint, or all of them
+  w, watch <start:len>    print that memory range at every stop
+  bt                      print the frames being ran
+  stack, locals, gas      print that part of the state
+  mem <start:len>         print a memory range
+  q, quit                 stop debugging`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if hexBytes == "" && filePath == "" {
+			fmt.Println("Please, specify either hexadecimal bytes (--from-hex) or binary file path (--from-file)")
+			return
+		}
+
+		if hexBytes != "" && filePath != "" {
+			fmt.Println("Can't have both! Please, specify either hexadecimal bytes (--from-hex) or binary file path (--from-file)")
+			return
+		}
+
+		var rawBytes []byte
+		var err error
+
+		if hexBytes != "" {
+			rawBytes, err = hex.DecodeString(hexBytes)
+			if err != nil {
+				log.Fatal(err)
+			}
+		} else if filePath != "" {
+			rawBytes, err = os.ReadFile(filePath)
+			if err != nil {
+				log.Fatal(err)
+			}
+		}
+
+		dbg, err := newDebugger(rawBytes, os.Stdout)
+		if err != nil {
+			log.Fatal(err)
+		}
+		dbg.repl(os.Stdin)
+	},
+}
+
+func init() {
+	dbgCmd.Flags().StringVar(&hexBytes, "from-hex", "", "bytes in hexadecimal representation to debug")
+	dbgCmd.Flags().StringVar(&filePath, "from-file", "", "path to binary file to debug")
+
+	dbgCmd.Flags().Uint64VarP(&gas, "gas", "g", 0, "amount of gas to allocate for the execution")
+	dbgCmd.Flags().StringVarP(&functionHash, "function", "f", "", "hash or name of the function to be debugged")
+	dbgCmd.Flags().StringVarP(&functionArgs, "args", "a", "", "comma separated function arguments")
+	dbgCmd.Flags().StringSliceVarP(&breakpointSpecs, "break", "b", []string{}, "breakpoints to start with, as function+offset")
+	dbgCmd.Flags().StringSliceVarP(&watchSpecs, "watch", "w", []string{}, "memory ranges to print at every stop, as start:length")
+
+	dbgCmd.MarkFlagRequired("gas")
+	dbgCmd.MarkFlagRequired("function")
+
+	rootCmd.AddCommand(dbgCmd)
+}
+
+type memoryRange struct {
+	start  uint64
+	length uint64
+}
+
+type debugger struct {
+	vm          *VM.Machine
+	spoofer     VM.DBSpoofer
+	hashes      [][]byte
+	out         io.Writer
+	breakpoints map[VM.TraceEntry]bool
+	watches     []memoryRange
+	done        bool
+	err         error
+}
+
+// newDebugger loads the module and prepares the call set by the flags, without running anything.
+func newDebugger(moduleBytes []byte, out io.Writer) (*debugger, error) {
+	dbg := &debugger{
+		spoofer:     VM.NewDBSpoofer(),
+		out:         out,
+		breakpoints: map[VM.TraceEntry]bool{},
+	}
+	var err error
+	if dbg.hashes, err = dbg.spoofer.AddModuleToSpoofedCode(VM.DecodeModule(moduleBytes)); err != nil {
+		return nil, err
+	}
+
+	config := VM.GetDefaultConfig()
+	config.CodeGetter = dbg.spoofer.GetCode
+	config.NameGetter = dbg.spoofer.GetFunctionName
+	dbg.vm = VM.NewVirtualMachine([]byte{}, []uint64{}, &config, gas)
+
+	hash, err := dbg.resolveFunction(functionHash)
+	if err != nil {
+		return nil, err
+	}
+	functionType, _, _ := config.CodeGetter(hash)
+	callHash := hex.EncodeToString(hash)
+	if functionArgs != "" {
+		callHash += encodeFunctionArguments(functionArgs, functionType)
+	}
+	if err := dbg.vm.PrepareCall(callHash, gas); err != nil {
+		return nil, err
+	}
+
+	for _, spec := range breakpointSpecs {
+		if err := dbg.addBreakpoint(spec); err != nil {
+			return nil, err
+		}
+	}
+	for _, spec := range watchSpecs {
+		r, err := parseMemoryRange(spec)
+		if err != nil {
+			return nil, err
+		}
+		dbg.watches = append(dbg.watches, r)
+	}
+	return dbg, nil
+}
+
+// resolveFunction finds the hash of a function from either its name or its hash.
+func (dbg *debugger) resolveFunction(function string) ([]byte, error) {
+	for _, h := range dbg.hashes {
+		if hex.EncodeToString(h) == function || dbg.spoofer.GetFunctionName(h) == function {
+			return h, nil
+		}
+	}
+	return nil, fmt.Errorf("no function %v in the module", function)
+}
+
+// parseBreakpoint reads function+offset into the same form the VM reports frames with.
+func (dbg *debugger) parseBreakpoint(spec string) (VM.TraceEntry, error) {
+	function, offsetString, hasOffset := strings.Cut(spec, "+")
+	hash, err := dbg.resolveFunction(function)
+	if err != nil {
+		return VM.TraceEntry{}, err
+	}
+	entry := VM.TraceEntry{Function: dbg.spoofer.GetFunctionName(hash)}
+	if entry.Function == "" {
+		entry.Function = hex.EncodeToString(hash)
+	}
+	if hasOffset {
+		if entry.Offset, err = strconv.ParseUint(offsetString, 0, 64); err != nil {
+			return VM.TraceEntry{}, fmt.Errorf("invalid breakpoint offset %v: %w", offsetString, err)
+		}
+	}
+	return entry, nil
+}
+
+func (dbg *debugger) addBreakpoint(spec string) error {
+	entry, err := dbg.parseBreakpoint(spec)
+	if err != nil {
+		return err
+	}
+	dbg.breakpoints[entry] = true
+	return nil
+}
+
+func parseMemoryRange(spec string) (memoryRange, error) {
+	startString, lengthString, found := strings.Cut(spec, ":")
+	if !found {
+		return memoryRange{}, fmt.Errorf("memory range %v should be written as start:length", spec)
+	}
+	start, err := strconv.ParseUint(startString, 0, 64)
+	if err != nil {
+		return memoryRange{}, err
+	}
+	length, err := strconv.ParseUint(lengthString, 0, 64)
+	if err != nil {
+		return memoryRange{}, err
+	}
+	return memoryRange{start: start, length: length}, nil
+}
+
+// step runs a single operation, and returns true if the call has finished.
+func (dbg *debugger) step() bool {
+	if dbg.done {
+		return true
+	}
+	dbg.done, dbg.err = dbg.vm.Step()
+	return dbg.done
+}
+
+func (dbg *debugger) atBreakpoint() bool {
+	trace := dbg.vm.Backtrace()
+	return len(trace) != 0 && dbg.breakpoints[trace[0]]
+}
+
+// continueRun steps until a breakpoint is reached, always running at least one operation.
+func (dbg *debugger) continueRun() {
+	for !dbg.step() && !dbg.atBreakpoint() {
+	}
+}
+
+func (dbg *debugger) printPosition() {
+	if dbg.done {
+		if dbg.err != nil {
+			fmt.Fprintf(dbg.out, "execution stopped: %v\n", dbg.err)
+		} else {
+			fmt.Fprintln(dbg.out, "execution finished")
+		}
+		dbg.printStack()
+		dbg.printGas()
+		return
+	}
+	trace := dbg.vm.Backtrace()
+	if len(trace) != 0 {
+		fmt.Fprintf(dbg.out, "%v\t%v\n", trace[0], describeOperation(dbg.vm.NextOperation()))
+	}
+	dbg.printStack()
+	dbg.printLocals()
+	dbg.printGas()
+	for _, r := range dbg.watches {
+		dbg.printMemory(r)
+	}
+}
+
+func (dbg *debugger) printStack() {
+	fmt.Fprintf(dbg.out, "stack:  %v\n", dbg.vm.Stack())
+}
+
+func (dbg *debugger) printLocals() {
+	fmt.Fprintf(dbg.out, "locals: %v\n", dbg.vm.Locals())
+}
+
+func (dbg *debugger) printGas() {
+	fmt.Fprintf(dbg.out, "gas:    %v\n", dbg.vm.GasRemaining())
+}
+
+func (dbg *debugger) printMemory(r memoryRange) {
+	fmt.Fprintf(dbg.out, "mem[%#x:%#x]: % x\n", r.start, r.start+r.length, dbg.vm.Memory(r.start, r.length))
+}
+
+func (dbg *debugger) printBacktrace() {
+	for _, entry := range dbg.vm.Backtrace() {
+		fmt.Fprintf(dbg.out, "\tat %v\n", entry)
+	}
+}
+
+// describeOperation turns an operation like VM.localGet{point:0 gas:1} into "localGet {point:0 gas:1}"
+func describeOperation(op VM.OperationCommon) string {
+	if op == nil {
+		return "<end>"
+	}
+	return strings.TrimPrefix(fmt.Sprintf("%T %+v", op, op), "VM.")
+}
+
+// runCommand handles a single line typed by the user, and returns false once the user wants to quit.
+func (dbg *debugger) runCommand(line string) bool {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		fields = []string{"step"}
+	}
+	switch fields[0] {
+	case "s", "step":
+		count := uint64(1)
+		if len(fields) > 1 {
+			var err error
+			if count, err = strconv.ParseUint(fields[1], 0, 64); err != nil {
+				fmt.Fprintln(dbg.out, err)
+				return true
+			}
+		}
+		for i := uint64(0); i < count && !dbg.step(); i++ {
+		}
+		dbg.printPosition()
+	case "c", "continue":
+		dbg.continueRun()
+		dbg.printPosition()
+	case "b", "break":
+		if len(fields) < 2 {
+			fmt.Fprintln(dbg.out, "usage: break <function[+offset]>")
+			return true
+		}
+		if err := dbg.addBreakpoint(fields[1]); err != nil {
+			fmt.Fprintln(dbg.out, err)
+		}
+	case "d", "delete":
+		if len(fields) < 2 {
+			dbg.breakpoints = map[VM.TraceEntry]bool{}
+			return true
+		}
+		entry, err := dbg.parseBreakpoint(fields[1])
+		if err != nil {
+			fmt.Fprintln(dbg.out, err)
+			return true
+		}
+		delete(dbg.breakpoints, entry)
+	case "w", "watch", "mem":
+		if len(fields) < 2 {
+			fmt.Fprintf(dbg.out, "usage: %v <start:length>\n", fields[0])
+			return true
+		}
+		r, err := parseMemoryRange(fields[1])
+		if err != nil {
+			fmt.Fprintln(dbg.out, err)
+			return true
+		}
+		if fields[0] != "mem" {
+			dbg.watches = append(dbg.watches, r)
+		}
+		dbg.printMemory(r)
+	case "bt":
+		dbg.printBacktrace()
+	case "stack":
+		dbg.printStack()
+	case "locals":
+		dbg.printLocals()
+	case "gas":
+		dbg.printGas()
+	case "q", "quit":
+		return false
+	default:
+		fmt.Fprintf(dbg.out, "unknown command %v\n", fields[0])
+	}
+	return true
+}
+
+// repl reads commands until the input ends or the user quits.
+func (dbg *debugger) repl(in io.Reader) {
+	dbg.printPosition()
+	scanner := bufio.NewScanner(in)
+	fmt.Fprint(dbg.out, "(dbg) ")
+	for scanner.Scan() {
+		if !dbg.runCommand(scanner.Text()) {
+			return
+		}
+		fmt.Fprint(dbg.out, "(dbg) ")
+	}
+}
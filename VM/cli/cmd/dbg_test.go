@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/adamnite/go-adamnite/VM"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDebuggerSteps(t *testing.T) {
+	rawBytes, err := hex.DecodeString("0061736d0100000001070160027e7e017e03020100070a010661646454776f00000a09010700200020017c0b000a046e616d650203010000")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	functionHash = "9703bdb17a160ed80486a83aa3c413c1"
+	functionArgs = "1,2"
+	gas = 10000
+	breakpointSpecs = []string{functionHash + "+2"}
+	watchSpecs = []string{}
+
+	out := &bytes.Buffer{}
+	dbg, err := newDebugger(rawBytes, out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []VM.TraceEntry{{Function: functionHash, Offset: 0}}, dbg.vm.Backtrace())
+
+	dbg.runCommand("step")
+	assert.Equal(t, []uint64{1}, dbg.vm.Stack())
+	assert.Equal(t, []uint64{1, 2}, dbg.vm.Locals())
+	assert.Equal(t, uint64(10000-1), dbg.vm.GasRemaining())
+
+	// stops before running the add
+	dbg.runCommand("continue")
+	assert.Equal(t, []VM.TraceEntry{{Function: functionHash, Offset: 2}}, dbg.vm.Backtrace())
+	assert.Equal(t, []uint64{1, 2}, dbg.vm.Stack())
+
+	dbg.runCommand("delete")
+	dbg.runCommand("continue")
+	assert.True(t, dbg.done)
+	assert.NoError(t, dbg.err)
+	assert.Equal(t, []uint64{3}, dbg.vm.Stack())
+	assert.True(t, strings.Contains(out.String(), "execution finished"))
+}
@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+
+	"github.com/adamnite/go-adamnite/VM"
+	"github.com/adamnite/go-adamnite/database"
+	"github.com/adamnite/go-adamnite/database/offchain"
+	"github.com/spf13/cobra"
+)
+
+var (
+	dbListenAddress string
+	dbPath          string
+)
+
+var dbServerCmd = &cobra.Command{
+	Use:   "dbserver",
+	Short: "run the off-chain code DB that contracts and methods are uploaded to",
+	Run: func(cmd *cobra.Command, args []string) {
+		var db *database.Database
+		var err error
+		if dbPath == "" {
+			db, err = database.NewMemory()
+		} else {
+			db, err = database.New(dbPath)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer db.Close()
+
+		server := offchain.NewServer(VM.NewLevelDBCodeStore(db))
+		if err := server.Start(dbListenAddress); err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("Off-chain DB listening on %v\n", server.Endpoint())
+
+		interrupt := make(chan os.Signal, 1)
+		signal.Notify(interrupt, os.Interrupt)
+		<-interrupt
+		server.Close()
+	},
+}
+
+func init() {
+	dbServerCmd.Flags().StringVarP(&dbListenAddress, "listen", "l", "127.0.0.1:5000", "address to serve the DB on")
+	dbServerCmd.Flags().StringVar(&dbPath, "path", "", "directory of the LevelDB files (kept in memory if empty)")
+
+	rootCmd.AddCommand(dbServerCmd)
+}
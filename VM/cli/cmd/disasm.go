@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/adamnite/go-adamnite/VM"
+	"github.com/spf13/cobra"
+)
+
+var disasmCmd = &cobra.Command{
+	Use:   "disasm",
+	Short: "print the functions of a compiled contract in a readable text form",
+	Run: func(cmd *cobra.Command, args []string) {
+		if hexBytes == "" && filePath == "" {
+			fmt.Println("Please, specify either hexadecimal bytes (--from-hex) or binary file path (--from-file)")
+			return
+		}
+
+		if hexBytes != "" && filePath != "" {
+			fmt.Println("Can't have both! Please, specify either hexadecimal bytes (--from-hex) or binary file path (--from-file)")
+			return
+		}
+
+		var rawBytes []byte
+		var err error
+
+		if hexBytes != "" {
+			rawBytes, err = hex.DecodeString(hexBytes)
+			if err != nil {
+				log.Fatal(err)
+			}
+		} else if filePath != "" {
+			rawBytes, err = os.ReadFile(filePath)
+			if err != nil {
+				log.Fatal(err)
+			}
+		}
+
+		decodedModule := VM.DecodeModule(rawBytes)
+		if err := VM.Disassemble(os.Stdout, &decodedModule); err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+var outputPath string
+
+var asmCmd = &cobra.Command{
+	Use:   "asm",
+	Short: "assemble the text form printed by disasm back into a compiled contract",
+	Run: func(cmd *cobra.Command, args []string) {
+		if filePath == "" {
+			fmt.Println("Please, specify the text file to assemble (--from-file)")
+			return
+		}
+		text, err := os.ReadFile(filePath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		moduleBytes, err := VM.Assemble(string(text))
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		if outputPath == "" {
+			fmt.Println(hex.EncodeToString(moduleBytes))
+			return
+		}
+		if err := os.WriteFile(outputPath, moduleBytes, 0644); err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+func init() {
+	disasmCmd.Flags().StringVar(&hexBytes, "from-hex", "", "bytes in hexadecimal representation to disassemble")
+	disasmCmd.Flags().StringVar(&filePath, "from-file", "", "path to binary file to disassemble")
+
+	asmCmd.Flags().StringVar(&filePath, "from-file", "", "path to the text file to assemble")
+	asmCmd.Flags().StringVarP(&outputPath, "out", "o", "", "path to write the binary to (prints hexadecimal bytes otherwise)")
+
+	rootCmd.AddCommand(disasmCmd)
+	rootCmd.AddCommand(asmCmd)
+}
@@ -5,12 +5,14 @@ import (
 	"fmt"
 	"log"
 	"math"
+	"math/big"
 	"os"
 	"strconv"
 	"strings"
 
-	"github.com/adamnite/go-adamnite/databaseDeprecated/statedb"
 	"github.com/adamnite/go-adamnite/VM"
+	"github.com/adamnite/go-adamnite/common"
+	"github.com/adamnite/go-adamnite/databaseDeprecated/statedb"
 	"github.com/adamnite/go-adamnite/params"
 	"github.com/spf13/cobra"
 )
@@ -29,7 +31,7 @@ var executeCmd = &cobra.Command{
 			return
 		}
 
-		if !testNet {
+		if statePath == "" && !testNet {
 			return
 		}
 
@@ -48,10 +50,50 @@ var executeCmd = &cobra.Command{
 			}
 		}
 
-		executeStateless(rawBytes)
+		if statePath == "" {
+			executeStateless(rawBytes)
+			return
+		}
+
+		ctx, err := callContextFromFlags()
+		if err != nil {
+			log.Fatal(err)
+		}
+		pre, err := readStateFile(statePath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		post, output, err := executeStateful(rawBytes, pre, ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Print(output)
+		for _, line := range diffStates(pre, post) {
+			fmt.Println(line)
+		}
+
+		if outPath == "" {
+			outPath = statePath
+		}
+		if err := writeStateFile(outPath, post); err != nil {
+			log.Fatal(err)
+		}
 	},
 }
 
+// Flags of the stateful execution mode
+var (
+	statePath   string
+	outPath     string
+	callerHex   string
+	contractHex string
+	callValue   string
+	coinbaseHex string
+	blockNumber uint64
+	blockTime   uint64
+	blockGasCap uint64
+)
+
 func init() {
 	executeCmd.Flags().StringVar(&hexBytes, "from-hex", "", "bytes in hexadecimal representation to execute")
 	executeCmd.Flags().StringVar(&filePath, "from-file", "", "path to binary file to execute")
@@ -61,12 +103,54 @@ func init() {
 	executeCmd.Flags().StringVarP(&functionArgs, "args", "a", "", "comma separated function arguments")
 	executeCmd.Flags().BoolVar(&testNet, "test", true, "use the test network (otherwise, main network will be used)")
 
+	executeCmd.Flags().StringVar(&statePath, "state", "", "path to a JSON state file to execute against, written back with the post-state")
+	executeCmd.Flags().StringVar(&outPath, "out", "", "path to write the post-state to instead of the state file")
+	executeCmd.Flags().StringVar(&callerHex, "caller", "", "address of the caller")
+	executeCmd.Flags().StringVar(&contractHex, "contract", "", "address of the contract called")
+	executeCmd.Flags().StringVar(&callValue, "value", "0", "value sent along with the call")
+	executeCmd.Flags().StringVar(&coinbaseHex, "coinbase", "", "coinbase address of the block")
+	executeCmd.Flags().Uint64Var(&blockNumber, "block-number", 0, "number of the block the call is ran in")
+	executeCmd.Flags().Uint64Var(&blockTime, "block-time", 0, "timestamp of the block the call is ran in")
+	executeCmd.Flags().Uint64Var(&blockGasCap, "block-gas-limit", 30000, "gas limit of the block the call is ran in")
+
 	executeCmd.MarkFlagRequired("gas")
 	executeCmd.MarkFlagRequired("function")
 
 	rootCmd.AddCommand(executeCmd)
 }
 
+// callContextFromFlags reads the caller, value and block context of a stateful execution from the flags.
+func callContextFromFlags() (callContext, error) {
+	var ctx callContext
+	var err error
+	for _, a := range []struct {
+		hex  string
+		addr *common.Address
+	}{{callerHex, &ctx.caller}, {contractHex, &ctx.contract}, {coinbaseHex, &ctx.block.Coinbase}} {
+		if a.hex == "" {
+			continue
+		}
+		if *a.addr, err = parseAddress(a.hex); err != nil {
+			return ctx, err
+		}
+	}
+
+	value, ok := new(big.Int).SetString(callValue, 0)
+	if !ok {
+		return ctx, fmt.Errorf("invalid value %v", callValue)
+	}
+	ctx.value = value
+	ctx.block = VM.NewBlockContext(
+		ctx.block.Coinbase,
+		blockGasCap,
+		new(big.Int).SetUint64(blockNumber),
+		new(big.Int).SetUint64(blockTime),
+		big.NewInt(0),
+		big.NewInt(0),
+	)
+	return ctx, nil
+}
+
 func executeStateless(bytes []byte) string {
 	spoofer := VM.NewDBSpoofer()
 
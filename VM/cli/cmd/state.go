@@ -0,0 +1,212 @@
+package cmd
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/adamnite/go-adamnite/VM"
+	"github.com/adamnite/go-adamnite/common"
+	"github.com/adamnite/go-adamnite/databaseDeprecated/rawdb"
+	"github.com/adamnite/go-adamnite/databaseDeprecated/statedb"
+)
+
+// stateAccount is a single account of a local state file. Storage is only set for contracts.
+type stateAccount struct {
+	Balance *big.Int `json:"balance"`
+	Nonce   uint64   `json:"nonce"`
+	Storage []uint64 `json:"storage,omitempty"`
+}
+
+// stateFile is the local state an execution is ran against, with accounts keyed by their hex address.
+type stateFile struct {
+	Accounts map[string]*stateAccount `json:"accounts"`
+}
+
+// callContext holds everything about a stateful call that isn't part of the state itself.
+type callContext struct {
+	caller   common.Address
+	contract common.Address
+	value    *big.Int
+	block    VM.BlockContext
+}
+
+func readStateFile(path string) (*stateFile, error) {
+	state := &stateFile{Accounts: map[string]*stateAccount{}}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("unable to parse state file %v: %w", path, err)
+	}
+	normalized := map[string]*stateAccount{}
+	for addr, account := range state.Accounts {
+		if account.Balance == nil {
+			account.Balance = big.NewInt(0)
+		}
+		normalized[addressKey(common.HexToAddress(addr))] = account
+	}
+	state.Accounts = normalized
+	return state, nil
+}
+
+func writeStateFile(path string, state *stateFile) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+func addressKey(addr common.Address) string {
+	return "0x" + addr.Hex()
+}
+
+func parseAddress(s string) (common.Address, error) {
+	raw, err := hex.DecodeString(strings.TrimPrefix(s, "0x"))
+	if err != nil {
+		return common.Address{}, fmt.Errorf("invalid address %v: %w", s, err)
+	}
+	return common.BytesToAddress(raw), nil
+}
+
+// loadStateDB builds an in memory StateDB holding the balances and nonces of the state file.
+func loadStateDB(state *stateFile) (*statedb.StateDB, error) {
+	db, err := statedb.New(common.Hash{}, statedb.NewDatabase(rawdb.NewMemoryDB()))
+	if err != nil {
+		return nil, err
+	}
+	for key, account := range state.Accounts {
+		addr := common.HexToAddress(key)
+		db.CreateAccount(addr)
+		db.SetBalance(addr, account.Balance)
+		db.SetNonce(addr, account.Nonce)
+	}
+	return db, nil
+}
+
+// executeStateful runs the function set by the flags from the contract in ctx, against the given state.
+// It returns the state after the call along with the output stack. A failed call leaves the state untouched,
+// and is returned as an error alongside that state.
+func executeStateful(bytes []byte, pre *stateFile, ctx callContext) (*stateFile, string, error) {
+	spoofer := VM.NewDBSpoofer()
+	if _, err := spoofer.AddModuleToSpoofedCode(VM.DecodeModule(bytes)); err != nil {
+		return nil, "", err
+	}
+
+	db, err := loadStateDB(pre)
+	if err != nil {
+		return nil, "", err
+	}
+
+	storage := []uint64{}
+	if account, exists := pre.Accounts[addressKey(ctx.contract)]; exists {
+		storage = append(storage, account.Storage...)
+	}
+
+	config := VM.GetDefaultConfig()
+	config.CodeGetter = spoofer.GetCode
+	config.NameGetter = spoofer.GetFunctionName
+	vm := VM.NewVirtualMachine([]byte{}, storage, &config, gas)
+	vm.Statedb = db
+	vm.BlockCtx = ctx.block
+
+	functionHashBytes, err := hex.DecodeString(functionHash)
+	if err != nil {
+		return nil, "", err
+	}
+	input := functionHashBytes
+	if functionArgs != "" {
+		functionType, _, _ := config.CodeGetter(functionHashBytes)
+		args, err := hex.DecodeString(encodeFunctionArguments(functionArgs, functionType))
+		if err != nil {
+			return nil, "", err
+		}
+		input = append(input, args...)
+	}
+
+	if !ctx.block.CanTransfer(db, ctx.caller, ctx.value) {
+		return pre, "", VM.ErrInsufficientBalance
+	}
+	if _, _, err := vm.Call(ctx.caller, ctx.contract, input, gas, ctx.value); err != nil {
+		return pre, "", err
+	}
+
+	post := &stateFile{Accounts: map[string]*stateAccount{}}
+	touched := []common.Address{ctx.caller, ctx.contract, ctx.block.Coinbase}
+	for key := range pre.Accounts {
+		touched = append(touched, common.HexToAddress(key))
+	}
+	for _, addr := range touched {
+		if !db.Exist(addr) {
+			continue
+		}
+		account := &stateAccount{Balance: db.GetBalance(addr), Nonce: db.GetNonce(addr)}
+		if old, exists := pre.Accounts[addressKey(addr)]; exists {
+			account.Storage = old.Storage
+		}
+		post.Accounts[addressKey(addr)] = account
+	}
+	post.Accounts[addressKey(ctx.contract)].Storage = vm.Storage()
+
+	return post, vm.OutputStack(), nil
+}
+
+// diffStates lists every balance, nonce and storage slot that differs between the two states, one per line.
+func diffStates(pre *stateFile, post *stateFile) []string {
+	keys := map[string]bool{}
+	for key := range pre.Accounts {
+		keys[key] = true
+	}
+	for key := range post.Accounts {
+		keys[key] = true
+	}
+	sorted := make([]string, 0, len(keys))
+	for key := range keys {
+		sorted = append(sorted, key)
+	}
+	sort.Strings(sorted)
+
+	empty := &stateAccount{Balance: big.NewInt(0)}
+	diff := []string{}
+	for _, key := range sorted {
+		before, after := pre.Accounts[key], post.Accounts[key]
+		if before == nil {
+			before = empty
+		}
+		if after == nil {
+			after = empty
+		}
+		if before.Balance.Cmp(after.Balance) != 0 {
+			diff = append(diff, fmt.Sprintf("%v balance: %v -> %v", key, before.Balance, after.Balance))
+		}
+		if before.Nonce != after.Nonce {
+			diff = append(diff, fmt.Sprintf("%v nonce: %v -> %v", key, before.Nonce, after.Nonce))
+		}
+		slots := len(before.Storage)
+		if len(after.Storage) > slots {
+			slots = len(after.Storage)
+		}
+		for i := 0; i < slots; i++ {
+			var a, b uint64
+			if i < len(before.Storage) {
+				a = before.Storage[i]
+			}
+			if i < len(after.Storage) {
+				b = after.Storage[i]
+			}
+			if a != b {
+				diff = append(diff, fmt.Sprintf("%v storage[%d]: %v -> %v", key, i, a, b))
+			}
+		}
+	}
+	return diff
+}
@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"encoding/hex"
+	"math/big"
+	"path/filepath"
+	"testing"
+
+	"github.com/adamnite/go-adamnite/VM"
+	"github.com/adamnite/go-adamnite/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExecuteStateful(t *testing.T) {
+	rawBytes, err := VM.Assemble(`(module
+		(func $deposit (param $x i64)
+			global.get 0
+			local.get 0
+			i64.add
+			global.set 0))`)
+	assert.NoError(t, err)
+	spoofer := VM.NewDBSpoofer()
+	hashes, err := spoofer.AddModuleToSpoofedCode(VM.DecodeModule(rawBytes))
+	assert.NoError(t, err)
+
+	caller := common.BytesToAddress([]byte{0x01})
+	contract := common.BytesToAddress([]byte{0x02})
+	pre := &stateFile{Accounts: map[string]*stateAccount{
+		addressKey(caller):   {Balance: big.NewInt(100), Nonce: 4},
+		addressKey(contract): {Balance: big.NewInt(0), Storage: []uint64{5}},
+	}}
+	path := filepath.Join(t.TempDir(), "state.json")
+	assert.NoError(t, writeStateFile(path, pre))
+	pre, err = readStateFile(path)
+	assert.NoError(t, err)
+
+	functionHash = hex.EncodeToString(hashes[0])
+	functionArgs = "3"
+	gas = 10000
+	ctx := callContext{
+		caller:   caller,
+		contract: contract,
+		value:    big.NewInt(10),
+		block:    VM.NewBlockContext(common.Address{}, 30000, big.NewInt(1), big.NewInt(1), big.NewInt(0), big.NewInt(0)),
+	}
+
+	post, _, err := executeStateful(rawBytes, pre, ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		addressKey(caller) + " balance: 100 -> 90",
+		addressKey(contract) + " balance: 0 -> 10",
+		addressKey(contract) + " storage[0]: 5 -> 8",
+	}, diffStates(pre, post))
+	assert.Equal(t, uint64(4), post.Accounts[addressKey(caller)].Nonce)
+
+	// a caller that can't afford the value leaves the state untouched
+	ctx.value = big.NewInt(1000)
+	post, _, err = executeStateful(rawBytes, pre, ctx)
+	assert.ErrorIs(t, err, VM.ErrInsufficientBalance)
+	assert.Empty(t, diffStates(pre, post))
+}
@@ -77,8 +77,9 @@ func upload(bytes []byte) bool {
 	stateDB.CreateAccount(callerAddress)
 	stateDB.AddBalance(callerAddress, big.NewInt(1000000))
 
+	store := VM.NewHTTPCodeStore(dbHost)
 	config := VM.GetDefaultConfig()
-	config.Uri = dbHost
+	config.CodeStore = store
 
 	vm := VM.NewVM(stateDB, &config, nil)
 	_, _, err = vm.Create(callerAddress, bytes, gas, big.NewInt(1))
@@ -89,7 +90,7 @@ func upload(bytes []byte) bool {
 	// contract := vm.NewContract(common.Address{}, value, bytes, gas)
 	// err := vm.UploadContract(dbHost, *contract)
 
-	err = vm.UploadContract(dbHost)
+	err = vm.UploadContract(store)
 	if err != nil {
 		log.Fatal(err)
 	}
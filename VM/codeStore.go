@@ -0,0 +1,143 @@
+package VM
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/adamnite/go-adamnite/common"
+	"github.com/adamnite/go-adamnite/database"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// CodeStore is where the code of methods and the data of contracts are kept off-chain.
+// Methods are addressed by the hash of their CodeStored, contracts by their address.
+type CodeStore interface {
+	GetMethod(hash []byte) (*CodeStored, error)
+	PutMethod(code CodeStored) ([]byte, error)
+	GetContract(address common.Address) (*Contract, error)
+	PutContract(con Contract) error
+}
+
+// verifyCode checks that the code fetched is the code that was asked for, so a store can't serve anything else.
+func verifyCode(hash []byte, code *CodeStored) error {
+	actualHash, err := code.Hash()
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(hash, actualHash) {
+		return fmt.Errorf("%w: requested %x, received %x", ErrCodeHashMismatch, hash, actualHash)
+	}
+	return nil
+}
+
+// parse turns stored code into what the VM needs to run it.
+func (code CodeStored) parse(hash []byte) (FunctionType, []OperationCommon, []ControlBlock) {
+	ops, blocks := parseBytes(code.CodeBytes)
+	funcType := FunctionType{
+		params:  code.CodeParams,
+		results: code.CodeResults,
+		string:  hex.EncodeToString(hash), //so you can lie better.
+	}
+	return funcType, ops, blocks
+}
+
+// MSGPackBytesToContract reads a contract packed by ContractToMSGPackBytes.
+func MSGPackBytesToContract(packedData []byte) (*Contract, error) {
+	var cdata ContractData
+	if err := msgpack.Unmarshal(packedData, &cdata); err != nil {
+		return nil, err
+	}
+	return contractDataToContract(cdata), nil
+}
+
+// UploadModuleFunctions puts every function of the module in the store, and returns them with their hashes.
+func UploadModuleFunctions(store CodeStore, mod Module) ([]CodeStored, [][]byte, error) {
+	functionsToUpload := []CodeStored{}
+	hashes := [][]byte{}
+	for x, typeIndex := range mod.functionSection {
+		code := CodeStored{
+			CodeParams:  mod.typeSection[typeIndex].params,
+			CodeResults: mod.typeSection[typeIndex].results,
+			CodeBytes:   mod.codeSection[x].body,
+		}
+		functionsToUpload = append(functionsToUpload, code)
+		hash, err := store.PutMethod(code)
+		if err != nil {
+			return nil, nil, err
+		}
+		hashes = append(hashes, hash)
+	}
+	return functionsToUpload, hashes, nil
+}
+
+const (
+	levelDBCodePrefix     = "code-"
+	levelDBContractPrefix = "contract-"
+)
+
+// LevelDBCodeStore keeps methods and contracts in a local LevelDB database.
+type LevelDBCodeStore struct {
+	db *database.Database
+}
+
+func NewLevelDBCodeStore(db *database.Database) *LevelDBCodeStore {
+	return &LevelDBCodeStore{db: db}
+}
+
+// get returns the value at key, or nil if nothing is stored there.
+func (s *LevelDBCodeStore) get(key []byte) ([]byte, error) {
+	if has, err := s.db.Has(key); err != nil || !has {
+		return nil, err
+	}
+	return s.db.Get(key)
+}
+
+func (s *LevelDBCodeStore) GetMethod(hash []byte) (*CodeStored, error) {
+	packedData, err := s.get([]byte(levelDBCodePrefix + hex.EncodeToString(hash)))
+	if err != nil {
+		return nil, err
+	}
+	if packedData == nil {
+		return nil, ErrCodeNotStored
+	}
+	var code CodeStored
+	if err := msgpack.Unmarshal(packedData, &code); err != nil {
+		return nil, err
+	}
+	if err := verifyCode(hash, &code); err != nil {
+		return nil, err
+	}
+	return &code, nil
+}
+
+func (s *LevelDBCodeStore) PutMethod(code CodeStored) ([]byte, error) {
+	hash, err := code.Hash()
+	if err != nil {
+		return nil, err
+	}
+	packedData, err := msgpack.Marshal(&code)
+	if err != nil {
+		return nil, err
+	}
+	return hash, s.db.Insert([]byte(levelDBCodePrefix+hex.EncodeToString(hash)), packedData)
+}
+
+func (s *LevelDBCodeStore) GetContract(address common.Address) (*Contract, error) {
+	packedData, err := s.get([]byte(levelDBContractPrefix + address.Hex()))
+	if err != nil {
+		return nil, err
+	}
+	if packedData == nil {
+		return nil, ERR_CONTRACT_NOT_STORED
+	}
+	return MSGPackBytesToContract(packedData)
+}
+
+func (s *LevelDBCodeStore) PutContract(con Contract) error {
+	packedData, err := ContractToMSGPackBytes(con)
+	if err != nil {
+		return err
+	}
+	return s.db.Insert([]byte(levelDBContractPrefix+con.Address.Hex()), packedData)
+}
@@ -0,0 +1,134 @@
+package VM
+
+import (
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/adamnite/go-adamnite/common"
+	"github.com/adamnite/go-adamnite/database"
+	"github.com/stretchr/testify/assert"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+func TestCodeStores(t *testing.T) {
+	db, err := database.NewMemory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	spoofer := NewDBSpoofer()
+	stores := map[string]CodeStore{
+		"memory":  &spoofer,
+		"leveldb": NewLevelDBCodeStore(db),
+	}
+
+	for name, store := range stores {
+		_, err := store.GetMethod(addTwoFunctionHash)
+		assert.ErrorIs(t, err, ErrCodeNotStored, name)
+		hash, err := store.PutMethod(addTwoCodeStored)
+		assert.NoError(t, err, name)
+		assert.Equal(t, addTwoFunctionHash, hash, name)
+		code, err := store.GetMethod(hash)
+		assert.NoError(t, err, name)
+		assert.Equal(t, addTwoCodeStored, *code, name)
+
+		con := Contract{Address: common.Address{4, 5, 6}, CodeHashes: []string{"00ff"}, Storage: []uint64{7}}
+		_, err = store.GetContract(con.Address)
+		assert.Equal(t, ERR_CONTRACT_NOT_STORED, err, name)
+		assert.NoError(t, store.PutContract(con), name)
+		got, err := store.GetContract(con.Address)
+		assert.NoError(t, err, name)
+		assert.Equal(t, con.CodeHashes, got.CodeHashes, name)
+		assert.Equal(t, con.Storage, got.Storage, name)
+	}
+}
+
+func TestHTTPCodeStoreRetries(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		packed, _ := msgpack.Marshal(&addTwoCodeStored)
+		w.Write(packed)
+	}))
+	defer server.Close()
+
+	store := NewHTTPCodeStore(server.URL)
+	store.RetryDelay = time.Millisecond
+	code, err := store.GetMethod(addTwoFunctionHash)
+	assert.NoError(t, err)
+	assert.Equal(t, addTwoCodeStored, *code)
+	assert.Equal(t, 3, requests)
+
+	requests = 0
+	store.Retries = 1
+	_, err = store.GetMethod(addTwoFunctionHash)
+	assert.Equal(t, NewErrWithNetwork(http.StatusServiceUnavailable), err)
+	assert.Equal(t, 2, requests)
+}
+
+func TestMissingCodeTraps(t *testing.T) {
+	moduleBytes, err := Assemble(`(module (func $main call 1))`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spoofer := NewDBSpoofer()
+	hashes, err := spoofer.AddModuleToSpoofedCode(moduleBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := GetDefaultConfig()
+	config.CodeStore = &spoofer
+	config.NameGetter = spoofer.GetFunctionName
+	vm := NewVirtualMachine([]byte{}, []uint64{}, &config, 1000)
+	vm.contract.CodeHashes = []string{hex.EncodeToString(hashes[0]), "00112233445566778899aabbccddeeff"}
+
+	err = vm.Call2(hashes[0], 1000)
+	assert.ErrorIs(t, err, ErrCodeNotStored)
+	trap, isTrap := err.(*TrapError)
+	assert.True(t, isTrap)
+	if isTrap {
+		assert.Equal(t, []TraceEntry{{"main", 0}}, trap.Trace)
+	}
+}
+
+func TestCodeHashMismatch(t *testing.T) {
+	otherCode := CodeStored{[]ValueType{Op_i64}, []ValueType{Op_i64}, []byte{0x00, 0x20, 0x00, 0x0b}}
+
+	spoofer := NewDBSpoofer()
+	spoofer.AddSpoofedCode(hex.EncodeToString(addTwoFunctionHash), otherCode)
+	_, err := spoofer.GetMethod(addTwoFunctionHash)
+	assert.ErrorIs(t, err, ErrCodeHashMismatch)
+	//nor through the code getter, which panics having no error to return
+	func() {
+		defer func() {
+			err, _ := recover().(error)
+			assert.ErrorIs(t, err, ErrCodeHashMismatch)
+		}()
+		spoofer.GetCode(addTwoFunctionHash)
+	}()
+
+	db, err := database.NewMemory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	packed, _ := msgpack.Marshal(&otherCode)
+	assert.NoError(t, db.Insert([]byte(levelDBCodePrefix+hex.EncodeToString(addTwoFunctionHash)), packed))
+	_, err = NewLevelDBCodeStore(db).GetMethod(addTwoFunctionHash)
+	assert.ErrorIs(t, err, ErrCodeHashMismatch)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(packed)
+	}))
+	defer server.Close()
+	_, err = NewHTTPCodeStore(server.URL).GetMethod(addTwoFunctionHash)
+	assert.ErrorIs(t, err, ErrCodeHashMismatch)
+}
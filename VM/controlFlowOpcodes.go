@@ -305,7 +305,10 @@ func (op Call) doOp(m *Machine) error {
 	hexEncodingOfHash, _ := hex.DecodeString((m.contract.CodeHashes[op.funcIndex]))
 
 	//TODO: have this save the code grabbed, if its used multiple times, we shouldn't need to fetch it multiple times.
-	lFuncType, lOps, lControlBlocks := m.config.CodeGetter(hexEncodingOfHash)
+	lFuncType, lOps, lControlBlocks, err := m.getCode(hexEncodingOfHash)
+	if err != nil {
+		return err
+	}
 
 	params := lFuncType.params
 	poppedParams := []uint64{}
@@ -320,6 +323,7 @@ func (op Call) doOp(m *Machine) error {
 	frame.CtrlStack = lControlBlocks
 	frame.Locals = poppedParams
 	frame.Ip = 0
+	frame.Function = m.functionName(hexEncodingOfHash)
 
 	m.pointInCode = 0
 	m.vmCode = frame.Code
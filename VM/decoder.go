@@ -102,6 +102,15 @@ type Code struct {
 	localTypes []ValueType
 }
 
+// NameSection holds the debug names emitted by compilers in the custom "name" section.
+// Function and local indexes are in the function index space (imports first).
+// See https://webassembly.github.io/spec/core/appendix/custom.html#name-section
+type NameSection struct {
+	ModuleName    string
+	FunctionNames map[Index]string
+	LocalNames    map[Index]map[Index]string
+}
+
 func sectionIDName(sectionID SectionID) string {
 	switch sectionID {
 	case sectionIDCustom:
@@ -149,6 +158,16 @@ const (
 	sectionIDData
 	sectionIDDataCount
 )
+
+// Subsections of the custom name section
+const (
+	nameSubsectionModule byte = iota
+	nameSubsectionFunction
+	nameSubsectionLocal
+)
+
+const nameSectionName = "name"
+
 const (
 	maxVarintLen32 = 5
 	maxVarintLen64 = 10
@@ -603,3 +622,74 @@ func decodeExport(r *bytes.Reader) (i *Export, err error) {
 	}
 	return
 }
+
+func decodeNameMap(r *bytes.Reader) (map[Index]string, error) {
+	vs, _, err := DecodeUint32(r)
+	if err != nil {
+		return nil, fmt.Errorf("get size of name map: %w", err)
+	}
+	ans := make(map[Index]string, vs)
+	for i := uint32(0); i < vs; i++ {
+		idx, _, err := DecodeUint32(r)
+		if err != nil {
+			return nil, fmt.Errorf("read index of name[%d]: %w", i, err)
+		}
+		name, _, err := decodeUTF8(r, "name[%d]", i)
+		if err != nil {
+			return nil, err
+		}
+		ans[idx] = name
+	}
+	return ans, nil
+}
+
+// decodeNameSection reads the content of the custom name section, after its name has been read.
+func decodeNameSection(r *bytes.Reader) (*NameSection, error) {
+	ns := &NameSection{
+		FunctionNames: map[Index]string{},
+		LocalNames:    map[Index]map[Index]string{},
+	}
+	for r.Len() > 0 {
+		subsectionID, err := r.ReadByte()
+		if err != nil {
+			return nil, fmt.Errorf("read subsection id: %w", err)
+		}
+		size, _, err := DecodeUint32(r)
+		if err != nil {
+			return nil, fmt.Errorf("get size of name subsection %d: %w", subsectionID, err)
+		}
+		content := make([]byte, size)
+		if _, err := io.ReadFull(r, content); err != nil {
+			return nil, fmt.Errorf("read name subsection %d: %w", subsectionID, err)
+		}
+		sr := bytes.NewReader(content)
+
+		switch subsectionID {
+		case nameSubsectionModule:
+			if ns.ModuleName, _, err = decodeUTF8(sr, "module name"); err != nil {
+				return nil, err
+			}
+		case nameSubsectionFunction:
+			if ns.FunctionNames, err = decodeNameMap(sr); err != nil {
+				return nil, fmt.Errorf("function names: %w", err)
+			}
+		case nameSubsectionLocal:
+			vs, _, err := DecodeUint32(sr)
+			if err != nil {
+				return nil, fmt.Errorf("get size of local names: %w", err)
+			}
+			for i := uint32(0); i < vs; i++ {
+				funcIdx, _, err := DecodeUint32(sr)
+				if err != nil {
+					return nil, fmt.Errorf("read function index of local names[%d]: %w", i, err)
+				}
+				if ns.LocalNames[funcIdx], err = decodeNameMap(sr); err != nil {
+					return nil, fmt.Errorf("local names of function %d: %w", funcIdx, err)
+				}
+			}
+		default:
+			// unknown subsections are allowed, and simply skipped
+		}
+	}
+	return ns, nil
+}
@@ -135,3 +135,29 @@ func Test_i32Store3Decode(t *testing.T) {
 
 	assert.Equal(t, expectedModuleCode, module.codeSection[0].body)
 }
+
+func TestDecodeNameSection(t *testing.T) {
+	// (module
+	// 	(func $add (param i32 i32) (result i32) ...)
+	// 	(func $sub (param i32 i32) (result i32) ...)
+	// 	(func $mul (param i32 i32) (result i32) ...))
+	wasmBytes, _ := hex.DecodeString("0061736d0100000001070160027f7f017f0304030000000a19030700200020016a0b0700200020016b0b0700200020016c0b0020046e616d650110030003616464010373756202036d756c020703000001000200")
+
+	module := decode(wasmBytes)
+	assert.NotNil(t, module.Names())
+	assert.Equal(t, uint32(1), module.sectionElementCount(sectionIDCustom))
+	assert.Equal(t, "add", module.FunctionName(0))
+	assert.Equal(t, "sub", module.FunctionName(1))
+	assert.Equal(t, "mul", module.FunctionName(2))
+	assert.Equal(t, "", module.FunctionName(3))
+}
+
+func TestDecodeLocalNames(t *testing.T) {
+	// (func (param $x i64) (param $y i64) (result i64) ...)
+	wasmBytes, _ := hex.DecodeString("0061736d0100000001070160027e7e017e03020100070a010661646454776f00000a09010700200020017c0b0010046e616d650209010002000178010179")
+
+	module := decode(wasmBytes)
+	assert.Equal(t, "x", module.LocalName(0, 0))
+	assert.Equal(t, "y", module.LocalName(0, 1))
+	assert.Equal(t, "", module.FunctionName(0))
+}
@@ -0,0 +1,478 @@
+package VM
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// the kind of immediate arguments that follow an opcode
+type immediateKind byte
+
+const (
+	immNone immediateKind = iota
+	immBlockType
+	immLabel
+	immIndex
+	immBrTable
+	immCallIndirect
+	immMemArg
+	immReserved
+	immI32
+	immI64
+	immF32
+	immF64
+)
+
+type opcodeInfo struct {
+	name      string
+	immediate immediateKind
+	align     uint32 // natural alignment of memory operations, as a power of 2
+}
+
+var opcodeInfos = map[byte]opcodeInfo{
+	Op_unreachable:   {"unreachable", immNone, 0},
+	Op_nop:           {"nop", immNone, 0},
+	Op_block:         {"block", immBlockType, 0},
+	Op_loop:          {"loop", immBlockType, 0},
+	Op_if:            {"if", immBlockType, 0},
+	Op_else:          {"else", immNone, 0},
+	Op_end:           {"end", immNone, 0},
+	Op_br:            {"br", immLabel, 0},
+	Op_br_if:         {"br_if", immLabel, 0},
+	Op_br_table:      {"br_table", immBrTable, 0},
+	Op_return:        {"return", immNone, 0},
+	Op_call:          {"call", immIndex, 0},
+	Op_call_indirect: {"call_indirect", immCallIndirect, 0},
+
+	Op_drop:   {"drop", immNone, 0},
+	Op_select: {"select", immNone, 0},
+
+	Op_get_local:  {"local.get", immIndex, 0},
+	Op_set_local:  {"local.set", immIndex, 0},
+	Op_tee_local:  {"local.tee", immIndex, 0},
+	Op_get_global: {"global.get", immIndex, 0},
+	Op_set_global: {"global.set", immIndex, 0},
+
+	Op_i32_load:       {"i32.load", immMemArg, 2},
+	Op_i64_load:       {"i64.load", immMemArg, 3},
+	Op_f32_load:       {"f32.load", immMemArg, 2},
+	Op_f64_load:       {"f64.load", immMemArg, 3},
+	Op_i32_load8_s:    {"i32.load8_s", immMemArg, 0},
+	Op_i32_load8_u:    {"i32.load8_u", immMemArg, 0},
+	Op_i32_load16_s:   {"i32.load16_s", immMemArg, 1},
+	Op_i32_load16_u:   {"i32.load16_u", immMemArg, 1},
+	Op_i64_load8_s:    {"i64.load8_s", immMemArg, 0},
+	Op_i64_load8_u:    {"i64.load8_u", immMemArg, 0},
+	Op_i64_load16_s:   {"i64.load16_s", immMemArg, 1},
+	Op_i64_load16_u:   {"i64.load16_u", immMemArg, 1},
+	Op_i64_load32_s:   {"i64.load32_s", immMemArg, 2},
+	Op_i64_load32_u:   {"i64.load32_u", immMemArg, 2},
+	Op_i32_store:      {"i32.store", immMemArg, 2},
+	Op_i64_store:      {"i64.store", immMemArg, 3},
+	Op_f32_store:      {"f32.store", immMemArg, 2},
+	Op_f64_store:      {"f64.store", immMemArg, 3},
+	Op_i32_store8:     {"i32.store8", immMemArg, 0},
+	Op_i32_store16:    {"i32.store16", immMemArg, 1},
+	Op_i64_store8:     {"i64.store8", immMemArg, 0},
+	Op_i64_store16:    {"i64.store16", immMemArg, 1},
+	Op_i64_store32:    {"i64.store32", immMemArg, 2},
+	Op_current_memory: {"memory.size", immReserved, 0},
+	Op_grow_memory:    {"memory.grow", immReserved, 0},
+
+	Op_i32_const: {"i32.const", immI32, 0},
+	Op_i64_const: {"i64.const", immI64, 0},
+	Op_f32_const: {"f32.const", immF32, 0},
+	Op_f64_const: {"f64.const", immF64, 0},
+
+	Op_i32_eqz:  {"i32.eqz", immNone, 0},
+	Op_i32_eq:   {"i32.eq", immNone, 0},
+	Op_i32_ne:   {"i32.ne", immNone, 0},
+	Op_i32_lt_s: {"i32.lt_s", immNone, 0},
+	Op_i32_lt_u: {"i32.lt_u", immNone, 0},
+	Op_i32_gt_s: {"i32.gt_s", immNone, 0},
+	Op_i32_gt_u: {"i32.gt_u", immNone, 0},
+	Op_i32_le_s: {"i32.le_s", immNone, 0},
+	Op_i32_le_u: {"i32.le_u", immNone, 0},
+	Op_i32_ge_s: {"i32.ge_s", immNone, 0},
+	Op_i32_ge_u: {"i32.ge_u", immNone, 0},
+	Op_i64_eqz:  {"i64.eqz", immNone, 0},
+	Op_i64_eq:   {"i64.eq", immNone, 0},
+	Op_i64_ne:   {"i64.ne", immNone, 0},
+	Op_i64_lt_s: {"i64.lt_s", immNone, 0},
+	Op_i64_lt_u: {"i64.lt_u", immNone, 0},
+	Op_i64_gt_s: {"i64.gt_s", immNone, 0},
+	Op_i64_gt_u: {"i64.gt_u", immNone, 0},
+	Op_i64_le_s: {"i64.le_s", immNone, 0},
+	Op_i64_le_u: {"i64.le_u", immNone, 0},
+	Op_i64_ge_s: {"i64.ge_s", immNone, 0},
+	Op_i64_ge_u: {"i64.ge_u", immNone, 0},
+	Op_f32_eq:   {"f32.eq", immNone, 0},
+	Op_f32_ne:   {"f32.ne", immNone, 0},
+	Op_f32_lt:   {"f32.lt", immNone, 0},
+	Op_f32_gt:   {"f32.gt", immNone, 0},
+	Op_f32_le:   {"f32.le", immNone, 0},
+	Op_f32_ge:   {"f32.ge", immNone, 0},
+	Op_f64_eq:   {"f64.eq", immNone, 0},
+	Op_f64_ne:   {"f64.ne", immNone, 0},
+	Op_f64_lt:   {"f64.lt", immNone, 0},
+	Op_f64_gt:   {"f64.gt", immNone, 0},
+	Op_f64_le:   {"f64.le", immNone, 0},
+	Op_f64_ge:   {"f64.ge", immNone, 0},
+
+	Op_i32_clz:      {"i32.clz", immNone, 0},
+	Op_i32_ctz:      {"i32.ctz", immNone, 0},
+	Op_i32_popcnt:   {"i32.popcnt", immNone, 0},
+	Op_i32_add:      {"i32.add", immNone, 0},
+	Op_i32_sub:      {"i32.sub", immNone, 0},
+	Op_i32_mul:      {"i32.mul", immNone, 0},
+	Op_i32_div_s:    {"i32.div_s", immNone, 0},
+	Op_i32_div_u:    {"i32.div_u", immNone, 0},
+	Op_i32_rem_s:    {"i32.rem_s", immNone, 0},
+	Op_i32_rem_u:    {"i32.rem_u", immNone, 0},
+	Op_i32_and:      {"i32.and", immNone, 0},
+	Op_i32_or:       {"i32.or", immNone, 0},
+	Op_i32_xor:      {"i32.xor", immNone, 0},
+	Op_i32_shl:      {"i32.shl", immNone, 0},
+	Op_i32_shr_s:    {"i32.shr_s", immNone, 0},
+	Op_i32_shr_u:    {"i32.shr_u", immNone, 0},
+	Op_i32_rotl:     {"i32.rotl", immNone, 0},
+	Op_i32_rotr:     {"i32.rotr", immNone, 0},
+	Op_i64_clz:      {"i64.clz", immNone, 0},
+	Op_i64_ctz:      {"i64.ctz", immNone, 0},
+	Op_i64_popcnt:   {"i64.popcnt", immNone, 0},
+	Op_i64_add:      {"i64.add", immNone, 0},
+	Op_i64_sub:      {"i64.sub", immNone, 0},
+	Op_i64_mul:      {"i64.mul", immNone, 0},
+	Op_i64_div_s:    {"i64.div_s", immNone, 0},
+	Op_i64_div_u:    {"i64.div_u", immNone, 0},
+	Op_i64_rem_s:    {"i64.rem_s", immNone, 0},
+	Op_i64_rem_u:    {"i64.rem_u", immNone, 0},
+	Op_i64_and:      {"i64.and", immNone, 0},
+	Op_i64_or:       {"i64.or", immNone, 0},
+	Op_i64_xor:      {"i64.xor", immNone, 0},
+	Op_i64_shl:      {"i64.shl", immNone, 0},
+	Op_i64_shr_s:    {"i64.shr_s", immNone, 0},
+	Op_i64_shr_u:    {"i64.shr_u", immNone, 0},
+	Op_i64_rotl:     {"i64.rotl", immNone, 0},
+	Op_i64_rotr:     {"i64.rotr", immNone, 0},
+	Op_f32_abs:      {"f32.abs", immNone, 0},
+	Op_f32_neg:      {"f32.neg", immNone, 0},
+	Op_f32_ceil:     {"f32.ceil", immNone, 0},
+	Op_f32_floor:    {"f32.floor", immNone, 0},
+	Op_f32_trunc:    {"f32.trunc", immNone, 0},
+	Op_f32_nearest:  {"f32.nearest", immNone, 0},
+	Op_f32_sqrt:     {"f32.sqrt", immNone, 0},
+	Op_f32_add:      {"f32.add", immNone, 0},
+	Op_f32_sub:      {"f32.sub", immNone, 0},
+	Op_f32_mul:      {"f32.mul", immNone, 0},
+	Op_f32_div:      {"f32.div", immNone, 0},
+	Op_f32_min:      {"f32.min", immNone, 0},
+	Op_f32_max:      {"f32.max", immNone, 0},
+	Op_f32_copysign: {"f32.copysign", immNone, 0},
+	Op_f64_abs:      {"f64.abs", immNone, 0},
+	Op_f64_neg:      {"f64.neg", immNone, 0},
+	Op_f64_ceil:     {"f64.ceil", immNone, 0},
+	Op_f64_floor:    {"f64.floor", immNone, 0},
+	Op_f64_trunc:    {"f64.trunc", immNone, 0},
+	Op_f64_nearest:  {"f64.nearest", immNone, 0},
+	Op_f64_sqrt:     {"f64.sqrt", immNone, 0},
+	Op_f64_add:      {"f64.add", immNone, 0},
+	Op_f64_sub:      {"f64.sub", immNone, 0},
+	Op_f64_mul:      {"f64.mul", immNone, 0},
+	Op_f64_div:      {"f64.div", immNone, 0},
+	Op_f64_min:      {"f64.min", immNone, 0},
+	Op_f64_max:      {"f64.max", immNone, 0},
+	Op_f64_copysign: {"f64.copysign", immNone, 0},
+
+	Op_i32_wrap_i64:      {"i32.wrap_i64", immNone, 0},
+	Op_i32_trunc_s_f32:   {"i32.trunc_f32_s", immNone, 0},
+	Op_i32_trunc_u_f32:   {"i32.trunc_f32_u", immNone, 0},
+	Op_i32_trunc_s_f64:   {"i32.trunc_f64_s", immNone, 0},
+	Op_i32_trunc_u_f64:   {"i32.trunc_f64_u", immNone, 0},
+	Op_i64_extend_s_i32:  {"i64.extend_i32_s", immNone, 0},
+	Op_i64_extend_u_i32:  {"i64.extend_i32_u", immNone, 0},
+	Op_i64_trunc_s_f32:   {"i64.trunc_f32_s", immNone, 0},
+	Op_i64_trunc_u_f32:   {"i64.trunc_f32_u", immNone, 0},
+	Op_i64_trunc_s_f64:   {"i64.trunc_f64_s", immNone, 0},
+	Op_i64_trunc_u_f64:   {"i64.trunc_f64_u", immNone, 0},
+	Op_f32_convert_s_i32: {"f32.convert_i32_s", immNone, 0},
+	Op_f32_convert_u_i32: {"f32.convert_i32_u", immNone, 0},
+	Op_f32_convert_s_i64: {"f32.convert_i64_s", immNone, 0},
+	Op_f32_convert_u_i64: {"f32.convert_i64_u", immNone, 0},
+	Op_f32_demote_f64:    {"f32.demote_f64", immNone, 0},
+	Op_f64_convert_s_i32: {"f64.convert_i32_s", immNone, 0},
+	Op_f64_convert_u_i32: {"f64.convert_i32_u", immNone, 0},
+	Op_f64_convert_s_i64: {"f64.convert_i64_s", immNone, 0},
+	Op_f64_convert_u_i64: {"f64.convert_i64_u", immNone, 0},
+	Op_f64_promote_f32:   {"f64.promote_f32", immNone, 0},
+
+	Op_address:   {"address", immNone, 0},
+	Op_balance:   {"balance", immNone, 0},
+	Op_caller:    {"caller", immNone, 0},
+	Op_timestamp: {"timestamp", immNone, 0},
+	Op_value:     {"value", immNone, 0},
+	Op_gas_price: {"gas_price", immNone, 0},
+	Op_code_size: {"code_size", immNone, 0},
+	Op_data_size: {"data_size", immNone, 0},
+	Op_get_code:  {"get_code", immNone, 0},
+	Op_copy_code: {"copy_code", immNone, 0},
+	Op_get_data:  {"get_data", immNone, 0},
+}
+
+// opcodesByName is the reverse of opcodeInfos, used by the assembler
+var opcodesByName = func() map[string]byte {
+	ans := make(map[string]byte, len(opcodeInfos))
+	for op, info := range opcodeInfos {
+		ans[info.name] = op
+	}
+	return ans
+}()
+
+func valueTypeName(t ValueType) string {
+	switch t {
+	case Op_i32:
+		return "i32"
+	case Op_i64:
+		return "i64"
+	case Op_f32:
+		return "f32"
+	case Op_f64:
+		return "f64"
+	case Op_anyfunc:
+		return "anyfunc"
+	case Op_func:
+		return "func"
+	}
+	return fmt.Sprintf("%#x", t)
+}
+
+// operationGas reads the gas an operation charges, as set by the parser. Operations without a gas field are free.
+func operationGas(op OperationCommon) uint64 {
+	v := reflect.ValueOf(op)
+	if v.Kind() != reflect.Struct {
+		return 0
+	}
+	if gas := v.FieldByName("gas"); gas.IsValid() && gas.Kind() == reflect.Uint64 {
+		return gas.Uint()
+	}
+	return 0
+}
+
+// Disassemble writes the functions of the module in a WAT like text form. Each instruction is followed by a
+// comment with its index in the parsed operations (the offset used in backtraces), its offset in bytes and its gas.
+// Instructions that the VM's parser skips are marked as unsupported. The output can be read back by Assemble.
+func Disassemble(w io.Writer, m *Module) error {
+	fmt.Fprintln(w, "(module")
+	if m.nameSection != nil && m.nameSection.ModuleName != "" {
+		fmt.Fprintf(w, "  ;; module %v\n", m.nameSection.ModuleName)
+	}
+	for i, code := range m.codeSection {
+		if i >= len(m.functionSection) || int(m.functionSection[i]) >= len(m.typeSection) {
+			return fmt.Errorf("function %d has no type", i)
+		}
+		funcType := m.typeSection[m.functionSection[i]]
+
+		header := "  (func"
+		if name := m.FunctionName(i); name != "" {
+			header += " $" + name
+		}
+		header += fmt.Sprintf(" (;%d;)", m.importedFunctionCount()+uint32(i))
+		for p, t := range funcType.params {
+			header += " (param" + localLabel(m, i, Index(p)) + " " + valueTypeName(t) + ")"
+		}
+		if len(funcType.results) != 0 {
+			header += " (result"
+			for _, t := range funcType.results {
+				header += " " + valueTypeName(t)
+			}
+			header += ")"
+		}
+		fmt.Fprintln(w, header)
+
+		for l, t := range code.localTypes {
+			local := Index(len(funcType.params) + l)
+			fmt.Fprintf(w, "    (local%v %v)\n", localLabel(m, i, local), valueTypeName(t))
+		}
+		if err := disassembleBody(w, m, i, code.body); err != nil {
+			return fmt.Errorf("function %d: %w", i, err)
+		}
+		fmt.Fprintln(w, "  )")
+	}
+	fmt.Fprintln(w, ")")
+	return nil
+}
+
+// DisassembleToString is Disassemble, returning the text instead.
+func DisassembleToString(m *Module) (string, error) {
+	var sb strings.Builder
+	err := Disassemble(&sb, m)
+	return sb.String(), err
+}
+
+func localLabel(m *Module, function int, local Index) string {
+	if name := m.LocalName(function, local); name != "" {
+		return " $" + name
+	}
+	return ""
+}
+
+func disassembleBody(w io.Writer, m *Module, function int, body []byte) error {
+	ops, _, offsets := parseBytesWithOffsets(body)
+	opAt := make(map[int]int, len(offsets)) // byte offset => op index
+	for i, offset := range offsets {
+		opAt[offset] = i
+	}
+
+	r := bytes.NewReader(body)
+	depth := 0
+	for r.Len() > 0 {
+		offset := len(body) - r.Len()
+		opcode, _ := r.ReadByte()
+		info, known := opcodeInfos[opcode]
+		if !known {
+			return fmt.Errorf("unknown opcode %#x at %#x", opcode, offset)
+		}
+
+		if opcode == Op_end && depth == 0 {
+			// the end of the function body itself is implied by the closing parenthesis
+			if r.Len() != 0 {
+				return fmt.Errorf("function body ends at %#x, before the end of its code", offset)
+			}
+			break
+		}
+		if opcode == Op_end {
+			depth--
+		}
+		indent := depth
+		if opcode == Op_else {
+			indent--
+		}
+
+		text := info.name
+		immediates, err := disassembleImmediates(r, m, function, info, depth)
+		if err != nil {
+			return fmt.Errorf("%v at %#x: %w", info.name, offset, err)
+		}
+		if immediates != "" {
+			text += " " + immediates
+		}
+		if info.immediate == immBlockType {
+			depth++
+			text += fmt.Sprintf(" ;; label = @%d", depth)
+		}
+
+		comment := "unsupported"
+		if opIndex, parsed := opAt[offset]; parsed {
+			comment = fmt.Sprintf("op %d gas %d", opIndex, operationGas(ops[opIndex]))
+		}
+		line := strings.Repeat("  ", indent+2) + text
+		if !strings.Contains(text, ";;") {
+			line += " ;;"
+		}
+		fmt.Fprintf(w, "%-48s @%#04x %v\n", line, offset, comment)
+	}
+	return nil
+}
+
+// disassembleImmediates reads the immediates that follow an opcode, depth is the amount of blocks it is nested in.
+func disassembleImmediates(r *bytes.Reader, m *Module, function int, info opcodeInfo, depth int) (string, error) {
+	switch info.immediate {
+	case immBlockType:
+		blockType, err := r.ReadByte()
+		if err != nil {
+			return "", err
+		}
+		if blockType == Op_empty {
+			return "", nil
+		}
+		return "(result " + valueTypeName(blockType) + ")", nil
+	case immLabel:
+		label, _, err := DecodeUint32(r)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%d (;@%d;)", label, depth-int(label)), nil
+	case immIndex:
+		index, _, err := DecodeUint32(r)
+		if err != nil {
+			return "", err
+		}
+		if info.name == "local.get" || info.name == "local.set" || info.name == "local.tee" {
+			if name := m.LocalName(function, index); name != "" {
+				return fmt.Sprintf("%d (;$%v;)", index, name), nil
+			}
+		}
+		if info.name == "call" && m.nameSection != nil {
+			if name := m.nameSection.FunctionNames[index]; name != "" {
+				return fmt.Sprintf("%d (;$%v;)", index, name), nil
+			}
+		}
+		return fmt.Sprint(index), nil
+	case immBrTable:
+		count, _, err := DecodeUint32(r)
+		if err != nil {
+			return "", err
+		}
+		labels := []string{}
+		for i := uint32(0); i <= count; i++ { // the default label comes after the count
+			label, _, err := DecodeUint32(r)
+			if err != nil {
+				return "", err
+			}
+			labels = append(labels, fmt.Sprintf("%d (;@%d;)", label, depth-int(label)))
+		}
+		return strings.Join(labels, " "), nil
+	case immCallIndirect:
+		typeIndex, _, err := DecodeUint32(r)
+		if err != nil {
+			return "", err
+		}
+		if _, err := r.ReadByte(); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("(type %d)", typeIndex), nil
+	case immMemArg:
+		align, _, err := DecodeUint32(r)
+		if err != nil {
+			return "", err
+		}
+		offset, _, err := DecodeUint32(r)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("offset=%d align=%d", offset, uint64(1)<<align), nil
+	case immReserved:
+		_, err := r.ReadByte()
+		return "", err
+	case immI32:
+		v, _, err := DecodeInt32(r)
+		return fmt.Sprint(v), err
+	case immI64:
+		v, _, err := DecodeInt64(r)
+		return fmt.Sprint(v), err
+	case immF32:
+		v, err := DecodeFloat32(r)
+		return formatFloat(float64(v), 32), err
+	case immF64:
+		v, err := DecodeFloat64(r)
+		return formatFloat(v, 64), err
+	}
+	return "", nil
+}
+
+func formatFloat(v float64, bitSize int) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "inf"
+	case math.IsInf(v, -1):
+		return "-inf"
+	case math.IsNaN(v):
+		return "nan"
+	}
+	return strconv.FormatFloat(v, 'g', -1, bitSize)
+}
@@ -0,0 +1,78 @@
+package VM
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDisassembleRoundTrip(t *testing.T) {
+	// add, sub and mul from TestCall2, with a name section
+	wasmBytes, _ := hex.DecodeString("0061736d0100000001070160027f7f017f0304030000000a19030700200020016a0b0700200020016b0b0700200020016c0b0020046e616d650110030003616464010373756202036d756c020703000001000200")
+	module := decode(wasmBytes)
+
+	text, err := DisassembleToString(module)
+	assert.NoError(t, err)
+	assert.True(t, strings.Contains(text, "(func $sub (;1;) (param i32) (param i32) (result i32)"), text)
+	assert.True(t, strings.Contains(text, "i32.sub ;;"), text)
+
+	assembled, err := Assemble(text)
+	assert.NoError(t, err)
+	reassembled := decode(assembled)
+	for i := range module.codeSection {
+		assert.Equal(t, module.codeSection[i].body, reassembled.codeSection[i].body)
+		assert.Equal(t, module.FunctionName(i), reassembled.FunctionName(i))
+	}
+}
+
+func TestAssembleAndRun(t *testing.T) {
+	text := `
+(module
+  (func $max (param $a i64) (param $b i64) (result i64)
+    (local $ans i64)
+    local.get 0
+    local.set 2 ;; ans = a
+    block
+      local.get 0
+      local.get 1
+      i64.gt_s
+      br_if 0
+      local.get 1
+      local.set 2
+    end
+    local.get 2
+  )
+)`
+	wasmBytes, err := Assemble(text)
+	assert.NoError(t, err)
+	module := decode(wasmBytes)
+	assert.Equal(t, "max", module.FunctionName(0))
+	assert.Equal(t, "ans", module.LocalName(0, 2))
+
+	disassembled, err := DisassembleToString(module)
+	assert.NoError(t, err)
+	assert.True(t, strings.Contains(disassembled, "br_if 0 (;@1;)"), disassembled)
+	assert.True(t, strings.Contains(disassembled, "block ;; label = @1"), disassembled)
+
+	spoofer := NewDBSpoofer()
+	hashes, err := spoofer.AddModuleToSpoofedCode(wasmBytes)
+	assert.NoError(t, err)
+	config := GetDefaultConfig()
+	config.CodeGetter = spoofer.GetCode
+	vm := NewVirtualMachine([]byte{}, []uint64{}, &config, 1000)
+
+	callCode := hex.EncodeToString(hashes[0]) + "7e05" + "7e09"
+	assert.NoError(t, vm.Call2(callCode, 1000))
+	assert.Equal(t, []uint64{9}, vm.Stack())
+}
+
+func TestAssembleErrors(t *testing.T) {
+	_, err := Assemble("(module (func i32.foo))")
+	assert.Error(t, err)
+	_, err = Assemble("(module (func block nop))")
+	assert.Error(t, err)
+	_, err = Assemble("(module (func end))")
+	assert.Error(t, err)
+}
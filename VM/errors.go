@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"strings"
 )
 
 var (
@@ -21,6 +22,9 @@ var (
 
 	//offchain DB interaction errors
 	ErrConnectionRefused = errors.New("403 Forbidden. Server could not be connected to")
+	ErrCodeNotStored     = errors.New("no code stored with that hash")
+	ErrNoCodeStore       = errors.New("virtual machine does not have a code store setup")
+	ErrCodeHashMismatch  = errors.New("code served does not match the hash it was requested by")
 )
 
 type ErrWithNetwork struct {
@@ -37,3 +41,41 @@ func (e ErrWithNetwork) Is(code int) bool {
 func (e ErrWithNetwork) Error() string {
 	return fmt.Errorf("%v. Server could not be connected to", http.StatusText(e.code)).Error()
 }
+
+// TraceEntry is a single frame of a symbolic backtrace
+type TraceEntry struct {
+	Function string // the name of the function from the name section, or its hash if it has no name
+	Offset   uint64 // the index of the instruction being ran in the function body
+}
+
+func (te TraceEntry) String() string {
+	return fmt.Sprintf("%v+%#x", te.Function, te.Offset)
+}
+
+// TrapError is returned when the execution stops on an error, and records where it happened.
+// The trace starts at the innermost frame.
+type TrapError struct {
+	Err   error
+	Trace []TraceEntry
+}
+
+func (e *TrapError) Error() string {
+	lines := []string{e.Err.Error()}
+	for _, entry := range e.Trace {
+		lines = append(lines, "\tat "+entry.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (e *TrapError) Unwrap() error {
+	return e.Err
+}
+
+// TrapCause returns the error that caused a trap, or the error itself if it is not a trap
+func TrapCause(err error) error {
+	var trap *TrapError
+	if errors.As(err, &trap) {
+		return trap.Err
+	}
+	return err
+}
@@ -25,6 +25,7 @@ type Module struct {
 	codeSection      []*Code
 	dataSection      []*DataSegment
 	dataCountSection *uint32
+	nameSection      *NameSection
 	ID               ModuleID
 }
 
@@ -79,6 +80,16 @@ func decode(wasmBytes []byte) *Module {
 			buf := make([]byte, sectionSize)
 			io.ReadFull(r, buf)
 
+			cr := bytes.NewReader(buf)
+			name, _, err := decodeUTF8(cr, "custom section name")
+			if err != nil || name != nameSectionName {
+				continue
+			}
+			// a malformed name section is only debug info, and shouldn't make the module invalid
+			if ns, err := decodeNameSection(cr); err == nil {
+				m.nameSection = ns
+			}
+
 		case sectionIDType:
 			vs, _, err := DecodeUint32(r)
 			if err != nil {
@@ -269,11 +280,11 @@ func decode(wasmBytes []byte) *Module {
 
 func (m *Module) sectionElementCount(sectionID SectionID) uint32 { // element as in vector elements!
 	switch sectionID {
-	// case sectionIDCustom:
-	// 	if m.nameSection != nil {
-	// 		return 1
-	// 	}
-	// 	return 0
+	case sectionIDCustom:
+		if m.nameSection != nil {
+			return 1
+		}
+		return 0
 	case sectionIDType:
 		return uint32(len(m.typeSection))
 	case sectionIDImport:
@@ -307,3 +318,35 @@ func (m *Module) sectionElementCount(sectionID SectionID) uint32 { // element as
 	}
 
 }
+
+// Names returns the parsed name section of the module, or nil if the module has none.
+func (m *Module) Names() *NameSection {
+	return m.nameSection
+}
+
+// importedFunctionCount is the number of imported functions, which come first in the function index space.
+func (m *Module) importedFunctionCount() uint32 {
+	count := uint32(0)
+	for _, imp := range m.importSection {
+		if imp.Type == 0x00 {
+			count++
+		}
+	}
+	return count
+}
+
+// FunctionName returns the debug name of the i-th function in the code section, or "" if it has none.
+func (m *Module) FunctionName(i int) string {
+	if m.nameSection == nil {
+		return ""
+	}
+	return m.nameSection.FunctionNames[m.importedFunctionCount()+uint32(i)]
+}
+
+// LocalName returns the debug name of a local (params included) of the i-th function in the code section.
+func (m *Module) LocalName(i int, local Index) string {
+	if m.nameSection == nil {
+		return ""
+	}
+	return m.nameSection.LocalNames[m.importedFunctionCount()+uint32(i)][local]
+}
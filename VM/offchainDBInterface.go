@@ -4,9 +4,12 @@ import (
 	"bytes"
 	"encoding/hex"
 	"fmt"
-	"io/ioutil"
+	"io"
 	"net/http"
+	"strings"
+	"time"
 
+	"github.com/adamnite/go-adamnite/common"
 	"github.com/vmihailenco/msgpack/v5"
 )
 
@@ -14,183 +17,135 @@ var (
 	ERR_CONTRACT_NOT_STORED = fmt.Errorf("No contract saved at that point")
 )
 
-type APIcodeGetter struct {
-	apiEndpointString string
-}
+const (
+	defaultHTTPTimeout    = 10 * time.Second
+	defaultHTTPRetries    = 3
+	defaultHTTPRetryDelay = 100 * time.Millisecond
+)
 
-func NewAPICodeGetter(apiString string) APIcodeGetter {
-	return APIcodeGetter{apiEndpointString: apiString}
+// HTTPCodeStore talks to an off-chain DB server over HTTP. Requests that fail to connect, or that the
+// server fails with a 5xx status, are retried with an exponential backoff.
+type HTTPCodeStore struct {
+	Client     *http.Client
+	Retries    int           // how many times a failed request is tried again
+	RetryDelay time.Duration // how long to wait before the first retry, doubled every retry after
+	endpoint   string
 }
 
-func (c APIcodeGetter) GetCode(hash []byte) (FunctionType, []OperationCommon, []ControlBlock) {
-	locCopy, err := GetMethodCode(c.apiEndpointString, hex.EncodeToString(hash))
-	if err != nil {
-		panic(err)
+func NewHTTPCodeStore(apiEndpoint string) *HTTPCodeStore {
+	return &HTTPCodeStore{
+		Client:     &http.Client{Timeout: defaultHTTPTimeout},
+		Retries:    defaultHTTPRetries,
+		RetryDelay: defaultHTTPRetryDelay,
+		endpoint:   strings.TrimSuffix(apiEndpoint, "/"),
 	}
-	ops, blocks := parseBytes(locCopy.CodeBytes)
-	funcType := FunctionType{
-		params:  locCopy.CodeParams,
-		results: locCopy.CodeResults,
-		string:  hex.EncodeToString(hash), //so you can lie better.
-	}
-	return funcType, ops, blocks
-	// func GetMethodCode(apiEndpoint string, codeHash string) (*CodeStored, error) {
 }
 
-//UPLOADER
-
-func UploadMethod(apiEndpoint string, code CodeStored) ([]byte, error) {
-	//takes the code as an array of bytes and returns the hash, and any errors
-	contractApiString := apiEndpoint
-	if contractApiString[len(contractApiString)-1:] == "/" {
-		contractApiString = contractApiString[:len(contractApiString)-1]
-	}
-	packedData, err := msgpack.Marshal(&code)
-	if err != nil {
-		fmt.Println(err)
-		return nil, err
+// do sends the request, and returns the body and status of the first answer that isn't a server error.
+func (s *HTTPCodeStore) do(method string, path string, body []byte) ([]byte, int, error) {
+	var lastErr error
+	for attempt := 0; attempt <= s.Retries; attempt++ {
+		if attempt != 0 {
+			time.Sleep(s.RetryDelay << (attempt - 1))
+		}
+		re, err := http.NewRequest(method, s.endpoint+path, bytes.NewReader(body))
+		if err != nil {
+			return nil, 0, err
+		}
+		ans, err := s.Client.Do(re)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		byteResponse, err := io.ReadAll(ans.Body)
+		ans.Body.Close()
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if ans.StatusCode >= http.StatusInternalServerError {
+			lastErr = NewErrWithNetwork(ans.StatusCode)
+			continue
+		}
+		return byteResponse, ans.StatusCode, nil
 	}
+	return nil, 0, lastErr
+}
 
-	re, err := http.NewRequest("PUT", contractApiString+"/uploadCode", bytes.NewReader(packedData))
+func (s *HTTPCodeStore) GetMethod(hash []byte) (*CodeStored, error) {
+	byteResponse, status, err := s.do(http.MethodGet, "/code/"+hex.EncodeToString(hash), nil)
 	if err != nil {
-		fmt.Println(err)
 		return nil, err
 	}
-
-	ans, err := http.DefaultClient.Do(re)
-	if err != nil {
-		return nil, err
+	if status == http.StatusNotFound {
+		return nil, ErrCodeNotStored
 	}
-	netErr := NewErrWithNetwork(ans.StatusCode)
-	if !netErr.Is(200) {
-		return nil, netErr
+	if status != http.StatusOK {
+		return nil, NewErrWithNetwork(status)
 	}
 
-	byteResponse, err := ioutil.ReadAll(ans.Body)
-	if err != nil {
+	var code CodeStored
+	if err := msgpack.Unmarshal(byteResponse, &code); err != nil {
 		return nil, err
 	}
-	hashInBytes, err := hex.DecodeString(string(byteResponse))
-	if err != nil {
+	if err := verifyCode(hash, &code); err != nil {
 		return nil, err
 	}
-	return hashInBytes, nil
+	return &code, nil
 }
 
-func UploadContract(apiEndpoint string, con Contract) error {
-	contractApiString := apiEndpoint
-	if contractApiString[len(contractApiString)-1:] == "/" {
-		contractApiString = contractApiString[:len(contractApiString)-1]
-	}
-
-	packedData, err := ContractToMSGPackBytes(con)
-	if err != nil {
-		fmt.Println(err)
-		return err
-	}
-
-	re, err := http.NewRequest("PUT", contractApiString+"/contract/"+con.Address.Hex(), bytes.NewReader(packedData))
+// PutMethod uploads the code and returns the hash the server stored it under, which has to match our own.
+func (s *HTTPCodeStore) PutMethod(code CodeStored) ([]byte, error) {
+	packedData, err := msgpack.Marshal(&code)
 	if err != nil {
-		fmt.Println(err)
-		return err
+		return nil, err
 	}
-
-	ans, err := http.DefaultClient.Do(re)
+	byteResponse, status, err := s.do(http.MethodPut, "/uploadCode", packedData)
 	if err != nil {
-		return err
-	}
-	netErr := NewErrWithNetwork(ans.StatusCode)
-	if !netErr.Is(200) {
-		return netErr
-	}
-	return nil
-
-}
-
-func UploadModuleFunctions(apiEndpoint string, mod Module) ([]CodeStored, [][]byte, error) {
-	functionsToUpload := []CodeStored{}
-	hashes := [][]byte{}
-	for x := range mod.typeSection {
-		code := CodeStored{
-			CodeParams:  mod.typeSection[x].params,
-			CodeResults: mod.typeSection[x].results,
-			CodeBytes:   mod.codeSection[x].body,
-		}
-		functionsToUpload = append(functionsToUpload, code)
-		newHash, err := UploadMethod(apiEndpoint, code)
-		if err != nil {
-			return nil, nil, err
-		}
-		localHash, err := code.Hash()
-
-		if !bytes.Equal(newHash, localHash) || err != nil {
-			fmt.Println(err)
-			return nil, nil, fmt.Errorf("hashes are not equal, or could not hash local copy. ERR: %w, server hash: %v, local hash: %v", err, newHash, localHash)
-		}
-		hashes = append(hashes, newHash)
+		return nil, err
 	}
-
-	return functionsToUpload, hashes, nil
-}
-func (m Machine) UploadMachinesContract(apiEndpoint string) error {
-	return UploadContract(apiEndpoint, m.contract)
-}
-
-//GETTER
-
-func GetMethodCode(apiEndpoint string, codeHash string) (*CodeStored, error) {
-	ApiString := apiEndpoint
-	if ApiString[len(ApiString)-1:] == "/" {
-		ApiString = ApiString[:len(ApiString)-1]
+	if status != http.StatusOK {
+		return nil, NewErrWithNetwork(status)
 	}
-	re, err := http.Get(ApiString + "/code/" + codeHash)
+	serverHash, err := hex.DecodeString(string(byteResponse))
 	if err != nil {
-		fmt.Println(err)
 		return nil, err
 	}
-
-	byteResponse, err := ioutil.ReadAll(re.Body)
+	localHash, err := code.Hash()
 	if err != nil {
-		fmt.Println(err)
 		return nil, err
 	}
-
-	var code CodeStored
-	err = msgpack.Unmarshal(byteResponse, &code)
-	if err != nil {
-		fmt.Println(err)
-		return nil, err
+	if !bytes.Equal(serverHash, localHash) {
+		return nil, fmt.Errorf("hashes are not equal, server hash: %x, local hash: %x", serverHash, localHash)
 	}
-
-	return &code, nil
+	return serverHash, nil
 }
 
-func GetContractData(apiEndpoint string, contractAddress string) (*Contract, error) {
-	contractApiString := apiEndpoint
-	if contractApiString[len(contractApiString)-1:] == "/" {
-		contractApiString = contractApiString[:len(contractApiString)-1]
-	}
-	re, err := http.Get(contractApiString + "/contract/" + contractAddress)
-	if err != nil {
-		fmt.Println(err)
-		return nil, err
-	}
-
-	byteResponse, err := ioutil.ReadAll(re.Body)
+func (s *HTTPCodeStore) GetContract(address common.Address) (*Contract, error) {
+	byteResponse, status, err := s.do(http.MethodGet, "/contract/"+address.Hex(), nil)
 	if err != nil {
-		fmt.Println(err)
 		return nil, err
 	}
 	if string(byteResponse) == "contract not stored" {
 		return nil, ERR_CONTRACT_NOT_STORED
 	}
-	//hopefully you know if things went wrong by here!
-	var conData ContractData
-	err = msgpack.Unmarshal(byteResponse, &conData)
+	if status != http.StatusOK {
+		return nil, NewErrWithNetwork(status)
+	}
+	return MSGPackBytesToContract(byteResponse)
+}
+
+func (s *HTTPCodeStore) PutContract(con Contract) error {
+	packedData, err := ContractToMSGPackBytes(con)
 	if err != nil {
-		fmt.Println(err)
-		return nil, err
+		return err
 	}
-	foo := contractDataToContract(conData)
-	return foo, nil
+	_, status, err := s.do(http.MethodPut, "/contract/"+con.Address.Hex(), packedData)
+	if err != nil {
+		return err
+	}
+	if status != http.StatusOK {
+		return NewErrWithNetwork(status)
+	}
+	return nil
 }
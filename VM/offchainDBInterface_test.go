@@ -1,11 +1,9 @@
 package VM
 
 import (
-	"bytes"
 	"encoding/hex"
 	"fmt"
 	"math/big"
-	"net/http"
 	"testing"
 
 	"github.com/adamnite/go-adamnite/databaseDeprecated/rawdb"
@@ -34,16 +32,11 @@ var (
 
 // a simple test to see if the offchain DB is actually live. Otherwise these tests cannot return a truthful error.
 func isDBAPILive() bool {
-	//just push to a known empty API, and see if we get an error that it's offline, or not able to be written to
-	re, err := http.NewRequest("PUT", apiEndpoint+"/contract/0x0000", bytes.NewReader([]byte{}))
-	if err != nil {
-		return false
-	}
-	ans, err := http.DefaultClient.Do(re)
-	if err != nil {
-		return false
-	}
-	return ans.StatusCode == 200
+	//just ask for a contract that was never stored, and see if we get an answer at all
+	store := NewHTTPCodeStore(apiEndpoint)
+	store.Retries = 0
+	_, err := store.GetContract(common.Address{})
+	return err == nil || err == ERR_CONTRACT_NOT_STORED
 }
 
 // automatically skips tests if the DB is offline.
@@ -56,7 +49,7 @@ func skipIfDBOffline(t *testing.T) {
 func TestUploadingContract(t *testing.T) {
 	skipIfDBOffline(t)
 	testContract = newContract(common.BytesToAddress([]byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08}), big.NewInt(0), nil, 10000)
-	err := UploadContract(apiEndpoint, *testContract)
+	err := NewHTTPCodeStore(apiEndpoint).PutContract(*testContract)
 	fmt.Println(testContract.Address.Hex())
 	fmt.Println(testContract)
 	if err != nil {
@@ -66,7 +59,7 @@ func TestUploadingContract(t *testing.T) {
 
 func TestGettingContract(t *testing.T) {
 	skipIfDBOffline(t)
-	cdata, err := GetContractData(apiEndpoint, testContract.Address.Hex())
+	cdata, err := NewHTTPCodeStore(apiEndpoint).GetContract(testContract.Address)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -81,7 +74,7 @@ func TestUploadingCode(t *testing.T) {
 	skipIfDBOffline(t)
 	fmt.Println(addTwoFunctionHash)
 
-	hash, err := UploadMethod(apiEndpoint, addTwoCodeStored)
+	hash, err := NewHTTPCodeStore(apiEndpoint).PutMethod(addTwoCodeStored)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -91,7 +84,7 @@ func TestUploadingCode(t *testing.T) {
 }
 func TestGettingCode(t *testing.T) {
 	skipIfDBOffline(t)
-	codeString, err := GetMethodCode(apiEndpoint, hex.EncodeToString(addTwoFunctionHash))
+	codeString, err := NewHTTPCodeStore(apiEndpoint).GetMethod(addTwoFunctionHash)
 	if err != nil {
 		t.Fatal(err)
 	}
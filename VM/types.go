@@ -63,6 +63,7 @@ type Machine struct {
 	gas               uint64 // The allocated gas for the code execution
 	callStack         []*Frame
 	stopSignal        bool
+	frameEntered      bool // set once the active frame's code and locals are loaded into the machine
 	currentFrame      int
 	BlockCtx          BlockContext
 	Statedb           *statedb.StateDB
@@ -90,6 +91,9 @@ type BlockContext struct {
 
 type GetCode func(hash []byte) (FunctionType, []OperationCommon, []ControlBlock)
 
+// GetFunctionName returns the debug name of the function with that hash, or "" if it isn't known
+type GetFunctionName func(hash []byte) string
+
 type VMConfig struct {
 	maxCallStackDepth        uint
 	gasLimit                 uint64
@@ -97,8 +101,8 @@ type VMConfig struct {
 	debugStack               bool // should it output the stack every operation
 	maxCodeSize              uint64
 	CodeGetter               GetCode
-	CodeBytesGetter          func(uri string, hash string) ([]byte, error)
-	Uri                      string
+	CodeStore                CodeStore // used instead of CodeGetter when set, so missing code is an error rather than a panic
+	NameGetter               GetFunctionName
 }
 
 type Frame struct {
@@ -109,6 +113,7 @@ type Frame struct {
 	ReturnReg    int
 	Continuation int64
 	CtrlStack    []ControlBlock
+	Function     string //name (or hash) of the function being ran, used for backtraces
 }
 
 // Contract represents an adm contract in the state database. It contains
@@ -119,6 +124,7 @@ type Contract struct {
 	CallerAddress common.Address
 	Code          []CodeStored
 	CodeHashes    []string //the hash of the code,the code is only actually in Contract.Code once its called
+	CodeNames     []string //debug names of the methods, in the same order as CodeHashes. May be empty
 	Storage       []uint64
 	Input         []byte // The bytes from `input` field of the transaction
 	Gas           uint64
@@ -168,7 +174,7 @@ func (rtc RuntimeChanges) CleanCopy() *RuntimeChanges {
 func (a RuntimeChanges) Equal(b *RuntimeChanges) bool {
 	if bytes.Equal(a.Caller.Bytes(), b.Caller.Bytes()) &&
 		bytes.Equal(a.ContractCalled[:], b.ContractCalled[:]) &&
-		a.GasLimit == b.GasLimit && a.ErrorsEncountered == b.ErrorsEncountered &&
+		a.GasLimit == b.GasLimit && TrapCause(a.ErrorsEncountered) == TrapCause(b.ErrorsEncountered) &&
 		len(a.ChangeStartPoints) == len(b.ChangeStartPoints) &&
 		len(a.Changed) == len(b.Changed) {
 
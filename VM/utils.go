@@ -15,9 +15,10 @@ import (
 // Contract represents an adm contract in the state database. It contains
 // the contract methods, calling arguments.
 type ContractData struct {
-	Address string   //the Address of the contract
-	Methods []string //just store all the hashes of the functions it can run as strings
-	Storage []uint64 //all storage inside the contract is held as an array of bytes
+	Address     string   //the Address of the contract
+	Methods     []string //just store all the hashes of the functions it can run as strings
+	Storage     []uint64 //all storage inside the contract is held as an array of bytes
+	MethodNames []string `msgpack:",omitempty"` //debug names of the methods, if the compiler emitted any
 }
 type CodeStored struct {
 	CodeParams  []ValueType
@@ -25,25 +26,64 @@ type CodeStored struct {
 	CodeBytes   []byte
 }
 
-// API DB Spoofing
+// DBSpoofer is the in memory CodeStore, used by tests and local tools that have no off-chain DB to talk to.
 type DBSpoofer struct {
-	storedFunctions map[string]CodeStored //hash=>functions
+	storedFunctions map[string]CodeStored   //hash=>functions
+	functionNames   map[string]string       //hash=>debug name
+	contracts       map[string]ContractData //address=>contract
 }
 
 func NewDBSpoofer() DBSpoofer {
-	return DBSpoofer{map[string]CodeStored{}}
+	return DBSpoofer{map[string]CodeStored{}, map[string]string{}, map[string]ContractData{}}
 }
 
+// GetFunctionName returns the name the function had in the name section of its module, if any.
+func (spoof *DBSpoofer) GetFunctionName(hash []byte) string {
+	return spoof.functionNames[hex.EncodeToString(hash)]
+}
+
+// GetCode is the CodeGetter of the spoofer. The code is checked against its hash as GetMethod does, but having no
+// error to return, it panics if the code isn't stored or doesn't match.
 func (spoof *DBSpoofer) GetCode(hash []byte) (FunctionType, []OperationCommon, []ControlBlock) {
-	localCode := spoof.storedFunctions[hex.EncodeToString(hash)]
-	ops, blocks := parseBytes(localCode.CodeBytes)
+	code, err := spoof.GetMethod(hash)
+	if err != nil {
+		panic(fmt.Errorf("unable to get code %x: %w", hash, err))
+	}
+	return code.parse(hash)
+}
 
-	funcType := FunctionType{
-		params:  localCode.CodeParams,
-		results: localCode.CodeResults,
-		string:  hex.EncodeToString(hash), //so you can lie better.
+func (spoof *DBSpoofer) GetMethod(hash []byte) (*CodeStored, error) {
+	code, exists := spoof.storedFunctions[hex.EncodeToString(hash)]
+	if !exists {
+		return nil, ErrCodeNotStored
 	}
-	return funcType, ops, blocks
+	if err := verifyCode(hash, &code); err != nil {
+		return nil, err
+	}
+	return &code, nil
+}
+
+func (spoof *DBSpoofer) PutMethod(code CodeStored) ([]byte, error) {
+	hash, err := code.Hash()
+	if err != nil {
+		return nil, err
+	}
+	spoof.AddSpoofedCode(hex.EncodeToString(hash), code)
+	return hash, nil
+}
+
+// GetContract returns the contract as it would come back from a real off-chain DB, with only its stored data set.
+func (spoof *DBSpoofer) GetContract(address common.Address) (*Contract, error) {
+	cdata, exists := spoof.contracts[address.Hex()]
+	if !exists {
+		return nil, ERR_CONTRACT_NOT_STORED
+	}
+	return contractDataToContract(cdata), nil
+}
+
+func (spoof *DBSpoofer) PutContract(con Contract) error {
+	spoof.contracts[con.Address.Hex()] = contractToContractData(con)
+	return nil
 }
 
 func (spoof *DBSpoofer) AddSpoofedCode(hash string, funcCode CodeStored) {
@@ -85,10 +125,10 @@ func (spoof *DBSpoofer) AddModuleToSpoofedCode(input interface{}) ([][]byte, err
 		return hashes, nil
 	}
 	hashes := [][]byte{}
-	for x := range mod.functionSection {
+	for x, typeIndex := range mod.functionSection {
 		code := CodeStored{
-			CodeParams:  mod.typeSection[x].params,
-			CodeResults: mod.typeSection[x].results,
+			CodeParams:  mod.typeSection[typeIndex].params,
+			CodeResults: mod.typeSection[typeIndex].results,
 			CodeBytes:   mod.codeSection[x].body,
 		}
 		localHash, err := code.Hash()
@@ -97,6 +137,9 @@ func (spoof *DBSpoofer) AddModuleToSpoofedCode(input interface{}) ([][]byte, err
 			return nil, err
 		}
 		spoof.AddSpoofedCode(hex.EncodeToString(localHash), code)
+		if name := mod.FunctionName(x); name != "" {
+			spoof.functionNames[hex.EncodeToString(localHash)] = name
+		}
 		hashes = append(hashes, localHash)
 	}
 	return hashes, nil
@@ -258,17 +301,26 @@ func contractDataToContract(cdata ContractData) *Contract {
 		foo, _ := hex.DecodeString(code)
 		con.CodeHashes = append(con.CodeHashes, hex.EncodeToString(foo))
 	}
+	if len(cdata.MethodNames) == len(cdata.Methods) {
+		con.CodeNames = cdata.MethodNames
+	}
 	return &con
 }
 func contractToContractData(con Contract) ContractData {
 	cdata := ContractData{
 		Address: con.Address.Hex(),
-		Methods: []string{},
+		Methods: append([]string{}, con.CodeHashes...),
 		Storage: con.Storage,
 	}
-	for _, code := range con.Code {
-		foo, _ := code.Hash()
-		cdata.Methods = append(cdata.Methods, hex.EncodeToString(foo))
+	// the hashes are enough to find the code again, only hash the loaded code if they were never set
+	if len(cdata.Methods) == 0 {
+		for _, code := range con.Code {
+			foo, _ := code.Hash()
+			cdata.Methods = append(cdata.Methods, hex.EncodeToString(foo))
+		}
+	}
+	if len(con.CodeNames) == len(cdata.Methods) && len(con.CodeNames) != 0 {
+		cdata.MethodNames = con.CodeNames
 	}
 	return cdata
 }
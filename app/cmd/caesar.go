@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"bytes"
 	"fmt"
 	"log"
 	"time"
@@ -9,6 +10,7 @@ import (
 	"github.com/adamnite/go-adamnite/caesar"
 	"github.com/adamnite/go-adamnite/common"
 	"github.com/adamnite/go-adamnite/crypto"
+	"github.com/adamnite/go-adamnite/node"
 	"github.com/adamnite/go-adamnite/utils"
 	"github.com/adamnite/go-adamnite/utils/accounts"
 	"github.com/fatih/color"
@@ -16,48 +18,103 @@ import (
 
 type CaesarHandler struct {
 	server              *caesar.CaesarNode
+	config              *node.Config //where messages are stored, and for how long
 	thisUser            *accounts.Account
 	maxMessagesOnScreen int
 	chatLogs            map[common.Address][]*chatText //the chat history by mapping
 	HoldingFocus        bool
 }
 type chatText struct {
-	fromUs bool
-	text   string
-	time   string
+	fromUs   bool
+	text     string
+	time     string
+	msg      *utils.CaesarMessage
+	hash     []byte
+	status   utils.CaesarReceiptStatus //how far a message from us got
+	edited   bool
+	readSent bool //if we've told them we read their message
 }
 
-func (ch *CaesarHandler) addChatMsg(msg *utils.CaesarMessage) {
+// chatWith returns the account on the other side of the message.
+func (ch *CaesarHandler) chatWith(msg *utils.CaesarMessage) common.Address {
 	if msg.From.Address == ch.thisUser.Address {
-		if _, exists := ch.chatLogs[msg.To.Address]; !exists {
-			ch.chatLogs[msg.To.Address] = []*chatText{}
+		return msg.To.Address
+	}
+	return msg.From.Address
+}
+
+// findChatMsg returns the chat text of the message with that hash, and where it is in the chat.
+func (ch *CaesarHandler) findChatMsg(with common.Address, hash []byte) (*chatText, int) {
+	for i, text := range ch.chatLogs[with] {
+		if bytes.Equal(text.hash, hash) {
+			return text, i
 		}
-		//well, we can't decrypt our own messages... shit...
-		//TODO: setup the weird math keys that let us decrypt things we've sent...
-		ch.chatLogs[msg.To.Address] = append(ch.chatLogs[msg.To.Address], &chatText{
-			fromUs: true,
-			text:   "*******",
-			time:   msg.GetTime().Format(time.Kitchen),
-		})
-	} else {
-		text, _ := msg.GetMessageString(*ch.thisUser)
-		newMsg := chatText{
-			fromUs: false,
+	}
+	return nil, -1
+}
+
+func (ch *CaesarHandler) addChatMsg(msg *utils.CaesarMessage) {
+	with := ch.chatWith(msg)
+	if existing, _ := ch.findChatMsg(with, msg.Hash()); existing != nil {
+		return
+	}
+	payload, err := msg.GetPayload(*ch.thisUser)
+	if err != nil {
+		//sent before messages were readable by their sender
+		payload = &utils.CaesarPayload{Type: utils.CaesarPayloadText, Text: "*******"}
+	}
+
+	switch payload.Type {
+	case utils.CaesarPayloadText, utils.CaesarPayloadAttachment:
+		text := payload.Text
+		if payload.Attachment != nil {
+			text = fmt.Sprintf("[attachment %v, %v bytes]", payload.Attachment.Name, payload.Attachment.Size)
+		}
+		ch.chatLogs[with] = append(ch.chatLogs[with], &chatText{
+			fromUs: msg.From.Address == ch.thisUser.Address,
 			text:   text,
 			time:   msg.GetTime().Format(time.Kitchen),
+			msg:    msg,
+			hash:   msg.Hash(),
+		})
+	case utils.CaesarPayloadEdit:
+		if target, _ := ch.findChatMsg(with, payload.Target); target != nil {
+			target.text = payload.Text
+			target.edited = true
 		}
-		if _, exists := ch.chatLogs[msg.From.Address]; !exists {
-			ch.chatLogs[msg.From.Address] = []*chatText{&newMsg}
-		} else {
-			ch.chatLogs[msg.From.Address] = append(ch.chatLogs[msg.From.Address], &newMsg)
+	case utils.CaesarPayloadDelete:
+		if _, i := ch.findChatMsg(with, payload.Target); i != -1 {
+			ch.chatLogs[with] = append(ch.chatLogs[with][:i], ch.chatLogs[with][i+1:]...)
 		}
+	case utils.CaesarPayloadReceipt:
+		if target, _ := ch.findChatMsg(with, payload.Target); target != nil && target.fromUs && payload.Status > target.status {
+			target.status = payload.Status
+		}
+	}
+}
 
+// sendReadReceipts tells the account we've read everything they sent that is on screen.
+func (ch *CaesarHandler) sendReadReceipts(with common.Address) {
+	for _, text := range ch.chatLogs[with] {
+		if text.fromUs || text.readSent {
+			continue
+		}
+		if err := ch.server.SendReceipt(text.msg, utils.CaesarStatusRead); err != nil {
+			log.Println(err)
+			continue
+		}
+		text.readSent = true
 	}
 }
 
-// get a Caesar chat handler
+// get a Caesar chat handler, keeping messages in the default data directory
 func NewCaesarHandler() *CaesarHandler {
-	return &CaesarHandler{maxMessagesOnScreen: 10, chatLogs: make(map[common.Address][]*chatText), HoldingFocus: false}
+	return NewCaesarHandlerWithConfig(&node.Config{Name: "caesar", DataDir: node.DefaultDataDir()})
+}
+
+// get a Caesar chat handler, keeping messages where config sets
+func NewCaesarHandlerWithConfig(config *node.Config) *CaesarHandler {
+	return &CaesarHandler{config: config, maxMessagesOnScreen: 10, chatLogs: make(map[common.Address][]*chatText), HoldingFocus: false}
 }
 func (ch CaesarHandler) isServerLive() bool {
 	return ch.server != nil
@@ -96,7 +153,12 @@ func (ch *CaesarHandler) Start(c *ishell.Context) {
 	//TODO: this assume that no account was passed to local!
 	ch.thisUser, _ = accounts.GenerateAccount()
 	c.Println("Hosting from :", crypto.B58encode(ch.thisUser.PublicKey))
-	server := caesar.NewCaesarNode(ch.thisUser)
+	server, err := caesar.NewCaesarNodeFromConfig(ch.thisUser, ch.config)
+	if err != nil {
+		c.Println(err)
+		progBar.Stop()
+		return
+	}
 	if err := server.Startup(); err != nil {
 		c.Println(err)
 		progBar.Stop()
@@ -104,6 +166,7 @@ func (ch *CaesarHandler) Start(c *ishell.Context) {
 	}
 	progBar.Progress(10)
 
+	server.AutoDeliveryReceipts = true
 	ch.server = server //if we've made it here, the servers probably gonna be working
 	if len(c.Args) >= 1 {
 		//test that we have enough arguments that a connection string *could* have been passed
@@ -145,18 +208,25 @@ func (ch *CaesarHandler) OpenChat(c *ishell.Context) {
 	c.Println("\n\n\n")
 	breakFully := false
 	ch.server.NewMessageUpdater = func(msg *utils.CaesarMessage) {
-		if msg.To.Address == ch.thisUser.Address {
-			//only add messages *to* us, no point in adding messages from us, we cant decrypt them!
-			ch.addChatMsg(msg)
+		if msg.To.Address != ch.thisUser.Address && msg.From.Address != ch.thisUser.Address {
+			return
+		}
+		ch.addChatMsg(msg)
+		if ch.chatWith(msg) == target.Address {
+			if msg.From.Address == target.Address {
+				//we have the chat open, so they can know we've read it
+				ch.sendReadReceipts(target.Address)
+			}
 			err := ch.updateChatScreen(c, target)
 			breakFully = err != nil
 		}
 	}
 	//get all the logged messages we have
-	msgs := ch.server.GetMessagesBetween(ch.thisUser.Address, target.Address) //TODO: fix this. Right now if you run this again in the same CLI instance, it will double the messages
+	msgs := ch.server.GetMessagesBetween(ch.thisUser.Address, target.Address)
 	for _, m := range msgs {
 		ch.addChatMsg(m)
 	}
+	ch.sendReadReceipts(target.Address)
 	if err := ch.updateChatScreen(c, target); err != nil || breakFully {
 		c.Println(err)
 		return
@@ -210,13 +280,17 @@ func (ch *CaesarHandler) updateChatScreen(c *ishell.Context, target accounts.Acc
 	}
 
 	for _, msg := range messagesToDisplay {
+		text := msg.text
+		if msg.edited {
+			text += " (edited)"
+		}
 		if msg.fromUs {
 			//then its on the left
-			c.Println(userMsgColor.Sprintf("[%v]%v", msg.time, msg.text)) //TODO: space this so it'll look nice
+			c.Println(userMsgColor.Sprintf("[%v]%v%v", msg.time, text, statusMark(msg.status))) //TODO: space this so it'll look nice
 		} else {
 			//on the right
 			//assume max characters on the screen per side to be 50(ish)
-			c.Println(otherMsgColor.Sprintf("[%v]%v", msg.time, msg.text)) //TODO: space this so it'll look nice
+			c.Println(otherMsgColor.Sprintf("[%v]%v", msg.time, text)) //TODO: space this so it'll look nice
 
 		}
 	}
@@ -224,19 +298,21 @@ func (ch *CaesarHandler) updateChatScreen(c *ishell.Context, target accounts.Acc
 }
 func (ch *CaesarHandler) sendMessage(target accounts.Account, text string) {
 	//TODO: check the account is real, otherwise this will break
-	if err := ch.server.Send(target, text); err != nil {
+	msg, err := ch.server.SendPayload(target, utils.NewTextPayload(text))
+	if err != nil {
 		log.Println(err)
 		return
 	}
-	newMsg := chatText{
-		fromUs: true,
-		text:   text,
-		time:   time.Now().UTC().Format(time.Kitchen),
-	}
-	if _, exists := ch.chatLogs[target.Address]; !exists {
-		ch.chatLogs[target.Address] = []*chatText{&newMsg}
-	} else {
-		ch.chatLogs[target.Address] = append(ch.chatLogs[target.Address], &newMsg)
-	}
+	ch.addChatMsg(msg)
+}
 
+// statusMark shows how far a message we sent got.
+func statusMark(status utils.CaesarReceiptStatus) string {
+	switch status {
+	case utils.CaesarStatusDelivered:
+		return " ✓"
+	case utils.CaesarStatusRead:
+		return " ✓✓"
+	}
+	return ""
 }
@@ -5,6 +5,7 @@ import (
 
 	"github.com/abiosoft/ishell/v2"
 	"github.com/adamnite/go-adamnite/crypto"
+	"github.com/adamnite/go-adamnite/node"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -15,11 +16,11 @@ func TestCaesarMessaging(t *testing.T) {
 	seedShell.Process("seed")
 	seedString := seedNode.hosting.GetConnectionString()
 
-	a := NewCaesarHandler()
+	a := NewCaesarHandlerWithConfig(&node.Config{Name: "a", DataDir: t.TempDir()})
 	aShell := ishell.New()
 	aShell.AddCmd(a.GetCaesarCommands())
 	aShell.Process("caesar", "start", seedString)
-	b := NewCaesarHandler()
+	b := NewCaesarHandlerWithConfig(&node.Config{Name: "b", DataDir: t.TempDir()})
 	bShell := ishell.New()
 	bShell.AddCmd(b.GetCaesarCommands())
 	bShell.Process("caesar", "start", seedString)
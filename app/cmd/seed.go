@@ -6,15 +6,23 @@ import (
 	"github.com/abiosoft/ishell/v2"
 	"github.com/adamnite/go-adamnite/common"
 	"github.com/adamnite/go-adamnite/networking"
+	"github.com/adamnite/go-adamnite/node"
+	"github.com/adamnite/go-adamnite/rpc"
 )
 
 // this is for handling any seed types to be started through the CLI
 type SeedHandler struct {
 	hosting *networking.NetNode
+	config  *node.Config //who can call what on the bouncer
 }
 
 func NewSeedHandler() *SeedHandler {
-	return &SeedHandler{}
+	return NewSeedHandlerWithConfig(&node.Config{Name: "seed"})
+}
+
+// get a seed handler, with the bouncer policy of config
+func NewSeedHandlerWithConfig(config *node.Config) *SeedHandler {
+	return &SeedHandler{config: config}
 }
 func (sh *SeedHandler) GetSeedCommands() *ishell.Cmd {
 	seedFuncs := ishell.Cmd{
@@ -24,7 +32,7 @@ func (sh *SeedHandler) GetSeedCommands() *ishell.Cmd {
 	}
 	seedFuncs.AddCmd(&ishell.Cmd{
 		Name: "start",
-		Help: "start <optionalBouncerPort> seed node server",
+		Help: "start <optionalBouncerPort> <optionalJSONRPCPort> <optionalHost> seed node server. Pass 0.0.0.0 as the host to accept remote nodes",
 		Func: sh.Start,
 	})
 	seedFuncs.AddCmd(&ishell.Cmd{
@@ -45,20 +53,47 @@ func (sh *SeedHandler) Start(c *ishell.Context) {
 		c.Println("server already started")
 		return
 	}
+	host := rpc.DefaultListenHost
+	if len(c.Args) >= 3 {
+		host = c.Args[2]
+	}
 	sh.hosting = networking.NewNetNode(common.Address{0})
+	if err := sh.hosting.SetListenConfig(rpc.ListenConfig{Host: host}); err != nil {
+		c.Println(err)
+		sh.hosting = nil
+		return
+	}
 	if err := sh.hosting.AddServer(); err != nil {
 		c.Println(err)
+		sh.hosting = nil
 		return
 	}
+	bouncerListen := rpc.ListenConfig{Host: host}
 	if len(c.Args) >= 1 {
 		if i, err := strconv.Atoi(c.Args[0]); err != nil {
 			c.Println("error parsing bouncer hosting port")
 			return
 		} else {
-			sh.hosting.AddBouncerServer(nil, nil, uint32(i))
+			bouncerListen.Port = uint32(i)
+		}
+	}
+	if err := sh.hosting.AddBouncerServer(nil, nil, bouncerListen); err != nil {
+		c.Println(err)
+		return
+	}
+	if err := sh.hosting.SetBouncerPolicy(sh.config.BouncerRPCPolicy()); err != nil {
+		c.Println(err)
+		return
+	}
+	if len(c.Args) >= 2 {
+		if i, err := strconv.Atoi(c.Args[1]); err != nil {
+			c.Println("error parsing JSON-RPC hosting port")
+			return
+		} else if err := sh.hosting.AddBouncerJSONRPC(rpc.ListenConfig{Host: host, Port: uint32(i)}, nil); err != nil {
+			c.Println(err)
+			return
 		}
-	} else {
-		sh.hosting.AddBouncerServer(nil, nil, 0)
+		c.Printf("Seed JSON-RPC available at http://%v\n", sh.hosting.GetBouncerJSONRPCString())
 	}
 
 	c.Printf("Seed server has started up at %v\n", sh.hosting.GetConnectionString())
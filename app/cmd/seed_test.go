@@ -0,0 +1,33 @@
+package cmd
+
+import (
+	"net/rpc"
+	"testing"
+
+	"github.com/abiosoft/ishell/v2"
+	"github.com/adamnite/go-adamnite/node"
+	admRpc "github.com/adamnite/go-adamnite/rpc"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSeedBouncerPolicy(t *testing.T) {
+	policy := admRpc.DefaultBouncerPolicy()
+	policy.Access[admRpc.BouncerGetChainIDEndpoint] = admRpc.AccessAdmin
+	policy.AdminTokens = []string{"secret"}
+	seedNode := NewSeedHandlerWithConfig(&node.Config{BouncerPolicy: policy})
+	seedShell := ishell.New()
+	seedShell.AddCmd(seedNode.GetSeedCommands())
+	seedShell.Process("seed")
+	defer seedNode.hosting.Close()
+
+	client, err := rpc.Dial("tcp", seedNode.hosting.GetBouncerString())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	output := []byte{}
+	err = client.Call(admRpc.BouncerGetChainIDEndpoint, []byte{}, &output)
+	if assert.Error(t, err, "the bouncer policy of the config wasn't applied") {
+		assert.Equal(t, admRpc.ErrUnauthorized.Error(), err.Error())
+	}
+}
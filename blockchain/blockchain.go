@@ -2,10 +2,14 @@ package blockchain
 
 import (
 	"encoding/binary"
+	"errors"
 	"math/big"
 	"sync"
 
+	"github.com/adamnite/go-adamnite/VM"
 	"github.com/adamnite/go-adamnite/databaseDeprecated"
+	"github.com/adamnite/go-adamnite/databaseDeprecated/rawdb"
+	"github.com/adamnite/go-adamnite/databaseDeprecated/statedb"
 	"github.com/adamnite/go-adamnite/utils"
 	"github.com/vmihailenco/msgpack/v5"
 
@@ -24,6 +28,8 @@ const (
 
 var (
 	headerPrefix = []byte("h")
+
+	ErrBlockNotRun = errors.New("a block with transactions has to be run on a state, so their receipts are kept")
 )
 
 type Blockchain struct {
@@ -39,7 +45,7 @@ type Blockchain struct {
 	// For demo version
 	blocks         []types.Block // memory cache
 	blocksByHash   map[common.Hash]*types.Block
-	blocksByNumber map[*big.Int]*types.Block
+	blocksByNumber map[uint64]*types.Block
 
 	// events
 	importBlockFeed event.Feed
@@ -55,7 +61,7 @@ func NewBlockchain(db adamnitedb.Database, chainConfig *params.ChainConfig, engi
 		db:             db,
 		engine:         engine,
 		blocksByHash:   make(map[common.Hash]*types.Block),
-		blocksByNumber: make(map[*big.Int]*types.Block),
+		blocksByNumber: make(map[uint64]*types.Block),
 	}
 
 	// demo logic
@@ -106,7 +112,7 @@ func (bc *Blockchain) GetHeaderByHash(hash common.Hash) *types.BlockHeader {
 }
 
 func (bc *Blockchain) GetHeaderByNumber(number *big.Int) *types.BlockHeader {
-	return bc.blocksByNumber[number].Header()
+	return bc.blocksByNumber[number.Uint64()].Header()
 }
 
 func (bc *Blockchain) GetBlockByHash(hash common.Hash) *types.Block {
@@ -114,33 +120,90 @@ func (bc *Blockchain) GetBlockByHash(hash common.Hash) *types.Block {
 }
 
 func (bc *Blockchain) GetBlockByNumber(number *big.Int) *types.Block {
-	return bc.blocksByNumber[number]
+	return bc.blocksByNumber[number.Uint64()]
 }
 
 func (bc *Blockchain) CurrentBlock() *types.Block {
 	return &bc.blocks[len(bc.blocks)-1]
 }
 
+// WriteBlock writes a block without running it, so only blocks without transactions, that have no receipts, can be
+// written this way.
 func (bc *Blockchain) WriteBlock(block *types.Block) error {
-	bc.chainlock.Lock()
-	defer bc.chainlock.Unlock()
+	if len(block.Body().Transactions) != 0 {
+		return ErrBlockNotRun
+	}
+	return bc.WriteBlockWithReceipts(block, nil)
+}
 
+// WriteBlockWithReceipts writes the block, keeping the receipts of its transactions for them to be looked up.
+func (bc *Blockchain) WriteBlockWithReceipts(block *types.Block, receipts types.Receipts) error {
+	bc.chainlock.Lock()
 	bc.addBlockToCache(*block)
+	if receipts != nil {
+		rawdb.WriteReceipts(bc.db, block.Hash(), block.Numberu64(), receipts)
+	}
+	bc.chainlock.Unlock()
+
+	//sent without the lock, so a slow subscriber doesn't hold up the chain
+	bc.chainHeadFeed.Send(ChainHeadEvent{Block: block})
 	return nil
 }
 
-func (bc *Blockchain) AddImportedBlock(block *types.Block) error {
-	bc.chainlock.Lock()
-	defer bc.chainlock.Unlock()
+// InsertBlock runs the transactions of the block on the state, then writes the block with the receipts they gave.
+func (bc *Blockchain) InsertBlock(block *types.Block, state *statedb.StateDB, cfg VM.VMConfig) (types.Receipts, error) {
+	receipts, _, err := NewStateProcessor(bc.chainConfig, bc, bc.engine).Process(block, state, cfg, nil)
+	if err != nil {
+		return nil, err
+	}
+	return receipts, bc.WriteBlockWithReceipts(block, receipts)
+}
 
-	currentBlock := bc.CurrentBlock()
+// GetTransaction returns the transaction and where it was included, or nil if it isn't in a block.
+func (bc *Blockchain) GetTransaction(hash common.Hash) (*types.Transaction, *rawdb.TxLookupEntry) {
+	entry := rawdb.ReadTxLookupEntry(bc.db, hash)
+	if entry == nil {
+		return nil, nil
+	}
+	bc.chainlock.RLock()
+	defer bc.chainlock.RUnlock()
+	block := bc.blocksByHash[entry.BlockHash]
+	if block == nil {
+		return nil, nil
+	}
+	transactions := block.Body().Transactions
+	if entry.Index >= uint64(len(transactions)) {
+		return nil, nil
+	}
+	return transactions[entry.Index], entry
+}
 
-	if currentBlock.Numberu64() >= block.Numberu64() {
+// GetReceipt returns the receipt of the transaction, or nil if it isn't in a block or its block came without receipts.
+func (bc *Blockchain) GetReceipt(hash common.Hash) *types.Receipt {
+	entry := rawdb.ReadTxLookupEntry(bc.db, hash)
+	if entry == nil {
+		return nil
+	}
+	receipts := rawdb.ReadReceipts(bc.db, entry.BlockHash, entry.BlockNumber)
+	if entry.Index >= uint64(len(receipts)) {
 		return nil
 	}
+	return receipts[entry.Index]
+}
 
-	bc.addBlockToCache(*block)
+// AddImportedBlock runs a block received from another node on the state, then writes it with the receipts of its
+// transactions. Blocks that aren't newer than the current one are ignored.
+func (bc *Blockchain) AddImportedBlock(block *types.Block, state *statedb.StateDB, cfg VM.VMConfig) error {
+	bc.chainlock.RLock()
+	known := bc.CurrentBlock().Numberu64() >= block.Numberu64()
+	bc.chainlock.RUnlock()
+	if known {
+		return nil
+	}
 
+	if _, err := bc.InsertBlock(block, state, cfg); err != nil {
+		return err
+	}
 	bc.importBlockFeed.Send(ImportBlockEvent{Block: block})
 	return nil
 }
@@ -157,9 +220,10 @@ func (bc *Blockchain) SubscribeChainSideEvent(ch chan<- ChainSideEvent) event.Su
 	return bc.scope.Track(bc.chainSideFeed.Subscribe(ch))
 }
 
-// adds blocks to the local cache so they can easily be found by hash, or block id number.
+// adds blocks to the local cache so they can easily be found by hash, or block id number. Their transactions are indexed by hash.
 func (bc *Blockchain) addBlockToCache(block types.Block) {
 	bc.blocks = append(bc.blocks, block)
 	bc.blocksByHash[block.Hash()] = &block
-	bc.blocksByNumber[block.Number()] = &block
+	bc.blocksByNumber[block.Numberu64()] = &block
+	rawdb.WriteTxLookupEntries(bc.db, &block)
 }
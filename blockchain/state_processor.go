@@ -13,38 +13,38 @@ import (
 )
 
 type StateProcessor struct {
-	config             *params.ChainConfig // Chain configuration options
-	bc                 *Blockchain         // Canonical block chain
-	engine             dpos.AdamniteDPOS   // Consensus engine used for block rewards
-	vmInstances        []VM.Machine
-	localDBAPIEndpoint string
+	config    *params.ChainConfig // Chain configuration options
+	bc        *Blockchain         // Canonical block chain
+	engine    dpos.DPOS           // Consensus engine used for block rewards
+	codeStore VM.CodeStore
 }
 
 // NewStateProcessor initializes a new StateProcessor.
-func NewStateProcessor(config *params.ChainConfig, bc *Blockchain, engine dpos.AdamniteDPOS) *StateProcessor {
+func NewStateProcessor(config *params.ChainConfig, bc *Blockchain, engine dpos.DPOS) *StateProcessor {
 	return &StateProcessor{
-		config:             config,
-		bc:                 bc,
-		engine:             engine,
-		vmInstances:        []VM.Machine{},
-		localDBAPIEndpoint: "http://127.0.0.1:5001/",
+		config:    config,
+		bc:        bc,
+		engine:    engine,
+		codeStore: VM.NewHTTPCodeStore("http://127.0.0.1:5001/"),
 	}
 }
 
-func (p *StateProcessor) Process(block *types.Block, statedb *statedb.StateDB, cfg VM.VMConfig, gasPrice *big.Int) (uint64, error) {
+// Process runs the transactions of the block, returning their receipts and the ate used by the block.
+func (p *StateProcessor) Process(block *types.Block, statedb *statedb.StateDB, cfg VM.VMConfig, gasPrice *big.Int) (types.Receipts, uint64, error) {
 	var (
-		usedGas = new(uint64)
-		header  = block.Header()
+		receipts    types.Receipts
+		usedGas     = new(uint64)
+		header      = block.Header()
+		vmInstances []*VM.Machine // the machines that ran contract code, to upload their contracts after
 	)
 	// Mutate the block and state according to any hard-fork specs
 	// Iterate over and process the individual transactions
-	if cfg.CodeGetter == nil {
-		getObject := VM.NewAPICodeGetter(p.localDBAPIEndpoint)
-		cfg.CodeGetter = getObject.GetCode
+	if cfg.CodeStore == nil {
+		cfg.CodeStore = p.codeStore
 	}
 	for i, tx := range block.Body().Transactions {
 		statedb.Prepare(tx.Hash(), block.Hash(), i)
-		v, err := ApplyTransaction(
+		v, receipt, err := ApplyTransaction(
 			p.config,
 			p.bc,
 			nil,
@@ -62,42 +62,57 @@ func (p *StateProcessor) Process(block *types.Block, statedb *statedb.StateDB, c
 				big.NewInt(1),
 				tx.Cost()))
 		if err != nil {
-			return 0, err
+			return nil, 0, err
+		}
+		receipt.BlockHash = block.Hash()
+		receipt.TransactionIndex = uint(i)
+		receipts = append(receipts, receipt)
+		if len(tx.Data()) != 0 { //transfers run no code, so have no contract to upload
+			vmInstances = append(vmInstances, v)
 		}
-		p.vmInstances = append(p.vmInstances, *v)
 	}
 	// Finalize the block, applying any consensus engine specific extras (e.g. block rewards)
-	p.engine.Finalize(p.bc, header, statedb, block.Body().Transactions)
-	if p.localDBAPIEndpoint != "" { //just check that this server is in fact, running a DB
-		for _, v := range p.vmInstances {
-			err := v.UploadMachinesContract(p.localDBAPIEndpoint)
-			if err != nil {
-				return 0, err
-			}
+	if p.engine != nil {
+		p.engine.Finalize(p.bc, header, statedb, block.Body().Transactions)
+	}
+	for _, v := range vmInstances {
+		if err := v.UploadContract(cfg.CodeStore); err != nil {
+			return nil, 0, err
 		}
 	}
-	return *usedGas, nil
+	return receipts, *usedGas, nil
 }
 
 func ApplyTransaction(config *params.ChainConfig, bc *Blockchain, author *common.Address, gp *big.Int,
-	statedb *statedb.StateDB, header *types.BlockHeader, tx *types.Transaction, usedGas *uint64, vmcfg VM.VMConfig, blockContext VM.BlockContext) (*VM.Machine, error) {
+	statedb *statedb.StateDB, header *types.BlockHeader, tx *types.Transaction, usedGas *uint64, vmcfg VM.VMConfig, blockContext VM.BlockContext) (*VM.Machine, *types.Receipt, error) {
 
 	msg, err := tx.AsMessage(types.MakeSigner(config, header.Number))
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	vmenv := VM.NewVM(
 		statedb, //stateDB
 		&vmcfg,  //vm config
 		config)  //chain config
+	vmenv.BlockCtx = blockContext
 	// Apply the transaction to the current state (included in the env)
-	_, gas, _, err := core.ApplyMessage(vmenv, msg)
+	_, gas, failed, err := core.ApplyMessage(vmenv, msg)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	*usedGas += gas
 
-	return vmenv, err
+	receipt := &types.Receipt{
+		Status:            types.ReceiptStatusSuccessful,
+		GasUsed:           gas,
+		CumulativeGasUsed: *usedGas,
+		TxHash:            tx.Hash(),
+		BlockNumber:       header.Number,
+	}
+	if failed {
+		receipt.Status = types.ReceiptStatusFailed
+	}
+	return vmenv, receipt, err
 }
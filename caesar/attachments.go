@@ -0,0 +1,122 @@
+package caesar
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/adamnite/go-adamnite/common"
+	"github.com/adamnite/go-adamnite/rpc"
+	"github.com/adamnite/go-adamnite/utils"
+	"github.com/adamnite/go-adamnite/utils/accounts"
+)
+
+var ErrNotAnAttachment = fmt.Errorf("the message does not carry an attachment")
+
+// SendAttachment splits the data into encrypted chunks, shares them with the recipient and their mailboxes, then sends
+// the message referencing them. The chunks are kept here too, so any that were missed can be fetched from us.
+func (cn *CaesarNode) SendAttachment(to accounts.Account, name string, mimeType string, data []byte) (*utils.CaesarMessage, error) {
+	ref, chunks, err := utils.NewCaesarAttachment(name, mimeType, data)
+	if err != nil {
+		return nil, err
+	}
+	destinations := []common.Address{to.Address}
+	if record := cn.GetMailboxRecord(to.Address); record != nil {
+		destinations = append(destinations, record.Mailboxes...)
+	}
+	for _, chunk := range chunks {
+		if _, err := cn.messages.PutChunk(chunk); err != nil {
+			return nil, err
+		}
+		share, err := utils.NewCaesarChunkShare(cn.signerSet, *chunk)
+		if err != nil {
+			return nil, err
+		}
+		for _, destination := range destinations {
+			if err := cn.netHandler.PropagateTo(share, destination); err != nil {
+				log.Printf("[Caesar] unable to share an attachment chunk, it can still be fetched from us: %v", err)
+			}
+		}
+	}
+	return cn.SendPayload(to, utils.NewAttachmentPayload(*ref))
+}
+
+// GetAttachment reads the attachment a message references, fetching the chunks we don't have from its sender or our
+// mailboxes. Every chunk is checked against its ID, and the whole against the manifest.
+func (cn *CaesarNode) GetAttachment(msg *utils.CaesarMessage) (*utils.CaesarAttachmentRef, []byte, error) {
+	payload, err := msg.GetPayload(*cn.signerSet)
+	if err != nil {
+		return nil, nil, err
+	}
+	if payload.Type != utils.CaesarPayloadAttachment || payload.Attachment == nil {
+		return nil, nil, ErrNotAnAttachment
+	}
+	ref := payload.Attachment
+	sources := []common.Address{msg.From.Address}
+	if record := cn.GetMailboxRecord(cn.signerSet.Address); record != nil {
+		sources = append(sources, record.Mailboxes...)
+	}
+
+	manifestChunk, err := cn.fetchChunk(ref.ID, sources)
+	if err != nil {
+		return nil, nil, err
+	}
+	manifest, err := ref.OpenManifest(*manifestChunk)
+	if err != nil {
+		return nil, nil, err
+	}
+	parts := [][]byte{}
+	for _, id := range manifest.Chunks {
+		chunk, err := cn.fetchChunk(id, sources)
+		if err != nil {
+			return nil, nil, err
+		}
+		part, err := ref.OpenChunk(id, *chunk)
+		if err != nil {
+			return nil, nil, err
+		}
+		parts = append(parts, part)
+	}
+	data, err := manifest.Assemble(parts)
+	return ref, data, err
+}
+
+// fetchChunk returns the chunk with that ID, from our store if we have it, otherwise from the first of the
+// sources holding it. A source sending back a chunk that doesn't match the ID is passed over.
+func (cn *CaesarNode) fetchChunk(id []byte, sources []common.Address) (*utils.CaesarChunk, error) {
+	chunk, err := cn.messages.GetChunk(id)
+	if err != nil || chunk != nil {
+		return chunk, err
+	}
+	lastErr := rpc.ErrUnknownChunk
+	for _, source := range sources {
+		if source == cn.signerSet.Address {
+			continue
+		}
+		chunk, err := cn.netHandler.FetchCaesarChunk(source, id)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if err := chunk.Check(id); err != nil {
+			log.Printf("[Caesar] %v sent back another chunk than the one asked for: %v", source.Hex(), err)
+			lastErr = err
+			continue
+		}
+		if _, err := cn.messages.PutChunk(chunk); err != nil {
+			log.Printf("[Caesar] unable to store an attachment chunk: %v", err)
+		}
+		return chunk, nil
+	}
+	return nil, lastErr
+}
+
+// addChunk keeps a chunk that was shared with us, counted against the share of the chunks stored of who shared it.
+func (cn *CaesarNode) addChunk(share *utils.CaesarChunkShare) {
+	if !share.Verify() {
+		log.Println("[Caesar] dropping an attachment chunk that wasn't signed by who shared it")
+		return
+	}
+	if _, err := cn.messages.PutSharedChunk(&share.Chunk, share.From.Address); err != nil {
+		log.Printf("[Caesar] unable to store an attachment chunk: %v", err)
+	}
+}
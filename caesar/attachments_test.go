@@ -0,0 +1,94 @@
+package caesar
+
+import (
+	"crypto/rand"
+	"testing"
+	"time"
+
+	"github.com/adamnite/go-adamnite/common"
+	"github.com/adamnite/go-adamnite/networking"
+	"github.com/adamnite/go-adamnite/utils"
+	"github.com/adamnite/go-adamnite/utils/accounts"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAttachmentDelivery(t *testing.T) {
+	seedNode := networking.NewNetNode(common.Address{0, 1, 2, 3, 4, 5, 6, 7, 8, 9})
+	seedNode.AddServer()
+	seedContact := seedNode.GetOwnContact()
+
+	_, aNode := setupTestCaesarNode(&seedContact)
+	bAccount, bNode := setupTestCaesarNode(&seedContact)
+	seedNode.FillOpenConnections()
+	aNode.FillNetworking(false)
+	bNode.FillNetworking(false)
+
+	data := make([]byte, utils.CaesarChunkSize+10)
+	rand.Read(data)
+	sent, err := aNode.SendAttachment(*bAccount, "photo.png", "image/png", data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Eventually(t, func() bool {
+		has, _ := bNode.messages.Has(sent.Hash())
+		return has
+	}, time.Second, 10*time.Millisecond, "attachment message never arrived")
+
+	ref, received, err := bNode.GetAttachment(sent)
+	assert.NoError(t, err)
+	assert.Equal(t, data, received)
+	if assert.NotNil(t, ref) {
+		assert.Equal(t, "photo.png", ref.Name)
+		assert.Equal(t, "image/png", ref.MimeType)
+	}
+
+	//chunks that were missed are fetched from the sender
+	payload, _ := sent.GetPayload(*bAccount)
+	bNode.messages.db.Delete(concat(chunkPrefix, payload.Attachment.ID))
+	_, received, err = bNode.GetAttachment(sent)
+	assert.NoError(t, err)
+	assert.Equal(t, data, received)
+
+	//a source sending back another chunk than the one asked for isn't trusted, nor is what it sent kept
+	key := concat(chunkPrefix, payload.Attachment.ID)
+	bNode.messages.db.Delete(key)
+	value, err := aNode.messages.db.Get(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	value[len(value)-1] ^= 1
+	assert.NoError(t, aNode.messages.db.Insert(key, value))
+	_, _, err = bNode.GetAttachment(sent)
+	assert.ErrorIs(t, err, utils.ErrChunkIntegrity)
+	kept, err := bNode.messages.GetChunk(payload.Attachment.ID)
+	assert.NoError(t, err)
+	assert.Nil(t, kept, "kept a chunk that didn't match its ID")
+
+	text, err := aNode.SendPayload(*bAccount, utils.NewTextPayload("Hello World!"))
+	assert.NoError(t, err)
+	_, _, err = bNode.GetAttachment(text)
+	assert.ErrorIs(t, err, ErrNotAnAttachment)
+}
+
+func TestSharedChunksSigned(t *testing.T) {
+	sharer, _ := accounts.GenerateAccount()
+	_, node := setupTestCaesarNode(nil)
+
+	share, err := utils.NewCaesarChunkShare(sharer, utils.CaesarChunk{Data: []byte{1, 2, 3}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	forged := *share
+	forged.Chunk = utils.CaesarChunk{Data: []byte{4, 5, 6}}
+	node.addChunk(&forged)
+	kept, err := node.messages.GetChunk(forged.Chunk.ID())
+	assert.NoError(t, err)
+	assert.Nil(t, kept, "kept a chunk its sharer never signed")
+
+	node.addChunk(share)
+	kept, err = node.messages.GetChunk(share.Chunk.ID())
+	assert.NoError(t, err)
+	if assert.NotNil(t, kept) {
+		assert.Equal(t, share.Chunk.Data, kept.Data)
+	}
+}
@@ -1,28 +1,69 @@
 package caesar
 
 import (
-	"sort"
+	"log"
+	"sync"
 
 	"github.com/adamnite/go-adamnite/common"
+	"github.com/adamnite/go-adamnite/event"
 	"github.com/adamnite/go-adamnite/networking"
+	"github.com/adamnite/go-adamnite/node"
+	"github.com/adamnite/go-adamnite/rpc"
 	"github.com/adamnite/go-adamnite/utils"
 	"github.com/adamnite/go-adamnite/utils/accounts"
 )
 
 type CaesarNode struct {
-	netHandler        *networking.NetNode
-	signerSet         *accounts.Account
-	msgByHash         map[string]*utils.CaesarMessage
-	msgByRecipient    map[common.Address][]*utils.CaesarMessage
-	msgBySender       map[common.Address][]*utils.CaesarMessage
-	NewMessageUpdater func(*utils.CaesarMessage)
+	netHandler           *networking.NetNode
+	signerSet            *accounts.Account
+	messages             *MessageStore
+	groups               map[common.Address]*groupState //groups we follow
+	groupLock            sync.Mutex
+	mailboxRecords       map[common.Address]*utils.CaesarMailboxRecord //where accounts want their messages held
+	hostsMailboxes       bool
+	mailboxLock          sync.Mutex
+	newMessageFeed       event.Feed
+	scope                event.SubscriptionScope
+	NewMessageUpdater    func(*utils.CaesarMessage) //called for every new message, including receipts, edits and deletes
+	AutoDeliveryReceipts bool                       //send a delivery receipt for every message we receive
+	BouncerPolicy        *rpc.Policy                //who can call what on the bouncer, and how often. The default is used if nil
 }
 
-func NewCaesarNode(sendingKey *accounts.Account) *CaesarNode {
+// NewCaesarNode creates a node that keeps its messages in memory.
+func NewCaesarNode(sendingKey *accounts.Account) (*CaesarNode, error) {
+	store, err := NewMemoryMessageStore(0)
+	if err != nil {
+		return nil, err
+	}
+	return NewCaesarNodeWithStore(sendingKey, store), nil
+}
+
+// NewCaesarNodeFromConfig creates a node that keeps its messages in the data directory of config, or in memory
+// if it has none, for as long as config sets.
+func NewCaesarNodeFromConfig(sendingKey *accounts.Account, config *node.Config) (*CaesarNode, error) {
+	store, err := OpenMessageStore(config.CaesarDB(), config.CaesarRetention)
+	if err != nil {
+		return nil, err
+	}
+	quota := config.CaesarChunkQuota
+	if quota == 0 {
+		quota = DefaultChunkQuota
+	}
+	store.SetChunkLimits(config.CaesarChunkRetention, quota)
+	if config.CaesarChunkShareQuota != 0 {
+		store.SetChunkShareQuota(config.CaesarChunkShareQuota)
+	}
+	cn := NewCaesarNodeWithStore(sendingKey, store)
+	cn.BouncerPolicy = config.BouncerRPCPolicy()
+	return cn, nil
+}
+
+// NewCaesarNodeWithStore creates a node that keeps its messages in store, so they are kept across restarts.
+func NewCaesarNodeWithStore(sendingKey *accounts.Account, store *MessageStore) *CaesarNode {
 	cn := CaesarNode{
-		msgByHash:      make(map[string]*utils.CaesarMessage),
-		msgByRecipient: make(map[common.Address][]*utils.CaesarMessage),
-		msgBySender:    make(map[common.Address][]*utils.CaesarMessage),
+		messages:       store,
+		groups:         make(map[common.Address]*groupState),
+		mailboxRecords: make(map[common.Address]*utils.CaesarMailboxRecord),
 	}
 	if sendingKey == nil {
 		cn.signerSet, _ = accounts.GenerateAccount()
@@ -39,37 +80,57 @@ func (cn *CaesarNode) Startup() error {
 	cn.netHandler.AddMessagingCapabilities(
 		cn.AddMessage,
 	)
+	cn.netHandler.AddMailboxCapabilities(
+		cn.AddMailboxRecord,
+		cn.fetchMailbox,
+		cn.ackMailbox,
+	)
+	cn.netHandler.AddChunkCapabilities(
+		cn.addChunk,
+		cn.messages.GetChunk,
+	)
 	return nil
 }
 
+// Close stops the servers of the node, and closes its message store.
+func (cn *CaesarNode) Close() error {
+	cn.netHandler.Close()
+	return cn.messages.Close()
+}
+
 // adds a bouncer, so web based users can access messages through this
-func (cn *CaesarNode) StartBouncer() {
-	cn.netHandler.AddBouncerServer(nil, nil, 0)
-	cn.netHandler.SetBounceServerMessaging(cn.GetMessagesBetween)
+func (cn *CaesarNode) StartBouncer(listen rpc.ListenConfig) error {
+	if err := cn.netHandler.AddBouncerServer(nil, nil, listen); err != nil {
+		return err
+	}
+	if cn.BouncerPolicy != nil {
+		if err := cn.netHandler.SetBouncerPolicy(cn.BouncerPolicy); err != nil {
+			return err
+		}
+	}
+	cn.netHandler.SetBounceServerMessaging(cn.GetMessagesBetween, cn.SendMessage)
+	cn.netHandler.SetBounceServerSubscriptions(cn.SubscribeNewMessages)
+	cn.netHandler.SetBounceServerChunks(cn.messages.GetChunk)
+	return nil
 }
-func (cn CaesarNode) GetConnectionPoint() string {
+func (cn *CaesarNode) GetConnectionPoint() string {
 	return cn.netHandler.GetConnectionString()
 }
-func (cn CaesarNode) GetMessagesBetween(a, b common.Address) []*utils.CaesarMessage {
-	ansMessages := []*utils.CaesarMessage{}
-	for _, msg := range cn.msgBySender[a] {
-		if msg.To.Address == b {
-			ansMessages = append(ansMessages, msg)
-		}
-	}
-	for _, msg := range cn.msgBySender[b] {
-		if msg.To.Address == a {
-			ansMessages = append(ansMessages, msg)
-		}
+func (cn *CaesarNode) GetMessagesBetween(a, b common.Address) []*utils.CaesarMessage {
+	ansMessages, _, err := cn.messages.MessagesBetween(a, b, "", 0)
+	if err != nil {
+		log.Printf("[Caesar] unable to read messages: %v", err)
+		return []*utils.CaesarMessage{}
 	}
-	//sort them to be in order, cause why not
-	sort.Slice(ansMessages, func(i, j int) bool {
-		return ansMessages[i].InitialTime < ansMessages[j].InitialTime
-	}) //TODO: why not might be for important performance reasons...
-
 	return ansMessages
 }
 
+// GetMessagesBetweenPage returns up to limit messages between a and b, oldest first, starting after the cursor.
+// The cursor returned is passed to get the next page, and is empty once there are no more messages.
+func (cn *CaesarNode) GetMessagesBetweenPage(a, b common.Address, cursor string, limit int) ([]*utils.CaesarMessage, string, error) {
+	return cn.messages.MessagesBetween(a, b, cursor, limit)
+}
+
 // connect this to a seed node that it can propagate from
 func (cn *CaesarNode) ConnectToNetworkFrom(seedConnectionPoint string) error {
 	//TODO: once we have a seed node running, we should add a " seedConnectionPoint == '', use our known default"
@@ -95,36 +156,56 @@ func (cn *CaesarNode) FillNetworking(overrideSetLimits bool) error {
 }
 
 func (cn *CaesarNode) AddMessage(msg *utils.CaesarMessage) {
-	if _, exists := cn.msgByHash[string(msg.Hash())]; exists {
+	if !msg.VerifyNew() {
+		log.Printf("[Caesar] dropping message with an invalid signature, or of the legacy protocol")
+		return
+	}
+	if msg.Version == utils.CaesarVersionGroup && !cn.fromGroupMember(msg) {
+		log.Printf("[Caesar] dropping a group message from someone outside the group")
 		return
 	}
+	stored, err := cn.messages.Put(msg)
+	if err != nil {
+		log.Printf("[Caesar] unable to store message: %v", err)
+		return
+	}
+	if !stored {
+		return
+	}
+	if msg.Version == utils.CaesarVersionGroupUpdate {
+		cn.applyGroupUpdate(msg)
+	}
+	cn.applyPayload(msg)
+	cn.applyPending(msg)
+	if deleted, _ := cn.messages.IsDeleted(msg.Hash()); deleted {
+		return
+	}
+	if cn.shouldHold(msg.To.Address) {
+		if err := cn.messages.Hold(msg); err != nil {
+			log.Printf("[Caesar] unable to hold message: %v", err)
+		}
+	}
+	cn.newMessageFeed.Send(msg)
 	if cn.NewMessageUpdater != nil {
 		cn.NewMessageUpdater(msg)
 	}
+}
 
-	cn.msgByHash[string(msg.Hash())] = msg
-	if msgArray, exists := cn.msgByRecipient[msg.To.Address]; exists {
-		cn.msgByRecipient[msg.To.Address] = []*utils.CaesarMessage{msg}
-	} else {
-		cn.msgByRecipient[msg.To.Address] = append(msgArray, msg)
-	}
-	if msgArray, exists := cn.msgBySender[msg.From.Address]; exists {
-		cn.msgBySender[msg.From.Address] = []*utils.CaesarMessage{msg}
-	} else {
-		cn.msgBySender[msg.From.Address] = append(msgArray, msg)
-	}
+// SubscribeNewMessages sends every message stored from now on to the channel.
+func (cn *CaesarNode) SubscribeNewMessages(ch chan<- *utils.CaesarMessage) event.Subscription {
+	return cn.scope.Track(cn.newMessageFeed.Subscribe(ch))
 }
+
+// SendMessage shares the message with the network. Messages marked HasHostingServer only go to the recipient and
+// their mailboxes, if we know of them.
 func (cn *CaesarNode) SendMessage(msg *utils.CaesarMessage) error {
 	cn.AddMessage(msg)
+	if record := cn.GetMailboxRecord(msg.To.Address); msg.HasHostingServer && record != nil && len(record.Mailboxes) != 0 {
+		return cn.sendToMailboxes(msg, record)
+	}
 	return cn.netHandler.Propagate(msg)
 }
 func (cn *CaesarNode) Send(to accounts.Account, message string) error {
-	msg, err := utils.NewCaesarMessage(to, *cn.signerSet, message)
-	if err != nil {
-		return err
-	}
-	if err := msg.Sign(); err != nil {
-		return err
-	}
-	return cn.SendMessage(msg)
+	_, err := cn.SendPayload(to, utils.NewTextPayload(message))
+	return err
 }
@@ -2,10 +2,13 @@ package caesar
 
 import (
 	"math"
+	netrpc "net/rpc"
 	"testing"
 
 	"github.com/adamnite/go-adamnite/common"
 	"github.com/adamnite/go-adamnite/networking"
+	"github.com/adamnite/go-adamnite/node"
+	"github.com/adamnite/go-adamnite/rpc"
 	"github.com/adamnite/go-adamnite/utils"
 	"github.com/adamnite/go-adamnite/utils/accounts"
 	"github.com/stretchr/testify/assert"
@@ -13,7 +16,10 @@ import (
 
 func setupTestCaesarNode(autoConnectSeed *networking.Contact) (*accounts.Account, *CaesarNode) {
 	account, _ := accounts.GenerateAccount()
-	node := NewCaesarNode(account)
+	node, err := NewCaesarNode(account)
+	if err != nil {
+		panic(err)
+	}
 	node.Startup()
 	if autoConnectSeed != nil {
 		node.netHandler.ConnectToContact(autoConnectSeed)
@@ -38,16 +44,19 @@ func TestTwoServersMessagingOpenly(t *testing.T) {
 	if err := aNode.SendMessage(testMessage); err != nil {
 		t.Fatal(err)
 	}
+	count, err := bNode.messages.Count()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, count, "b node appears to have not received the message")
+	received, _, err := bNode.messages.MessagesTo(bAccount.Address, "", 0)
+	assert.NoError(t, err)
 	assert.Equal(t, 1,
-		len(bNode.msgByHash),
-		"b node appears to have not received the message",
-	)
-	assert.Equal(t, 1,
-		len(bNode.msgByRecipient),
+		len(received),
 		"b node appears to have not received the message",
 	)
+	sent, _, err := bNode.messages.MessagesFrom(aAccount.Address, "", 0)
+	assert.NoError(t, err)
 	assert.Equal(t, 1,
-		len(bNode.msgBySender),
+		len(sent),
 		"b node appears to have not received the message",
 	)
 }
@@ -79,6 +88,97 @@ func TestManyOpenMessages(t *testing.T) {
 
 	for _, node := range testingNodes {
 		//check everyone has the same messages, and all of them
-		assert.Equal(t, math.Pow(float64(len(testingNodes)), 2), float64(len(node.msgByHash)), "not all messages seen")
+		count, err := node.messages.Count()
+		assert.NoError(t, err)
+		assert.Equal(t, math.Pow(float64(len(testingNodes)), 2), float64(count), "not all messages seen")
+	}
+}
+
+func TestInvalidSignatureDropped(t *testing.T) {
+	aAccount, aNode := setupTestCaesarNode(nil)
+	bAccount, _ := accounts.GenerateAccount()
+	msg, err := utils.NewCaesarMessage(*bAccount, *aAccount, "Hello World!")
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg.InitialTime++ //the signature no longer matches
+	aNode.AddMessage(msg)
+	count, err := aNode.messages.Count()
+	assert.NoError(t, err)
+	assert.Equal(t, 0, count, "message with a bad signature was stored")
+}
+
+func TestLegacyMessageDropped(t *testing.T) {
+	aAccount, aNode := setupTestCaesarNode(nil)
+	bAccount, _ := accounts.GenerateAccount()
+	encrypted, err := aAccount.Encrypt([]byte("Hello World!"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	signature, err := bAccount.Sign(encrypted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := utils.NewSignedCaesarMessage(accounts.AccountFromPubBytes(aAccount.PublicKey), accounts.AccountFromPubBytes(bAccount.PublicKey), encrypted, signature)
+	assert.True(t, msg.Verify())
+	aNode.AddMessage(msg)
+	count, err := aNode.messages.Count()
+	assert.NoError(t, err)
+	assert.Equal(t, 0, count, "legacy message was stored from new traffic")
+}
+
+func TestMessagesKeptOnDisk(t *testing.T) {
+	config := &node.Config{Name: "caesar", DataDir: t.TempDir(), CaesarChunkQuota: 20}
+	account, _ := accounts.GenerateAccount()
+	sender, _ := accounts.GenerateAccount()
+	first, err := NewCaesarNodeFromConfig(account, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, err := utils.NewCaesarMessage(*account, *sender, "Hello World!")
+	if err != nil {
+		t.Fatal(err)
+	}
+	first.AddMessage(msg)
+	assert.NoError(t, first.Close())
+
+	reopened, err := NewCaesarNodeFromConfig(account, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	kept, err := reopened.messages.Has(msg.Hash())
+	assert.NoError(t, err)
+	assert.True(t, kept, "the message wasn't kept across a restart")
+	assert.Equal(t, 20, reopened.messages.chunkQuota, "the chunk quota of the config wasn't used")
+}
+
+func TestBouncerPolicyApplied(t *testing.T) {
+	policy := rpc.DefaultBouncerPolicy()
+	policy.Access[rpc.BouncerGetChainIDEndpoint] = rpc.AccessAdmin
+	policy.AdminTokens = []string{"secret"}
+	account, _ := accounts.GenerateAccount()
+	cn, err := NewCaesarNodeFromConfig(account, &node.Config{BouncerPolicy: policy})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cn.StartBouncer(rpc.ListenConfig{}); err != nil {
+		t.Fatal(err)
+	}
+	defer cn.Close()
+
+	client, err := netrpc.Dial("tcp", cn.netHandler.GetBouncerString())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	output := []byte{}
+	err = client.Call(rpc.BouncerGetChainIDEndpoint, []byte{}, &output)
+	if assert.Error(t, err, "the bouncer policy of the config wasn't applied") {
+		assert.Equal(t, rpc.ErrUnauthorized.Error(), err.Error())
+	}
+	token, _ := rpc.NewTokenParams("secret", []byte{})
+	if err := client.Call(rpc.BouncerGetChainIDEndpoint, token, &output); err != nil {
+		assert.NotEqual(t, rpc.ErrUnauthorized.Error(), err.Error(), "the admin token of the config was refused")
 	}
 }
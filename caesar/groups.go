@@ -0,0 +1,340 @@
+package caesar
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/adamnite/go-adamnite/common"
+	"github.com/adamnite/go-adamnite/utils"
+	"github.com/adamnite/go-adamnite/utils/accounts"
+)
+
+var (
+	ErrUnknownGroup       = fmt.Errorf("not following a group with that ID")
+	ErrMissingGroupKey    = fmt.Errorf("the group key the message was sealed with is not known")
+	ErrGroupKeyNotRotated = fmt.Errorf("the group key has not been rotated since the last member left")
+)
+
+// groupState is what a node knows of a group it follows: its latest state, and every group key it was given.
+type groupState struct {
+	group  utils.CaesarGroup
+	keys   map[uint64][]byte            // group keys by epoch, kept so older messages can still be read
+	epochs map[uint64]utils.CaesarGroup // the group as of every epoch, to check who could send in it
+}
+
+func newGroupState(group utils.CaesarGroup) *groupState {
+	return &groupState{
+		group:  group,
+		keys:   map[uint64][]byte{},
+		epochs: map[uint64]utils.CaesarGroup{group.Epoch: group},
+	}
+}
+
+// advance moves the group on to the update.
+func (gs *groupState) advance(update utils.CaesarGroup) {
+	gs.group = update
+	gs.epochs[update.Epoch] = update
+}
+
+// sentByMember reports whether the sender of a group message was a member in the epoch it was sealed for. Messages
+// for an epoch we haven't seen can't be checked yet, so known is false for them.
+func (gs *groupState) sentByMember(msg *utils.CaesarMessage) (member bool, known bool) {
+	epoch, err := msg.GroupEpoch()
+	if err != nil {
+		return false, true
+	}
+	group, known := gs.epochs[epoch]
+	if !known {
+		return false, false
+	}
+	return group.IsMember(msg.From.Address), true
+}
+
+// latestKey returns the newest group key we have, and the epoch it is for.
+func (gs *groupState) latestKey() (uint64, []byte, bool) {
+	var latestEpoch uint64
+	var latest []byte
+	for epoch, key := range gs.keys {
+		if latest == nil || epoch > latestEpoch {
+			latestEpoch, latest = epoch, key
+		}
+	}
+	return latestEpoch, latest, latest != nil
+}
+
+// CreateGroup creates a group with us as its admin, and shares it with its members.
+func (cn *CaesarNode) CreateGroup(name string, members ...accounts.Account) (common.Address, error) {
+	group, key, err := utils.NewCaesarGroup(name, *cn.signerSet, members...)
+	if err != nil {
+		return common.Address{}, err
+	}
+	update, err := utils.NewCaesarGroupUpdate(*group, *cn.signerSet)
+	if err != nil {
+		return common.Address{}, err
+	}
+	cn.groupLock.Lock()
+	state := newGroupState(*group)
+	state.keys[group.Epoch] = key
+	cn.groups[group.ID] = state
+	cn.groupLock.Unlock()
+	return group.ID, cn.SendMessage(update)
+}
+
+// JoinGroup starts following a group we were added to, catching up on every update to it we have seen so far.
+// Updates for groups that aren't followed are still kept, so a group can be joined after the invitation arrived.
+func (cn *CaesarNode) JoinGroup(groupID common.Address) error {
+	state, _, err := cn.catchUpGroup(groupID, nil)
+	if err != nil {
+		return err
+	}
+	if state == nil || !state.group.IsMember(cn.signerSet.Address) {
+		return utils.ErrNotAGroupMember
+	}
+
+	cn.groupLock.Lock()
+	cn.groups[groupID] = state
+	needsRotation := cn.needsRotation(state)
+	cn.groupLock.Unlock()
+	if needsRotation {
+		return cn.rotateGroupKey(groupID)
+	}
+	return nil
+}
+
+// LeaveGroup removes us from the group. The admin rotates the key once they see we left.
+func (cn *CaesarNode) LeaveGroup(groupID common.Address) error {
+	cn.groupLock.Lock()
+	state, exists := cn.groups[groupID]
+	if !exists {
+		cn.groupLock.Unlock()
+		return ErrUnknownGroup
+	}
+	next := state.group.Next(state.group.Without(cn.signerSet.Address))
+	cn.groupLock.Unlock()
+	return cn.sendGroupUpdate(*next)
+}
+
+// AddGroupMember adds the account to the group, rotating the group key. Only the admin can do so.
+func (cn *CaesarNode) AddGroupMember(groupID common.Address, member accounts.Account) error {
+	cn.groupLock.Lock()
+	state, exists := cn.groups[groupID]
+	if !exists {
+		cn.groupLock.Unlock()
+		return ErrUnknownGroup
+	}
+	members := state.group.Without(member.Address)
+	members = append(members, accounts.AccountFromPubBytes(member.PublicKey))
+	next := state.group.Next(members)
+	cn.groupLock.Unlock()
+	if _, err := next.Rotate(); err != nil {
+		return err
+	}
+	return cn.sendGroupUpdate(*next)
+}
+
+// RemoveGroupMember removes the member from the group, rotating the group key. Only the admin can do so.
+func (cn *CaesarNode) RemoveGroupMember(groupID common.Address, member common.Address) error {
+	cn.groupLock.Lock()
+	state, exists := cn.groups[groupID]
+	if !exists {
+		cn.groupLock.Unlock()
+		return ErrUnknownGroup
+	}
+	next := state.group.Next(state.group.Without(member))
+	cn.groupLock.Unlock()
+	if _, err := next.Rotate(); err != nil {
+		return err
+	}
+	return cn.sendGroupUpdate(*next)
+}
+
+// rotateGroupKey moves the group to a new epoch with a new key, and the same members.
+func (cn *CaesarNode) rotateGroupKey(groupID common.Address) error {
+	cn.groupLock.Lock()
+	state, exists := cn.groups[groupID]
+	if !exists {
+		cn.groupLock.Unlock()
+		return ErrUnknownGroup
+	}
+	next := state.group.Next(state.group.Members)
+	cn.groupLock.Unlock()
+	if _, err := next.Rotate(); err != nil {
+		return err
+	}
+	return cn.sendGroupUpdate(*next)
+}
+
+func (cn *CaesarNode) sendGroupUpdate(group utils.CaesarGroup) error {
+	update, err := utils.NewCaesarGroupUpdate(group, *cn.signerSet)
+	if err != nil {
+		return err
+	}
+	return cn.SendMessage(update)
+}
+
+// GetGroup returns the latest state of a group we follow.
+func (cn *CaesarNode) GetGroup(groupID common.Address) (utils.CaesarGroup, error) {
+	cn.groupLock.Lock()
+	defer cn.groupLock.Unlock()
+	state, exists := cn.groups[groupID]
+	if !exists {
+		return utils.CaesarGroup{}, ErrUnknownGroup
+	}
+	return state.group, nil
+}
+
+// SendToGroup sends a message to every member of the group, sealed with the newest group key.
+func (cn *CaesarNode) SendToGroup(groupID common.Address, message string) error {
+	cn.groupLock.Lock()
+	state, exists := cn.groups[groupID]
+	if !exists {
+		cn.groupLock.Unlock()
+		return ErrUnknownGroup
+	}
+	group := state.group
+	epoch, key, hasKey := state.latestKey()
+	cn.groupLock.Unlock()
+	if !group.IsMember(cn.signerSet.Address) {
+		return utils.ErrNotAGroupMember
+	}
+	if !hasKey {
+		return ErrMissingGroupKey
+	}
+	if epoch != group.Epoch {
+		//someone left, and the admin hasn't given us the new key yet. Sending now would let them read it
+		return ErrGroupKeyNotRotated
+	}
+	msg, err := utils.NewCaesarGroupMessage(group, epoch, key, *cn.signerSet, message)
+	if err != nil {
+		return err
+	}
+	return cn.SendMessage(msg)
+}
+
+// GetGroupHistory returns up to limit messages and updates sent to the group, oldest first, starting after the cursor.
+func (cn *CaesarNode) GetGroupHistory(groupID common.Address, cursor string, limit int) ([]*utils.CaesarMessage, string, error) {
+	return cn.messages.MessagesTo(groupID, cursor, limit)
+}
+
+// GetGroupMessageString decrypts a message sent to a group we follow, by someone who was a member when they sent it.
+func (cn *CaesarNode) GetGroupMessageString(msg *utils.CaesarMessage) (string, error) {
+	epoch, err := msg.GroupEpoch()
+	if err != nil {
+		return "", err
+	}
+	cn.groupLock.Lock()
+	state, exists := cn.groups[msg.To.Address]
+	var key []byte
+	member := false
+	if exists {
+		key = state.keys[epoch]
+		member, _ = state.sentByMember(msg)
+	}
+	cn.groupLock.Unlock()
+	if !exists {
+		return "", ErrUnknownGroup
+	}
+	if key == nil {
+		return "", ErrMissingGroupKey
+	}
+	//the key only shows the sender was a member at some point, not in the epoch they sent in
+	if !member || !msg.Verify() {
+		return "", utils.ErrNotAGroupMember
+	}
+	text, err := msg.GetGroupMessage(key)
+	return string(text), err
+}
+
+// takeGroupKey keeps the group key of the update, if it was wrapped for us.
+func (cn *CaesarNode) takeGroupKey(state *groupState, update *utils.CaesarGroup) {
+	if len(update.Keys) == 0 || !update.IsMember(cn.signerSet.Address) {
+		return
+	}
+	key, err := update.OpenKey(*cn.signerSet)
+	if err != nil {
+		log.Printf("[Caesar] unable to open the key of group %v: %v", update.ID.Hex(), err)
+		return
+	}
+	state.keys[update.Epoch] = key
+}
+
+// needsRotation is true when someone left the group, and it is up to us to give the others a new key.
+func (cn *CaesarNode) needsRotation(state *groupState) bool {
+	return len(state.group.Keys) == 0 &&
+		state.group.Admin == cn.signerSet.Address &&
+		state.group.IsMember(cn.signerSet.Address)
+}
+
+// catchUpGroup applies every stored update that follows the state of the group. Updates can arrive out of order,
+// so the history is read again until no more of them apply. A nil state is started from the first epoch of the group.
+// It returns the new state, and whether any update was applied.
+func (cn *CaesarNode) catchUpGroup(groupID common.Address, state *groupState) (*groupState, bool, error) {
+	history, _, err := cn.messages.MessagesTo(groupID, "", 0)
+	if err != nil {
+		return state, false, err
+	}
+	applied := false
+	for progress := true; progress; {
+		progress = false
+		for _, msg := range history {
+			if msg.Version != utils.CaesarVersionGroupUpdate || !msg.Verify() {
+				continue
+			}
+			update, err := msg.GetGroupUpdate()
+			if err != nil {
+				continue
+			}
+			if state == nil {
+				if update.CheckGenesis(msg.From.Address) != nil {
+					continue
+				}
+				state = newGroupState(*update)
+			} else if state.group.CheckUpdate(*update, msg.From.Address) == nil {
+				state.advance(*update)
+			} else {
+				continue
+			}
+			cn.takeGroupKey(state, update)
+			progress, applied = true, true
+		}
+	}
+	return state, applied, nil
+}
+
+// fromGroupMember reports whether a group message could have come from a member of the group. Only groups we follow
+// can be checked, and only in the epochs we've seen, so the rest are let through to be checked once they're read.
+func (cn *CaesarNode) fromGroupMember(msg *utils.CaesarMessage) bool {
+	cn.groupLock.Lock()
+	defer cn.groupLock.Unlock()
+	state, exists := cn.groups[msg.To.Address]
+	if !exists {
+		return true
+	}
+	member, known := state.sentByMember(msg)
+	return member || !known
+}
+
+// applyGroupUpdate moves a group we follow forward once an update to it is stored.
+func (cn *CaesarNode) applyGroupUpdate(msg *utils.CaesarMessage) {
+	groupID := msg.To.Address
+	cn.groupLock.Lock()
+	state, exists := cn.groups[groupID]
+	if !exists {
+		cn.groupLock.Unlock()
+		return
+	}
+	_, applied, err := cn.catchUpGroup(groupID, state)
+	//only the update that moved the group on can start a rotation, so there is never more than one
+	needsRotation := applied && cn.needsRotation(state)
+	cn.groupLock.Unlock()
+	if err != nil {
+		log.Printf("[Caesar] unable to update group %v: %v", groupID.Hex(), err)
+		return
+	}
+
+	if needsRotation {
+		if err := cn.rotateGroupKey(groupID); err != nil {
+			log.Printf("[Caesar] unable to rotate the key of group %v: %v", groupID.Hex(), err)
+		}
+	}
+}
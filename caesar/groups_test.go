@@ -0,0 +1,100 @@
+package caesar
+
+import (
+	"testing"
+	"time"
+
+	"github.com/adamnite/go-adamnite/common"
+	"github.com/adamnite/go-adamnite/networking"
+	"github.com/adamnite/go-adamnite/utils"
+	"github.com/adamnite/go-adamnite/utils/accounts"
+	"github.com/stretchr/testify/assert"
+)
+
+// groupEpoch is the epoch of the group the node follows, or -1 if it doesn't
+func groupEpoch(node *CaesarNode, groupID common.Address) int {
+	group, err := node.GetGroup(groupID)
+	if err != nil {
+		return -1
+	}
+	return int(group.Epoch)
+}
+
+func TestGroupConversation(t *testing.T) {
+	seedNode := networking.NewNetNode(common.Address{0, 1, 2, 3, 4, 5, 6, 7, 8, 9})
+	seedNode.AddServer()
+	seedContact := seedNode.GetOwnContact()
+
+	_, aNode := setupTestCaesarNode(&seedContact)
+	bAccount, bNode := setupTestCaesarNode(&seedContact)
+	cAccount, cNode := setupTestCaesarNode(&seedContact)
+	seedNode.FillOpenConnections()
+
+	groupID, err := aNode.CreateGroup("test", *bAccount, *cAccount)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, node := range []*CaesarNode{bNode, cNode} {
+		assert.Eventually(t, func() bool { return node.JoinGroup(groupID) == nil }, time.Second, 10*time.Millisecond)
+	}
+
+	assert.NoError(t, aNode.SendToGroup(groupID, "Hello World!"))
+	assert.Eventually(t, func() bool {
+		history, _, _ := bNode.GetGroupHistory(groupID, "", 0)
+		return len(history) == 2
+	}, time.Second, 10*time.Millisecond)
+	history, _, err := bNode.GetGroupHistory(groupID, "", 0)
+	assert.NoError(t, err)
+	text, err := bNode.GetGroupMessageString(history[1])
+	assert.NoError(t, err)
+	assert.Equal(t, "Hello World!", text)
+
+	//once c leaves, a rotates the key so c can't read anything sent after
+	assert.NoError(t, cNode.LeaveGroup(groupID))
+	for _, node := range []*CaesarNode{aNode, bNode, cNode} {
+		assert.Eventually(t, func() bool { return groupEpoch(node, groupID) == 2 }, time.Second, 10*time.Millisecond)
+	}
+	group, err := bNode.GetGroup(groupID)
+	assert.NoError(t, err)
+	assert.False(t, group.IsMember(cAccount.Address))
+	assert.ErrorIs(t, cNode.SendToGroup(groupID, "still here?"), utils.ErrNotAGroupMember)
+
+	assert.NoError(t, bNode.SendToGroup(groupID, "just us now"))
+	var sent *utils.CaesarMessage
+	assert.Eventually(t, func() bool {
+		history, _, _ := cNode.GetGroupHistory(groupID, "", 0)
+		for _, msg := range history {
+			if msg.From.Address == bAccount.Address && msg.Version == utils.CaesarVersionGroup {
+				sent = msg
+			}
+		}
+		return sent != nil
+	}, time.Second, 10*time.Millisecond)
+	_, err = cNode.GetGroupMessageString(sent)
+	assert.ErrorIs(t, err, ErrMissingGroupKey)
+	text, err = aNode.GetGroupMessageString(sent)
+	assert.NoError(t, err)
+	assert.Equal(t, "just us now", text)
+
+	//c can still read what was sent while they were a member
+	text, err = cNode.GetGroupMessageString(history[1])
+	assert.NoError(t, err)
+	assert.Equal(t, "Hello World!", text)
+
+	//messages from outside the group are refused, even sealed with the right key
+	outsider, _ := accounts.GenerateAccount()
+	group, _ = aNode.GetGroup(groupID)
+	aNode.groupLock.Lock()
+	epoch, key, _ := aNode.groups[groupID].latestKey()
+	aNode.groupLock.Unlock()
+	forged, err := utils.NewCaesarGroupMessage(group, epoch, key, *outsider, "let me in")
+	if err != nil {
+		t.Fatal(err)
+	}
+	aNode.AddMessage(forged)
+	stored, err := aNode.messages.Has(forged.Hash())
+	assert.NoError(t, err)
+	assert.False(t, stored, "message from outside the group stored")
+	_, err = aNode.GetGroupMessageString(forged)
+	assert.ErrorIs(t, err, utils.ErrNotAGroupMember)
+}
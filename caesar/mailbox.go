@@ -0,0 +1,140 @@
+package caesar
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/adamnite/go-adamnite/common"
+	"github.com/adamnite/go-adamnite/rpc"
+	"github.com/adamnite/go-adamnite/utils"
+)
+
+var ErrNoMailboxes = fmt.Errorf("no mailboxes have been set for this account")
+
+// HostMailboxes lets accounts name this node as one of their mailboxes. Messages to those accounts are then held
+// until the account fetches and acks them.
+func (cn *CaesarNode) HostMailboxes() {
+	cn.mailboxLock.Lock()
+	defer cn.mailboxLock.Unlock()
+	cn.hostsMailboxes = true
+}
+
+// SetMailboxes names the nodes that hold messages for us while we are offline, and tells the network about them.
+func (cn *CaesarNode) SetMailboxes(mailboxes ...common.Address) error {
+	record, err := utils.NewCaesarMailboxRecord(*cn.signerSet, mailboxes...)
+	if err != nil {
+		return err
+	}
+	cn.AddMailboxRecord(record)
+	return cn.netHandler.Propagate(record)
+}
+
+// AddMailboxRecord keeps the record if it is signed by its owner, and newer than the one we have.
+func (cn *CaesarNode) AddMailboxRecord(record *utils.CaesarMailboxRecord) {
+	if !record.Verify() {
+		log.Printf("[Caesar] dropping mailbox record with an invalid signature")
+		return
+	}
+	cn.mailboxLock.Lock()
+	defer cn.mailboxLock.Unlock()
+	if known, exists := cn.mailboxRecords[record.Owner.Address]; exists && known.IssuedAt >= record.IssuedAt {
+		return
+	}
+	cn.mailboxRecords[record.Owner.Address] = record
+}
+
+// GetMailboxRecord returns the latest record of the account, or nil if none is known.
+func (cn *CaesarNode) GetMailboxRecord(owner common.Address) *utils.CaesarMailboxRecord {
+	cn.mailboxLock.Lock()
+	defer cn.mailboxLock.Unlock()
+	return cn.mailboxRecords[owner]
+}
+
+// shouldHold reports whether the recipient has named us as one of their mailboxes.
+func (cn *CaesarNode) shouldHold(recipient common.Address) bool {
+	cn.mailboxLock.Lock()
+	defer cn.mailboxLock.Unlock()
+	record, exists := cn.mailboxRecords[recipient]
+	return cn.hostsMailboxes && exists && record.HasMailbox(cn.signerSet.Address)
+}
+
+// fetchMailbox answers a request for the messages we hold.
+func (cn *CaesarNode) fetchMailbox(request utils.CaesarMailboxRequest) ([]*utils.CaesarMessage, error) {
+	if !cn.shouldHold(request.Owner.Address) {
+		return nil, rpc.ErrNotAMailbox
+	}
+	if err := request.Verify(time.Now()); err != nil {
+		return nil, err
+	}
+	held, _, err := cn.messages.Held(request.Owner.Address, "", 0)
+	return held, err
+}
+
+// ackMailbox stops holding the messages that were received.
+func (cn *CaesarNode) ackMailbox(request utils.CaesarMailboxRequest) error {
+	if err := request.Verify(time.Now()); err != nil {
+		return err
+	}
+	return cn.messages.Release(request.Owner.Address, request.Hashes)
+}
+
+// FetchMail collects the messages our mailboxes hold for us, and acks them so they are dropped.
+// It returns how many messages were received.
+func (cn *CaesarNode) FetchMail() (int, error) {
+	record := cn.GetMailboxRecord(cn.signerSet.Address)
+	if record == nil || len(record.Mailboxes) == 0 {
+		return 0, ErrNoMailboxes
+	}
+	request, err := utils.NewCaesarMailboxRequest(*cn.signerSet)
+	if err != nil {
+		return 0, err
+	}
+	received := 0
+	var lastErr error
+	for _, mailbox := range record.Mailboxes {
+		messages, err := cn.netHandler.FetchCaesarMailbox(mailbox, *request)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		hashes := [][]byte{}
+		for _, msg := range messages {
+			if !msg.VerifyNew() || msg.To.Address != cn.signerSet.Address {
+				continue
+			}
+			cn.AddMessage(msg)
+			hashes = append(hashes, msg.Hash())
+		}
+		received += len(hashes)
+		if len(hashes) == 0 {
+			continue
+		}
+		ack, err := utils.NewCaesarMailboxRequest(*cn.signerSet, hashes...)
+		if err != nil {
+			return received, err
+		}
+		if err := cn.netHandler.AckCaesarMailbox(mailbox, *ack); err != nil {
+			lastErr = err
+		}
+	}
+	return received, lastErr
+}
+
+// sendToMailboxes delivers the message to its recipient and to each of their mailboxes, instead of everyone.
+// It only fails if none of them could be reached.
+func (cn *CaesarNode) sendToMailboxes(msg *utils.CaesarMessage, record *utils.CaesarMailboxRecord) error {
+	var lastErr error
+	delivered := false
+	for _, destination := range append([]common.Address{msg.To.Address}, record.Mailboxes...) {
+		if err := cn.netHandler.PropagateTo(msg, destination); err != nil {
+			lastErr = err
+			continue
+		}
+		delivered = true
+	}
+	if delivered {
+		return nil
+	}
+	return lastErr
+}
@@ -0,0 +1,93 @@
+package caesar
+
+import (
+	"testing"
+	"time"
+
+	"github.com/adamnite/go-adamnite/common"
+	"github.com/adamnite/go-adamnite/networking"
+	"github.com/adamnite/go-adamnite/utils"
+	"github.com/adamnite/go-adamnite/utils/accounts"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMailboxDelivery(t *testing.T) {
+	seedNode := networking.NewNetNode(common.Address{0, 1, 2, 3, 4, 5, 6, 7, 8, 9})
+	seedNode.AddServer()
+	seedContact := seedNode.GetOwnContact()
+
+	aAccount, aNode := setupTestCaesarNode(&seedContact)
+	mailboxAccount, mailboxNode := setupTestCaesarNode(&seedContact)
+	_, bystanderNode := setupTestCaesarNode(&seedContact)
+	mailboxNode.HostMailboxes()
+	seedNode.FillOpenConnections()
+
+	//b is offline, but someone shares the record they signed earlier
+	bAccount, _ := accounts.GenerateAccount()
+	record, err := utils.NewCaesarMailboxRecord(*bAccount, mailboxAccount.Address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	aNode.AddMailboxRecord(record)
+	assert.NoError(t, aNode.netHandler.Propagate(record))
+	assert.Eventually(t, func() bool { return mailboxNode.GetMailboxRecord(bAccount.Address) != nil }, time.Second, 10*time.Millisecond)
+
+	assert.NoError(t, aNode.Send(*bAccount, "Hello World!"))
+	assert.Eventually(t, func() bool {
+		held, _, _ := mailboxNode.messages.Held(bAccount.Address, "", 0)
+		return len(held) == 1
+	}, time.Second, 10*time.Millisecond)
+	count, err := bystanderNode.messages.Count()
+	assert.NoError(t, err)
+	assert.Equal(t, 0, count, "message was shared with everyone instead of the mailbox")
+
+	//someone else can't fetch b's messages
+	forged, err := utils.NewCaesarMailboxRequest(*aAccount)
+	if err != nil {
+		t.Fatal(err)
+	}
+	forged.Owner = accounts.AccountFromPubBytes(bAccount.PublicKey)
+	_, err = mailboxNode.fetchMailbox(*forged)
+	assert.ErrorIs(t, err, utils.ErrMailboxRequestInvalid)
+
+	//b comes online, and collects their messages
+	bNode, err := NewCaesarNode(bAccount)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bNode.Startup()
+	bNode.netHandler.ConnectToContact(&seedContact)
+	assert.NoError(t, bNode.netHandler.SprawlConnections(2, 0))
+	bNode.AddMailboxRecord(record)
+	received, err := bNode.FetchMail()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, received)
+	messages := bNode.GetMessagesBetween(aAccount.Address, bAccount.Address)
+	if assert.Len(t, messages, 1) {
+		text, err := messages[0].GetMessageString(*bAccount)
+		assert.NoError(t, err)
+		assert.Equal(t, "Hello World!", text)
+	}
+
+	held, _, err := mailboxNode.messages.Held(bAccount.Address, "", 0)
+	assert.NoError(t, err)
+	assert.Empty(t, held, "acked messages are still held")
+}
+
+func TestMailboxRecordsReplaced(t *testing.T) {
+	_, node := setupTestCaesarNode(nil)
+	owner, _ := accounts.GenerateAccount()
+	older, _ := utils.NewCaesarMailboxRecord(*owner, common.Address{1})
+	time.Sleep(time.Millisecond)
+	newer, _ := utils.NewCaesarMailboxRecord(*owner, common.Address{2})
+
+	node.AddMailboxRecord(newer)
+	node.AddMailboxRecord(older)
+	assert.Equal(t, newer, node.GetMailboxRecord(owner.Address), "older record replaced a newer one")
+
+	forged := *newer
+	forged.Mailboxes = []common.Address{{3}}
+	forged.IssuedAt++
+	node.AddMailboxRecord(&forged)
+	assert.Equal(t, newer, node.GetMailboxRecord(owner.Address), "unsigned record was kept")
+}
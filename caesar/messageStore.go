@@ -0,0 +1,579 @@
+package caesar
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/adamnite/go-adamnite/common"
+	"github.com/adamnite/go-adamnite/database"
+	"github.com/adamnite/go-adamnite/utils"
+	encoding "github.com/vmihailenco/msgpack/v5"
+)
+
+var (
+	messagePrefix      = []byte("caesar-msg-")
+	senderIndexPrefix  = []byte("caesar-from-")
+	recipientPrefix    = []byte("caesar-to-")
+	timeIndexPrefix    = []byte("caesar-time-")
+	conversationPrefix = []byte("caesar-conv-")
+	heldPrefix         = []byte("caesar-held-")
+	deletedPrefix      = []byte("caesar-deleted-")
+	editPrefix         = []byte("caesar-edit-")
+	receiptPrefix      = []byte("caesar-receipt-")
+	pendingPrefix      = []byte("caesar-pending-")
+	chunkPrefix        = []byte("caesar-chunk-")
+
+	ErrInvalidCursor   = fmt.Errorf("the cursor passed is not one returned by this store")
+	ErrChunkStoreFull  = fmt.Errorf("the attachment chunks stored have reached their quota")
+	ErrChunkSenderFull = fmt.Errorf("the account sharing the chunk has reached its share of the chunks stored")
+)
+
+const (
+	DefaultChunkRetention  = 7 * 24 * time.Hour     // how long chunks are kept for, when messages are kept forever
+	DefaultChunkQuota      = 256 << 20              // most bytes of attachment chunks stored
+	DefaultChunkShareQuota = DefaultChunkQuota / 16 // most bytes of chunks stored that were shared by any one account
+)
+
+// MessageStore keeps Caesar messages in a LevelDB database. Messages are indexed by sender, recipient,
+// conversation and time, every index being ordered by the time the message was first sent.
+type MessageStore struct {
+	db             *database.Database
+	retention      time.Duration          // how long messages are kept for, forever if 0
+	chunkRetention time.Duration          // how long chunks are kept for, they're never kept forever
+	chunkQuota     int                    // most bytes of chunks stored
+	chunkBytes     int                    // bytes of chunks stored, -1 until they're counted
+	shareQuota     int                    // most bytes of chunks stored that were shared by any one account
+	sharedBytes    map[common.Address]int // bytes of chunks stored by who shared them, nil until they're counted
+	lock           sync.Mutex
+}
+
+// NewMessageStore keeps messages in db, removing them once they are older than retention (if it isn't 0).
+// Attachment chunks are kept as long as messages, or DefaultChunkRetention if messages are kept forever,
+// up to DefaultChunkQuota bytes of them, and DefaultChunkShareQuota of those shared by any one account.
+func NewMessageStore(db *database.Database, retention time.Duration) *MessageStore {
+	chunkRetention := retention
+	if chunkRetention == 0 {
+		chunkRetention = DefaultChunkRetention
+	}
+	return &MessageStore{
+		db:             db,
+		retention:      retention,
+		chunkRetention: chunkRetention,
+		chunkQuota:     DefaultChunkQuota,
+		chunkBytes:     -1,
+		shareQuota:     DefaultChunkShareQuota,
+	}
+}
+
+// SetChunkLimits sets how long attachment chunks are kept for, and how many bytes of them are stored at most.
+// A retention of 0 keeps the current one.
+func (s *MessageStore) SetChunkLimits(retention time.Duration, quota int) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if retention != 0 {
+		s.chunkRetention = retention
+	}
+	s.chunkQuota = quota
+}
+
+// SetChunkShareQuota sets how many bytes of the chunks stored can have been shared by any one account.
+func (s *MessageStore) SetChunkShareQuota(quota int) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.shareQuota = quota
+}
+
+// NewMemoryMessageStore keeps messages in memory only, so they are lost once the node stops.
+func NewMemoryMessageStore(retention time.Duration) (*MessageStore, error) {
+	db, err := database.NewMemory()
+	if err != nil {
+		return nil, err
+	}
+	return NewMessageStore(db, retention), nil
+}
+
+// OpenMessageStore keeps messages in the LevelDB database at path, so they are kept across restarts.
+// An empty path keeps them in memory.
+func OpenMessageStore(path string, retention time.Duration) (*MessageStore, error) {
+	if path == "" {
+		return NewMemoryMessageStore(retention)
+	}
+	db, err := database.New(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewMessageStore(db, retention), nil
+}
+
+func (s *MessageStore) Close() error {
+	return s.db.Close()
+}
+
+// sortableTimeSize is the length of a time encoded by sortableTime.
+const sortableTimeSize = 8
+
+// sortableTime encodes a time so that byte order matches time order, negative times included.
+func sortableTime(t int64) []byte {
+	return binary.BigEndian.AppendUint64([]byte{}, uint64(t)^(1<<63))
+}
+
+func concat(parts ...[]byte) []byte {
+	return bytes.Join(parts, nil)
+}
+
+// conversationKey is the same no matter which of the two addresses sent the message.
+func conversationKey(a, b common.Address) []byte {
+	if bytes.Compare(a.Bytes(), b.Bytes()) > 0 {
+		a, b = b, a
+	}
+	return concat(a.Bytes(), b.Bytes())
+}
+
+// indexKeys returns every index key a message is stored under.
+func indexKeys(msg *utils.CaesarMessage, hash []byte) [][]byte {
+	suffix := concat(sortableTime(msg.InitialTime), hash)
+	return [][]byte{
+		concat(senderIndexPrefix, msg.From.Address.Bytes(), suffix),
+		concat(recipientPrefix, msg.To.Address.Bytes(), suffix),
+		concat(timeIndexPrefix, suffix),
+		concat(conversationPrefix, conversationKey(msg.From.Address, msg.To.Address), suffix),
+	}
+}
+
+// heldKey is where a message held for its recipient is indexed, until they fetch and ack it.
+func heldKey(msg *utils.CaesarMessage, hash []byte) []byte {
+	return concat(heldPrefix, msg.To.Address.Bytes(), sortableTime(msg.InitialTime), hash)
+}
+
+// expired reports whether a message sent at the given time should no longer be kept.
+func (s *MessageStore) expired(initialTime int64, now time.Time) bool {
+	return s.retention != 0 && time.UnixMicro(initialTime).Before(now.Add(-s.retention))
+}
+
+func (s *MessageStore) Has(hash []byte) (bool, error) {
+	return s.db.Has(concat(messagePrefix, hash))
+}
+
+// Get returns the message with that hash, or nil if it isn't stored.
+func (s *MessageStore) Get(hash []byte) (*utils.CaesarMessage, error) {
+	if has, err := s.Has(hash); err != nil || !has {
+		return nil, err
+	}
+	packed, err := s.db.Get(concat(messagePrefix, hash))
+	if err != nil {
+		return nil, err
+	}
+	var msg utils.CaesarMessage
+	if err := encoding.Unmarshal(packed, &msg); err != nil {
+		return nil, err
+	}
+	return &msg, nil
+}
+
+// Put stores the message along with its indexes. It returns false if the message was already stored,
+// or is too old to be kept.
+func (s *MessageStore) Put(msg *utils.CaesarMessage) (bool, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	now := time.Now()
+	if s.expired(msg.InitialTime, now) {
+		return false, nil
+	}
+	if _, err := s.prune(now); err != nil {
+		return false, err
+	}
+	hash := msg.Hash()
+	if has, err := s.Has(hash); err != nil || has {
+		return false, err
+	}
+	if deleted, err := s.db.Has(concat(deletedPrefix, hash)); err != nil || deleted {
+		return false, err
+	}
+	packed, err := encoding.Marshal(msg)
+	if err != nil {
+		return false, err
+	}
+
+	batch := new(database.Batch)
+	batch.Insert(concat(messagePrefix, hash), packed)
+	for _, key := range indexKeys(msg, hash) {
+		batch.Insert(key, hash)
+	}
+	return true, s.db.Write(batch)
+}
+
+// page reads up to limit messages from an index, starting after the cursor. The cursor returned points past the
+// last message read, and is empty once the index has been read to the end. A limit of 0 reads everything.
+func (s *MessageStore) page(prefix []byte, cursor string, limit int) ([]*utils.CaesarMessage, string, error) {
+	var start []byte
+	if cursor != "" {
+		position, err := hex.DecodeString(cursor)
+		if err != nil {
+			return nil, "", ErrInvalidCursor
+		}
+		start = concat(prefix, position, []byte{0})
+	}
+
+	hashes := [][]byte{}
+	lastKeys := [][]byte{}
+	err := s.db.Iterate(prefix, start, func(key []byte, value []byte) bool {
+		hashes = append(hashes, append([]byte{}, value...))
+		lastKeys = append(lastKeys, append([]byte{}, key[len(prefix):]...))
+		return limit <= 0 || len(hashes) <= limit
+	})
+	if err != nil {
+		return nil, "", err
+	}
+
+	nextCursor := ""
+	if limit > 0 && len(hashes) > limit {
+		hashes = hashes[:limit]
+		nextCursor = hex.EncodeToString(lastKeys[limit-1])
+	}
+	messages := make([]*utils.CaesarMessage, 0, len(hashes))
+	for _, hash := range hashes {
+		msg, err := s.Get(hash)
+		if err != nil {
+			return nil, "", err
+		}
+		if msg != nil {
+			messages = append(messages, msg)
+		}
+	}
+	return messages, nextCursor, nil
+}
+
+// MessagesBetween returns the messages sent either way between a and b, oldest first.
+func (s *MessageStore) MessagesBetween(a, b common.Address, cursor string, limit int) ([]*utils.CaesarMessage, string, error) {
+	return s.page(concat(conversationPrefix, conversationKey(a, b)), cursor, limit)
+}
+
+// MessagesFrom returns the messages sent by sender, oldest first.
+func (s *MessageStore) MessagesFrom(sender common.Address, cursor string, limit int) ([]*utils.CaesarMessage, string, error) {
+	return s.page(concat(senderIndexPrefix, sender.Bytes()), cursor, limit)
+}
+
+// MessagesTo returns the messages sent to recipient, oldest first.
+func (s *MessageStore) MessagesTo(recipient common.Address, cursor string, limit int) ([]*utils.CaesarMessage, string, error) {
+	return s.page(concat(recipientPrefix, recipient.Bytes()), cursor, limit)
+}
+
+// Hold keeps a stored message for its recipient, until they fetch and Release it.
+func (s *MessageStore) Hold(msg *utils.CaesarMessage) error {
+	hash := msg.Hash()
+	return s.db.Insert(heldKey(msg, hash), hash)
+}
+
+// Held returns the messages held for the recipient, oldest first.
+func (s *MessageStore) Held(recipient common.Address, cursor string, limit int) ([]*utils.CaesarMessage, string, error) {
+	return s.page(concat(heldPrefix, recipient.Bytes()), cursor, limit)
+}
+
+// Release stops holding the messages with those hashes for the recipient. The messages themselves are kept.
+func (s *MessageStore) Release(recipient common.Address, hashes [][]byte) error {
+	batch := new(database.Batch)
+	for _, hash := range hashes {
+		msg, err := s.Get(hash)
+		if err != nil {
+			return err
+		}
+		if msg == nil || msg.To.Address != recipient {
+			continue
+		}
+		batch.Delete(heldKey(msg, hash))
+	}
+	return s.db.Write(batch)
+}
+
+// Count returns how many messages are stored.
+func (s *MessageStore) Count() (int, error) {
+	count := 0
+	err := s.db.Iterate(messagePrefix, nil, func(key []byte, value []byte) bool {
+		count++
+		return true
+	})
+	return count, err
+}
+
+// Prune removes every message older than the retention period, along with the tombstones of deleted messages and the
+// chunks stored longer than theirs, and returns how many messages were removed.
+func (s *MessageStore) Prune(now time.Time) (int, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if err := s.pruneTombstones(now); err != nil {
+		return 0, err
+	}
+	if err := s.pruneChunks(now); err != nil {
+		return 0, err
+	}
+	return s.prune(now)
+}
+
+func (s *MessageStore) prune(now time.Time) (int, error) {
+	if s.retention == 0 {
+		return 0, nil
+	}
+	cutoff := now.Add(-s.retention).UnixMicro()
+	expiredHashes := [][]byte{}
+	err := s.db.Iterate(timeIndexPrefix, nil, func(key []byte, value []byte) bool {
+		if bytes.Compare(key[len(timeIndexPrefix):], sortableTime(cutoff)) >= 0 {
+			return false
+		}
+		expiredHashes = append(expiredHashes, append([]byte{}, value...))
+		return true
+	})
+	if err != nil || len(expiredHashes) == 0 {
+		return 0, err
+	}
+
+	batch := new(database.Batch)
+	for _, hash := range expiredHashes {
+		msg, err := s.Get(hash)
+		if err != nil {
+			return 0, err
+		}
+		if msg == nil {
+			continue
+		}
+		deleteMessage(batch, msg, hash)
+	}
+	return len(expiredHashes), s.db.Write(batch)
+}
+
+// pruneTombstones drops the tombstones of deleted messages that are too old to be stored again anyway, and the
+// updates still waiting for a message that old.
+func (s *MessageStore) pruneTombstones(now time.Time) error {
+	if s.retention == 0 {
+		return nil
+	}
+	cutoff := sortableTime(now.Add(-s.retention).UnixMicro())
+	batch := new(database.Batch)
+	for _, prefix := range [][]byte{deletedPrefix, pendingPrefix} {
+		err := s.db.Iterate(prefix, nil, func(key []byte, value []byte) bool {
+			if bytes.Compare(value, cutoff) < 0 {
+				batch.Delete(append([]byte{}, key...))
+			}
+			return true
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return s.db.Write(batch)
+}
+
+// deleteMessage adds everything stored about the message to the batch of things to delete.
+func deleteMessage(batch *database.Batch, msg *utils.CaesarMessage, hash []byte) {
+	batch.Delete(concat(messagePrefix, hash))
+	batch.Delete(heldKey(msg, hash))
+	batch.Delete(concat(editPrefix, hash))
+	batch.Delete(concat(receiptPrefix, hash))
+	for _, key := range indexKeys(msg, hash) {
+		batch.Delete(key)
+	}
+}
+
+// Delete removes the message, and keeps a tombstone so it isn't stored again if another copy arrives.
+func (s *MessageStore) Delete(hash []byte) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	msg, err := s.Get(hash)
+	if err != nil || msg == nil {
+		return err
+	}
+	batch := new(database.Batch)
+	deleteMessage(batch, msg, hash)
+	batch.Insert(concat(deletedPrefix, hash), sortableTime(msg.InitialTime))
+	return s.db.Write(batch)
+}
+
+// IsDeleted reports whether the message with that hash was deleted.
+func (s *MessageStore) IsDeleted(hash []byte) (bool, error) {
+	return s.db.Has(concat(deletedPrefix, hash))
+}
+
+// SetEdit records edit as the latest edit of the target message, unless a newer edit is already recorded.
+func (s *MessageStore) SetEdit(target []byte, edit *utils.CaesarMessage) (bool, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	latest, err := s.GetEdit(target)
+	if err != nil {
+		return false, err
+	}
+	if latest != nil && latest.InitialTime >= edit.InitialTime {
+		return false, nil
+	}
+	return true, s.db.Insert(concat(editPrefix, target), edit.Hash())
+}
+
+// GetEdit returns the latest edit of the target message, or nil if it was never edited.
+func (s *MessageStore) GetEdit(target []byte) (*utils.CaesarMessage, error) {
+	key := concat(editPrefix, target)
+	if has, err := s.db.Has(key); err != nil || !has {
+		return nil, err
+	}
+	editHash, err := s.db.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	return s.Get(editHash)
+}
+
+// SetReceipt raises the status of the target message. A status lower than the one recorded is ignored.
+func (s *MessageStore) SetReceipt(target []byte, status utils.CaesarReceiptStatus) (bool, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	current, err := s.GetReceipt(target)
+	if err != nil || current >= status {
+		return false, err
+	}
+	return true, s.db.Insert(concat(receiptPrefix, target), []byte{byte(status)})
+}
+
+// GetReceipt returns how far the target message got, CaesarStatusSent if no receipt was received for it.
+func (s *MessageStore) GetReceipt(target []byte) (utils.CaesarReceiptStatus, error) {
+	key := concat(receiptPrefix, target)
+	if has, err := s.db.Has(key); err != nil || !has {
+		return utils.CaesarStatusSent, err
+	}
+	status, err := s.db.Get(key)
+	if err != nil || len(status) == 0 {
+		return utils.CaesarStatusSent, err
+	}
+	return utils.CaesarReceiptStatus(status[0]), nil
+}
+
+// AddPending keeps an update, such as an edit or delete, that arrived before the target message it is for. The
+// update itself must already be stored.
+func (s *MessageStore) AddPending(target []byte, update *utils.CaesarMessage) error {
+	return s.db.Insert(concat(pendingPrefix, target, update.Hash()), sortableTime(update.InitialTime))
+}
+
+// TakePending returns the updates kept for the target message, oldest first, and stops keeping them.
+func (s *MessageStore) TakePending(target []byte) ([]*utils.CaesarMessage, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	prefix := concat(pendingPrefix, target)
+	keys := [][]byte{}
+	err := s.db.Iterate(prefix, nil, func(key []byte, value []byte) bool {
+		keys = append(keys, append([]byte{}, key...))
+		return true
+	})
+	if err != nil || len(keys) == 0 {
+		return nil, err
+	}
+	updates := make([]*utils.CaesarMessage, 0, len(keys))
+	batch := new(database.Batch)
+	for _, key := range keys {
+		batch.Delete(key)
+		update, err := s.Get(key[len(prefix):])
+		if err != nil {
+			return nil, err
+		}
+		if update != nil {
+			updates = append(updates, update)
+		}
+	}
+	sort.Slice(updates, func(i, j int) bool { return updates[i].InitialTime < updates[j].InitialTime })
+	return updates, s.db.Write(batch)
+}
+
+// PutChunk stores an attachment chunk under its ID. It returns false if the chunk was already stored, and
+// ErrChunkStoreFull if there's no room for it once the expired chunks are dropped.
+func (s *MessageStore) PutChunk(chunk *utils.CaesarChunk) (bool, error) {
+	return s.putChunk(chunk, common.Address{})
+}
+
+// PutSharedChunk stores a chunk that an account shared with us without being asked for it, returning
+// ErrChunkSenderFull rather than storing it if that account already has its share of the chunks stored.
+func (s *MessageStore) PutSharedChunk(chunk *utils.CaesarChunk, from common.Address) (bool, error) {
+	return s.putChunk(chunk, from)
+}
+
+// putChunk stores a chunk, counted against the share of from unless it's the zero address.
+func (s *MessageStore) putChunk(chunk *utils.CaesarChunk, from common.Address) (bool, error) {
+	if len(chunk.Data) > utils.MaxCaesarChunkSize {
+		return false, utils.ErrChunkTooLarge
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	key := concat(chunkPrefix, chunk.ID())
+	if has, err := s.db.Has(key); err != nil || has {
+		return false, err
+	}
+	now := time.Now()
+	shared := from != common.Address{}
+	if s.chunkBytes < 0 || s.chunkBytes+len(chunk.Data) > s.chunkQuota ||
+		(shared && s.sharedBytes[from]+len(chunk.Data) > s.shareQuota) {
+		if err := s.pruneChunks(now); err != nil {
+			return false, err
+		}
+		if s.chunkBytes+len(chunk.Data) > s.chunkQuota {
+			return false, ErrChunkStoreFull
+		}
+		if shared && s.sharedBytes[from]+len(chunk.Data) > s.shareQuota {
+			return false, ErrChunkSenderFull
+		}
+	}
+	//chunks have no time of their own, so they are kept for the retention period from when they were stored
+	if err := s.db.Insert(key, concat(sortableTime(now.UnixMicro()), from.Bytes(), chunk.Data)); err != nil {
+		return false, err
+	}
+	s.chunkBytes += len(chunk.Data)
+	if shared {
+		s.sharedBytes[from] += len(chunk.Data)
+	}
+	return true, nil
+}
+
+// GetChunk returns the chunk with that ID, or nil if it isn't stored.
+func (s *MessageStore) GetChunk(id []byte) (*utils.CaesarChunk, error) {
+	key := concat(chunkPrefix, id)
+	if has, err := s.db.Has(key); err != nil || !has {
+		return nil, err
+	}
+	value, err := s.db.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	return &utils.CaesarChunk{Data: value[chunkHeaderSize:]}, nil
+}
+
+// chunkHeaderSize is the length of what's kept before the data of a chunk: when it was stored, then who shared it.
+const chunkHeaderSize = sortableTimeSize + common.AddressLength
+
+// pruneChunks drops the chunks stored longer than the chunk retention period, counting the bytes of those kept,
+// in all and by who shared them.
+func (s *MessageStore) pruneChunks(now time.Time) error {
+	cutoff := sortableTime(now.Add(-s.chunkRetention).UnixMicro())
+	batch := new(database.Batch)
+	kept := 0
+	shared := make(map[common.Address]int)
+	err := s.db.Iterate(chunkPrefix, nil, func(key []byte, value []byte) bool {
+		if bytes.Compare(value[:sortableTimeSize], cutoff) < 0 {
+			batch.Delete(append([]byte{}, key...))
+			return true
+		}
+		size := len(value) - chunkHeaderSize
+		kept += size
+		if from := common.BytesToAddress(value[sortableTimeSize:chunkHeaderSize]); from != (common.Address{}) {
+			shared[from] += size
+		}
+		return true
+	})
+	if err != nil {
+		return err
+	}
+	if err := s.db.Write(batch); err != nil {
+		return err
+	}
+	s.chunkBytes = kept
+	s.sharedBytes = shared
+	return nil
+}
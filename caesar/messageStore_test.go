@@ -0,0 +1,267 @@
+package caesar
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/adamnite/go-adamnite/common"
+	"github.com/adamnite/go-adamnite/database"
+	"github.com/adamnite/go-adamnite/utils"
+	"github.com/adamnite/go-adamnite/utils/accounts"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestMessage(t *testing.T, to, from *accounts.Account, sentAt time.Time) *utils.CaesarMessage {
+	msg, err := utils.NewCaesarMessage(*to, *from, "hi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg.InitialTime = sentAt.UnixMicro()
+	if err := msg.Sign(); err != nil {
+		t.Fatal(err)
+	}
+	return msg
+}
+
+// hashesOf identifies messages by hash, as the private keys of their accounts are never stored
+func hashesOf(msgs []*utils.CaesarMessage) [][]byte {
+	hashes := [][]byte{}
+	for _, msg := range msgs {
+		hashes = append(hashes, msg.Hash())
+	}
+	return hashes
+}
+
+func TestMessageStorePagination(t *testing.T) {
+	store, err := NewMemoryMessageStore(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	a, _ := accounts.GenerateAccount()
+	b, _ := accounts.GenerateAccount()
+	c, _ := accounts.GenerateAccount()
+
+	start := time.Now()
+	sent := []*utils.CaesarMessage{}
+	for i := 0; i < 5; i++ {
+		from, to := a, b
+		if i%2 == 1 {
+			from, to = b, a
+		}
+		msg := newTestMessage(t, to, from, start.Add(time.Duration(i)*time.Second))
+		sent = append(sent, msg)
+		stored, err := store.Put(msg)
+		assert.NoError(t, err)
+		assert.True(t, stored)
+	}
+	// a message from someone else should not show up in the conversation
+	_, err = store.Put(newTestMessage(t, a, c, start))
+	assert.NoError(t, err)
+	stored, err := store.Put(sent[0])
+	assert.NoError(t, err)
+	assert.False(t, stored, "duplicate message stored")
+
+	pages := [][]*utils.CaesarMessage{}
+	cursor := ""
+	for {
+		page, next, err := store.MessagesBetween(b.Address, a.Address, cursor, 2)
+		assert.NoError(t, err)
+		pages = append(pages, page)
+		if next == "" {
+			break
+		}
+		cursor = next
+	}
+	assert.Equal(t, 3, len(pages))
+	assert.Equal(t, hashesOf(sent[:2]), hashesOf(pages[0]))
+	assert.Equal(t, hashesOf(sent[2:4]), hashesOf(pages[1]))
+	assert.Equal(t, hashesOf(sent[4:]), hashesOf(pages[2]))
+
+	fromA, _, err := store.MessagesFrom(a.Address, "", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, hashesOf([]*utils.CaesarMessage{sent[0], sent[2], sent[4]}), hashesOf(fromA))
+	toA, _, err := store.MessagesTo(a.Address, "", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, len(toA))
+
+	_, _, err = store.MessagesBetween(a.Address, b.Address, "not a cursor", 2)
+	assert.ErrorIs(t, err, ErrInvalidCursor)
+}
+
+func TestMessageStoreRetention(t *testing.T) {
+	store, err := NewMemoryMessageStore(time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	a, _ := accounts.GenerateAccount()
+	b, _ := accounts.GenerateAccount()
+
+	now := time.Now()
+	stored, err := store.Put(newTestMessage(t, a, b, now.Add(-2*time.Hour)))
+	assert.NoError(t, err)
+	assert.False(t, stored, "expired message stored")
+
+	old := newTestMessage(t, a, b, now.Add(-30*time.Minute))
+	recent := newTestMessage(t, a, b, now)
+	for _, msg := range []*utils.CaesarMessage{old, recent} {
+		_, err := store.Put(msg)
+		assert.NoError(t, err)
+	}
+
+	removed, err := store.Prune(now.Add(45 * time.Minute))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, removed)
+	messages, _, err := store.MessagesBetween(a.Address, b.Address, "", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, [][]byte{recent.Hash()}, hashesOf(messages))
+	count, err := store.Count()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, count)
+}
+
+func TestMessageStoreChunkLimits(t *testing.T) {
+	store, err := NewMemoryMessageStore(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	assert.Equal(t, DefaultChunkRetention, store.chunkRetention, "chunks shouldn't be kept forever")
+
+	store.SetChunkLimits(time.Hour, 20)
+	first := &utils.CaesarChunk{Data: make([]byte, 10)}
+	second := &utils.CaesarChunk{Data: append(make([]byte, 9), 1)}
+	third := &utils.CaesarChunk{Data: append(make([]byte, 9), 2)}
+	for _, chunk := range []*utils.CaesarChunk{first, second} {
+		stored, err := store.PutChunk(chunk)
+		assert.NoError(t, err)
+		assert.True(t, stored)
+	}
+	stored, err := store.PutChunk(third)
+	assert.ErrorIs(t, err, ErrChunkStoreFull)
+	assert.False(t, stored)
+
+	//once the others expire there's room again
+	_, err = store.Prune(time.Now().Add(2 * time.Hour))
+	assert.NoError(t, err)
+	kept, err := store.GetChunk(first.ID())
+	assert.NoError(t, err)
+	assert.Nil(t, kept, "expired chunk kept")
+	stored, err = store.PutChunk(third)
+	assert.NoError(t, err)
+	assert.True(t, stored)
+}
+
+func TestMessageStoreChunkShareQuota(t *testing.T) {
+	store, err := NewMemoryMessageStore(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	store.SetChunkShareQuota(15)
+	sharer := common.Address{1}
+	first := &utils.CaesarChunk{Data: make([]byte, 10)}
+	second := &utils.CaesarChunk{Data: append(make([]byte, 9), 1)}
+
+	stored, err := store.PutSharedChunk(first, sharer)
+	assert.NoError(t, err)
+	assert.True(t, stored)
+	stored, err = store.PutSharedChunk(second, sharer)
+	assert.ErrorIs(t, err, ErrChunkSenderFull)
+	assert.False(t, stored)
+
+	//others, and the chunks we fetched ourselves, aren't held to that account's share
+	stored, err = store.PutSharedChunk(second, common.Address{2})
+	assert.NoError(t, err)
+	assert.True(t, stored)
+	kept, err := store.GetChunk(second.ID())
+	assert.NoError(t, err)
+	if assert.NotNil(t, kept) {
+		assert.Equal(t, second.Data, kept.Data)
+	}
+	third := &utils.CaesarChunk{Data: append(make([]byte, 9), 2)}
+	stored, err = store.PutChunk(third)
+	assert.NoError(t, err)
+	assert.True(t, stored)
+}
+
+func TestMessageStorePersists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "caesar")
+	a, _ := accounts.GenerateAccount()
+	b, _ := accounts.GenerateAccount()
+	msg := newTestMessage(t, a, b, time.Now())
+
+	db, err := database.New(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	node := NewCaesarNodeWithStore(a, NewMessageStore(db, 0))
+	node.AddMessage(msg)
+	assert.NoError(t, db.Close())
+
+	db, err = database.New(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	node = NewCaesarNodeWithStore(a, NewMessageStore(db, 0))
+	assert.Equal(t, [][]byte{msg.Hash()}, hashesOf(node.GetMessagesBetween(a.Address, b.Address)))
+}
+
+func TestMessageStoreUpdates(t *testing.T) {
+	store, err := NewMemoryMessageStore(time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	a, _ := accounts.GenerateAccount()
+	b, _ := accounts.GenerateAccount()
+	now := time.Now()
+	msg := newTestMessage(t, a, b, now)
+	_, err = store.Put(msg)
+	assert.NoError(t, err)
+
+	//receipts only move forward
+	for _, status := range []utils.CaesarReceiptStatus{utils.CaesarStatusRead, utils.CaesarStatusDelivered} {
+		_, err := store.SetReceipt(msg.Hash(), status)
+		assert.NoError(t, err)
+	}
+	status, err := store.GetReceipt(msg.Hash())
+	assert.NoError(t, err)
+	assert.Equal(t, utils.CaesarStatusRead, status)
+
+	//the newest edit wins, whatever order they arrive in
+	newer := newTestMessage(t, b, a, now.Add(2*time.Minute))
+	older := newTestMessage(t, b, a, now.Add(time.Minute))
+	for _, edit := range []*utils.CaesarMessage{newer, older} {
+		_, err := store.Put(edit)
+		assert.NoError(t, err)
+		_, err = store.SetEdit(msg.Hash(), edit)
+		assert.NoError(t, err)
+	}
+	latest, err := store.GetEdit(msg.Hash())
+	assert.NoError(t, err)
+	if assert.NotNil(t, latest) {
+		assert.Equal(t, newer.Hash(), latest.Hash())
+	}
+
+	assert.NoError(t, store.Delete(msg.Hash()))
+	deleted, err := store.IsDeleted(msg.Hash())
+	assert.NoError(t, err)
+	assert.True(t, deleted)
+	stored, err := store.Put(msg)
+	assert.NoError(t, err)
+	assert.False(t, stored, "deleted message stored again")
+	messages, _, err := store.MessagesBetween(a.Address, b.Address, "", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, [][]byte{older.Hash(), newer.Hash()}, hashesOf(messages))
+
+	//tombstones go once the message would have expired anyway
+	_, err = store.Prune(now.Add(2 * time.Hour))
+	assert.NoError(t, err)
+	deleted, err = store.IsDeleted(msg.Hash())
+	assert.NoError(t, err)
+	assert.False(t, deleted)
+}
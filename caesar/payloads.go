@@ -0,0 +1,142 @@
+package caesar
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/adamnite/go-adamnite/utils"
+	"github.com/adamnite/go-adamnite/utils/accounts"
+)
+
+var ErrNotOurMessage = fmt.Errorf("only the sender of a message can edit or delete it")
+
+// SendPayload sends the typed payload to the account, and returns the message it was sent in.
+func (cn *CaesarNode) SendPayload(to accounts.Account, payload utils.CaesarPayload) (*utils.CaesarMessage, error) {
+	msg, err := utils.NewCaesarMessage(to, *cn.signerSet, payload)
+	if err != nil {
+		return nil, err
+	}
+	if record := cn.GetMailboxRecord(to.Address); record != nil && len(record.Mailboxes) != 0 {
+		msg.HasHostingServer = true
+	}
+	if err := msg.Sign(); err != nil {
+		return nil, err
+	}
+	return msg, cn.SendMessage(msg)
+}
+
+// SendReceipt tells the sender of the message how far it got.
+func (cn *CaesarNode) SendReceipt(msg *utils.CaesarMessage, status utils.CaesarReceiptStatus) error {
+	_, err := cn.SendPayload(msg.From, utils.NewReceiptPayload(msg.Hash(), status))
+	return err
+}
+
+// SendTyping lets the account know we are writing to them.
+func (cn *CaesarNode) SendTyping(to accounts.Account) error {
+	_, err := cn.SendPayload(to, utils.NewTypingPayload())
+	return err
+}
+
+// EditMessage replaces the text of a message we sent.
+func (cn *CaesarNode) EditMessage(msg *utils.CaesarMessage, text string) error {
+	if msg.From.Address != cn.signerSet.Address {
+		return ErrNotOurMessage
+	}
+	_, err := cn.SendPayload(msg.To, utils.NewEditPayload(msg.Hash(), text))
+	return err
+}
+
+// DeleteMessage removes a message we sent, for us and for the recipient.
+func (cn *CaesarNode) DeleteMessage(msg *utils.CaesarMessage) error {
+	if msg.From.Address != cn.signerSet.Address {
+		return ErrNotOurMessage
+	}
+	_, err := cn.SendPayload(msg.To, utils.NewDeletePayload(msg.Hash()))
+	return err
+}
+
+// GetMessageText returns the text of a message, as of its latest edit.
+func (cn *CaesarNode) GetMessageText(msg *utils.CaesarMessage) (string, error) {
+	edit, err := cn.messages.GetEdit(msg.Hash())
+	if err != nil {
+		return "", err
+	}
+	if edit != nil {
+		return edit.GetMessageString(*cn.signerSet)
+	}
+	return msg.GetMessageString(*cn.signerSet)
+}
+
+// GetMessageStatus returns how far a message we sent got.
+func (cn *CaesarNode) GetMessageStatus(msg *utils.CaesarMessage) (utils.CaesarReceiptStatus, error) {
+	return cn.messages.GetReceipt(msg.Hash())
+}
+
+// applyPending applies the updates that arrived before the message they are for.
+func (cn *CaesarNode) applyPending(msg *utils.CaesarMessage) {
+	updates, err := cn.messages.TakePending(msg.Hash())
+	if err != nil {
+		log.Printf("[Caesar] unable to read the pending message updates: %v", err)
+		return
+	}
+	for _, update := range updates {
+		cn.applyPayload(update)
+	}
+}
+
+// applyPayload acts on receipts, edits and deletes in messages we can read, once they are stored. Those that arrive
+// before their target message are kept until it does.
+func (cn *CaesarNode) applyPayload(msg *utils.CaesarMessage) {
+	if msg.To.Address != cn.signerSet.Address && msg.From.Address != cn.signerSet.Address {
+		return //we're only passing it along
+	}
+	if msg.Version != utils.CaesarVersionDirect && msg.Version != utils.CaesarVersionEnvelope {
+		return
+	}
+	payload, err := msg.GetPayload(*cn.signerSet)
+	if err != nil {
+		return
+	}
+
+	switch payload.Type {
+	case utils.CaesarPayloadText, utils.CaesarPayloadAttachment:
+		if cn.AutoDeliveryReceipts && msg.To.Address == cn.signerSet.Address && msg.From.Address != cn.signerSet.Address {
+			if err := cn.SendReceipt(msg, utils.CaesarStatusDelivered); err != nil {
+				log.Printf("[Caesar] unable to send a delivery receipt: %v", err)
+			}
+		}
+		return
+	case utils.CaesarPayloadTyping:
+		return
+	}
+
+	target, err := cn.messages.Get(payload.Target)
+	if err != nil {
+		return
+	}
+	if target == nil {
+		//the update got here first, it's applied once the message does
+		if err := cn.messages.AddPending(payload.Target, msg); err != nil {
+			log.Printf("[Caesar] unable to keep a message update: %v", err)
+		}
+		return
+	}
+	switch payload.Type {
+	case utils.CaesarPayloadReceipt:
+		//only the recipient can say how far the message got
+		if target.To.Address == msg.From.Address {
+			_, err = cn.messages.SetReceipt(payload.Target, payload.Status)
+		}
+	case utils.CaesarPayloadEdit:
+		if target.From.Address == msg.From.Address {
+			_, err = cn.messages.SetEdit(payload.Target, msg)
+		}
+	case utils.CaesarPayloadDelete:
+		if target.From.Address == msg.From.Address {
+			err = cn.messages.Delete(payload.Target)
+		}
+	}
+	if err != nil {
+		log.Printf("[Caesar] unable to apply a message update: %v", err)
+	}
+}
@@ -0,0 +1,111 @@
+package caesar
+
+import (
+	"testing"
+	"time"
+
+	"github.com/adamnite/go-adamnite/common"
+	"github.com/adamnite/go-adamnite/networking"
+	"github.com/adamnite/go-adamnite/utils"
+	"github.com/adamnite/go-adamnite/utils/accounts"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReceiptsEditsAndDeletes(t *testing.T) {
+	seedNode := networking.NewNetNode(common.Address{0, 1, 2, 3, 4, 5, 6, 7, 8, 9})
+	seedNode.AddServer()
+	seedContact := seedNode.GetOwnContact()
+
+	aAccount, aNode := setupTestCaesarNode(&seedContact)
+	bAccount, bNode := setupTestCaesarNode(&seedContact)
+	bNode.AutoDeliveryReceipts = true
+	seedNode.FillOpenConnections()
+
+	sent, err := aNode.SendPayload(*bAccount, utils.NewTextPayload("Hello World!"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Eventually(t, func() bool {
+		status, _ := aNode.GetMessageStatus(sent)
+		return status == utils.CaesarStatusDelivered
+	}, time.Second, 10*time.Millisecond, "delivery receipt never arrived")
+
+	received, err := bNode.messages.Get(sent.Hash())
+	if err != nil || received == nil {
+		t.Fatal("message never arrived", err)
+	}
+	assert.NoError(t, bNode.SendReceipt(received, utils.CaesarStatusRead))
+	assert.Eventually(t, func() bool {
+		status, _ := aNode.GetMessageStatus(sent)
+		return status == utils.CaesarStatusRead
+	}, time.Second, 10*time.Millisecond, "read receipt never arrived")
+
+	//only the sender can edit or delete
+	assert.ErrorIs(t, bNode.EditMessage(received, "Goodbye World!"), ErrNotOurMessage)
+	forged, err := utils.NewCaesarMessage(*aAccount, *bAccount, utils.NewEditPayload(sent.Hash(), "Goodbye World!"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	aNode.AddMessage(forged)
+	text, err := aNode.GetMessageText(sent)
+	assert.NoError(t, err)
+	assert.Equal(t, "Hello World!", text, "edit from the recipient was applied")
+
+	assert.NoError(t, aNode.EditMessage(sent, "Hello Again!"))
+	assert.Eventually(t, func() bool {
+		text, _ := bNode.GetMessageText(received)
+		return text == "Hello Again!"
+	}, time.Second, 10*time.Millisecond, "edit never arrived")
+
+	assert.NoError(t, aNode.DeleteMessage(sent))
+	assert.Eventually(t, func() bool {
+		deleted, _ := bNode.messages.IsDeleted(sent.Hash())
+		return deleted
+	}, time.Second, 10*time.Millisecond, "delete never arrived")
+	for _, node := range []*CaesarNode{aNode, bNode} {
+		msg, err := node.messages.Get(sent.Hash())
+		assert.NoError(t, err)
+		assert.Nil(t, msg)
+		//another copy arriving later isn't stored again
+		node.AddMessage(sent)
+		msg, _ = node.messages.Get(sent.Hash())
+		assert.Nil(t, msg, "deleted message stored again")
+	}
+}
+
+func TestUpdatesBeforeTheirMessage(t *testing.T) {
+	aAccount, _ := accounts.GenerateAccount()
+	bAccount, _ := accounts.GenerateAccount()
+	bNode, err := NewCaesarNode(bAccount)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signed := func(payload utils.CaesarPayload) *utils.CaesarMessage {
+		msg, err := utils.NewCaesarMessage(*bAccount, *aAccount, payload)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := msg.Sign(); err != nil {
+			t.Fatal(err)
+		}
+		return msg
+	}
+	edited := signed(utils.NewTextPayload("Hello World!"))
+	deleted := signed(utils.NewTextPayload("Goodbye World!"))
+	bNode.AddMessage(signed(utils.NewEditPayload(edited.Hash(), "Hello Again!")))
+	bNode.AddMessage(signed(utils.NewDeletePayload(deleted.Hash())))
+
+	bNode.AddMessage(edited)
+	text, err := bNode.GetMessageText(edited)
+	assert.NoError(t, err)
+	assert.Equal(t, "Hello Again!", text, "the early edit wasn't applied")
+
+	bNode.AddMessage(deleted)
+	msg, err := bNode.messages.Get(deleted.Hash())
+	assert.NoError(t, err)
+	assert.Nil(t, msg, "the early delete wasn't applied")
+	isDeleted, err := bNode.messages.IsDeleted(deleted.Hash())
+	assert.NoError(t, err)
+	assert.True(t, isDeleted)
+}
@@ -1,6 +1,9 @@
 package consensus
 
 import (
+	"errors"
+	"fmt"
+
 	"github.com/adamnite/go-adamnite/VM"
 	"github.com/adamnite/go-adamnite/networking"
 )
@@ -9,7 +12,7 @@ import (
 func NewBConsensus(codeServer string) (*ConsensusNode, error) {
 	conNode, err := newConsensus(nil, nil)
 	conNode.handlingType = networking.SecondaryTransactions
-	conNode.ocdbLink = codeServer
+	conNode.codeStore = VM.NewHTTPCodeStore(codeServer)
 
 	return conNode, err
 }
@@ -19,12 +22,17 @@ func (bNode *ConsensusNode) isBNode() bool {
 }
 
 // run the claimed changes again to verify that we have the same results.
+// If the off-chain DB served code that doesn't match its hash, the claim can't be judged and ErrCodeDBUntrusted is
+// returned instead, so the DB witness is the one held responsible.
 func (bNode *ConsensusNode) VerifyRun(runtimeClaim VM.RuntimeChanges) (bool, *VM.RuntimeChanges, error) {
 	if !bNode.isBNode() {
 		return false, nil, ErrNotBNode
 	}
 	ourChanges := runtimeClaim.CleanCopy()
 	err := bNode.ProcessRun(ourChanges)
+	if errors.Is(err, VM.ErrCodeHashMismatch) {
+		return false, nil, fmt.Errorf("%w: %w", ErrCodeDBUntrusted, err)
+	}
 	trustable := runtimeClaim.Equal(ourChanges)
 	return trustable, ourChanges, err
 }
@@ -35,13 +43,13 @@ func (bNode *ConsensusNode) ProcessRun(claim *VM.RuntimeChanges) error {
 		return ErrNotBNode
 	}
 	if bNode.vm == nil {
-		vm, err := VM.NewVirtualMachineWithContract(bNode.ocdbLink, nil)
+		vm, err := VM.NewVirtualMachineWithContract(bNode.codeStore, nil)
 		if err != nil {
 			return err
 		}
 		bNode.vm = vm
 	}
-	newClaim, err := bNode.vm.CallWith(bNode.ocdbLink, claim)
+	newClaim, err := bNode.vm.CallWith(bNode.codeStore, claim)
 	if err != nil {
 		return err
 	}
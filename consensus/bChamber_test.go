@@ -10,6 +10,8 @@ import (
 
 	"github.com/adamnite/go-adamnite/VM"
 	"github.com/adamnite/go-adamnite/common"
+	"github.com/adamnite/go-adamnite/database/offchain"
+	"github.com/stretchr/testify/assert"
 )
 
 var (
@@ -25,7 +27,7 @@ var (
 func setup() error {
 	addTwoFunctionBytes, _ = hex.DecodeString(addTwoFunctionCode)
 	mod := VM.DecodeModule(addTwoFunctionBytes)
-	stored, _, err := VM.UploadModuleFunctions(apiEndpoint, mod)
+	stored, _, err := VM.UploadModuleFunctions(VM.NewHTTPCodeStore(apiEndpoint), mod)
 	if err != nil {
 		return err
 	}
@@ -45,10 +47,11 @@ func TestProcessingRun(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	if _, err := VM.UploadMethod(apiEndpoint, addTwoCodeStored); err != nil {
+	store := VM.NewHTTPCodeStore(apiEndpoint)
+	if _, err := store.PutMethod(addTwoCodeStored); err != nil {
 		t.Fatal(err)
 	}
-	if err := VM.UploadContract(apiEndpoint, testContract); err != nil {
+	if err := store.PutContract(testContract); err != nil {
 		t.Fatal(err)
 	}
 	claim := VM.RuntimeChanges{
@@ -80,7 +83,49 @@ func TestProcessingRun(t *testing.T) {
 	}
 
 }
+func TestVerifyRunUntrustedCode(t *testing.T) {
+	if err := setup(); err != nil {
+		t.Skip(err)
+	}
+	bNode, err := NewBConsensus(apiEndpoint)
+	if err != nil {
+		t.Fatal(err)
+	}
+	//the DB serves other code under the hash of the function called
+	spoofer := VM.NewDBSpoofer()
+	spoofer.AddSpoofedCode(hex.EncodeToString(addTwoFunctionHash), VM.CodeStored{
+		CodeParams:  []VM.ValueType{VM.Op_i64},
+		CodeResults: []VM.ValueType{VM.Op_i64},
+		CodeBytes:   []byte{0x00, 0x20, 0x00, 0x0b},
+	})
+	if err := spoofer.PutContract(testContract); err != nil {
+		t.Fatal(err)
+	}
+	bNode.codeStore = &spoofer
+
+	claim := VM.RuntimeChanges{
+		Caller:           testAccount,
+		CallTime:         time.Now().UTC(),
+		ContractCalled:   testContract.Address,
+		ParametersPassed: append(append([]byte{}, addTwoFunctionHash...), byte(VM.Op_i64)),
+		GasLimit:         10000,
+	}
+	claim.ParametersPassed = append(claim.ParametersPassed, VM.EncodeUint64(1)...)
+	claim.ParametersPassed = append(claim.ParametersPassed, VM.Op_i64)
+	claim.ParametersPassed = append(claim.ParametersPassed, VM.EncodeUint64(2)...)
+	didPass, _, err := bNode.VerifyRun(claim)
+	assert.False(t, didPass)
+	assert.ErrorIs(t, err, ErrCodeDBUntrusted)
+	assert.ErrorIs(t, err, VM.ErrCodeHashMismatch, "the VM error was lost")
+}
+
 func TestMain(m *testing.M) {
+	server, err := offchain.NewInProcess()
+	if err != nil {
+		log.Fatal(err)
+	}
+	apiEndpoint = server.Endpoint()
 	code := m.Run()
+	server.Close()
 	os.Exit(code)
 }
@@ -10,8 +10,10 @@ import (
 	"github.com/adamnite/go-adamnite/databaseDeprecated/statedb"
 	"github.com/adamnite/go-adamnite/blockchain"
 	"github.com/adamnite/go-adamnite/common"
+	"github.com/adamnite/go-adamnite/core/types"
 	"github.com/adamnite/go-adamnite/crypto"
 	"github.com/adamnite/go-adamnite/networking"
+	"github.com/adamnite/go-adamnite/txpool"
 	"github.com/adamnite/go-adamnite/utils"
 	"github.com/adamnite/go-adamnite/utils/accounts"
 )
@@ -32,8 +34,9 @@ type ConsensusNode struct {
 	vrfKey        accounts.Account
 	state         *statedb.StateDB
 	chain         *blockchain.Blockchain //we need to keep the chain
-	ocdbLink      string                 //off chain database, if running the VM verification, this should be local.
+	codeStore     VM.CodeStore           //off chain database, if running the VM verification, this should be local.
 	vm            *VM.Machine
+	txPool        *txpool.TxPool //transactions waiting on a block, only kept with a state and chain to check them against
 
 	autoVoteForNode *common.Address
 	autoVoteWith    *common.Address
@@ -64,15 +67,43 @@ func newConsensus(state *statedb.StateDB, chain *blockchain.Blockchain) (*Consen
 		candidateStakeValues: make(map[string]*big.Int),
 		candidates:           make(map[string]*utils.Candidate),
 	}
+	if state != nil && chain != nil {
+		con.txPool = txpool.New(txpool.DefaultConfig, chain.Config(), state, chain)
+		hostingNode.SetTransactionPool(con.txPool)
+	}
 	if err := hostingNode.AddFullServer(state, chain, con.ReviewTransaction, con.ReviewCandidacy, con.ReviewVote); err != nil {
 		log.Printf("error:%v", err)
 		return nil, err
 	}
 	return &con, nil
 }
+// review a transaction, holding it in the pool if its good. An error stops it from being propagated further
 func (con *ConsensusNode) ReviewTransaction(transaction *utils.Transaction) error {
-	//TODO: give this a quick look over, review it, if its good, add it locally and propagate it out, otherwise, ignore it.
-	return nil
+	if con.txPool == nil {
+		return nil //without a state and chain there's nothing to check it against, so leave it to the others
+	}
+	if con.txPool.Has(transaction.Hash()) {
+		return nil //the networking node already pooled it
+	}
+	return con.txPool.Add(transaction)
+}
+
+// the transactions waiting to be put in a block. Nil if this node has no state and chain to check them with
+func (con *ConsensusNode) TransactionPool() *txpool.TxPool {
+	return con.txPool
+}
+
+// the pooled transactions ready to go into the next block, at most limit of them, in the form blocks carry
+func (con *ConsensusNode) BlockTransactions(limit int) types.Transactions {
+	if con.txPool == nil {
+		return nil
+	}
+	executable := con.txPool.Executable(limit)
+	transactions := make(types.Transactions, len(executable))
+	for i, transaction := range executable {
+		transactions[i] = transaction.Canonical()
+	}
+	return transactions
 }
 
 // review authenticity of a vote, as well as recording it for our own records. If no errors are returned, will propagate further
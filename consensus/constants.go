@@ -21,4 +21,5 @@ var (
 	ErrNotANode               = fmt.Errorf("node is not setup to handle transaction based operations")
 	ErrCandidateNotApplicable = fmt.Errorf("the candidate reviewed is not applicable for this pools recordings")
 	ErrVoteUnVerified         = fmt.Errorf("this vote could not be verified")
+	ErrCodeDBUntrusted        = fmt.Errorf("the off-chain DB served code that does not match its hash")
 )
@@ -13,6 +13,8 @@ import (
 
 var (
 	errInsufficientBalanceForGas = errors.New("insufficient balance to pay for operation fee")
+	ErrNonceTooLow               = errors.New("nonce too low")
+	ErrNonceTooHigh              = errors.New("nonce too high")
 )
 
 type StateTransition struct {
@@ -86,7 +88,24 @@ func (st *StateTransition) buyAte() error {
 	return nil
 }
 
+// preCheck makes sure the message is the next one from its sender, so a transaction can't be run twice.
+func (st *StateTransition) preCheck() error {
+	if !st.msg.CheckNonce() {
+		return nil
+	}
+	stateNonce := st.state.GetNonce(st.msg.From())
+	if msgNonce := st.msg.Nonce(); msgNonce < stateNonce {
+		return ErrNonceTooLow
+	} else if msgNonce > stateNonce {
+		return ErrNonceTooHigh
+	}
+	return nil
+}
+
 func (st *StateTransition) TransitionDb() (ret []byte, usedAte uint64, failed bool, err error) {
+	if err = st.preCheck(); err != nil {
+		return nil, 0, false, err
+	}
 
 	msg := st.msg
 	sender := msg.From()
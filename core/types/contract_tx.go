@@ -0,0 +1,119 @@
+package types
+
+import (
+	"math/big"
+
+	"github.com/adamnite/go-adamnite/common"
+)
+
+// ContractCallTransaction calls a function of a contract, sending it funds along with the call.
+type ContractCallTransaction struct {
+	ChainID  *big.Int       // the chain the transaction is for, see params.ChainConfig
+	Nonce    uint64         // nonce of the sender account
+	To       common.Address // the contract called
+	Amount   *big.Int       // funds sent to the contract
+	Data     []byte         // function hash followed by function params
+	AtePrice *big.Int       // price paid per ate
+	AteMax   uint64         // most ate the call may use
+	V, R, S  *big.Int       // signature value
+}
+
+func NewContractCallTransaction(chainID *big.Int, nonce uint64, to common.Address, amount *big.Int, data []byte, atePrice *big.Int, ateMax uint64) *Transaction {
+	return NewTx(&ContractCallTransaction{
+		ChainID:  chainID,
+		Nonce:    nonce,
+		To:       to,
+		Amount:   amount,
+		Data:     data,
+		AtePrice: atePrice,
+		AteMax:   ateMax,
+	})
+}
+
+func (tx *ContractCallTransaction) copy() Transaction_Data {
+	return &ContractCallTransaction{
+		ChainID:  copyBig(tx.ChainID),
+		Nonce:    tx.Nonce,
+		To:       tx.To,
+		Amount:   copyBig(tx.Amount),
+		Data:     common.CopyBytes(tx.Data),
+		AtePrice: copyBig(tx.AtePrice),
+		AteMax:   tx.AteMax,
+		V:        copyBig(tx.V),
+		R:        copyBig(tx.R),
+		S:        copyBig(tx.S),
+	}
+}
+
+func (tx *ContractCallTransaction) txtype() TxType         { return CONTRACT_TX }
+func (tx *ContractCallTransaction) chain_TYPE() *big.Int   { return tx.ChainID }
+func (tx *ContractCallTransaction) amount() *big.Int       { return tx.Amount }
+func (tx *ContractCallTransaction) message() []byte        { return tx.Data }
+func (tx *ContractCallTransaction) message_size() *big.Int { return big.NewInt(int64(len(tx.Data))) }
+func (tx *ContractCallTransaction) ATE_MAX() uint64        { return tx.AteMax }
+func (tx *ContractCallTransaction) ATE_price() *big.Int    { return tx.AtePrice }
+func (tx *ContractCallTransaction) to() *common.Address    { return &tx.To }
+func (tx *ContractCallTransaction) nonce() uint64          { return tx.Nonce }
+
+func (tx *ContractCallTransaction) rawSignature() (v, r, s *big.Int) {
+	return tx.V, tx.R, tx.S
+}
+
+func (tx *ContractCallTransaction) setSignature(chainID, v, r, s *big.Int) {
+	tx.V, tx.R, tx.S = v, r, s
+}
+
+// ContractCreateTransaction uploads the code of a new contract. It has no recipient, the contract's address comes
+// from the sender.
+type ContractCreateTransaction struct {
+	ChainID  *big.Int // the chain the transaction is for, see params.ChainConfig
+	Nonce    uint64   // nonce of the sender account
+	Amount   *big.Int // funds the contract starts with
+	Code     []byte   // the contract's code
+	AtePrice *big.Int // price paid per ate
+	AteMax   uint64   // most ate the creation may use
+	V, R, S  *big.Int // signature value
+}
+
+func NewContractCreateTransaction(chainID *big.Int, nonce uint64, amount *big.Int, code []byte, atePrice *big.Int, ateMax uint64) *Transaction {
+	return NewTx(&ContractCreateTransaction{
+		ChainID:  chainID,
+		Nonce:    nonce,
+		Amount:   amount,
+		Code:     code,
+		AtePrice: atePrice,
+		AteMax:   ateMax,
+	})
+}
+
+func (tx *ContractCreateTransaction) copy() Transaction_Data {
+	return &ContractCreateTransaction{
+		ChainID:  copyBig(tx.ChainID),
+		Nonce:    tx.Nonce,
+		Amount:   copyBig(tx.Amount),
+		Code:     common.CopyBytes(tx.Code),
+		AtePrice: copyBig(tx.AtePrice),
+		AteMax:   tx.AteMax,
+		V:        copyBig(tx.V),
+		R:        copyBig(tx.R),
+		S:        copyBig(tx.S),
+	}
+}
+
+func (tx *ContractCreateTransaction) txtype() TxType         { return CONTRACT_CREATE_TX }
+func (tx *ContractCreateTransaction) chain_TYPE() *big.Int   { return tx.ChainID }
+func (tx *ContractCreateTransaction) amount() *big.Int       { return tx.Amount }
+func (tx *ContractCreateTransaction) message() []byte        { return tx.Code }
+func (tx *ContractCreateTransaction) message_size() *big.Int { return big.NewInt(int64(len(tx.Code))) }
+func (tx *ContractCreateTransaction) ATE_MAX() uint64        { return tx.AteMax }
+func (tx *ContractCreateTransaction) ATE_price() *big.Int    { return tx.AtePrice }
+func (tx *ContractCreateTransaction) to() *common.Address    { return nil }
+func (tx *ContractCreateTransaction) nonce() uint64          { return tx.Nonce }
+
+func (tx *ContractCreateTransaction) rawSignature() (v, r, s *big.Int) {
+	return tx.V, tx.R, tx.S
+}
+
+func (tx *ContractCreateTransaction) setSignature(chainID, v, r, s *big.Int) {
+	tx.V, tx.R, tx.S = v, r, s
+}
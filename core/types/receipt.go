@@ -0,0 +1,28 @@
+package types
+
+import (
+	"math/big"
+
+	"github.com/adamnite/go-adamnite/common"
+)
+
+const (
+	// ReceiptStatusFailed is the status of a transaction that ran out of ate or was reverted. It's still included.
+	ReceiptStatusFailed = uint64(0)
+	// ReceiptStatusSuccessful is the status of a transaction that ran to the end.
+	ReceiptStatusSuccessful = uint64(1)
+)
+
+// Receipt is the outcome of a transaction included in a block.
+type Receipt struct {
+	Status            uint64
+	GasUsed           uint64 // ate used by this transaction
+	CumulativeGasUsed uint64 // ate used by the block, up to and including this transaction
+
+	TxHash           common.Hash
+	BlockHash        common.Hash
+	BlockNumber      *big.Int
+	TransactionIndex uint
+}
+
+type Receipts []*Receipt
@@ -2,6 +2,7 @@ package types
 
 import (
 	"bytes"
+	"errors"
 	"math/big"
 	"sync/atomic"
 	"time"
@@ -15,10 +16,28 @@ type TxType int8
 const (
 	VOTE_TX TxType = iota
 	VOTE_POH_TX
-	NORMAL_TX
-	CONTRACT_TX
+	NORMAL_TX          // a transfer of funds, see TransferTransaction
+	CONTRACT_TX        // a call to a contract, see ContractCallTransaction
+	CONTRACT_CREATE_TX // the creation of a contract, see ContractCreateTransaction
 )
 
+var errEmptyTypedTx = errors.New("empty typed transaction bytes")
+
+// newTransactionData is an empty transaction of the type, to decode into.
+func newTransactionData(txType TxType) (Transaction_Data, error) {
+	switch txType {
+	case VOTE_TX:
+		return new(VoteTransaction), nil
+	case NORMAL_TX:
+		return new(TransferTransaction), nil
+	case CONTRACT_TX:
+		return new(ContractCallTransaction), nil
+	case CONTRACT_CREATE_TX:
+		return new(ContractCreateTransaction), nil
+	}
+	return nil, ErrTxTypeNotSupported
+}
+
 // Transaction is an Adamnite transaction.
 type Transaction struct {
 	InnerData Transaction_Data
@@ -30,10 +49,7 @@ type Transaction struct {
 }
 
 func CreateTx(InnerData Transaction_Data) *Transaction {
-	transaction := new(Transaction)
-	transaction.Decode(InnerData.copy(), 0) //We will need to some functions for decoding and encoding data types
-	//This can be Serialization or our own implementation.
-	return transaction
+	return NewTx(InnerData)
 }
 
 // TxData is the underlying data of a transaction.
@@ -63,10 +79,6 @@ func (s Transactions) EncodeIndex(i int, w *bytes.Buffer) {
 	tx.encodeTyped(w)
 }
 
-//// TODO: Implement structure for creating a new transaction, basic message transactions (will do this weekend),
-//proper encoding and decoding of transactions, fetch operations (the fetching of various transaction related data),
-//and some stack operations such as shift.
-
 func NewTx(inner Transaction_Data) *Transaction {
 	tx := new(Transaction)
 	tx.setDecoded(inner.copy(), 0)
@@ -77,8 +89,53 @@ func (tx *Transaction) To() *common.Address {
 	return tx.InnerData.to()
 }
 
-func (tx *Transaction) Decode(s Transaction_Data, _ int) {
+// Encode is the transaction's type followed by its msgpack encoded data, the form hashed into the transaction root.
+func (tx *Transaction) Encode() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := tx.encodeTyped(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Decode reads the transaction from the form written by Encode.
+func (tx *Transaction) Decode(b []byte) error {
+	if len(b) == 0 {
+		return errEmptyTypedTx
+	}
+	inner, err := newTransactionData(TxType(b[0]))
+	if err != nil {
+		return err
+	}
+	if err := msgpack.Unmarshal(b[1:], inner); err != nil {
+		return err
+	}
+	tx.setDecoded(inner, len(b))
+	return nil
+}
+
+var _ msgpack.CustomEncoder = (*Transaction)(nil)
+var _ msgpack.CustomDecoder = (*Transaction)(nil)
 
+// EncodeMsgpack writes the type ahead of the data, so the transaction decodes back into the same variant.
+func (tx *Transaction) EncodeMsgpack(enc *msgpack.Encoder) error {
+	return enc.EncodeMulti(tx.Type(), tx.InnerData)
+}
+
+func (tx *Transaction) DecodeMsgpack(dec *msgpack.Decoder) error {
+	var txType TxType
+	if err := dec.Decode(&txType); err != nil {
+		return err
+	}
+	inner, err := newTransactionData(txType)
+	if err != nil {
+		return err
+	}
+	if err := dec.Decode(inner); err != nil {
+		return err
+	}
+	tx.setDecoded(inner, 0)
+	return nil
 }
 
 type Message struct {
@@ -121,6 +178,7 @@ func (tx *Transaction) AsMessage(s Signer) (Message, error) {
 	msg := Message{
 		nonce: tx.InnerData.nonce(),
 
+		gasLimit:   tx.ATEMax(),
 		gasPrice:   tx.ATEPrice(),
 		to:         tx.InnerData.to(),
 		amount:     tx.InnerData.amount(),
@@ -162,6 +220,24 @@ func (tx *Transaction) WithSignature(signer Signer, signature []byte) (*Transact
 	return &Transaction{InnerData: cpy, timestamp: tx.timestamp}, nil
 }
 
+// Signature is the transaction's signature as R, S and the recovery id, or nil if it isn't signed.
+func (tx *Transaction) Signature() []byte {
+	v, r, s := tx.RawSignature()
+	if v == nil || r == nil || s == nil || v.Sign() == 0 || v.BitLen() > 8 || r.BitLen() > 256 || s.BitLen() > 256 {
+		return nil
+	}
+	return encodeSignature(r, s, v)
+}
+
+// ChainID is the chain the transaction is for.
+func (tx *Transaction) ChainID() *big.Int { return tx.InnerData.chain_TYPE() }
+
+// Data is the call data of a contract call, or the code of a contract creation.
+func (tx *Transaction) Data() []byte { return tx.InnerData.message() }
+
+// Time is when the transaction was made or decoded.
+func (tx *Transaction) Time() time.Time { return tx.timestamp }
+
 // Nonce returns the sender account nonce of the transaction.
 func (tx *Transaction) Nonce() uint64 { return tx.InnerData.nonce() }
 
@@ -172,8 +248,10 @@ func (tx *Transaction) ATEPrice() *big.Int { return tx.InnerData.ATE_price() }
 func (tx *Transaction) Amount() *big.Int { return tx.InnerData.amount() }
 
 func (tx *Transaction) Cost() *big.Int {
-	total := new(big.Int).Mul(new(big.Int).SetUint64(tx.ATEMax()), tx.ATEPrice())
-	total.Add(total, tx.Amount())
+	total := new(big.Int).Mul(new(big.Int).SetUint64(tx.ATEMax()), copyBig(tx.ATEPrice()))
+	if amount := tx.Amount(); amount != nil {
+		total.Add(total, amount)
+	}
 	return total
 }
 
@@ -9,12 +9,12 @@ import (
 	"github.com/adamnite/go-adamnite/common"
 	"github.com/adamnite/go-adamnite/crypto"
 	"github.com/adamnite/go-adamnite/params"
-	"github.com/vmihailenco/msgpack/v5"
 )
 
 var (
 	ErrTxTypeNotSupported = errors.New("transaction type not supported")
 	ErrInvalidSig         = errors.New("Invalid signature")
+	ErrInvalidChainId     = errors.New("the transaction is for another chain")
 )
 
 type Signer interface {
@@ -31,44 +31,66 @@ type Signer interface {
 	Hash(tx *Transaction) common.Hash
 }
 
-type AdamniteSigner struct{}
+// AdamniteSigner signs every type of transaction the same way, with a recoverable secp256k1 signature over the
+// transaction's type and data. Transactions are only accepted for the signer's chain, so they can't be replayed on
+// another.
+type AdamniteSigner struct {
+	chainID *big.Int
+}
+
+func NewAdamniteSigner(chainID *big.Int) AdamniteSigner {
+	return AdamniteSigner{chainID: chainID}
+}
 
 func (as AdamniteSigner) Sender(tx *Transaction) (common.Address, error) {
-	if tx.Type() != VOTE_TX && tx.Type() != NORMAL_TX {
-		return common.Address{}, ErrTxTypeNotSupported
+	if _, err := newTransactionData(tx.Type()); err != nil {
+		return common.Address{}, err
+	}
+	if copyBig(tx.ChainID()).Cmp(copyBig(as.chainID)) != 0 {
+		return common.Address{}, ErrInvalidChainId
 	}
 	v, r, s := tx.RawSignature()
+	if v == nil || r == nil || s == nil {
+		return common.Address{}, ErrInvalidSig
+	}
 	return recoverPlain(as.Hash(tx), r, s, v)
 }
 
+// Hash is the hash of everything in the transaction but its signature.
 func (as AdamniteSigner) Hash(tx *Transaction) common.Hash {
-	serial, _ := msgpack.Marshal(tx.Nonce())
-	bytes := crypto.Sha512(serial)
-	hash := common.Hash{}
-	copy(hash[:], bytes)
-	return hash
+	unsigned := tx.InnerData.copy()
+	unsigned.setSignature(nil, nil, nil, nil)
+	return prefixedSerializationHash(byte(tx.Type()), unsigned)
 }
 
 func (as AdamniteSigner) ChainType() *big.Int {
-	return nil
+	return as.chainID
 }
 
 func (as AdamniteSigner) SignatureValues(tx *Transaction, signature []byte) (r, s, v *big.Int, err error) {
-	if tx.Type() != VOTE_TX && tx.Type() != NORMAL_TX {
-		return nil, nil, nil, ErrTxTypeNotSupported
+	if _, err := newTransactionData(tx.Type()); err != nil {
+		return nil, nil, nil, err
 	}
-	r, s, v = decodeSignature(signature)
-	return r, s, v, nil
+	return decodeSignature(signature)
 }
 
-func decodeSignature(sig []byte) (r, s, v *big.Int) {
+func decodeSignature(sig []byte) (r, s, v *big.Int, err error) {
 	if len(sig) != crypto.SignatureLength {
-		panic(fmt.Sprintf("wrong size for signature: got %d, want %d", len(sig), crypto.SignatureLength))
+		return nil, nil, nil, fmt.Errorf("%w: got %d bytes, want %d", ErrInvalidSig, len(sig), crypto.SignatureLength)
 	}
 	r = new(big.Int).SetBytes(sig[:32])
 	s = new(big.Int).SetBytes(sig[32:64])
 	v = new(big.Int).SetBytes([]byte{sig[64] + 27})
-	return r, s, v
+	return r, s, v, nil
+}
+
+// encodeSignature is the inverse of decodeSignature.
+func encodeSignature(r, s, v *big.Int) []byte {
+	sig := make([]byte, crypto.SignatureLength)
+	r.FillBytes(sig[:32])
+	s.FillBytes(sig[32:64])
+	sig[64] = byte(v.Uint64() - 27)
+	return sig
 }
 
 func recoverPlain(sighash common.Hash, R, S, Vb *big.Int) (common.Address, error) {
@@ -117,8 +139,5 @@ func Sender(signer Signer, tx *Transaction) (common.Address, error) {
 }
 
 func MakeSigner(config *params.ChainConfig, blockNumber *big.Int) Signer {
-	var signer Signer
-
-	return signer
-
+	return NewAdamniteSigner(config.ChainID)
 }
@@ -12,9 +12,10 @@ func TestAdamniteSigner(t *testing.T) {
 	key, _ := crypto.GenerateKey()
 	addr := crypto.PubkeyToAddress(key.PublicKey)
 
-	signer := AdamniteSigner{}
+	signer := NewAdamniteSigner(big.NewInt(889))
 
 	vote := NewVoteTransaction(
+		big.NewInt(889),
 		0,
 		common.HexToAddress("0x2d9487a9551db05414018c7fac9aed393f2fccda"),
 		new(big.Int),
@@ -36,4 +37,32 @@ func TestAdamniteSigner(t *testing.T) {
 	if from != addr {
 		t.Errorf("executed from and addr to be equal. Got %x want %x", from, addr)
 	}
+
+	if _, err := Sender(NewAdamniteSigner(big.NewInt(1)), tx); err != ErrInvalidChainId {
+		t.Errorf("expected a signer for another chain to reject the transaction. Got %v", err)
+	}
+	if _, err := Sender(signer, vote); err != ErrInvalidSig {
+		t.Errorf("expected an unsigned transaction to have no sender. Got %v", err)
+	}
+}
+
+// flipping s to N-s, with the other recovery id, recovers the same sender, so it has to be refused to keep one
+// signature, and one hash, per transaction
+func TestAdamniteSignerMalleability(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	signer := NewAdamniteSigner(big.NewInt(889))
+	tx, err := SignTransaction(NewTransferTransaction(big.NewInt(889), 0, common.Address{1}, big.NewInt(1), big.NewInt(1), 1), signer, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v, r, s := tx.RawSignature()
+	malleated := tx.InnerData.copy()
+	malleated.setSignature(nil, new(big.Int).Sub(big.NewInt(55), v), r, new(big.Int).Sub(crypto.S256().Params().N, s))
+	if _, err := Sender(signer, NewTx(malleated)); err != ErrInvalidSig {
+		t.Errorf("expected a high s signature to be refused. Got %v", err)
+	}
+	if from, err := Sender(signer, tx); err != nil || from != crypto.PubkeyToAddress(key.PublicKey) {
+		t.Errorf("expected the original signature to recover the sender. Got %x, %v", from, err)
+	}
 }
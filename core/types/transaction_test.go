@@ -0,0 +1,100 @@
+package types
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/adamnite/go-adamnite/common"
+	"github.com/adamnite/go-adamnite/crypto"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+func testTransactions(chainID *big.Int) []*Transaction {
+	return []*Transaction{
+		NewTransferTransaction(chainID, 1, common.Address{1}, big.NewInt(10), big.NewInt(2), 1),
+		NewVoteTransaction(chainID, 2, common.Address{2}, big.NewInt(2), 21000),
+		NewContractCallTransaction(chainID, 3, common.Address{3}, big.NewInt(5), []byte{0xA, 0xB}, big.NewInt(1), 50000),
+		NewContractCreateTransaction(chainID, 4, big.NewInt(0), []byte{0, 0x61, 0x73, 0x6D}, big.NewInt(1), 90000),
+	}
+}
+
+func TestTransactionEncoding(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	signer := NewAdamniteSigner(big.NewInt(889))
+
+	for _, unsigned := range testTransactions(big.NewInt(889)) {
+		tx, err := SignTransaction(unsigned, signer, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		encoded, err := msgpack.Marshal(tx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var decoded *Transaction
+		if err := msgpack.Unmarshal(encoded, &decoded); err != nil {
+			t.Fatalf("type %v: %v", tx.Type(), err)
+		}
+		if decoded.Type() != tx.Type() || decoded.Hash() != tx.Hash() {
+			t.Errorf("type %v: decoded into a different transaction", tx.Type())
+		}
+		if from, err := Sender(signer, decoded); err != nil || from != crypto.PubkeyToAddress(key.PublicKey) {
+			t.Errorf("type %v: the decoded transaction lost its sender. Got %x, %v", tx.Type(), from, err)
+		}
+
+		typed, err := tx.Encode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		decoded = new(Transaction)
+		if err := decoded.Decode(typed); err != nil {
+			t.Fatalf("type %v: %v", tx.Type(), err)
+		}
+		if decoded.Hash() != tx.Hash() || !bytes.Equal(decoded.Signature(), tx.Signature()) {
+			t.Errorf("type %v: typed decoding gave a different transaction", tx.Type())
+		}
+	}
+
+	if err := new(Transaction).Decode([]byte{byte(VOTE_POH_TX), 0x80}); err != ErrTxTypeNotSupported {
+		t.Errorf("expected an unknown type to be rejected. Got %v", err)
+	}
+}
+
+func TestTransactionMessage(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	signer := NewAdamniteSigner(big.NewInt(889))
+	txs := testTransactions(big.NewInt(889))
+
+	call, err := SignTransaction(txs[2], signer, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, err := call.AsMessage(signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.From() != crypto.PubkeyToAddress(key.PublicKey) || *msg.To() != (common.Address{3}) ||
+		!bytes.Equal(msg.Data(), []byte{0xA, 0xB}) || msg.Ate() != 50000 {
+		t.Errorf("the message doesn't match the contract call: %+v", msg)
+	}
+
+	create, err := SignTransaction(txs[3], signer, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg, err = create.AsMessage(signer); err != nil {
+		t.Fatal(err)
+	}
+	if msg.To() != nil {
+		t.Errorf("expected a contract creation to have no recipient. Got %x", msg.To())
+	}
+
+	//anything changed after signing is signed by someone else
+	tampered := call.InnerData.copy().(*ContractCallTransaction)
+	tampered.Amount = big.NewInt(500)
+	if from, _ := Sender(signer, NewTx(tampered)); from == crypto.PubkeyToAddress(key.PublicKey) {
+		t.Errorf("a tampered transaction kept its sender")
+	}
+}
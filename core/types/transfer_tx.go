@@ -0,0 +1,61 @@
+package types
+
+import (
+	"math/big"
+
+	"github.com/adamnite/go-adamnite/common"
+)
+
+// TransferTransaction sends funds from one account to another, without running any code.
+type TransferTransaction struct {
+	ChainID  *big.Int       // the chain the transaction is for, see params.ChainConfig
+	Nonce    uint64         // nonce of the sender account
+	To       common.Address // the account receiving the funds
+	Amount   *big.Int       // funds sent
+	AtePrice *big.Int       // price paid per ate
+	AteMax   uint64         // most ate the transaction may use
+	V, R, S  *big.Int       // signature value
+}
+
+func NewTransferTransaction(chainID *big.Int, nonce uint64, to common.Address, amount *big.Int, atePrice *big.Int, ateMax uint64) *Transaction {
+	return NewTx(&TransferTransaction{
+		ChainID:  chainID,
+		Nonce:    nonce,
+		To:       to,
+		Amount:   amount,
+		AtePrice: atePrice,
+		AteMax:   ateMax,
+	})
+}
+
+func (tx *TransferTransaction) copy() Transaction_Data {
+	return &TransferTransaction{
+		ChainID:  copyBig(tx.ChainID),
+		Nonce:    tx.Nonce,
+		To:       tx.To,
+		Amount:   copyBig(tx.Amount),
+		AtePrice: copyBig(tx.AtePrice),
+		AteMax:   tx.AteMax,
+		V:        copyBig(tx.V),
+		R:        copyBig(tx.R),
+		S:        copyBig(tx.S),
+	}
+}
+
+func (tx *TransferTransaction) txtype() TxType         { return NORMAL_TX }
+func (tx *TransferTransaction) chain_TYPE() *big.Int   { return tx.ChainID }
+func (tx *TransferTransaction) amount() *big.Int       { return tx.Amount }
+func (tx *TransferTransaction) message() []byte        { return nil }
+func (tx *TransferTransaction) message_size() *big.Int { return new(big.Int) }
+func (tx *TransferTransaction) ATE_MAX() uint64        { return tx.AteMax }
+func (tx *TransferTransaction) ATE_price() *big.Int    { return tx.AtePrice }
+func (tx *TransferTransaction) to() *common.Address    { return &tx.To }
+func (tx *TransferTransaction) nonce() uint64          { return tx.Nonce }
+
+func (tx *TransferTransaction) rawSignature() (v, r, s *big.Int) {
+	return tx.V, tx.R, tx.S
+}
+
+func (tx *TransferTransaction) setSignature(chainID, v, r, s *big.Int) {
+	tx.V, tx.R, tx.S = v, r, s
+}
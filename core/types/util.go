@@ -1,6 +1,10 @@
 package types
 
-import "github.com/adamnite/go-adamnite/common"
+import (
+	"math/big"
+
+	"github.com/adamnite/go-adamnite/common"
+)
 
 type writeCounter common.StorageSize
 
@@ -8,3 +12,11 @@ func (c *writeCounter) Write(b []byte) (int, error) {
 	*c += writeCounter(len(b))
 	return len(b), nil
 }
+
+// copyBig copies x, treating nil as zero.
+func copyBig(x *big.Int) *big.Int {
+	if x == nil {
+		return new(big.Int)
+	}
+	return new(big.Int).Set(x)
+}
@@ -8,6 +8,7 @@ import (
 
 type VoteTransaction struct {
 	Type      TxType         // transaction type
+	ChainID   *big.Int       // the chain the transaction is for, see params.ChainConfig
 	Nonce     uint64         // nonce of the sender account
 	Candidate common.Address // the candidate address of witness or block producer
 	AtePrice  *big.Int       // wei per gas
@@ -16,9 +17,10 @@ type VoteTransaction struct {
 
 }
 
-func NewVoteTransaction(nonce uint64, candidate common.Address, atePrice *big.Int, ateMax uint64) *Transaction {
+func NewVoteTransaction(chainID *big.Int, nonce uint64, candidate common.Address, atePrice *big.Int, ateMax uint64) *Transaction {
 	return NewTx(&VoteTransaction{
 		Type:      VOTE_TX,
+		ChainID:   chainID,
 		Nonce:     nonce,
 		Candidate: candidate,
 		AtePrice:  atePrice,
@@ -29,6 +31,7 @@ func NewVoteTransaction(nonce uint64, candidate common.Address, atePrice *big.In
 func (tx *VoteTransaction) copy() Transaction_Data {
 	cpy := &VoteTransaction{
 		Type:      tx.Type,
+		ChainID:   new(big.Int),
 		Nonce:     tx.Nonce,
 		Candidate: tx.Candidate,
 		AtePrice:  new(big.Int),
@@ -38,6 +41,10 @@ func (tx *VoteTransaction) copy() Transaction_Data {
 		S:         new(big.Int),
 	}
 
+	if tx.ChainID != nil {
+		cpy.ChainID.Set(tx.ChainID)
+	}
+
 	if tx.AtePrice != nil {
 		cpy.AtePrice.Set(tx.AtePrice)
 	}
@@ -58,7 +65,7 @@ func (tx *VoteTransaction) copy() Transaction_Data {
 }
 
 func (tx *VoteTransaction) txtype() TxType         { return VOTE_TX }
-func (tx *VoteTransaction) chain_TYPE() *big.Int   { return nil }
+func (tx *VoteTransaction) chain_TYPE() *big.Int   { return tx.ChainID }
 func (tx *VoteTransaction) amount() *big.Int       { return nil }
 func (tx *VoteTransaction) message() []byte        { return nil }
 func (tx *VoteTransaction) message_size() *big.Int { return nil }
@@ -28,6 +28,7 @@ import (
 
 var (
 	secp256k1N, _  = new(big.Int).SetString("fffffffffffffffffffffffffffffffebaaedce6af48a03bbfd25e8cd0364141", 16)
+	secp256k1halfN = new(big.Int).Div(secp256k1N, big.NewInt(2))
 )
 
 var errInvalidPubkey = errors.New("invalid secp256k1 public key")
@@ -201,7 +202,8 @@ func ValidateSignatureValues(v byte, r, s *big.Int) bool {
 	if r.Cmp(big.NewInt(1)) < 0 || s.Cmp(big.NewInt(1)) < 0 {
 		return false
 	}
-	return r.Cmp(secp256k1N) < 0 && s.Cmp(secp256k1N) < 0 && (v == 0 || v == 1)
+	//s and N-s both verify, so only the lower is taken to keep one valid signature per message
+	return r.Cmp(secp256k1N) < 0 && s.Cmp(secp256k1halfN) <= 0 && (v == 0 || v == 1)
 }
 
 // UnmarshalPubkey converts bytes to a secp256k1 public key.
@@ -6,8 +6,13 @@ import (
 	"github.com/adamnite/go-adamnite/database/merkle"
 
 	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/storage"
+	"github.com/syndtr/goleveldb/leveldb/util"
 )
 
+// ErrNotFound is returned by Get when the key is not stored.
+var ErrNotFound = leveldb.ErrNotFound
+
 type Database struct {
 	Path string             // path to LevelDB instance
 	impl *leveldb.DB        // LevelDB instance
@@ -29,6 +34,21 @@ func New(path string) (*Database, error) {
 	return db, nil
 }
 
+// NewMemory creates a database that is only held in memory, mostly for tests.
+func NewMemory() (*Database, error) {
+	impl, err := leveldb.Open(storage.NewMemStorage(), nil)
+	if err != nil {
+		log.Printf("[Database] Opening memory storage error: %s", err)
+		return nil, err
+	}
+
+	db := &Database{
+		impl: impl,
+		tree: merkle.NewEmptyTree(),
+	}
+	return db, nil
+}
+
 func (db *Database) Close() error {
 	return db.impl.Close()
 }
@@ -43,6 +63,11 @@ func (db *Database) Get(key []byte) ([]byte, error) {
 	return value, nil
 }
 
+// Has reports whether the key is in the key-value store.
+func (db *Database) Has(key []byte) (bool, error) {
+	return db.impl.Has(key, nil)
+}
+
 // Insert inserts the given value into the key-value store.
 func (db *Database) Insert(key []byte, value []byte) error {
 	return db.impl.Put(key, value, nil)
@@ -51,4 +76,39 @@ func (db *Database) Insert(key []byte, value []byte) error {
 // Delete removes the given value from the key-value store.
 func (db *Database) Delete(key []byte) error {
 	return db.impl.Delete(key, nil)
-}
\ No newline at end of file
+}
+
+// Iterate calls fn with every key starting with prefix, in key order, beginning at start if it is set.
+// Iteration stops early once fn returns false. The slices passed to fn are only valid during the call.
+func (db *Database) Iterate(prefix []byte, start []byte, fn func(key []byte, value []byte) bool) error {
+	keyRange := util.BytesPrefix(prefix)
+	if start != nil {
+		keyRange.Start = start
+	}
+	iter := db.impl.NewIterator(keyRange, nil)
+	defer iter.Release()
+	for iter.Next() {
+		if !fn(iter.Key(), iter.Value()) {
+			break
+		}
+	}
+	return iter.Error()
+}
+
+// Batch groups inserts and deletes so they are written to the store at once.
+type Batch struct {
+	impl leveldb.Batch
+}
+
+func (b *Batch) Insert(key []byte, value []byte) {
+	b.impl.Put(key, value)
+}
+
+func (b *Batch) Delete(key []byte) {
+	b.impl.Delete(key)
+}
+
+// Write applies every change of the batch atomically.
+func (db *Database) Write(b *Batch) error {
+	return db.impl.Write(&b.impl, nil)
+}
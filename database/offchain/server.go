@@ -0,0 +1,205 @@
+package offchain
+
+import (
+	"encoding/hex"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/adamnite/go-adamnite/VM"
+	"github.com/adamnite/go-adamnite/common"
+	"github.com/adamnite/go-adamnite/database"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+const (
+	// contractNotStored is the body the VM client looks for when a contract is missing
+	contractNotStored = "contract not stored"
+
+	maxBodySize = 4 << 20
+)
+
+// Server is the off-chain code DB the VM uploads methods and contracts to, and reads them back from.
+type Server struct {
+	store    VM.CodeStore
+	server   *http.Server
+	listener net.Listener
+	ownedDB  *database.Database // closed along with the server, if the server opened it
+}
+
+// NewServer creates a server keeping everything in store. Nothing is served until Start is called.
+func NewServer(store VM.CodeStore) *Server {
+	s := &Server{store: store}
+	s.server = &http.Server{Handler: s.Handler()}
+	return s
+}
+
+// NewInProcess starts a server on a random local port, backed by a memory database.
+// It is meant for tests, which can point the VM to Endpoint.
+func NewInProcess() (*Server, error) {
+	db, err := database.NewMemory()
+	if err != nil {
+		return nil, err
+	}
+	s := NewServer(VM.NewLevelDBCodeStore(db))
+	s.ownedDB = db
+	if err := s.Start("127.0.0.1:0"); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// Handler returns the routes of the server, so it can be mounted elsewhere.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/uploadCode", s.handleUploadCode)
+	mux.HandleFunc("/code/", s.handleCode)
+	mux.HandleFunc("/contract/", s.handleContract)
+	return mux
+}
+
+// Start listens on addr and serves in the background.
+func (s *Server) Start(addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	s.listener = listener
+	go func() {
+		if err := s.server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			log.Printf("[Offchain DB] Serving error: %s", err)
+		}
+	}()
+	return nil
+}
+
+// Endpoint returns the URL the VM should use to reach the server.
+func (s *Server) Endpoint() string {
+	if s.listener == nil {
+		return ""
+	}
+	return "http://" + s.listener.Addr().String() + "/"
+}
+
+// Close stops the server, and closes the database if it was created by NewInProcess.
+func (s *Server) Close() error {
+	err := s.server.Close()
+	if s.ownedDB != nil {
+		if dbErr := s.ownedDB.Close(); err == nil {
+			err = dbErr
+		}
+	}
+	return err
+}
+
+func readBody(w http.ResponseWriter, r *http.Request) ([]byte, bool) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodySize))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		return nil, false
+	}
+	return body, true
+}
+
+func (s *Server) handleUploadCode(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut && r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	body, ok := readBody(w, r)
+	if !ok {
+		return
+	}
+	var code VM.CodeStored
+	if err := msgpack.Unmarshal(body, &code); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	hash, err := s.store.PutMethod(code)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Write([]byte(hex.EncodeToString(hash)))
+}
+
+func (s *Server) handleCode(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	hash, err := hex.DecodeString(strings.TrimPrefix(r.URL.Path, "/code/"))
+	if err != nil || len(hash) == 0 {
+		http.Error(w, "invalid code hash", http.StatusBadRequest)
+		return
+	}
+	code, err := s.store.GetMethod(hash)
+	if errors.Is(err, VM.ErrCodeNotStored) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	packed, err := msgpack.Marshal(code)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Write(packed)
+}
+
+func (s *Server) handleContract(w http.ResponseWriter, r *http.Request) {
+	rawAddress, err := hex.DecodeString(strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/contract/"), "0x"))
+	if err != nil || len(rawAddress) == 0 {
+		http.Error(w, "invalid contract address", http.StatusBadRequest)
+		return
+	}
+	address := common.BytesToAddress(rawAddress)
+
+	switch r.Method {
+	case http.MethodGet:
+		con, err := s.store.GetContract(address)
+		if err == VM.ERR_CONTRACT_NOT_STORED {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(contractNotStored))
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		packed, err := VM.ContractToMSGPackBytes(*con)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Write(packed)
+	case http.MethodPut, http.MethodPost:
+		body, ok := readBody(w, r)
+		if !ok {
+			return
+		}
+		con, err := VM.MSGPackBytesToContract(body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if con.Address != address {
+			http.Error(w, "contract address does not match the path", http.StatusBadRequest)
+			return
+		}
+		if err := s.store.PutContract(*con); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,49 @@
+package offchain
+
+import (
+	"encoding/hex"
+	"math/big"
+	"testing"
+
+	"github.com/adamnite/go-adamnite/VM"
+	"github.com/adamnite/go-adamnite/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUploadAndGet(t *testing.T) {
+	server, err := NewInProcess()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	code := VM.CodeStored{
+		CodeParams:  []VM.ValueType{VM.Op_i64, VM.Op_i64},
+		CodeResults: []VM.ValueType{VM.Op_i64},
+		CodeBytes:   []byte{0x00, 0x20, 0x00, 0x20, 0x01, 0x7c, 0x0b},
+	}
+	store := VM.NewHTTPCodeStore(server.Endpoint())
+	hash, err := store.PutMethod(code)
+	assert.NoError(t, err)
+	localHash, _ := code.Hash()
+	assert.Equal(t, localHash, hash)
+
+	stored, err := store.GetMethod(hash)
+	assert.NoError(t, err)
+	assert.Equal(t, code, *stored)
+
+	contract := VM.Contract{
+		Address:    common.Address{1, 2, 3},
+		Value:      big.NewInt(0),
+		CodeHashes: []string{hex.EncodeToString(hash)},
+		Storage:    []uint64{1, 2},
+	}
+	_, err = store.GetContract(contract.Address)
+	assert.ErrorIs(t, err, VM.ERR_CONTRACT_NOT_STORED)
+
+	assert.NoError(t, store.PutContract(contract))
+	got, err := store.GetContract(contract.Address)
+	assert.NoError(t, err)
+	assert.Equal(t, contract.CodeHashes, got.CodeHashes)
+	assert.Equal(t, contract.Storage, got.Storage)
+}
@@ -18,6 +18,9 @@ var (
 	headerNumberPrefix = []byte("H") // headerNumberPrefix + hash -> num (uint64 big endian)
 
 	preimagePrefix = []byte("secure-key-") // preimagePrefix + hash -> preimage
+
+	txLookupPrefix      = []byte("l") // txLookupPrefix + hash -> transaction lookup entry
+	blockReceiptsPrefix = []byte("r") // blockReceiptsPrefix + num (uint64 big endian) + hash -> block receipts
 )
 
 // blockHeaderHashKey = blockHeaderPrefix + num + headerHashSuffix
@@ -53,3 +56,13 @@ func headerNumberKey(hash common.Hash) []byte {
 func headerKey(number uint64, hash common.Hash) []byte {
 	return append(append(headerPrefix, encodeBlockNumber(number)...), hash.Bytes()...)
 }
+
+// txLookupKey = txLookupPrefix + hash
+func txLookupKey(hash common.Hash) []byte {
+	return append(append([]byte{}, txLookupPrefix...), hash.Bytes()...)
+}
+
+// blockReceiptsKey = blockReceiptsPrefix + num (uint64 big endian) + hash
+func blockReceiptsKey(number uint64, hash common.Hash) []byte {
+	return append(append(append([]byte{}, blockReceiptsPrefix...), encodeBlockNumber(number)...), hash.Bytes()...)
+}
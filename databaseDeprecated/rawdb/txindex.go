@@ -0,0 +1,73 @@
+package rawdb
+
+import (
+	"github.com/adamnite/go-adamnite/common"
+	"github.com/adamnite/go-adamnite/core/types"
+	"github.com/adamnite/go-adamnite/databaseDeprecated"
+
+	log "github.com/sirupsen/logrus"
+	encoding "github.com/vmihailenco/msgpack/v5"
+)
+
+// TxLookupEntry is where a transaction was included.
+type TxLookupEntry struct {
+	BlockHash   common.Hash
+	BlockNumber uint64
+	Index       uint64 // position of the transaction within the block
+}
+
+// WriteTxLookupEntries indexes every transaction of the block by its hash.
+func WriteTxLookupEntries(db adamnitedb.AdamniteDBWriter, block *types.Block) {
+	for i, tx := range block.Body().Transactions {
+		data, err := encoding.Marshal(TxLookupEntry{
+			BlockHash:   block.Hash(),
+			BlockNumber: block.Numberu64(),
+			Index:       uint64(i),
+		})
+		if err != nil {
+			log.Fatal("Failed to encode transaction lookup entry", "err", err)
+		}
+		if err := db.Insert(txLookupKey(tx.Hash()), data); err != nil {
+			log.Fatal("Failed to store transaction lookup entry", "err", err)
+		}
+	}
+}
+
+// ReadTxLookupEntry returns where the transaction was included, or nil if it's unknown.
+func ReadTxLookupEntry(db adamnitedb.AdamniteDBReader, hash common.Hash) *TxLookupEntry {
+	data, _ := db.Get(txLookupKey(hash))
+	if len(data) == 0 {
+		return nil
+	}
+	entry := new(TxLookupEntry)
+	if err := encoding.Unmarshal(data, entry); err != nil {
+		log.Error("Invalid transaction lookup entry", "hash", hash, "err", err)
+		return nil
+	}
+	return entry
+}
+
+// WriteReceipts stores the receipts of every transaction in the block.
+func WriteReceipts(db adamnitedb.AdamniteDBWriter, hash common.Hash, number uint64, receipts types.Receipts) {
+	data, err := encoding.Marshal(receipts)
+	if err != nil {
+		log.Fatal("Failed to encode block receipts", "err", err)
+	}
+	if err := db.Insert(blockReceiptsKey(number, hash), data); err != nil {
+		log.Fatal("Failed to store block receipts", "err", err)
+	}
+}
+
+// ReadReceipts returns the receipts of the block, or nil if none were stored for it.
+func ReadReceipts(db adamnitedb.AdamniteDBReader, hash common.Hash, number uint64) types.Receipts {
+	data, _ := db.Get(blockReceiptsKey(number, hash))
+	if len(data) == 0 {
+		return nil
+	}
+	var receipts types.Receipts
+	if err := encoding.Unmarshal(data, &receipts); err != nil {
+		log.Error("Invalid block receipts", "hash", hash, "err", err)
+		return nil
+	}
+	return receipts
+}
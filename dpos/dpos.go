@@ -359,7 +359,7 @@ func (adpos *AdamniteDPOS) calVote(chain ChainReader, header *types.BlockHeader,
 walk:
 	for _, tx := range txs {
 
-		sender, _ := types.Sender(types.AdamniteSigner{}, tx)
+		sender, _ := types.Sender(types.MakeSigner(adpos.config, number), tx)
 
 		vote := utils.Voter{}
 		switch tx.Type() {
@@ -14,6 +14,9 @@ var (
 	ErrContactIsSelf           = fmt.Errorf("the contact you're trying to add is the owner of this contact list")
 	ErrDistrustedConnection    = fmt.Errorf("the contact attempting to connect to is untrustworthy")
 	ErrNoNewConnectionsMade    = fmt.Errorf("no new connections were actually made after sprawl")
+	ErrUnknownContact          = fmt.Errorf("no contact is known with that node ID")
+	ErrNoBouncerServer         = fmt.Errorf("this node has no bouncer server running")
+	ErrHostingTLS              = fmt.Errorf("peers dial the hosting server without TLS, it can only be used on the bouncer")
 )
 
 var blacklisted common.Void
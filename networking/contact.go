@@ -54,6 +54,16 @@ func (cb *ContactBook) Close() {
 	}
 }
 
+// GetContactByID returns the contact with that node ID, or nil if it isn't known
+func (cb *ContactBook) GetContactByID(nodeID common.Address) *Contact {
+	for contact := range cb.connectionsByContact {
+		if contact.NodeID == nodeID {
+			return contact
+		}
+	}
+	return nil
+}
+
 func (cb *ContactBook) AddConnection(contact *Contact) error {
 	if cb.ownerContact == contact {
 		return ErrContactIsSelf //don't try to connect to yourself.
@@ -6,7 +6,9 @@ import (
 	"github.com/adamnite/go-adamnite/databaseDeprecated/statedb"
 	"github.com/adamnite/go-adamnite/blockchain"
 	"github.com/adamnite/go-adamnite/common"
+	"github.com/adamnite/go-adamnite/event"
 	"github.com/adamnite/go-adamnite/rpc"
+	"github.com/adamnite/go-adamnite/txpool"
 	"github.com/adamnite/go-adamnite/utils"
 )
 
@@ -40,10 +42,13 @@ type NetNode struct {
 
 	hostingServer *rpc.AdamniteServer
 	bouncerServer *rpc.BouncerServer //bouncer server is optional. Acting as the input for off chain interactions (eg, from web)
+	listen        rpc.ListenConfig   //where the hosting server listens. Only this machine can reach it by default
 
 	consensusCandidateHandler   func(utils.Candidate) error
 	consensusVoteHandler        func(utils.Voter) error
 	consensusTransactionHandler func(*utils.Transaction) error
+
+	txPool *txpool.TxPool //holds the transactions heard of, once checked. Optional
 }
 
 func NewNetNode(address common.Address) *NetNode {
@@ -74,25 +79,78 @@ func (n NetNode) GetBouncerString() string {
 
 }
 
+// set where the hosting server listens, taking effect the next time it's added. Peers can't reach it over TLS.
+func (n *NetNode) SetListenConfig(listen rpc.ListenConfig) error {
+	if listen.TLS {
+		return ErrHostingTLS
+	}
+	n.listen = listen
+	return nil
+}
 func (n *NetNode) SetMaxConnections(newMax uint) {
 	n.maxOutboundConnections = newMax
 }
-func (n *NetNode) SetBounceServerMessaging(getMsgs func(common.Address, common.Address) []*utils.CaesarMessage) {
+func (n *NetNode) SetBounceServerMessaging(
+	getMsgs func(common.Address, common.Address) []*utils.CaesarMessage,
+	sendMsg func(*utils.CaesarMessage) error) {
+	if n.bouncerServer == nil {
+		return
+	}
+	n.bouncerServer.SetMessagingHandlers(getMsgs, sendMsg)
+}
+
+// let web clients subscribe to the messages the node stores
+func (n *NetNode) SetBounceServerSubscriptions(subscribe func(chan<- *utils.CaesarMessage) event.Subscription) {
+	if n.bouncerServer == nil {
+		return
+	}
+	n.bouncerServer.SetMessageSubscriptions(subscribe)
+}
+func (n *NetNode) SetBounceServerChunks(getChunk func([]byte) (*utils.CaesarChunk, error)) {
 	if n.bouncerServer == nil {
 		return
 	}
-	n.bouncerServer.SetMessagingHandlers(getMsgs)
+	n.bouncerServer.SetChunkHandler(getChunk)
 }
 func (n *NetNode) AddBouncerServer(
 	state *statedb.StateDB, chain *blockchain.Blockchain,
-	hostPort uint32,
-) {
+	listen rpc.ListenConfig,
+) error {
 	if n.bouncerServer != nil {
 		log.Println("closing old bouncer server before starting new")
 		n.bouncerServer.Close()
+		n.bouncerServer = nil
+	}
+	bouncer, err := rpc.NewBouncerServer(state, chain, listen)
+	if err != nil {
+		return err
 	}
-	n.bouncerServer = rpc.NewBouncerServer(state, chain, hostPort)
+	n.bouncerServer = bouncer
 	n.bouncerServer.SetHandlers(n.handleForward)
+	return nil
+}
+
+// set who can call what on the bouncer
+func (n *NetNode) SetBouncerPolicy(policy *rpc.Policy) error {
+	if n.bouncerServer == nil {
+		return ErrNoBouncerServer
+	}
+	n.bouncerServer.SetPolicy(policy)
+	return nil
+}
+
+// serve the bouncer as JSON-RPC over HTTP too, for browsers. Any origin is allowed if none are given.
+func (n *NetNode) AddBouncerJSONRPC(listen rpc.ListenConfig, allowedOrigins []string) error {
+	if n.bouncerServer == nil {
+		return ErrNoBouncerServer
+	}
+	return n.bouncerServer.StartJSONRPC(listen, allowedOrigins)
+}
+func (n NetNode) GetBouncerJSONRPCString() string {
+	if n.bouncerServer == nil {
+		return ""
+	}
+	return n.bouncerServer.JSONRPCAddr()
 }
 
 // spins up a RPC server with chain reference, and capability to properly propagate transactions
@@ -101,11 +159,9 @@ func (n *NetNode) AddFullServer(
 	transactionHandler func(*utils.Transaction) error,
 	candidateHandler func(utils.Candidate) error,
 	voteHandler func(utils.Voter) error) error {
-	if n.hostingServer != nil {
-		log.Println("closing old server before adding new server")
-		n.hostingServer.Close() //assume they want to restart the server then
+	if err := n.startServer(); err != nil {
+		return err
 	}
-	n.hostingServer = rpc.NewAdamniteServer(0)
 	n.consensusCandidateHandler = candidateHandler
 	n.consensusVoteHandler = voteHandler
 	n.updateServer()
@@ -116,19 +172,61 @@ func (n *NetNode) AddFullServer(
 
 func (n *NetNode) AddMessagingCapabilities(msgHandler func(*utils.CaesarMessage)) {
 	if n.hostingServer == nil {
-		n.AddServer()
+		if err := n.AddServer(); err != nil {
+			log.Println(err)
+			return
+		}
 	}
 	n.hostingServer.SetCaesarMessagingHandlers(msgHandler)
 }
 
+// handle mailbox records, and let accounts fetch and ack the messages held for them.
+func (n *NetNode) AddMailboxCapabilities(
+	recordHandler func(*utils.CaesarMailboxRecord),
+	fetchHandler func(utils.CaesarMailboxRequest) ([]*utils.CaesarMessage, error),
+	ackHandler func(utils.CaesarMailboxRequest) error) {
+	if n.hostingServer == nil {
+		if err := n.AddServer(); err != nil {
+			log.Println(err)
+			return
+		}
+	}
+	n.hostingServer.SetCaesarMailboxHandlers(recordHandler, fetchHandler, ackHandler)
+}
+
+// keep the chunks of attachments sent to us, and share the chunks we hold.
+func (n *NetNode) AddChunkCapabilities(
+	chunkHandler func(*utils.CaesarChunkShare),
+	fetchHandler func([]byte) (*utils.CaesarChunk, error)) {
+	if n.hostingServer == nil {
+		if err := n.AddServer(); err != nil {
+			log.Println(err)
+			return
+		}
+	}
+	n.hostingServer.SetCaesarChunkHandlers(chunkHandler, fetchHandler)
+}
+
 // spins up a server for this node.
 func (n *NetNode) AddServer() error {
+	if err := n.startServer(); err != nil {
+		return err
+	}
+	n.updateServer()
+	return nil
+}
+
+func (n *NetNode) startServer() error {
 	if n.hostingServer != nil {
 		log.Println("closing old server before adding new server")
 		n.hostingServer.Close() //assume they want to restart the server then
+		n.hostingServer = nil
 	}
-	n.hostingServer = rpc.NewAdamniteServer(0)
-	n.updateServer()
+	server, err := rpc.NewAdamniteServer(n.listen)
+	if err != nil {
+		return err
+	}
+	n.hostingServer = server
 	return nil
 }
 
@@ -145,7 +243,7 @@ func (n *NetNode) updateServer() {
 		n.consensusVoteHandler,
 	)
 	go n.hostingServer.Run()
-	n.thisContact.ConnectionString = n.hostingServer.Addr()
+	n.thisContact.ConnectionString = n.hostingServer.AdvertisedAddr()
 }
 
 func (n *NetNode) Close() {
@@ -163,12 +261,25 @@ func (n *NetNode) Close() {
 
 }
 
+// hold the transactions this node hears of in the pool. Transactions the pool turns away aren't passed on.
+func (n *NetNode) SetTransactionPool(pool *txpool.TxPool) {
+	n.txPool = pool
+}
+
 // use to setup a max length a node will have its grey list as. Use 0 to ignore this. Only truncates when shortening the list
 func (n *NetNode) SetMaxGreyList(maxLength uint) {
 	n.contactBook.maxGreyList = maxLength
 }
 
 func (n *NetNode) handleTransaction(transaction *utils.Transaction, transactionBytes *[]byte) error {
+	if err := transaction.Verify(); err != nil {
+		return err //not signed by its sender, so it isn't worth passing on
+	}
+	if n.txPool != nil {
+		if err := n.txPool.Add(transaction); err != nil {
+			return err
+		}
+	}
 	if n.consensusTransactionHandler == nil {
 		//we can't verify this, so just propagate it out!
 
@@ -194,6 +305,11 @@ func (n *NetNode) handleForward(content rpc.ForwardingContent, reply *[]byte) er
 				return connection.ForwardMessage(content, reply)
 			}
 		}
+		if content.Hops >= rpc.MaxForwardHops {
+			log.Println("dropping a forward that didn't reach its destination in time")
+			return nil
+		}
+		content.Hops++
 	}
 	//this has been added to us, (and isn't called if the message is directly to us.)
 	log.Printf("forwarding to all %v known contacts", len(n.activeContactToClient))
@@ -258,3 +374,61 @@ func (n *NetNode) Propagate(v interface{}) error {
 	}
 	return n.handleForward(foo, nil)
 }
+
+// send a forward-able message that only the destination node handles. It goes straight to them if we know how to
+// reach them, otherwise it is passed along, at most rpc.MaxForwardHops times, until it reaches them.
+func (n *NetNode) PropagateTo(v interface{}, destination common.Address) error {
+	foo, err := rpc.CreateForwardTo(v, destination)
+	if err != nil {
+		return err
+	}
+	if client, err := n.clientFor(destination); err == nil {
+		n.hostingServer.AlreadySeen(foo)
+		return client.ForwardMessage(foo, &[]byte{})
+	}
+	return n.handleForward(foo, &[]byte{})
+}
+
+// clientFor returns a connection to the node with that ID, connecting to it if we know its contact
+func (n *NetNode) clientFor(nodeID common.Address) (*rpc.AdamniteClient, error) {
+	for contact, client := range n.activeContactToClient {
+		if contact.NodeID == nodeID {
+			return client, nil
+		}
+	}
+	contact := n.contactBook.GetContactByID(nodeID)
+	if contact == nil {
+		return nil, ErrUnknownContact
+	}
+	if err := n.ConnectToContact(contact); err != nil {
+		return nil, err
+	}
+	return n.activeContactToClient[contact], nil
+}
+
+// fetch the messages the mailbox node holds for the owner of the request
+func (n *NetNode) FetchCaesarMailbox(mailbox common.Address, request utils.CaesarMailboxRequest) ([]*utils.CaesarMessage, error) {
+	client, err := n.clientFor(mailbox)
+	if err != nil {
+		return nil, err
+	}
+	return client.FetchCaesarMailbox(request)
+}
+
+// let the mailbox node drop the messages the owner of the request received
+func (n *NetNode) AckCaesarMailbox(mailbox common.Address, request utils.CaesarMailboxRequest) error {
+	client, err := n.clientFor(mailbox)
+	if err != nil {
+		return err
+	}
+	return client.AckCaesarMailbox(request)
+}
+
+// fetch the chunk with that ID from the node holding it
+func (n *NetNode) FetchCaesarChunk(node common.Address, id []byte) (*utils.CaesarChunk, error) {
+	client, err := n.clientFor(node)
+	if err != nil {
+		return nil, err
+	}
+	return client.GetCaesarChunk(id)
+}
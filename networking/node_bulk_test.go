@@ -1,6 +1,7 @@
 package networking
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"math"
@@ -8,11 +9,18 @@ import (
 	"testing"
 	"time"
 
+	"github.com/adamnite/go-adamnite/databaseDeprecated/rawdb"
 	"github.com/adamnite/go-adamnite/databaseDeprecated/statedb"
 	"github.com/adamnite/go-adamnite/blockchain"
 	"github.com/adamnite/go-adamnite/common"
+	"github.com/adamnite/go-adamnite/crypto"
+	"github.com/adamnite/go-adamnite/dpos"
+	"github.com/adamnite/go-adamnite/params"
 	"github.com/adamnite/go-adamnite/rpc"
+	"github.com/adamnite/go-adamnite/rpc/bouncerclient"
+	"github.com/adamnite/go-adamnite/txpool"
 	"github.com/adamnite/go-adamnite/utils"
+	"github.com/adamnite/go-adamnite/utils/accounts"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -146,6 +154,59 @@ func TestLinearPropagationFromSide(t *testing.T) {
 	}
 }
 
+func TestPropagateToStaysOnRoute(t *testing.T) {
+	nodes, err := generateLineOfNodes(12)
+	if err != nil {
+		t.Fatal(err)
+	}
+	destination := nodes[len(nodes)-1]
+	received := make(chan *utils.CaesarChunkShare, 2)
+	destination.AddChunkCapabilities(func(share *utils.CaesarChunkShare) { received <- share }, nil)
+	sharer, err := accounts.GenerateAccount()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//not knowing the destination, the message is only relayed so far
+	lost, err := utils.NewCaesarChunkShare(sharer, utils.CaesarChunk{Data: []byte{1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := rpc.CreateForwardTo(lost, destination.thisContact.NodeID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := nodes[0].handleForward(content, &[]byte{}); err != nil {
+		t.Fatal(err)
+	}
+	for i, node := range nodes[1:] {
+		assert.Equal(t, i < rpc.MaxForwardHops, node.hostingServer.AlreadySeen(content), "node %v", i+1)
+	}
+
+	//knowing them, it goes straight there, and the nodes in between never see it
+	assert.NoError(t, nodes[0].contactBook.AddConnection(&destination.thisContact))
+	routed, err := utils.NewCaesarChunkShare(sharer, utils.CaesarChunk{Data: []byte{2}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sentAfter := time.Now().UnixMilli()
+	assert.NoError(t, nodes[0].PropagateTo(routed, destination.thisContact.NodeID))
+	sentBefore := time.Now().UnixMilli()
+	select {
+	case share := <-received:
+		assert.Equal(t, routed.Chunk.Data, share.Chunk.Data)
+	case <-time.After(time.Second):
+		t.Fatal("the destination never got the message")
+	}
+	content, _ = rpc.CreateForwardTo(routed, destination.thisContact.NodeID)
+	for sent := sentAfter; sent <= sentBefore; sent++ {
+		content.InitialTime = sent
+		for i, node := range nodes[1 : len(nodes)-1] {
+			assert.False(t, node.hostingServer.AlreadySeen(content), "node %v got a message for someone else", i+1)
+		}
+	}
+}
+
 func TestTransactionPropagation(t *testing.T) {
 	nodes, err := generateClusteredNodes(10, 15)
 	if err != nil {
@@ -172,12 +233,16 @@ func TestTransactionPropagation(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
+	key, _ := crypto.GenerateKey()
 	transaction := utils.Transaction{
-		From:      common.Address{0xA, 1, 2, 3, 4, 5},
-		To:        common.Address{0xB, 1, 2, 3, 4, 5},
-		Amount:    big.NewInt(1000),
-		Time:      time.Now(),
-		Signature: []byte{1, 2, 3, 4, 5},
+		From:    crypto.PubkeyToAddress(key.PublicKey),
+		To:      common.Address{0xB, 1, 2, 3, 4, 5},
+		Amount:  big.NewInt(1000),
+		ChainID: params.TestnetChainConfig.ChainID,
+		Time:    time.Now(),
+	}
+	if err := transaction.Sign(*key); err != nil {
+		t.Fatal(err)
 	}
 	log.Println("\n\nInfo")
 
@@ -195,6 +260,104 @@ func TestTransactionPropagation(t *testing.T) {
 	// assert.Equal(t, transaction, *ans, "not equal")
 }
 
+// transactions sent to a bouncer are checked, then reach the rest of the network
+func TestBouncerTransactionPropagation(t *testing.T) {
+	nodes, err := generateLineOfNodes(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	received := make(chan *utils.Transaction, 1)
+	testerNode := NewNetNode(common.Address{0xFF, 0xFE})
+	if err := testerNode.AddFullServer(&statedb.StateDB{}, &blockchain.Blockchain{}, func(transaction *utils.Transaction) error {
+		select { //nodes along the way may pass it on more than once
+		case received <- transaction:
+		default:
+		}
+		return nil
+	}, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := nodes[2].ConnectToContact(&testerNode.thisContact); err != nil {
+		t.Fatal(err)
+	}
+
+	key, _ := crypto.GenerateKey()
+	db := rawdb.NewMemoryDB()
+	state, _ := statedb.New(common.Hash{}, statedb.NewDatabase(db))
+	state.AddBalance(crypto.PubkeyToAddress(key.PublicKey), big.NewInt(1000))
+	chain, err := blockchain.NewBlockchain(db, params.TestnetChainConfig, dpos.New(params.TestnetChainConfig, db))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := nodes[0].AddBouncerServer(state, chain, rpc.ListenConfig{}); err != nil {
+		t.Fatal(err)
+	}
+	client, err := bouncerclient.Dial(context.Background(), nodes[0].bouncerServer.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	transaction := &utils.Transaction{
+		From:    crypto.PubkeyToAddress(key.PublicKey),
+		To:      common.Address{0xB},
+		Amount:  big.NewInt(1000),
+		ChainID: params.TestnetChainConfig.ChainID,
+		Time:    time.Now(),
+	}
+	if err := transaction.Sign(*key); err != nil {
+		t.Fatal(err)
+	}
+	hash, err := client.SendTransaction(context.Background(), transaction)
+	assert.NoError(t, err)
+	assert.Equal(t, transaction.Hash(), hash)
+
+	select {
+	case ans := <-received:
+		assert.True(t, ans.Equal(*transaction), "failed to return equal transaction")
+	case <-time.After(5 * time.Second):
+		t.Fatal("the transaction never reached the other end of the network")
+	}
+}
+
+// nodes with a pool hold the transactions they hear of, and only those that pass its checks
+func TestTransactionPoolPropagation(t *testing.T) {
+	nodes, err := generateLineOfNodes(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, _ := crypto.GenerateKey()
+	state, _ := statedb.New(common.Hash{}, statedb.NewDatabase(rawdb.NewMemoryDB()))
+	state.AddBalance(crypto.PubkeyToAddress(key.PublicKey), big.NewInt(1000))
+	pool := txpool.New(txpool.DefaultConfig, params.TestnetChainConfig, state, nil)
+	defer pool.Stop()
+	nodes[2].SetTransactionPool(pool)
+
+	client, err := rpc.NewAdamniteClient(nodes[0].thisContact.ConnectionString)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	transaction := &utils.Transaction{
+		From:    crypto.PubkeyToAddress(key.PublicKey),
+		To:      common.Address{0xB},
+		Amount:  big.NewInt(100),
+		ChainID: params.TestnetChainConfig.ChainID,
+		Fee:     big.NewInt(1),
+		Time:    time.Now(),
+	}
+	if err := transaction.Sign(*key); err != nil {
+		t.Fatal(err)
+	}
+	forged := *transaction
+	forged.Amount = big.NewInt(999)
+	assert.NoError(t, client.SendTransaction(transaction))
+	assert.EqualError(t, client.SendTransaction(&forged), utils.ErrIncorrectSigner.Error(), "a forged transaction was passed on")
+
+	assert.Eventually(t, func() bool { return pool.Has(transaction.Hash()) }, 5*time.Second, 10*time.Millisecond)
+	assert.False(t, pool.Has(forged.Hash()), "a transaction with a forged signature was pooled")
+}
+
 // generates a line where each node is connected to the one in front, and behind itself.
 func generateLineOfNodes(count int) ([]*NetNode, error) {
 	nodes := make([]*NetNode, count)
@@ -5,6 +5,7 @@ import (
 	"testing"
 
 	"github.com/adamnite/go-adamnite/common"
+	"github.com/adamnite/go-adamnite/rpc"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -74,3 +75,9 @@ func TestSingleNode(t *testing.T) {
 	fmt.Println(testingNode.thisContact.NodeID)
 
 }
+
+func TestHostingRefusesTLS(t *testing.T) {
+	node := NewNetNode(common.Address{0})
+	assert.ErrorIs(t, node.SetListenConfig(rpc.ListenConfig{TLS: true}), ErrHostingTLS)
+	assert.NoError(t, node.SetListenConfig(rpc.ListenConfig{Host: rpc.DefaultListenHost}))
+}
@@ -7,9 +7,11 @@ import (
 	"path/filepath"
 	"runtime"
 	"strings"
+	"time"
 
 	"github.com/adamnite/go-adamnite/bargossip"
 	"github.com/adamnite/go-adamnite/crypto"
+	"github.com/adamnite/go-adamnite/rpc"
 
 	log "github.com/sirupsen/logrus"
 )
@@ -18,6 +20,7 @@ const (
 	datadirPrivateKey      = "nodekey"
 	datadirNodeDatabase    = "nodes"
 	datadirDefaultKeystore = "keys"
+	datadirCaesarMessages  = "caesar"
 )
 
 type Config struct {
@@ -27,6 +30,36 @@ type Config struct {
 	KeyStoreDir string
 	IPCPath     string
 	P2P         bargossip.Config
+
+	// who can call what on the RPC servers, and how often, along with the admin tokens and keys. The defaults are
+	// used if left out
+	ServerPolicy  *rpc.Policy
+	BouncerPolicy *rpc.Policy
+
+	// where the RPC server listens. Left empty, it takes any free port on this machine only. Other nodes dial it
+	// without TLS, so TLS is refused here
+	ServerListen rpc.ListenConfig
+
+	// how long Caesar messages are kept for, forever if 0
+	CaesarRetention time.Duration
+
+	// how long Caesar attachment chunks are kept for, and the most bytes of them stored. Left 0, the defaults of the
+	// message store are used
+	CaesarChunkRetention time.Duration
+	CaesarChunkQuota     int
+
+	// the most bytes of Caesar attachment chunks stored that were shared by any one account. Left 0, the default of
+	// the message store is used
+	CaesarChunkShareQuota int
+}
+
+// DefaultDataDir is where a node keeps its data unless told otherwise, in the home directory of the user
+func DefaultDataDir() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".adamnite")
 }
 
 func (c *Config) name() string {
@@ -40,6 +73,22 @@ func (c *Config) name() string {
 	return c.Name
 }
 
+// ServerRPCPolicy is the policy of the server other nodes call
+func (c *Config) ServerRPCPolicy() *rpc.Policy {
+	if c.ServerPolicy == nil {
+		return rpc.DefaultServerPolicy()
+	}
+	return c.ServerPolicy
+}
+
+// BouncerRPCPolicy is the policy of the bouncer, that clients call
+func (c *Config) BouncerRPCPolicy() *rpc.Policy {
+	if c.BouncerPolicy == nil {
+		return rpc.DefaultBouncerPolicy()
+	}
+	return c.BouncerPolicy
+}
+
 func (c *Config) NodeName() string {
 	name := c.name()
 	if name == "gnite" {
@@ -116,3 +165,11 @@ func (c *Config) NodeDB() string {
 	}
 	return c.ResolvePath(datadirNodeDatabase)
 }
+
+// CaesarDB is where Caesar messages are stored, empty if they are only kept in memory
+func (c *Config) CaesarDB() string {
+	if c.DataDir == "" {
+		return "" // ephemeral
+	}
+	return c.ResolvePath(datadirCaesarMessages)
+}
@@ -12,6 +12,7 @@ var (
 	ErrNodeStopped    = errors.New("node not started")
 	ErrNodeRunning    = errors.New("node already running")
 	ErrServiceUnknown = errors.New("unknown service")
+	ErrServerTLS      = errors.New("other nodes dial the RPC server without TLS, it can only be used on the bouncer")
 
 	datadirInUseErrnos = map[uint]bool{11: true, 32: true, 35: true}
 )
@@ -23,7 +23,11 @@ func (ipc *ipcServer) start() error {
 	ipc.mu.Lock()
 	defer ipc.mu.Unlock()
 
-	ipc.server = rpc.NewAdamniteServer(ipc.port)
+	server, err := rpc.NewAdamniteServer(rpc.LocalListenConfig(ipc.port))
+	if err != nil {
+		return err
+	}
+	ipc.server = server
 	go ipc.server.Run()
 
 	log.Info("IPC endpoint opened", "url", ipc.server.Addr())
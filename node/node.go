@@ -54,16 +54,25 @@ func New(cfg *Config) (*Node, error) {
 		cfg.DataDir = absdatadir
 	}
 
+	if cfg.ServerListen.TLS {
+		return nil, ErrServerTLS
+	}
+	adamniteServer, err := rpc.NewAdamniteServer(cfg.ServerListen)
+	if err != nil {
+		return nil, err
+	}
+
 	node := &Node{
 		config:          cfg,
 		stop:            make(chan struct{}),
-		adamniteServer:  rpc.NewAdamniteServer(0),
+		adamniteServer:  adamniteServer,
 		server:          &bargossip.Server{Config: cfg.P2P},
 		openedDatabases: make(map[*OpenedDB]struct{}),
 		eventmux:        new(event.TypeMux),
 	}
 
 	node.ipc = newIPCServer(0)
+	node.adamniteServer.SetPolicy(cfg.ServerRPCPolicy())
 
 	node.server.Config.Name = node.config.NodeName()
 	node.server.Config.ServerPrvKey = node.config.NodeKey()
@@ -1,16 +1,22 @@
 package rpc
 
 import (
+	"context"
 	"encoding/hex"
 	"fmt"
 	"net"
+	"net/http"
 	"net/rpc"
 	"math/big"
-	"strings"
+	"sync"
+	"time"
 
 	"github.com/adamnite/go-adamnite/databaseDeprecated/statedb"
 	"github.com/adamnite/go-adamnite/blockchain"
 	"github.com/adamnite/go-adamnite/common"
+	"github.com/adamnite/go-adamnite/core/types"
+	"github.com/adamnite/go-adamnite/event"
+	"github.com/adamnite/go-adamnite/txpool"
 	"github.com/adamnite/go-adamnite/utils"
 	"github.com/adamnite/go-adamnite/utils/accounts"
 
@@ -34,13 +40,29 @@ type BouncerServer struct {
 	stateDB     *statedb.StateDB
 	chain       *blockchain.Blockchain
 	addresses   []string
-	listener    net.Listener
+	listener    *rpcListener
 	Version     string
+	guard       *rpcGuard
 
 	propagator  func(ForwardingContent, *[]byte) error
 	getMessages func(common.Address, common.Address) []*utils.CaesarMessage
+	sendMessage func(*utils.CaesarMessage) error
+	getChunk    func([]byte) (*utils.CaesarChunk, error)
 
-	messages map[messagesKey][]*messageContent
+	messages map[messagesKey][]*messageContent //only used when no node store is set
+
+	messageFeed      event.Subscription
+	subscriptions    map[string]*messageSubscription
+	challenges       map[string]time.Time
+	subscriptionLock sync.Mutex
+
+	httpServer     *http.Server //JSON-RPC, only once started
+	httpListener   net.Listener
+	allowedOrigins []string
+
+	txPool                 *txpool.TxPool //holds the transactions sent to the bouncer, only with a state and chain
+	pendingTransactionFeed event.Feed
+	webSockets             map[*webSocketConn]struct{}
 }
 
 const bouncerPreface = "[Adamnite Bouncer RPC server] %v \n"
@@ -55,11 +77,23 @@ func (b *BouncerServer) printError(methodName string, err error) {
 func (b *BouncerServer) SetHandlers(propagator func(ForwardingContent, *[]byte) error) {
 	b.propagator = propagator
 }
-func (b *BouncerServer) SetMessagingHandlers(getMsg func(common.Address, common.Address) []*utils.CaesarMessage) {
+// set the node the messages are kept by, and sent through. Until then, the bouncer keeps messages to itself.
+func (b *BouncerServer) SetMessagingHandlers(
+	getMsg func(common.Address, common.Address) []*utils.CaesarMessage,
+	sendMsg func(*utils.CaesarMessage) error) {
 	b.getMessages = getMsg
+	b.sendMessage = sendMsg
+}
+// SetPolicy sets who can call what on the bouncer, over net/rpc and JSON-RPC alike.
+func (b *BouncerServer) SetPolicy(policy *Policy) {
+	b.guard.setPolicy(policy)
+}
+func (b *BouncerServer) SetChunkHandler(getChunk func([]byte) (*utils.CaesarChunk, error)) {
+	b.getChunk = getChunk
 }
 
-func NewBouncerServer(stateDB *statedb.StateDB, chain *blockchain.Blockchain, port uint32) *BouncerServer {
+// NewBouncerServer binds the bouncer as configured, and starts taking calls.
+func NewBouncerServer(stateDB *statedb.StateDB, chain *blockchain.Blockchain, listen ListenConfig) (*BouncerServer, error) {
 	rpcServer := rpc.NewServer()
 
 	bouncer := new(BouncerServer)
@@ -67,46 +101,58 @@ func NewBouncerServer(stateDB *statedb.StateDB, chain *blockchain.Blockchain, po
 	bouncer.chain = chain
 	bouncer.Version = "0.1.2"
 	bouncer.messages = make(map[messagesKey][]*messageContent)
+	bouncer.subscriptions = make(map[string]*messageSubscription)
+	bouncer.challenges = make(map[string]time.Time)
+	bouncer.webSockets = make(map[*webSocketConn]struct{})
+	if stateDB != nil && chain != nil {
+		bouncer.txPool = txpool.New(txpool.DefaultConfig, chain.Config(), stateDB, chain)
+	}
 	bouncer.propagator = func(ForwardingContent, *[]byte) error {
 		return fmt.Errorf("this is an incomplete bouncer server, and cannot forward")
 	}
 
 	if err := rpcServer.Register(bouncer); err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
+	registerGuard(rpcServer)
+	bouncer.guard = newRPCGuard(DefaultBouncerPolicy())
 
-	listener, _ := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", port))
+	listener, err := listen.Listen()
+	if err != nil {
+		return nil, err
+	}
 	log.Printf(bouncerPreface, fmt.Sprint("Bouncer Endpoint: ", listener.Addr().String()))
-	bouncer.listener = listener
-	go func() {
-		for {
-			conn, err := listener.Accept()
-			if err != nil {
-				log.Println("[Listener accept]", err)
-				return
-			}
-
-			go func(conn net.Conn) {
-				defer func() {
-					if err = conn.Close(); err != nil && !strings.Contains(err.Error(), "use of closed network connection") {
-						log.Println(err)
-					}
-				}()
-				rpcServer.ServeConn(conn)
-			}(conn)
-		}
-	}()
-	return bouncer
+	bouncer.listener = newRPCListener(listener, rpcServer, bouncer.guard)
+	go bouncer.listener.serve()
+	return bouncer, nil
 }
 func (b *BouncerServer) Close() {
 	//TODO: clear all mappings!
-	_ = b.listener.Close()
+	b.subscriptionLock.Lock()
+	if b.messageFeed != nil {
+		b.messageFeed.Unsubscribe()
+	}
+	if b.txPool != nil {
+		b.txPool.Stop()
+	}
+	webSockets := b.webSockets
+	b.webSockets = make(map[*webSocketConn]struct{})
+	b.subscriptionLock.Unlock()
+	for conn := range webSockets {
+		conn.close()
+	}
+	if b.httpServer != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), jsonRPCShutdownTimeout)
+		_ = b.httpServer.Shutdown(ctx)
+		cancel()
+	}
+	b.listener.close(shutdownTimeout)
 }
 func (b *BouncerServer) Addr() string {
-	return b.listener.Addr().String()
+	return b.listener.addr().String()
 }
 
-const getChainIDEndpoint = "BouncerServer.GetChainID"
+const BouncerGetChainIDEndpoint = "BouncerServer.GetChainID"
 
 func (b *BouncerServer) GetChainID(params *[]byte, reply *[]byte) error {
 	b.print("Get chain ID")
@@ -121,7 +167,7 @@ func (b *BouncerServer) GetChainID(params *[]byte, reply *[]byte) error {
 	return nil
 }
 
-const getBalanceEndpoint = "BouncerServer.GetBalance"
+const BouncerGetBalanceEndpoint = "BouncerServer.GetBalance"
 
 func (b *BouncerServer) GetBalance(params *[]byte, reply *[]byte) error {
 	b.print("Get balance")
@@ -145,7 +191,7 @@ func (b *BouncerServer) GetBalance(params *[]byte, reply *[]byte) error {
 	return nil
 }
 
-const getAccountsEndpoint = "BouncerServer.GetAccounts"
+const BouncerGetAccountsEndpoint = "BouncerServer.GetAccounts"
 
 func (b *BouncerServer) GetAccounts(params *[]byte, reply *[]byte) error {
 	b.print("Get accounts")
@@ -160,7 +206,7 @@ func (b *BouncerServer) GetAccounts(params *[]byte, reply *[]byte) error {
 	return nil
 }
 
-const createAccountEndpoint = "BouncerServer.CreateAccount"
+const BouncerCreateAccountEndpoint = "BouncerServer.CreateAccount"
 
 func (b *BouncerServer) CreateAccount(params *[]byte, reply *[]byte) error {
 	b.print("Create account")
@@ -194,8 +240,9 @@ func (b *BouncerServer) CreateAccount(params *[]byte, reply *[]byte) error {
 	return nil
 }
 
-const getBlockByHashEndpoint = "BouncerServer.GetBlockByHash"
+const BouncerGetBlockByHashEndpoint = "BouncerServer.GetBlockByHash"
 
+// GetBlockByHash returns the header of the block (the encoding of a block leaves it out), or nil if there's no such block.
 func (b *BouncerServer) GetBlockByHash(params *[]byte, reply *[]byte) error {
 	b.print("Get block by hash")
 
@@ -208,7 +255,14 @@ func (b *BouncerServer) GetBlockByHash(params *[]byte, reply *[]byte) error {
 		return err
 	}
 
-	data, err := encoding.Marshal(*b.chain.GetBlockByHash(input.BlockHash))
+	if b.chain == nil {
+		return ErrChainNotSet
+	}
+	var header *types.BlockHeader
+	if block := b.chain.GetBlockByHash(input.BlockHash); block != nil {
+		header = block.Header()
+	}
+	data, err := encoding.Marshal(header)
 	if err != nil {
 		b.printError("Get block by hash", err)
 		return err
@@ -218,8 +272,9 @@ func (b *BouncerServer) GetBlockByHash(params *[]byte, reply *[]byte) error {
 	return nil
 }
 
-const getBlockByNumberEndpoint = "BouncerServer.GetBlockByNumber"
+const BouncerGetBlockByNumberEndpoint = "BouncerServer.GetBlockByNumber"
 
+// GetBlockByNumber returns the header of the block, or nil if there's no such block.
 func (b *BouncerServer) GetBlockByNumber(params *[]byte, reply *[]byte) error {
 	b.print("Get block by number")
 
@@ -232,7 +287,14 @@ func (b *BouncerServer) GetBlockByNumber(params *[]byte, reply *[]byte) error {
 		return err
 	}
 
-	data, err := encoding.Marshal(*b.chain.GetBlockByNumber(&input.BlockNumber))
+	if b.chain == nil {
+		return ErrChainNotSet
+	}
+	var header *types.BlockHeader
+	if block := b.chain.GetBlockByNumber(&input.BlockNumber); block != nil {
+		header = block.Header()
+	}
+	data, err := encoding.Marshal(header)
 	if err != nil {
 		b.printError("Get block by number", err)
 		return err
@@ -242,16 +304,20 @@ func (b *BouncerServer) GetBlockByNumber(params *[]byte, reply *[]byte) error {
 	return nil
 }
 
-const bouncerNewMessageEndpoint = "BouncerServer.NewMessage"
+const BouncerNewMessageEndpoint = "BouncerServer.NewMessage"
 
 func (b *BouncerServer) NewMessage(params *[]byte, reply *[]byte) error {
 	b.print("New Message")
 
 	input := struct {
-		FromPublicKey string
-		ToPublicKey   string
-		RawMessage    string
-		SignedMessage string
+		FromPublicKey    string
+		ToPublicKey      string
+		RawMessage       string
+		SignedMessage    string
+		Version          uint8 // CaesarVersionDirect if left out
+		Protocol         uint8 // required, the legacy protocol only signs RawMessage so it is refused
+		InitialTime      int64 // the time the client signed
+		HasHostingServer bool
 	}{}
 
 	if err := encoding.Unmarshal(*params, &input); err != nil {
@@ -264,9 +330,28 @@ func (b *BouncerServer) NewMessage(params *[]byte, reply *[]byte) error {
 		accounts.AccountFromPubBytes(common.FromHex(input.FromPublicKey)),
 		common.FromHex(input.RawMessage),
 		common.FromHex(input.SignedMessage))
+	m.Version = input.Version
+	m.Protocol = input.Protocol
+	m.HasHostingServer = input.HasHostingServer
+	m.InitialTime = input.InitialTime
+	if m.Protocol == utils.CaesarProtocolLegacy {
+		b.printError("New Message", utils.ErrLegacyProtocol)
+		return utils.ErrLegacyProtocol
+	}
+	if !m.Verify() {
+		b.printError("New Message", ErrBadSignature)
+		return ErrBadSignature
+	}
 
-	// TODO: Verify the message
-
+	if b.sendMessage != nil {
+		if err := b.sendMessage(m); err != nil {
+			b.printError("New Message", err)
+			return err
+		}
+		data, _ := encoding.Marshal(true)
+		*reply = data
+		return nil
+	}
 	k := messagesKey{
 		input.FromPublicKey,
 		input.ToPublicKey,
@@ -282,7 +367,7 @@ func (b *BouncerServer) NewMessage(params *[]byte, reply *[]byte) error {
 	return nil
 }
 
-const bouncerGetMessages = "BouncerServer.GetMessages"
+const BouncerGetMessagesEndpoint = "BouncerServer.GetMessages"
 
 func (b *BouncerServer) GetMessages(params *[]byte, reply *[]byte) error {
 	b.print("Get messages")
@@ -294,19 +379,20 @@ func (b *BouncerServer) GetMessages(params *[]byte, reply *[]byte) error {
 		return err
 	}
 
-	type message struct {
-		FromPublicKey string `msgpack:"fromPublicKey"`
-		Timestamp     int64  `msgpack:"timestamp"`
-		Content       string `msgpack:"content"`
-	}
-
-	var messages []message
+	var messages []bouncerMessage
 
+	if b.getMessages != nil {
+		from := accounts.AccountFromPubBytes(common.FromHex(input.FromPublicKey))
+		to := accounts.AccountFromPubBytes(common.FromHex(input.ToPublicKey))
+		for _, m := range b.getMessages(from.Address, to.Address) {
+			messages = append(messages, newBouncerMessage(m))
+		}
+	}
 	for k, v := range b.messages {
 		if (k.FromPublicKey == input.FromPublicKey && k.ToPublicKey == input.ToPublicKey) ||
 		   (k.FromPublicKey == input.ToPublicKey && k.ToPublicKey == input.FromPublicKey) {
 			for _, m := range v {
-				messages = append(messages, message{
+				messages = append(messages, bouncerMessage{
 					FromPublicKey: k.FromPublicKey,
 					Timestamp    : m.Timestamp,
 					Content      : m.Content,
@@ -325,8 +411,153 @@ func (b *BouncerServer) GetMessages(params *[]byte, reply *[]byte) error {
 	return nil
 }
 
-const sendTransactionEndpoint = "BouncerServer.SendTransaction"
+const BouncerGetCaesarChunkEndpoint = "BouncerServer.GetCaesarChunk"
+
+// GetCaesarChunk returns the hex encoded data of an attachment chunk (or manifest) held by this node.
+// Chunks are encrypted, and checked against their ID before being returned.
+func (b *BouncerServer) GetCaesarChunk(params *[]byte, reply *[]byte) error {
+	b.print("Get Caesar chunk")
+
+	input := struct {
+		ID string
+	}{}
+
+	if err := encoding.Unmarshal(*params, &input); err != nil {
+		b.printError("Get Caesar chunk", err)
+		return err
+	}
+	if b.getChunk == nil {
+		return ErrUnknownChunk
+	}
+
+	id := common.FromHex(input.ID)
+	chunk, err := b.getChunk(id)
+	if err != nil {
+		b.printError("Get Caesar chunk", err)
+		return err
+	}
+	if chunk == nil {
+		return ErrUnknownChunk
+	}
+	if err := chunk.Check(id); err != nil {
+		b.printError("Get Caesar chunk", err)
+		return err
+	}
+
+	data, err := encoding.Marshal(hex.EncodeToString(chunk.Data))
+	if err != nil {
+		b.printError("Get Caesar chunk", err)
+		return err
+	}
+
+	*reply = data
+	return nil
+}
 
+// IncludedTransaction is a transaction of a block, and where in the chain it is.
+type IncludedTransaction struct {
+	Hash     common.Hash
+	Type     types.TxType
+	From     *common.Address // nil if the signature can't be recovered
+	ChainID  *big.Int
+	Nonce    uint64
+	To       *common.Address
+	Amount   *big.Int
+	AteMax   uint64
+	AtePrice *big.Int
+
+	BlockHash   common.Hash
+	BlockNumber uint64
+	Index       uint64
+}
+
+// includedTransaction looks the transaction up on the chain, returning nil if it isn't in a block.
+func (b *BouncerServer) includedTransaction(hash common.Hash) *IncludedTransaction {
+	tx, entry := b.chain.GetTransaction(hash)
+	if tx == nil {
+		return nil
+	}
+	var from *common.Address
+	if sender, err := types.Sender(types.MakeSigner(b.chain.Config(), new(big.Int).SetUint64(entry.BlockNumber)), tx); err == nil {
+		from = &sender
+	}
+	return &IncludedTransaction{
+		Hash:        tx.Hash(),
+		Type:        tx.Type(),
+		From:        from,
+		ChainID:     tx.ChainID(),
+		Nonce:       tx.Nonce(),
+		To:          tx.To(),
+		Amount:      tx.Amount(),
+		AteMax:      tx.ATEMax(),
+		AtePrice:    tx.ATEPrice(),
+		BlockHash:   entry.BlockHash,
+		BlockNumber: entry.BlockNumber,
+		Index:       entry.Index,
+	}
+}
+
+const BouncerGetTransactionByHashEndpoint = "BouncerServer.GetTransactionByHash"
+
+// GetTransactionByHash returns the transaction as an IncludedTransaction, or nil if it isn't in a block.
+func (b *BouncerServer) GetTransactionByHash(params *[]byte, reply *[]byte) error {
+	b.print("Get transaction by hash")
+
+	input := struct {
+		Hash common.Hash
+	}{}
+
+	if err := encoding.Unmarshal(*params, &input); err != nil {
+		b.printError("Get transaction by hash", err)
+		return err
+	}
+	if b.chain == nil {
+		return ErrChainNotSet
+	}
+
+	data, err := encoding.Marshal(b.includedTransaction(input.Hash))
+	if err != nil {
+		b.printError("Get transaction by hash", err)
+		return err
+	}
+
+	*reply = data
+	return nil
+}
+
+const BouncerGetTransactionReceiptEndpoint = "BouncerServer.GetTransactionReceipt"
+
+// GetTransactionReceipt returns the receipt of the transaction, or nil if it isn't in a block, or its block was
+// imported without receipts.
+func (b *BouncerServer) GetTransactionReceipt(params *[]byte, reply *[]byte) error {
+	b.print("Get transaction receipt")
+
+	input := struct {
+		Hash common.Hash
+	}{}
+
+	if err := encoding.Unmarshal(*params, &input); err != nil {
+		b.printError("Get transaction receipt", err)
+		return err
+	}
+	if b.chain == nil {
+		return ErrChainNotSet
+	}
+
+	data, err := encoding.Marshal(b.chain.GetReceipt(input.Hash))
+	if err != nil {
+		b.printError("Get transaction receipt", err)
+		return err
+	}
+
+	*reply = data
+	return nil
+}
+
+const BouncerSendTransactionEndpoint = "BouncerServer.SendTransaction"
+
+// SendTransaction checks the transaction against the state and passes it on to the network, replying with its hash.
+// Transactions turned away return the reason as the error.
 func (b *BouncerServer) SendTransaction(params *[]byte, reply *[]byte) error {
 	b.print("Send transaction")
 
@@ -336,18 +567,18 @@ func (b *BouncerServer) SendTransaction(params *[]byte, reply *[]byte) error {
 		b.printError("Send transaction", err)
 		return err
 	}
-	data, err := encoding.Marshal(true)
+	if input == nil {
+		return ErrBadSignature
+	}
+	hash, err := b.submitTransaction(input)
+	if err != nil {
+		return err
+	}
+	data, err := encoding.Marshal(hash)
 	if err != nil {
 		b.printError("Send transaction", err)
 		return err
 	}
 	*reply = data
-
-	// if b.newTransactionReceived != nil {
-	// 	// return b.newTransactionReceived(input, params)
-	// } else {
-	// 	//TODO: this node cant forward this transaction at all.
-	// }
-
 	return nil
 }
@@ -0,0 +1,737 @@
+package rpc
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/adamnite/go-adamnite/common"
+	"github.com/adamnite/go-adamnite/core/types"
+	"github.com/adamnite/go-adamnite/utils"
+	"github.com/gorilla/websocket"
+	"github.com/rs/cors"
+	log "github.com/sirupsen/logrus"
+	encoding "github.com/vmihailenco/msgpack/v5"
+)
+
+// the bouncer can also be reached over HTTP with JSON-RPC 2.0, for browsers and clients that can't speak net/rpc.
+// Every call is handed to the matching bouncer endpoint, so both ways in behave the same.
+//
+// Params can be given by position, or by name. Quantities (balances, block numbers) are returned as 0x prefixed hex,
+// and can be passed as hex, decimal strings or JSON numbers. Bytes (keys, hashes, messages, signatures) are 0x
+// prefixed hex either way, although the prefix can be left out of params.
+
+const (
+	jsonRPCVersion         = "2.0"
+	maxJSONRPCRequestSize  = 8 << 20 // the default, attachments are chunked well below this
+	maxJSONRPCBatchSize    = 100
+	jsonRPCShutdownTimeout = 5 * time.Second
+
+	jsonRPCParseError     = -32700
+	jsonRPCInvalidRequest = -32600
+	jsonRPCMethodNotFound = -32601
+	jsonRPCInvalidParams  = -32602
+	jsonRPCServerError    = -32000 // errors returned by the bouncer itself
+	jsonRPCUnauthorized   = -32001
+	jsonRPCRejected       = -32003 // transactions turned away, with the reason as data
+	jsonRPCLimitExceeded  = -32005
+)
+
+var (
+	ErrJSONRPCAlreadyRunning = errors.New("the bouncer is already serving JSON-RPC")
+	errInvalidQuantity       = errors.New("quantities must be hex (0x prefixed), or decimal")
+)
+
+type jsonRPCRequest struct {
+	Version string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type jsonRPCError struct {
+	Code    int         `json:"code"`
+	Message string      `json:"message"`
+	Data    interface{} `json:"data,omitempty"`
+}
+
+func (e *jsonRPCError) Error() string {
+	return e.Message
+}
+
+type jsonRPCResponse struct {
+	Version string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   *jsonRPCError   `json:"error,omitempty"`
+}
+
+func invalidParams(err error) *jsonRPCError {
+	return &jsonRPCError{Code: jsonRPCInvalidParams, Message: err.Error()}
+}
+
+// jsonQuantity reads a number from JSON, given as a number, a decimal string, or a 0x prefixed hex string.
+type jsonQuantity struct {
+	big.Int
+}
+
+func (q *jsonQuantity) UnmarshalJSON(data []byte) error {
+	text := strings.Trim(string(data), "\"")
+	if _, ok := q.SetString(text, 0); !ok {
+		return errInvalidQuantity
+	}
+	return nil
+}
+
+func encodeQuantity(n *big.Int) string {
+	if n == nil {
+		return "0x0"
+	}
+	return "0x" + n.Text(16)
+}
+
+func encodeBytes(data []byte) string {
+	return "0x" + hex.EncodeToString(data)
+}
+
+// readJSONParams fills dst from params given by position, or by the names given, in the same order.
+// Params that are left out keep their zero value.
+func readJSONParams(params json.RawMessage, names []string, dst ...interface{}) error {
+	params = bytes.TrimSpace(params)
+	if len(params) == 0 || bytes.Equal(params, []byte("null")) {
+		return nil
+	}
+	switch params[0] {
+	case '[':
+		var positional []json.RawMessage
+		if err := json.Unmarshal(params, &positional); err != nil {
+			return err
+		}
+		if len(positional) > len(dst) {
+			return fmt.Errorf("expected at most %d params, got %d", len(dst), len(positional))
+		}
+		for i, param := range positional {
+			if err := json.Unmarshal(param, dst[i]); err != nil {
+				return fmt.Errorf("param %d (%v): %w", i, names[i], err)
+			}
+		}
+	case '{':
+		var named map[string]json.RawMessage
+		if err := json.Unmarshal(params, &named); err != nil {
+			return err
+		}
+		for i, name := range names {
+			if param, exists := named[name]; exists {
+				if err := json.Unmarshal(param, dst[i]); err != nil {
+					return fmt.Errorf("param %v: %w", name, err)
+				}
+			}
+		}
+	default:
+		return fmt.Errorf("params must be an array or an object")
+	}
+	return nil
+}
+
+// callEndpoint runs a bouncer endpoint in process, the same way a net/rpc client would.
+func callEndpoint(endpoint func(*[]byte, *[]byte) error, input interface{}, output interface{}) *jsonRPCError {
+	params, err := encoding.Marshal(input)
+	if err != nil {
+		return invalidParams(err)
+	}
+	reply := []byte{}
+	if err := endpoint(&params, &reply); err != nil {
+		return &jsonRPCError{Code: jsonRPCServerError, Message: err.Error()}
+	}
+	if err := encoding.Unmarshal(reply, output); err != nil {
+		return &jsonRPCError{Code: jsonRPCServerError, Message: err.Error()}
+	}
+	return nil
+}
+
+type jsonRPCMethod func(b *BouncerServer, params json.RawMessage) (interface{}, *jsonRPCError)
+
+// jsonRPCMethods are the JSON-RPC methods, by name, with the bouncer endpoint each stands for.
+var jsonRPCMethods = map[string]struct {
+	endpoint string
+	call     jsonRPCMethod
+}{
+	"getChainID":               {BouncerGetChainIDEndpoint, jsonGetChainID},
+	"getBalance":               {BouncerGetBalanceEndpoint, jsonGetBalance},
+	"getAccounts":              {BouncerGetAccountsEndpoint, jsonGetAccounts},
+	"createAccount":            {BouncerCreateAccountEndpoint, jsonCreateAccount},
+	"getBlockByHash":           {BouncerGetBlockByHashEndpoint, jsonGetBlockByHash},
+	"getBlockByNumber":         {BouncerGetBlockByNumberEndpoint, jsonGetBlockByNumber},
+	"sendTransaction":          {BouncerSendTransactionEndpoint, jsonSendTransaction},
+	"getTransactionByHash":     {BouncerGetTransactionByHashEndpoint, jsonGetTransactionByHash},
+	"getTransactionReceipt":    {BouncerGetTransactionReceiptEndpoint, jsonGetTransactionReceipt},
+	"newMessage":               {BouncerNewMessageEndpoint, jsonNewMessage},
+	"getMessages":              {BouncerGetMessagesEndpoint, jsonGetMessages},
+	"getCaesarChunk":           {BouncerGetCaesarChunkEndpoint, jsonGetCaesarChunk},
+	"getSubscriptionChallenge": {BouncerGetSubscriptionChallengeEndpoint, jsonGetSubscriptionChallenge},
+	"subscribeMessages":        {BouncerSubscribeMessagesEndpoint, jsonSubscribeMessages},
+	"pollMessages":             {BouncerPollMessagesEndpoint, jsonPollMessages},
+	"unsubscribeMessages":      {BouncerUnsubscribeMessagesEndpoint, jsonUnsubscribeMessages},
+}
+
+func jsonGetChainID(b *BouncerServer, params json.RawMessage) (interface{}, *jsonRPCError) {
+	var version string
+	if err := callEndpoint(b.GetChainID, nil, &version); err != nil {
+		return nil, err
+	}
+	return version, nil
+}
+
+func jsonGetBalance(b *BouncerServer, params json.RawMessage) (interface{}, *jsonRPCError) {
+	var input struct{ Address string }
+	if err := readJSONParams(params, []string{"address"}, &input.Address); err != nil {
+		return nil, invalidParams(err)
+	}
+	var balance string
+	if err := callEndpoint(b.GetBalance, input, &balance); err != nil {
+		return nil, err
+	}
+	amount, ok := new(big.Int).SetString(balance, 10)
+	if !ok {
+		return nil, &jsonRPCError{Code: jsonRPCServerError, Message: errInvalidQuantity.Error()}
+	}
+	return encodeQuantity(amount), nil
+}
+
+func jsonGetAccounts(b *BouncerServer, params json.RawMessage) (interface{}, *jsonRPCError) {
+	addresses := []string{}
+	if err := callEndpoint(b.GetAccounts, nil, &addresses); err != nil {
+		return nil, err
+	}
+	if addresses == nil {
+		addresses = []string{}
+	}
+	return addresses, nil
+}
+
+func jsonCreateAccount(b *BouncerServer, params json.RawMessage) (interface{}, *jsonRPCError) {
+	var input struct{ Address string }
+	if err := readJSONParams(params, []string{"address"}, &input.Address); err != nil {
+		return nil, invalidParams(err)
+	}
+	var ok bool
+	if err := callEndpoint(b.CreateAccount, input, &ok); err != nil {
+		return nil, err
+	}
+	return ok, nil
+}
+
+// jsonBlock is how blocks are shown to JSON clients, as the block itself keeps its header private.
+type jsonBlock struct {
+	Hash             string `json:"hash"`
+	Number           string `json:"number"`
+	ParentHash       string `json:"parentHash"`
+	Time             string `json:"time"`
+	Witness          string `json:"witness"`
+	WitnessRoot      string `json:"witnessRoot"`
+	DBWitness        string `json:"dbWitness"`
+	Signature        string `json:"signature"`
+	TransactionRoot  string `json:"transactionRoot"`
+	CurrentRound     string `json:"currentRound"`
+	StateRoot        string `json:"stateRoot"`
+	Extra            string `json:"extra"`
+	TransactionCount int    `json:"transactionCount"`
+}
+
+func newJSONBlock(block *types.Block) *jsonBlock {
+	if block == nil {
+		return nil
+	}
+	header := block.Header()
+	return &jsonBlock{
+		Hash:             block.Hash().Hex(),
+		Number:           encodeQuantity(header.Number),
+		ParentHash:       header.ParentHash.Hex(),
+		Time:             encodeQuantity(new(big.Int).SetUint64(header.Time)),
+		Witness:          header.Witness.Hex(),
+		WitnessRoot:      header.WitnessRoot.Hex(),
+		DBWitness:        header.DBWitness.Hex(),
+		Signature:        header.Signature.Hex(),
+		TransactionRoot:  header.TransactionRoot.Hex(),
+		CurrentRound:     encodeQuantity(new(big.Int).SetUint64(header.CurrentRound)),
+		StateRoot:        header.StateRoot.Hex(),
+		Extra:            encodeBytes(header.Extra),
+		TransactionCount: len(block.Body().Transactions),
+	}
+}
+
+// blocks are read from the chain directly, as the msgpack encoding of a block leaves out its header.
+// Unknown blocks are returned as null.
+func jsonGetBlockByHash(b *BouncerServer, params json.RawMessage) (interface{}, *jsonRPCError) {
+	var hash string
+	if err := readJSONParams(params, []string{"blockHash"}, &hash); err != nil {
+		return nil, invalidParams(err)
+	}
+	if b.chain == nil {
+		return nil, &jsonRPCError{Code: jsonRPCServerError, Message: ErrChainNotSet.Error()}
+	}
+	return newJSONBlock(b.chain.GetBlockByHash(common.HexToHash(hash))), nil
+}
+
+func jsonGetBlockByNumber(b *BouncerServer, params json.RawMessage) (interface{}, *jsonRPCError) {
+	var number jsonQuantity
+	if err := readJSONParams(params, []string{"blockNumber"}, &number); err != nil {
+		return nil, invalidParams(err)
+	}
+	if b.chain == nil {
+		return nil, &jsonRPCError{Code: jsonRPCServerError, Message: ErrChainNotSet.Error()}
+	}
+	return newJSONBlock(b.chain.GetBlockByNumber(&number.Int)), nil
+}
+
+func jsonSendTransaction(b *BouncerServer, params json.RawMessage) (interface{}, *jsonRPCError) {
+	var input struct {
+		From      string       `json:"from"`
+		To        string       `json:"to"`
+		Amount    jsonQuantity `json:"amount"`
+		Nonce     jsonQuantity `json:"nonce"`
+		ChainID   jsonQuantity `json:"chainId"`
+		Fee       jsonQuantity `json:"fee"`
+		Time      time.Time    `json:"time"`
+		Signature string       `json:"signature"`
+	}
+	if err := readJSONParams(params, []string{"transaction"}, &input); err != nil {
+		return nil, invalidParams(err)
+	}
+	transaction := &utils.Transaction{
+		From:      common.HexToAddress(input.From),
+		To:        common.HexToAddress(input.To),
+		Amount:    &input.Amount.Int,
+		Nonce:     input.Nonce.Uint64(),
+		ChainID:   &input.ChainID.Int,
+		Fee:       &input.Fee.Int,
+		Time:      input.Time,
+		Signature: common.FromHex(input.Signature),
+	}
+	hash, err := b.submitTransaction(transaction)
+	if err != nil {
+		return nil, rejectionError(err)
+	}
+	return hash.Hex(), nil
+}
+
+// rejectionError is how a transaction that was turned away is returned to JSON clients.
+func rejectionError(err error) *jsonRPCError {
+	reason, rejected := transactionRejection(err)
+	if !rejected {
+		return &jsonRPCError{Code: jsonRPCServerError, Message: err.Error()}
+	}
+	return &jsonRPCError{
+		Code:    jsonRPCRejected,
+		Message: err.Error(),
+		Data:    map[string]string{"reason": reason},
+	}
+}
+
+type jsonTransaction struct {
+	Hash             string  `json:"hash"`
+	Type             int     `json:"type"`
+	From             *string `json:"from"`
+	ChainID          string  `json:"chainId"`
+	Nonce            string  `json:"nonce"`
+	To               *string `json:"to"`
+	Amount           string  `json:"amount"`
+	AteMax           string  `json:"ateMax"`
+	AtePrice         string  `json:"atePrice"`
+	BlockHash        string  `json:"blockHash"`
+	BlockNumber      string  `json:"blockNumber"`
+	TransactionIndex string  `json:"transactionIndex"`
+}
+
+func newJSONTransaction(tx *IncludedTransaction) *jsonTransaction {
+	if tx == nil {
+		return nil
+	}
+	var from, to *string
+	if tx.From != nil {
+		address := tx.From.Hex()
+		from = &address
+	}
+	if tx.To != nil {
+		address := tx.To.Hex()
+		to = &address
+	}
+	return &jsonTransaction{
+		Hash:             tx.Hash.Hex(),
+		Type:             int(tx.Type),
+		From:             from,
+		ChainID:          encodeQuantity(tx.ChainID),
+		Nonce:            fmt.Sprintf("0x%x", tx.Nonce),
+		To:               to,
+		Amount:           encodeQuantity(tx.Amount),
+		AteMax:           fmt.Sprintf("0x%x", tx.AteMax),
+		AtePrice:         encodeQuantity(tx.AtePrice),
+		BlockHash:        tx.BlockHash.Hex(),
+		BlockNumber:      fmt.Sprintf("0x%x", tx.BlockNumber),
+		TransactionIndex: fmt.Sprintf("0x%x", tx.Index),
+	}
+}
+
+type jsonReceipt struct {
+	TransactionHash   string     `json:"transactionHash"`
+	TransactionIndex  string     `json:"transactionIndex"`
+	BlockHash         string     `json:"blockHash"`
+	BlockNumber       string     `json:"blockNumber"`
+	Status            string     `json:"status"`
+	GasUsed           string     `json:"gasUsed"`
+	CumulativeGasUsed string     `json:"cumulativeGasUsed"`
+	Logs              []struct{} `json:"logs"` // always empty, as contracts can't emit events yet
+}
+
+func newJSONReceipt(receipt *types.Receipt) *jsonReceipt {
+	if receipt == nil {
+		return nil
+	}
+	return &jsonReceipt{
+		TransactionHash:   receipt.TxHash.Hex(),
+		TransactionIndex:  fmt.Sprintf("0x%x", receipt.TransactionIndex),
+		BlockHash:         receipt.BlockHash.Hex(),
+		BlockNumber:       encodeQuantity(receipt.BlockNumber),
+		Status:            fmt.Sprintf("0x%x", receipt.Status),
+		GasUsed:           fmt.Sprintf("0x%x", receipt.GasUsed),
+		CumulativeGasUsed: fmt.Sprintf("0x%x", receipt.CumulativeGasUsed),
+		Logs:              []struct{}{},
+	}
+}
+
+// transactions that aren't in a block, and receipts that weren't kept, are returned as null.
+func jsonGetTransactionByHash(b *BouncerServer, params json.RawMessage) (interface{}, *jsonRPCError) {
+	var hash string
+	if err := readJSONParams(params, []string{"hash"}, &hash); err != nil {
+		return nil, invalidParams(err)
+	}
+	if b.chain == nil {
+		return nil, &jsonRPCError{Code: jsonRPCServerError, Message: ErrChainNotSet.Error()}
+	}
+	return newJSONTransaction(b.includedTransaction(common.HexToHash(hash))), nil
+}
+
+func jsonGetTransactionReceipt(b *BouncerServer, params json.RawMessage) (interface{}, *jsonRPCError) {
+	var hash string
+	if err := readJSONParams(params, []string{"hash"}, &hash); err != nil {
+		return nil, invalidParams(err)
+	}
+	if b.chain == nil {
+		return nil, &jsonRPCError{Code: jsonRPCServerError, Message: ErrChainNotSet.Error()}
+	}
+	return newJSONReceipt(b.chain.GetReceipt(common.HexToHash(hash))), nil
+}
+
+func jsonNewMessage(b *BouncerServer, params json.RawMessage) (interface{}, *jsonRPCError) {
+	var input struct {
+		FromPublicKey    string
+		ToPublicKey      string
+		RawMessage       string
+		SignedMessage    string
+		Version          uint8
+		Protocol         uint8
+		InitialTime      int64
+		HasHostingServer bool
+	}
+	if err := readJSONParams(params,
+		[]string{"fromPublicKey", "toPublicKey", "rawMessage", "signedMessage",
+			"version", "protocol", "initialTime", "hasHostingServer"},
+		&input.FromPublicKey, &input.ToPublicKey, &input.RawMessage, &input.SignedMessage,
+		&input.Version, &input.Protocol, &input.InitialTime, &input.HasHostingServer); err != nil {
+		return nil, invalidParams(err)
+	}
+	var ok bool
+	if err := callEndpoint(b.NewMessage, input, &ok); err != nil {
+		return nil, err
+	}
+	return ok, nil
+}
+
+// messages are handed out with their keys, content and hash as 0x prefixed hex.
+func jsonMessages(messages []bouncerMessage) []bouncerMessage {
+	prefix := func(data string) string {
+		if data == "" || strings.HasPrefix(data, "0x") {
+			return data
+		}
+		return "0x" + data
+	}
+	for i := range messages {
+		messages[i].FromPublicKey = prefix(messages[i].FromPublicKey)
+		messages[i].ToPublicKey = prefix(messages[i].ToPublicKey)
+		messages[i].Content = prefix(messages[i].Content)
+		messages[i].Hash = prefix(messages[i].Hash)
+	}
+	return messages
+}
+
+func jsonGetMessages(b *BouncerServer, params json.RawMessage) (interface{}, *jsonRPCError) {
+	var input messagesKey
+	if err := readJSONParams(params, []string{"fromPublicKey", "toPublicKey"},
+		&input.FromPublicKey, &input.ToPublicKey); err != nil {
+		return nil, invalidParams(err)
+	}
+	messages := []bouncerMessage{}
+	if err := callEndpoint(b.GetMessages, input, &messages); err != nil {
+		return nil, err
+	}
+	if messages == nil {
+		messages = []bouncerMessage{}
+	}
+	return jsonMessages(messages), nil
+}
+
+func jsonGetCaesarChunk(b *BouncerServer, params json.RawMessage) (interface{}, *jsonRPCError) {
+	var input struct{ ID string }
+	if err := readJSONParams(params, []string{"id"}, &input.ID); err != nil {
+		return nil, invalidParams(err)
+	}
+	var data string
+	if err := callEndpoint(b.GetCaesarChunk, input, &data); err != nil {
+		return nil, err
+	}
+	return "0x" + data, nil
+}
+
+func jsonGetSubscriptionChallenge(b *BouncerServer, params json.RawMessage) (interface{}, *jsonRPCError) {
+	var challenge string
+	if err := callEndpoint(b.GetSubscriptionChallenge, nil, &challenge); err != nil {
+		return nil, err
+	}
+	return "0x" + challenge, nil
+}
+
+func jsonSubscribeMessages(b *BouncerServer, params json.RawMessage) (interface{}, *jsonRPCError) {
+	var input struct {
+		PublicKey string
+		Challenge string
+		Signature string
+	}
+	if err := readJSONParams(params, []string{"publicKey", "challenge", "signature"},
+		&input.PublicKey, &input.Challenge, &input.Signature); err != nil {
+		return nil, invalidParams(err)
+	}
+	input.Challenge = strings.TrimPrefix(input.Challenge, "0x") //challenges are kept without the prefix
+	var id string
+	if err := callEndpoint(b.SubscribeMessages, input, &id); err != nil {
+		return nil, err
+	}
+	return id, nil
+}
+
+func jsonPollMessages(b *BouncerServer, params json.RawMessage) (interface{}, *jsonRPCError) {
+	var input struct {
+		SubscriptionID string
+		TimeoutMillis  int64
+	}
+	if err := readJSONParams(params, []string{"subscriptionID", "timeoutMillis"},
+		&input.SubscriptionID, &input.TimeoutMillis); err != nil {
+		return nil, invalidParams(err)
+	}
+	messages := []bouncerMessage{}
+	if err := callEndpoint(b.PollMessages, input, &messages); err != nil {
+		return nil, err
+	}
+	return jsonMessages(messages), nil
+}
+
+func jsonUnsubscribeMessages(b *BouncerServer, params json.RawMessage) (interface{}, *jsonRPCError) {
+	var input struct{ SubscriptionID string }
+	if err := readJSONParams(params, []string{"subscriptionID"}, &input.SubscriptionID); err != nil {
+		return nil, invalidParams(err)
+	}
+	var ok bool
+	if err := callEndpoint(b.UnsubscribeMessages, input, &ok); err != nil {
+		return nil, err
+	}
+	return ok, nil
+}
+
+// jsonRPCCaller is who sent a request, for the guard to check.
+type jsonRPCCaller struct {
+	ip    string
+	token string // sent as a bearer token
+}
+
+func newJSONRPCCaller(r *http.Request) jsonRPCCaller {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+	token := r.Header.Get("Authorization")
+	if !strings.HasPrefix(token, "Bearer ") {
+		token = ""
+	}
+	return jsonRPCCaller{ip, strings.TrimPrefix(token, "Bearer ")}
+}
+
+// guardError is how errors from the guard are returned to JSON clients.
+func guardError(err error) *jsonRPCError {
+	switch err {
+	case ErrRateLimited:
+		return &jsonRPCError{Code: jsonRPCLimitExceeded, Message: err.Error()}
+	case ErrUnauthorized, ErrMethodDisabled:
+		return &jsonRPCError{Code: jsonRPCUnauthorized, Message: err.Error()}
+	}
+	return &jsonRPCError{Code: jsonRPCServerError, Message: err.Error()}
+}
+
+// handleJSONRPC answers a single request, once the guard lets the caller make it. Notifications (requests without an
+// ID) get no response.
+func (b *BouncerServer) handleJSONRPC(caller jsonRPCCaller, raw json.RawMessage) *jsonRPCResponse {
+	var req jsonRPCRequest
+	if err := json.Unmarshal(raw, &req); err != nil || req.Version != jsonRPCVersion || req.Method == "" {
+		return &jsonRPCResponse{
+			Version: jsonRPCVersion,
+			ID:      json.RawMessage("null"),
+			Error:   &jsonRPCError{Code: jsonRPCInvalidRequest, Message: "invalid request"},
+		}
+	}
+	b.print(fmt.Sprint("JSON-RPC ", req.Method))
+
+	response := &jsonRPCResponse{Version: jsonRPCVersion, ID: req.ID}
+	if method, exists := jsonRPCMethods[req.Method]; exists {
+		if err := b.guard.allow(caller.ip); err != nil {
+			response.Error = guardError(err)
+		} else if err := b.guard.authorizeToken(method.endpoint, caller.token); err != nil {
+			response.Error = guardError(err)
+		} else if result, err := method.call(b, req.Params); err != nil {
+			response.Error = err
+		} else {
+			response.Result = result
+		}
+	} else {
+		response.Error = &jsonRPCError{Code: jsonRPCMethodNotFound, Message: fmt.Sprintf("the method %v does not exist", req.Method)}
+	}
+	if req.ID == nil {
+		return nil
+	}
+	if response.Error == nil && response.Result == nil {
+		response.Result = json.RawMessage("null") //results are required on success, even when there's nothing
+	}
+	return response
+}
+
+// answerJSONRPC answers a request, or a batch of them, with handle. It returns nil if only notifications were sent.
+func answerJSONRPC(body []byte, handle func(json.RawMessage) *jsonRPCResponse) interface{} {
+	body = bytes.TrimSpace(body)
+	switch {
+	case !json.Valid(body):
+		return &jsonRPCResponse{
+			Version: jsonRPCVersion,
+			ID:      json.RawMessage("null"),
+			Error:   &jsonRPCError{Code: jsonRPCParseError, Message: "parse error"},
+		}
+	case body[0] == '[':
+		var batch []json.RawMessage
+		_ = json.Unmarshal(body, &batch)
+		if len(batch) == 0 || len(batch) > maxJSONRPCBatchSize {
+			return &jsonRPCResponse{
+				Version: jsonRPCVersion,
+				ID:      json.RawMessage("null"),
+				Error:   &jsonRPCError{Code: jsonRPCInvalidRequest, Message: fmt.Sprintf("batches must hold 1 to %d requests", maxJSONRPCBatchSize)},
+			}
+		}
+		responses := []*jsonRPCResponse{}
+		for _, raw := range batch {
+			if response := handle(raw); response != nil {
+				responses = append(responses, response)
+			}
+		}
+		if len(responses) != 0 {
+			return responses
+		}
+	default:
+		if response := handle(body); response != nil {
+			return response
+		}
+	}
+	return nil
+}
+
+// ServeHTTP answers JSON-RPC 2.0 requests, and batches of them, posted to the bouncer. WebSocket upgrades are handed
+// to serveWebSocket.
+func (b *BouncerServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if websocket.IsWebSocketUpgrade(r) {
+		b.serveWebSocket(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "JSON-RPC requests must be posted", http.StatusMethodNotAllowed)
+		return
+	}
+	maxSize := b.guard.getPolicy().MaxRequestSize
+	var reader io.Reader = r.Body
+	if maxSize > 0 {
+		reader = io.LimitReader(r.Body, int64(maxSize)+1)
+	}
+	body, err := io.ReadAll(reader)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if maxSize > 0 && len(body) > maxSize {
+		http.Error(w, ErrRequestTooLarge.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	caller := newJSONRPCCaller(r)
+	reply := answerJSONRPC(body, func(raw json.RawMessage) *jsonRPCResponse {
+		return b.handleJSONRPC(caller, raw)
+	})
+	if reply == nil { //only notifications were sent
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(reply); err != nil {
+		b.printError("JSON-RPC", err)
+	}
+}
+
+// StartJSONRPC serves the bouncer as JSON-RPC 2.0 over HTTP, and WebSockets, where listen says, alongside the net/rpc
+// listener. Browsers on the allowedOrigins can call it, any origin can if none are given.
+func (b *BouncerServer) StartJSONRPC(listen ListenConfig, allowedOrigins []string) error {
+	if b.httpServer != nil {
+		return ErrJSONRPCAlreadyRunning
+	}
+	listener, err := listen.Listen()
+	if err != nil {
+		return err
+	}
+	handler := cors.New(cors.Options{
+		AllowedOrigins: allowedOrigins,
+		AllowedMethods: []string{http.MethodPost},
+		AllowedHeaders: []string{"Content-Type", "Authorization"}, //admin tokens are sent as bearer tokens
+	}).Handler(b)
+	b.httpServer = &http.Server{Handler: handler}
+	b.allowedOrigins = allowedOrigins
+	b.httpListener = listener
+	scheme := "http://"
+	if listen.TLS {
+		scheme = "https://"
+	}
+	log.Printf(bouncerPreface, fmt.Sprint("Bouncer JSON-RPC Endpoint: ", scheme, listener.Addr().String()))
+
+	go func(server *http.Server) {
+		if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			log.Println("[JSON-RPC serve]", err)
+		}
+	}(b.httpServer)
+	return nil
+}
+
+// JSONRPCAddr is the address JSON-RPC is served on, or empty if it isn't.
+func (b *BouncerServer) JSONRPCAddr() string {
+	if b.httpListener == nil {
+		return ""
+	}
+	return b.httpListener.Addr().String()
+}
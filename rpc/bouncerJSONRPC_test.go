@@ -0,0 +1,225 @@
+package rpc
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func postJSONRPC(t *testing.T, url string, body string) (*http.Response, []byte) {
+	resp, err := http.Post(url, "application/json", bytes.NewBufferString(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	buf := new(bytes.Buffer)
+	if _, err := buf.ReadFrom(resp.Body); err != nil {
+		t.Fatal(err)
+	}
+	return resp, buf.Bytes()
+}
+
+type testJSONRPCResponse struct {
+	Version string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  json.RawMessage `json:"result"`
+	Error   *jsonRPCError   `json:"error"`
+}
+
+func TestJSONRPCCalls(t *testing.T) {
+	server := httptest.NewServer(bouncerServer)
+	defer server.Close()
+
+	_, body := postJSONRPC(t, server.URL, `{"jsonrpc":"2.0","id":1,"method":"getChainID"}`)
+	var response testJSONRPCResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		t.Fatal(err)
+	}
+	assert.Nil(t, response.Error)
+	assert.Equal(t, `"0.1.2"`, string(response.Result))
+	assert.Equal(t, "1", string(response.ID))
+
+	//balances come back as hex, whether the address is passed by position or by name
+	for i, acc := range testAccounts {
+		for _, params := range []string{`["` + acc.Hex() + `"]`, `{"address":"` + acc.Hex() + `"}`} {
+			_, body := postJSONRPC(t, server.URL, `{"jsonrpc":"2.0","id":"a","method":"getBalance","params":`+params+`}`)
+			response = testJSONRPCResponse{}
+			if err := json.Unmarshal(body, &response); err != nil {
+				t.Fatal(err)
+			}
+			if !assert.Nil(t, response.Error) {
+				continue
+			}
+			var balance string
+			_ = json.Unmarshal(response.Result, &balance)
+			assert.Equal(t, "0x"+testBalances[i].Text(16), balance)
+		}
+	}
+}
+
+func TestJSONRPCErrors(t *testing.T) {
+	server := httptest.NewServer(bouncerServer)
+	defer server.Close()
+
+	cases := []struct {
+		body string
+		code int
+	}{
+		{`{"jsonrpc":"2.0","id":1,"method":"getChain`, jsonRPCParseError},
+		{`{"jsonrpc":"1.0","id":1,"method":"getChainID"}`, jsonRPCInvalidRequest},
+		{`[]`, jsonRPCInvalidRequest},
+		{`{"jsonrpc":"2.0","id":1,"method":"doesNotExist"}`, jsonRPCMethodNotFound},
+		{`{"jsonrpc":"2.0","id":1,"method":"getBalance","params":["a","b"]}`, jsonRPCInvalidParams},
+		{`{"jsonrpc":"2.0","id":1,"method":"getCaesarChunk","params":["0x00"]}`, jsonRPCServerError},
+	}
+	for _, c := range cases {
+		_, body := postJSONRPC(t, server.URL, c.body)
+		var response testJSONRPCResponse
+		if err := json.Unmarshal(body, &response); err != nil {
+			t.Fatal(err)
+		}
+		if assert.NotNil(t, response.Error, "no error for %v", c.body) {
+			assert.Equal(t, c.code, response.Error.Code, "wrong error for %v", c.body)
+		}
+	}
+
+	resp, _ := postJSONRPC(t, server.URL, `{"jsonrpc":"2.0","method":"getChainID"}`)
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode, "notifications should not be answered")
+}
+
+func TestJSONRPCBatch(t *testing.T) {
+	server := httptest.NewServer(bouncerServer)
+	defer server.Close()
+
+	_, body := postJSONRPC(t, server.URL, `[
+		{"jsonrpc":"2.0","id":1,"method":"getChainID"},
+		{"jsonrpc":"2.0","method":"getChainID"},
+		{"jsonrpc":"2.0","id":2,"method":"getBalance","params":["`+testAccounts[2].Hex()+`"]},
+		{"jsonrpc":"2.0","id":3,"method":"doesNotExist"}
+	]`)
+	var responses []testJSONRPCResponse
+	if err := json.Unmarshal(body, &responses); err != nil {
+		t.Fatal(err)
+	}
+	if !assert.Equal(t, 3, len(responses), "the notification should be left out") {
+		return
+	}
+	assert.Equal(t, `"0.1.2"`, string(responses[0].Result))
+	var balance string
+	_ = json.Unmarshal(responses[1].Result, &balance)
+	amount, _ := new(big.Int).SetString(balance, 0)
+	assert.Equal(t, testBalances[2], amount)
+	if assert.NotNil(t, responses[2].Error) {
+		assert.Equal(t, jsonRPCMethodNotFound, responses[2].Error.Code)
+	}
+}
+
+func TestJSONRPCListener(t *testing.T) {
+	assert.NoError(t, bouncerServer.StartJSONRPC(ListenConfig{}, []string{"https://adamnite.org"}))
+	assert.ErrorIs(t, bouncerServer.StartJSONRPC(ListenConfig{}, nil), ErrJSONRPCAlreadyRunning)
+
+	req, _ := http.NewRequest(http.MethodOptions, "http://"+bouncerServer.JSONRPCAddr(), nil)
+	req.Header.Set("Origin", "https://adamnite.org")
+	req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+	req.Header.Set("Access-Control-Request-Headers", "content-type,authorization")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	assert.Equal(t, "https://adamnite.org", resp.Header.Get("Access-Control-Allow-Origin"))
+	assert.Contains(t, strings.ToLower(resp.Header.Get("Access-Control-Allow-Headers")), "authorization")
+
+	req.Header.Set("Origin", "https://elsewhere.org")
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	assert.Empty(t, resp.Header.Get("Access-Control-Allow-Origin"), "other origins should not be allowed")
+
+	resp, body := postJSONRPC(t, "http://"+bouncerServer.JSONRPCAddr(), `{"jsonrpc":"2.0","id":1,"method":"getChainID"}`)
+	assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+	assert.Contains(t, string(body), `"result":"0.1.2"`)
+}
+
+func TestJSONRPCTransactionReceipt(t *testing.T) {
+	server := httptest.NewServer(bouncerServer)
+	defer server.Close()
+	tx, block := writeTestTransactionBlock(t, 2001)
+
+	_, body := postJSONRPC(t, server.URL, `{"jsonrpc":"2.0","id":1,"method":"getTransactionByHash","params":["`+tx.Hash().Hex()+`"]}`)
+	var response testJSONRPCResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		t.Fatal(err)
+	}
+	var transaction jsonTransaction
+	if assert.Nil(t, response.Error) && assert.NoError(t, json.Unmarshal(response.Result, &transaction)) {
+		assert.Equal(t, block.Hash().Hex(), transaction.BlockHash)
+		assert.Equal(t, "0x7d1", transaction.BlockNumber)
+		assert.Equal(t, "0x379", transaction.ChainID)
+		assert.NotNil(t, transaction.From)
+		if assert.NotNil(t, transaction.To) {
+			assert.Equal(t, tx.To().Hex(), *transaction.To)
+		}
+	}
+
+	_, body = postJSONRPC(t, server.URL, `{"jsonrpc":"2.0","id":1,"method":"getTransactionReceipt","params":{"hash":"`+tx.Hash().Hex()+`"}}`)
+	response = testJSONRPCResponse{}
+	if err := json.Unmarshal(body, &response); err != nil {
+		t.Fatal(err)
+	}
+	var receipt jsonReceipt
+	if assert.Nil(t, response.Error) && assert.NoError(t, json.Unmarshal(response.Result, &receipt)) {
+		assert.Equal(t, "0x1", receipt.Status)
+		assert.Equal(t, tx.Hash().Hex(), receipt.TransactionHash)
+	}
+
+	_, body = postJSONRPC(t, server.URL, `{"jsonrpc":"2.0","id":1,"method":"getTransactionReceipt","params":["0x01"]}`)
+	assert.Contains(t, string(body), `"result":null`)
+}
+
+func TestJSONRPCSendTransactionRejected(t *testing.T) {
+	server := httptest.NewServer(bouncerServer)
+	defer server.Close()
+	transaction, _, _ := newTestSendTransaction(t, 1, 10)
+
+	request, _ := json.Marshal(map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      1,
+		"method":  "sendTransaction",
+		"params": []interface{}{map[string]interface{}{
+			"from":      transaction.From.Hex(),
+			"to":        transaction.To.Hex(),
+			"amount":    "10",
+			"chainId":   transaction.ChainID.String(),
+			"time":      transaction.Time,
+			"signature": "0x" + hex.EncodeToString(transaction.Signature),
+		}},
+	})
+	_, body := postJSONRPC(t, server.URL, string(request))
+	var response testJSONRPCResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		t.Fatal(err)
+	}
+	if assert.NotNil(t, response.Error, "a transaction the sender can't cover was accepted") {
+		assert.Equal(t, jsonRPCRejected, response.Error.Code)
+		assert.Equal(t, map[string]interface{}{"reason": "insufficientBalance"}, response.Error.Data)
+	}
+
+	stateDB.AddBalance(transaction.From, big.NewInt(9))
+	_, body = postJSONRPC(t, server.URL, string(request))
+	response = testJSONRPCResponse{}
+	if err := json.Unmarshal(body, &response); err != nil {
+		t.Fatal(err)
+	}
+	assert.Nil(t, response.Error)
+	assert.Equal(t, `"`+transaction.Hash().Hex()+`"`, string(response.Result))
+}
@@ -0,0 +1,317 @@
+package rpc
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/adamnite/go-adamnite/common"
+	"github.com/adamnite/go-adamnite/event"
+	"github.com/adamnite/go-adamnite/utils"
+	"github.com/adamnite/go-adamnite/utils/accounts"
+	encoding "github.com/vmihailenco/msgpack/v5"
+)
+
+// web clients subscribe to the messages of an account, then long poll for them as they arrive. Only the owner of the
+// account can subscribe, proving it by signing a challenge handed out by the bouncer.
+
+const (
+	subscriptionChallengeSize   = 32
+	subscriptionChallengeWindow = time.Minute      // how long a challenge can be answered for
+	subscriptionIdleTimeout     = 2 * time.Minute  // subscriptions not polled for this long are dropped
+	maxPollTimeout              = 30 * time.Second // the longest a poll waits for new messages
+	maxQueuedMessages           = 256              // messages kept for a subscription between polls, the oldest are dropped past this
+	maxOutstandingChallenges    = 4096             // challenges handed out and not yet answered nor expired
+	maxSubscriptions            = 1024             // subscriptions polled for within subscriptionIdleTimeout
+)
+
+var (
+	ErrNoSubscriptions      = errors.New("this bouncer server is not setup to push messages")
+	ErrInvalidChallenge     = errors.New("the challenge is unknown, expired, or was already answered")
+	ErrUnknownSubscription  = errors.New("no subscription exists with that ID")
+	ErrTooManyChallenges    = errors.New("too many subscription challenges are outstanding, try again later")
+	ErrSubscriptionsFull    = errors.New("this bouncer server has as many subscriptions as it can take")
+	subscriptionDomainLabel = []byte("caesar-bouncer-subscribe")
+)
+
+// SubscriptionChallengePayload is what a client signs, with the key of the account it subscribes to, to answer the challenge.
+func SubscriptionChallengePayload(challenge []byte) []byte {
+	return append(append([]byte{}, subscriptionDomainLabel...), challenge...)
+}
+
+// bouncerMessage is how Caesar messages are handed to web clients.
+type bouncerMessage struct {
+	FromPublicKey string `msgpack:"fromPublicKey" json:"fromPublicKey"`
+	ToPublicKey   string `msgpack:"toPublicKey,omitempty" json:"toPublicKey,omitempty"`
+	Timestamp     int64  `msgpack:"timestamp" json:"timestamp"`
+	Content       string `msgpack:"content" json:"content"`
+	Hash          string `msgpack:"hash,omitempty" json:"hash,omitempty"` // lets clients drop messages they already fetched
+}
+
+func newBouncerMessage(msg *utils.CaesarMessage) bouncerMessage {
+	return bouncerMessage{
+		FromPublicKey: hex.EncodeToString(msg.From.PublicKey),
+		ToPublicKey:   hex.EncodeToString(msg.To.PublicKey),
+		Timestamp:     msg.InitialTime,
+		Content:       hex.EncodeToString(msg.Message),
+		Hash:          hex.EncodeToString(msg.Hash()),
+	}
+}
+
+type messageSubscription struct {
+	account  common.Address
+	queue    []bouncerMessage
+	notify   chan struct{} // signalled when the queue stops being empty
+	lastPoll time.Time
+}
+
+// SetMessageSubscriptions feeds the messages stored by the node to the subscriptions of web clients.
+func (b *BouncerServer) SetMessageSubscriptions(subscribe func(chan<- *utils.CaesarMessage) event.Subscription) {
+	b.subscriptionLock.Lock()
+	defer b.subscriptionLock.Unlock()
+	if b.messageFeed != nil {
+		b.messageFeed.Unsubscribe()
+	}
+	newMessages := make(chan *utils.CaesarMessage, maxQueuedMessages)
+	b.messageFeed = subscribe(newMessages)
+	go func(sub event.Subscription) {
+		for {
+			select {
+			case msg := <-newMessages:
+				b.pushMessage(msg)
+			case <-sub.Err():
+				return
+			}
+		}
+	}(b.messageFeed)
+}
+
+// pushMessage queues the message for everyone subscribed to its sender or recipient.
+func (b *BouncerServer) pushMessage(msg *utils.CaesarMessage) {
+	b.subscriptionLock.Lock()
+	defer b.subscriptionLock.Unlock()
+	b.dropIdleSubscriptions()
+	for _, sub := range b.subscriptions {
+		if sub.account != msg.To.Address && sub.account != msg.From.Address {
+			continue
+		}
+		sub.queue = append(sub.queue, newBouncerMessage(msg))
+		if len(sub.queue) > maxQueuedMessages {
+			sub.queue = sub.queue[len(sub.queue)-maxQueuedMessages:]
+		}
+		select {
+		case sub.notify <- struct{}{}:
+		default: //they've already been told
+		}
+	}
+}
+
+// dropIdleSubscriptions drops the subscriptions not polled for within subscriptionIdleTimeout. The subscription
+// lock must be held.
+func (b *BouncerServer) dropIdleSubscriptions() {
+	now := time.Now()
+	for id, sub := range b.subscriptions {
+		if now.Sub(sub.lastPoll) > subscriptionIdleTimeout {
+			delete(b.subscriptions, id)
+		}
+	}
+}
+
+func randomHex(size int) (string, error) {
+	random := make([]byte, size)
+	if _, err := rand.Read(random); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(random), nil
+}
+
+const BouncerGetSubscriptionChallengeEndpoint = "BouncerServer.GetSubscriptionChallenge"
+
+// GetSubscriptionChallenge returns a hex encoded challenge, to be signed in order to subscribe. At most
+// maxOutstandingChallenges are handed out at once, so they can't be asked for until the bouncer runs out of memory.
+func (b *BouncerServer) GetSubscriptionChallenge(params *[]byte, reply *[]byte) error {
+	b.print("Get subscription challenge")
+
+	challenge, err := randomHex(subscriptionChallengeSize)
+	if err != nil {
+		b.printError("Get subscription challenge", err)
+		return err
+	}
+	b.subscriptionLock.Lock()
+	now := time.Now()
+	for known, issued := range b.challenges {
+		if now.Sub(issued) > subscriptionChallengeWindow {
+			delete(b.challenges, known)
+		}
+	}
+	if len(b.challenges) >= maxOutstandingChallenges {
+		b.subscriptionLock.Unlock()
+		b.printError("Get subscription challenge", ErrTooManyChallenges)
+		return ErrTooManyChallenges
+	}
+	b.challenges[challenge] = now
+	b.subscriptionLock.Unlock()
+
+	data, err := encoding.Marshal(challenge)
+	if err != nil {
+		b.printError("Get subscription challenge", err)
+		return err
+	}
+	*reply = data
+	return nil
+}
+
+const BouncerSubscribeMessagesEndpoint = "BouncerServer.SubscribeMessages"
+
+// SubscribeMessages answers a challenge, signed with SubscriptionChallengePayload by the account, and returns the ID
+// of a subscription to the messages sent to or from that account from now on.
+func (b *BouncerServer) SubscribeMessages(params *[]byte, reply *[]byte) error {
+	b.print("Subscribe messages")
+
+	input := struct {
+		PublicKey string
+		Challenge string
+		Signature string
+	}{}
+
+	if err := encoding.Unmarshal(*params, &input); err != nil {
+		b.printError("Subscribe messages", err)
+		return err
+	}
+
+	b.subscriptionLock.Lock()
+	defer b.subscriptionLock.Unlock()
+	if b.messageFeed == nil {
+		return ErrNoSubscriptions
+	}
+	b.dropIdleSubscriptions()
+	if len(b.subscriptions) >= maxSubscriptions {
+		b.printError("Subscribe messages", ErrSubscriptionsFull)
+		return ErrSubscriptionsFull
+	}
+	issued, exists := b.challenges[input.Challenge]
+	if !exists || time.Since(issued) > subscriptionChallengeWindow {
+		return ErrInvalidChallenge
+	}
+	delete(b.challenges, input.Challenge) //each challenge can only be answered once
+
+	account := accounts.AccountFromPubBytes(common.FromHex(input.PublicKey))
+	signature := common.FromHex(input.Signature)
+	if len(account.PublicKey) == 0 || len(signature) < 64 ||
+		!account.Verify(SubscriptionChallengePayload(common.FromHex(input.Challenge)), signature) {
+		b.printError("Subscribe messages", ErrBadSignature)
+		return ErrBadSignature
+	}
+
+	id, err := randomHex(subscriptionChallengeSize)
+	if err != nil {
+		return err
+	}
+	b.subscriptions[id] = &messageSubscription{
+		account:  account.Address,
+		notify:   make(chan struct{}, 1),
+		lastPoll: time.Now(),
+	}
+
+	data, err := encoding.Marshal(id)
+	if err != nil {
+		b.printError("Subscribe messages", err)
+		return err
+	}
+	*reply = data
+	return nil
+}
+
+const BouncerPollMessagesEndpoint = "BouncerServer.PollMessages"
+
+// PollMessages returns the messages that arrived for the subscription since it was last polled. If there are none,
+// it waits for up to TimeoutMillis (capped at maxPollTimeout) for one to arrive, returning an empty list otherwise.
+func (b *BouncerServer) PollMessages(params *[]byte, reply *[]byte) error {
+	b.print("Poll messages")
+
+	input := struct {
+		SubscriptionID string
+		TimeoutMillis  int64
+	}{}
+
+	if err := encoding.Unmarshal(*params, &input); err != nil {
+		b.printError("Poll messages", err)
+		return err
+	}
+	timeout := time.Duration(input.TimeoutMillis) * time.Millisecond
+	if timeout > maxPollTimeout || timeout < 0 {
+		timeout = maxPollTimeout
+	}
+
+	sub, messages, err := b.takeQueued(input.SubscriptionID)
+	if err != nil {
+		return err
+	}
+	if len(messages) == 0 && timeout > 0 {
+		select {
+		case <-sub.notify:
+		case <-time.After(timeout):
+		}
+		if _, messages, err = b.takeQueued(input.SubscriptionID); err != nil {
+			return err
+		}
+	}
+
+	data, err := encoding.Marshal(messages)
+	if err != nil {
+		b.printError("Poll messages", err)
+		return err
+	}
+	*reply = data
+	return nil
+}
+
+// takeQueued empties the queue of the subscription, returning what was in it. The signal that the queue stopped
+// being empty is dropped with it, so the next poll waits for messages arriving after this one.
+func (b *BouncerServer) takeQueued(id string) (*messageSubscription, []bouncerMessage, error) {
+	b.subscriptionLock.Lock()
+	defer b.subscriptionLock.Unlock()
+	sub, exists := b.subscriptions[id]
+	if !exists {
+		return nil, nil, ErrUnknownSubscription
+	}
+	sub.lastPoll = time.Now()
+	messages := sub.queue
+	sub.queue = nil
+	select {
+	case <-sub.notify:
+	default:
+	}
+	if messages == nil {
+		messages = []bouncerMessage{}
+	}
+	return sub, messages, nil
+}
+
+const BouncerUnsubscribeMessagesEndpoint = "BouncerServer.UnsubscribeMessages"
+
+// UnsubscribeMessages drops the subscription, and any messages still queued for it.
+func (b *BouncerServer) UnsubscribeMessages(params *[]byte, reply *[]byte) error {
+	b.print("Unsubscribe messages")
+
+	input := struct {
+		SubscriptionID string
+	}{}
+
+	if err := encoding.Unmarshal(*params, &input); err != nil {
+		b.printError("Unsubscribe messages", err)
+		return err
+	}
+
+	b.subscriptionLock.Lock()
+	defer b.subscriptionLock.Unlock()
+	if _, exists := b.subscriptions[input.SubscriptionID]; !exists {
+		return ErrUnknownSubscription
+	}
+	delete(b.subscriptions, input.SubscriptionID)
+
+	data, _ := encoding.Marshal(true)
+	*reply = data
+	return nil
+}
@@ -0,0 +1,83 @@
+package rpc
+
+import (
+	"errors"
+
+	"github.com/adamnite/go-adamnite/common"
+	"github.com/adamnite/go-adamnite/txpool"
+	"github.com/adamnite/go-adamnite/utils"
+	log "github.com/sirupsen/logrus"
+)
+
+// transactions sent to the bouncer are checked against the state, held in a transaction pool while they are pending,
+// and passed on to the network. Held transactions count against the balance of their sender, so the same funds can't
+// be sent twice, until a block includes them and the state takes over.
+
+var (
+	ErrNotPropagated = errors.New("the transaction could not be passed on to the network")
+)
+
+// transactionRejections name the reasons a transaction is turned away, for clients to act on.
+var transactionRejections = []struct {
+	err    error
+	reason string
+}{
+	{ErrBadSignature, "badSignature"},
+	{utils.ErrNegativeAmount, "invalidAmount"},
+	{utils.ErrWrongChain, "wrongChain"},
+	{utils.ErrNonceUsed, "nonceUsed"},
+	{txpool.ErrInsufficientFunds, "insufficientBalance"},
+	{txpool.ErrAlreadyKnown, "alreadyKnown"},
+	{txpool.ErrReplaceUnderpriced, "replaceUnderpriced"},
+	{txpool.ErrAccountLimit, "accountLimit"},
+	{txpool.ErrUnderpriced, "poolFull"},
+	{ErrNotPropagated, "notPropagated"},
+}
+
+// transactionRejection is the reason the transaction was turned away, or false if the error isn't a rejection.
+func transactionRejection(err error) (string, bool) {
+	for _, rejection := range transactionRejections {
+		if errors.Is(err, rejection.err) {
+			return rejection.reason, true
+		}
+	}
+	return "", false
+}
+
+// submitTransaction checks the transaction, holds it and passes it on to the network, returning its hash.
+func (b *BouncerServer) submitTransaction(transaction *utils.Transaction) (common.Hash, error) {
+	if b.stateDB == nil {
+		return common.Hash{}, ErrStateNotSet
+	}
+	if b.chain == nil {
+		return common.Hash{}, ErrChainNotSet
+	}
+	if transaction.Amount == nil || transaction.Amount.Sign() != 1 {
+		return common.Hash{}, utils.ErrNegativeAmount
+	}
+	err := transaction.Validate(b.chain.Config().ChainID, b.stateDB.GetNonce(transaction.From))
+	if errors.Is(err, utils.ErrWrongChain) || errors.Is(err, utils.ErrNonceUsed) {
+		return common.Hash{}, err
+	}
+	if err != nil {
+		return common.Hash{}, ErrBadSignature
+	}
+	hash := transaction.Hash()
+	if err := b.txPool.Add(transaction); err != nil {
+		return common.Hash{}, err
+	}
+
+	content, err := CreateForwardToAll(transaction)
+	if err == nil {
+		content.InitialSender = transaction.From
+		err = b.propagator(content, &[]byte{})
+	}
+	if err != nil {
+		b.printError("Send transaction", err)
+		b.txPool.Remove(hash)
+		return common.Hash{}, ErrNotPropagated
+	}
+	log.Debugf(bouncerPreface, "transaction "+hash.Hex()+" sent on")
+	b.pendingTransactionFeed.Send(transaction)
+	return hash, nil
+}
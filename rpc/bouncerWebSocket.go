@@ -0,0 +1,301 @@
+package rpc
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/adamnite/go-adamnite/blockchain"
+	"github.com/adamnite/go-adamnite/event"
+	"github.com/adamnite/go-adamnite/utils"
+	"github.com/gorilla/websocket"
+)
+
+// clients can open a WebSocket on the JSON-RPC endpoint, and call "subscribe" to have chain events pushed to them:
+//
+//	{"jsonrpc":"2.0","id":1,"method":"subscribe","params":["newHeads"]}
+//	{"jsonrpc":"2.0","id":2,"method":"subscribe","params":["newPendingTransactions"]}
+//
+// each returns a subscription ID, that events are then sent with, until "unsubscribe" is called with it:
+//
+//	{"jsonrpc":"2.0","method":"subscription","params":{"subscription":"0x..","result":{..}}}
+//
+// There's no "logs" subscription, as contracts can't emit events yet. Every other bouncer method can be called over
+// the socket too. Clients that don't keep up with what is sent to them
+// are disconnected once webSocketQueueSize messages are waiting, rather than slowing the chain down.
+
+const (
+	webSocketQueueSize        = 256 // messages waiting to be written to a client, before it's deemed too slow
+	webSocketEventBuffer      = 16  // events buffered by each subscription, between the chain and the client's queue
+	webSocketMaxSubscriptions = 64  // per connection
+	webSocketWriteTimeout     = 10 * time.Second
+	webSocketPingInterval     = 30 * time.Second
+	webSocketPongTimeout      = 2 * webSocketPingInterval
+
+	subscriptionNewHeads               = "newHeads"
+	subscriptionNewPendingTransactions = "newPendingTransactions"
+)
+
+var (
+	ErrTooManySubscriptions  = errors.New("this connection has too many subscriptions open")
+	ErrUnknownSubscriptionTo = errors.New("unknown subscription type")
+	ErrConnectionClosed      = errors.New("the connection has closed")
+)
+
+type jsonRPCNotification struct {
+	Version string                    `json:"jsonrpc"`
+	Method  string                    `json:"method"`
+	Params  jsonRPCSubscriptionResult `json:"params"`
+}
+
+type jsonRPCSubscriptionResult struct {
+	Subscription string      `json:"subscription"`
+	Result       interface{} `json:"result"`
+}
+
+// SubscribePendingTransactions sends every transaction submitted to this bouncer to the channel.
+func (b *BouncerServer) SubscribePendingTransactions(ch chan<- *utils.Transaction) event.Subscription {
+	return b.pendingTransactionFeed.Subscribe(ch)
+}
+
+// checkOrigin lets browsers on the allowed origins open a WebSocket. Other clients don't send an origin.
+func (b *BouncerServer) checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" || len(b.allowedOrigins) == 0 {
+		return true
+	}
+	for _, allowed := range b.allowedOrigins {
+		if allowed == "*" || allowed == origin {
+			return true
+		}
+	}
+	return false
+}
+
+func (b *BouncerServer) serveWebSocket(w http.ResponseWriter, r *http.Request) {
+	upgrader := websocket.Upgrader{CheckOrigin: b.checkOrigin}
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		b.printError("WebSocket", err)
+		return
+	}
+	c := &webSocketConn{
+		bouncer:       b,
+		caller:        newJSONRPCCaller(r), //the handshake authenticates the whole connection
+		conn:          conn,
+		out:           make(chan interface{}, webSocketQueueSize),
+		closed:        make(chan struct{}),
+		subscriptions: make(map[string]event.Subscription),
+	}
+	b.subscriptionLock.Lock()
+	b.webSockets[c] = struct{}{}
+	b.subscriptionLock.Unlock()
+
+	go c.writeLoop()
+	c.readLoop()
+}
+
+// webSocketConn is a client connected over a WebSocket, and everything it's subscribed to.
+type webSocketConn struct {
+	bouncer *BouncerServer
+	caller  jsonRPCCaller
+	conn    *websocket.Conn
+	out     chan interface{} // only written to the socket by writeLoop
+	closed  chan struct{}
+
+	scope         event.SubscriptionScope
+	subscriptions map[string]event.Subscription
+	lock          sync.Mutex
+	closeOnce     sync.Once
+}
+
+// close drops every subscription of the connection, then the connection.
+func (c *webSocketConn) close() {
+	c.closeOnce.Do(func() {
+		close(c.closed)
+		c.scope.Close()
+		_ = c.conn.Close()
+
+		c.bouncer.subscriptionLock.Lock()
+		delete(c.bouncer.webSockets, c)
+		c.bouncer.subscriptionLock.Unlock()
+	})
+}
+
+// send queues the message to be written, disconnecting the client if it has fallen too far behind.
+func (c *webSocketConn) send(msg interface{}) {
+	select {
+	case <-c.closed:
+	case c.out <- msg:
+	default:
+		c.bouncer.printError("WebSocket", fmt.Errorf("client at %v is too slow, disconnecting", c.conn.RemoteAddr()))
+		c.close()
+	}
+}
+
+func (c *webSocketConn) notify(id string, result interface{}) {
+	c.send(&jsonRPCNotification{
+		Version: jsonRPCVersion,
+		Method:  "subscription",
+		Params:  jsonRPCSubscriptionResult{id, result},
+	})
+}
+
+func (c *webSocketConn) readLoop() {
+	defer c.close()
+	if maxSize := c.bouncer.guard.getPolicy().MaxRequestSize; maxSize > 0 {
+		c.conn.SetReadLimit(int64(maxSize))
+	}
+	_ = c.conn.SetReadDeadline(time.Now().Add(webSocketPongTimeout))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(webSocketPongTimeout))
+	})
+	for {
+		_, body, err := c.conn.ReadMessage()
+		if err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				c.bouncer.printError("WebSocket", err)
+			}
+			return
+		}
+		_ = c.conn.SetReadDeadline(time.Now().Add(webSocketPongTimeout))
+		if reply := answerJSONRPC(body, c.handle); reply != nil {
+			c.send(reply)
+		}
+	}
+}
+
+func (c *webSocketConn) writeLoop() {
+	ticker := time.NewTicker(webSocketPingInterval)
+	defer ticker.Stop()
+	defer c.close()
+	for {
+		select {
+		case msg := <-c.out:
+			_ = c.conn.SetWriteDeadline(time.Now().Add(webSocketWriteTimeout))
+			if err := c.conn.WriteJSON(msg); err != nil {
+				return
+			}
+		case <-ticker.C:
+			if err := c.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(webSocketWriteTimeout)); err != nil {
+				return
+			}
+		case <-c.closed:
+			return
+		}
+	}
+}
+
+// handle answers subscribe and unsubscribe, handing any other request to the bouncer.
+func (c *webSocketConn) handle(raw json.RawMessage) *jsonRPCResponse {
+	var req jsonRPCRequest
+	if err := json.Unmarshal(raw, &req); err != nil || req.Version != jsonRPCVersion ||
+		(req.Method != "subscribe" && req.Method != "unsubscribe") {
+		return c.bouncer.handleJSONRPC(c.caller, raw)
+	}
+	c.bouncer.print(fmt.Sprint("WebSocket ", req.Method))
+
+	var result interface{}
+	var err *jsonRPCError
+	if limited := c.bouncer.guard.allow(c.caller.ip); limited != nil {
+		err = guardError(limited)
+	} else if req.Method == "subscribe" {
+		result, err = c.subscribe(req.Params)
+	} else {
+		result, err = c.unsubscribe(req.Params)
+	}
+	if req.ID == nil {
+		return nil
+	}
+	return &jsonRPCResponse{Version: jsonRPCVersion, ID: req.ID, Result: result, Error: err}
+}
+
+func (c *webSocketConn) subscribe(params json.RawMessage) (interface{}, *jsonRPCError) {
+	var kind string
+	if err := readJSONParams(params, []string{"type"}, &kind); err != nil {
+		return nil, invalidParams(err)
+	}
+	chain := c.bouncer.chain
+	if chain == nil && kind == subscriptionNewHeads {
+		return nil, &jsonRPCError{Code: jsonRPCServerError, Message: ErrChainNotSet.Error()}
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if len(c.subscriptions) >= webSocketMaxSubscriptions {
+		return nil, &jsonRPCError{Code: jsonRPCServerError, Message: ErrTooManySubscriptions.Error()}
+	}
+	id, err := randomHex(16)
+	if err != nil {
+		return nil, &jsonRPCError{Code: jsonRPCServerError, Message: err.Error()}
+	}
+	id = "0x" + id
+
+	var sub event.Subscription
+	switch kind {
+	case subscriptionNewHeads:
+		heads := make(chan blockchain.ChainHeadEvent, webSocketEventBuffer)
+		sub = c.track(chain.SubscribeChainHeadEvent(heads))
+		if sub != nil {
+			go func(sub event.Subscription) {
+				for {
+					select {
+					case head := <-heads:
+						c.notify(id, newJSONBlock(head.Block))
+					case <-sub.Err():
+						return
+					}
+				}
+			}(sub)
+		}
+	case subscriptionNewPendingTransactions:
+		transactions := make(chan *utils.Transaction, webSocketEventBuffer)
+		sub = c.track(c.bouncer.SubscribePendingTransactions(transactions))
+		if sub != nil {
+			go func(sub event.Subscription) {
+				for {
+					select {
+					case transaction := <-transactions:
+						c.notify(id, transaction.Hash().Hex())
+					case <-sub.Err():
+						return
+					}
+				}
+			}(sub)
+		}
+	default:
+		return nil, invalidParams(fmt.Errorf("%w %q", ErrUnknownSubscriptionTo, kind))
+	}
+	if sub == nil {
+		return nil, &jsonRPCError{Code: jsonRPCServerError, Message: ErrConnectionClosed.Error()}
+	}
+	c.subscriptions[id] = sub
+	return id, nil
+}
+
+// track ties the subscription to the connection, returning nil if either has already closed.
+func (c *webSocketConn) track(sub event.Subscription) event.Subscription {
+	if sub == nil {
+		return nil
+	}
+	return c.scope.Track(sub)
+}
+
+func (c *webSocketConn) unsubscribe(params json.RawMessage) (interface{}, *jsonRPCError) {
+	var id string
+	if err := readJSONParams(params, []string{"subscription"}, &id); err != nil {
+		return nil, invalidParams(err)
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	sub, exists := c.subscriptions[id]
+	if !exists {
+		return nil, &jsonRPCError{Code: jsonRPCServerError, Message: ErrUnknownSubscription.Error()}
+	}
+	sub.Unsubscribe()
+	delete(c.subscriptions, id)
+	return true, nil
+}
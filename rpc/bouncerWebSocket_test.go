@@ -0,0 +1,140 @@
+package rpc
+
+import (
+	"encoding/json"
+	"math/big"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/adamnite/go-adamnite/core/types"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	encoding "github.com/vmihailenco/msgpack/v5"
+)
+
+type testWebSocketMessage struct {
+	testJSONRPCResponse
+	Method string `json:"method"`
+	Params struct {
+		Subscription string          `json:"subscription"`
+		Result       json.RawMessage `json:"result"`
+	} `json:"params"`
+}
+
+func dialTestWebSocket(t *testing.T) (*websocket.Conn, func()) {
+	server := httptest.NewServer(bouncerServer)
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		server.Close()
+		t.Fatal(err)
+	}
+	return conn, func() {
+		conn.Close()
+		server.Close()
+	}
+}
+
+func readTestWebSocket(t *testing.T, conn *websocket.Conn) testWebSocketMessage {
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var msg testWebSocketMessage
+	if err := conn.ReadJSON(&msg); err != nil {
+		t.Fatal(err)
+	}
+	return msg
+}
+
+func subscribeTestWebSocket(t *testing.T, conn *websocket.Conn, params string) string {
+	if err := conn.WriteMessage(websocket.TextMessage,
+		[]byte(`{"jsonrpc":"2.0","id":1,"method":"subscribe","params":`+params+`}`)); err != nil {
+		t.Fatal(err)
+	}
+	reply := readTestWebSocket(t, conn)
+	if reply.Error != nil {
+		t.Fatal(reply.Error)
+	}
+	var id string
+	if err := json.Unmarshal(reply.Result, &id); err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+func TestWebSocketNewHeads(t *testing.T) {
+	conn, done := dialTestWebSocket(t)
+	defer done()
+
+	id := subscribeTestWebSocket(t, conn, `["newHeads"]`)
+	block := types.NewBlockWithHeader(&types.BlockHeader{Number: big.NewInt(1000)})
+	if err := bouncerServer.chain.WriteBlock(block); err != nil {
+		t.Fatal(err)
+	}
+	msg := readTestWebSocket(t, conn)
+	assert.Equal(t, "subscription", msg.Method)
+	assert.Equal(t, id, msg.Params.Subscription)
+	var head jsonBlock
+	if err := json.Unmarshal(msg.Params.Result, &head); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "0x3e8", head.Number)
+	assert.Equal(t, block.Hash().Hex(), head.Hash)
+
+	//other methods still work on the socket
+	if err := conn.WriteMessage(websocket.TextMessage, []byte(`{"jsonrpc":"2.0","id":2,"method":"getChainID"}`)); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, `"0.1.2"`, string(readTestWebSocket(t, conn).Result))
+
+	if err := conn.WriteMessage(websocket.TextMessage,
+		[]byte(`{"jsonrpc":"2.0","id":3,"method":"unsubscribe","params":["`+id+`"]}`)); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "true", string(readTestWebSocket(t, conn).Result))
+	if err := conn.WriteMessage(websocket.TextMessage,
+		[]byte(`{"jsonrpc":"2.0","id":4,"method":"unsubscribe","params":["`+id+`"]}`)); err != nil {
+		t.Fatal(err)
+	}
+	assert.NotNil(t, readTestWebSocket(t, conn).Error, "unsubscribing twice should fail")
+}
+
+func TestWebSocketPendingTransactions(t *testing.T) {
+	conn, done := dialTestWebSocket(t)
+	defer done()
+
+	id := subscribeTestWebSocket(t, conn, `["newPendingTransactions"]`)
+	transaction, _, _ := newTestSendTransaction(t, 10, 10)
+	params, _ := encoding.Marshal(transaction)
+	output := []byte{}
+	if err := bouncerClient.Call(BouncerSendTransactionEndpoint, params, &output); err != nil {
+		t.Fatal(err)
+	}
+
+	msg := readTestWebSocket(t, conn)
+	assert.Equal(t, id, msg.Params.Subscription)
+	assert.Equal(t, `"`+transaction.Hash().Hex()+`"`, string(msg.Params.Result))
+}
+
+func TestWebSocketBadSubscription(t *testing.T) {
+	conn, done := dialTestWebSocket(t)
+	defer done()
+
+	if err := conn.WriteMessage(websocket.TextMessage,
+		[]byte(`{"jsonrpc":"2.0","id":1,"method":"subscribe","params":["somethingElse"]}`)); err != nil {
+		t.Fatal(err)
+	}
+	reply := readTestWebSocket(t, conn)
+	if assert.NotNil(t, reply.Error) {
+		assert.Equal(t, jsonRPCInvalidParams, reply.Error.Code)
+	}
+
+	//contracts can't emit events, so there are no logs to subscribe to
+	if err := conn.WriteMessage(websocket.TextMessage,
+		[]byte(`{"jsonrpc":"2.0","id":2,"method":"subscribe","params":["logs"]}`)); err != nil {
+		t.Fatal(err)
+	}
+	reply = readTestWebSocket(t, conn)
+	if assert.NotNil(t, reply.Error) {
+		assert.Equal(t, jsonRPCInvalidParams, reply.Error.Code)
+	}
+}
@@ -1,10 +1,23 @@
 package rpc
 
 import (
+	"crypto/ecdsa"
 	"encoding/hex"
+	"fmt"
 	"math/big"
 	"testing"
+	"time"
 
+	"github.com/adamnite/go-adamnite/VM"
+	"github.com/adamnite/go-adamnite/blockchain"
+	"github.com/adamnite/go-adamnite/common"
+	"github.com/adamnite/go-adamnite/core"
+	"github.com/adamnite/go-adamnite/core/types"
+	"github.com/adamnite/go-adamnite/crypto"
+	"github.com/adamnite/go-adamnite/databaseDeprecated/trie"
+	"github.com/adamnite/go-adamnite/event"
+	"github.com/adamnite/go-adamnite/txpool"
+	"github.com/adamnite/go-adamnite/utils"
 	"github.com/adamnite/go-adamnite/utils/accounts"
 	"github.com/stretchr/testify/assert"
 	encoding "github.com/vmihailenco/msgpack/v5"
@@ -15,7 +28,7 @@ import (
 func TestGetChainID(t *testing.T) {
 	output := []byte{}
 
-	err := bouncerClient.Call(getChainIDEndpoint, []byte{}, &output)
+	err := bouncerClient.Call(BouncerGetChainIDEndpoint, []byte{}, &output)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -38,7 +51,7 @@ func TestGetBalance(t *testing.T) {
 		accountData, _ := encoding.Marshal(&account)
 
 		output := []byte{}
-		if err := bouncerClient.Call(getBalanceEndpoint, accountData, &output); err != nil {
+		if err := bouncerClient.Call(BouncerGetBalanceEndpoint, accountData, &output); err != nil {
 			t.Fatal(err)
 		}
 
@@ -62,9 +75,13 @@ func TestGetAddresses(t *testing.T) {
 		if err != nil {
 			t.Fatal(err)
 		}
+		accountData, err = NewTokenParams(testAdminToken, accountData)
+		if err != nil {
+			t.Fatal(err)
+		}
 
 		output := []byte{}
-		if err := bouncerClient.Call(createAccountEndpoint, accountData, &output); err != nil {
+		if err := bouncerClient.Call(BouncerCreateAccountEndpoint, accountData, &output); err != nil {
 			t.Fatal(err)
 		}
 
@@ -85,15 +102,19 @@ func TestGetAddresses(t *testing.T) {
 		if err != nil {
 			t.Fatal(err)
 		}
+		accountData, err = NewTokenParams(testAdminToken, accountData)
+		if err != nil {
+			t.Fatal(err)
+		}
 
 		output := []byte{}
-		if err := bouncerClient.Call(createAccountEndpoint, accountData, &output); err.Error() != ErrPreExistingAccount.Error() {
+		if err := bouncerClient.Call(BouncerCreateAccountEndpoint, accountData, &output); err.Error() != ErrPreExistingAccount.Error() {
 			t.Fatal(err)
 		}
 	}
 }
 
-func TestMessaging(t *testing.T) {
+func TestMessagingLegacyRefused(t *testing.T) {
 	sender, _ := accounts.GenerateAccount()
 	receiver, _ := accounts.GenerateAccount()
 
@@ -125,14 +146,525 @@ func TestMessaging(t *testing.T) {
 		t.Fatal(err)
 	}
 
+	//only the ciphertext is signed by the legacy protocol, so everything else could be changed on the way
+	output := []byte{}
+	err = bouncerClient.Call(BouncerNewMessageEndpoint, msgData, &output)
+	if assert.Error(t, err) {
+		assert.Equal(t, utils.ErrLegacyProtocol.Error(), err.Error())
+	}
+}
+
+func TestMessagingSignatureChecked(t *testing.T) {
+	sender, _ := accounts.GenerateAccount()
+	receiver, _ := accounts.GenerateAccount()
+	msg, err := utils.NewCaesarMessage(*receiver, *sender, "Hello, world!")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	input := struct {
+		FromPublicKey string
+		ToPublicKey   string
+		RawMessage    string
+		SignedMessage string
+		Version       uint8
+		Protocol      uint8
+		InitialTime   int64
+	}{
+		hex.EncodeToString(sender.PublicKey),
+		hex.EncodeToString(receiver.PublicKey),
+		hex.EncodeToString(msg.Message),
+		hex.EncodeToString(msg.Signature),
+		msg.Version,
+		msg.Protocol,
+		msg.InitialTime,
+	}
+	msgData, err := encoding.Marshal(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	output := []byte{}
+	assert.NoError(t, bouncerClient.Call(BouncerNewMessageEndpoint, msgData, &output))
+
+	//the signature covers the time, so it can't be changed on the way
+	input.InitialTime++
+	msgData, err = encoding.Marshal(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = bouncerClient.Call(BouncerNewMessageEndpoint, msgData, &output)
+	if assert.Error(t, err) {
+		assert.Equal(t, ErrBadSignature.Error(), err.Error())
+	}
+}
+
+func TestGetCaesarChunk(t *testing.T) {
+	_, chunks, err := utils.NewCaesarAttachment("hello.txt", "text/plain", []byte("Hello World!"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored := chunks[0]
+	bouncerServer.SetChunkHandler(func(id []byte) (*utils.CaesarChunk, error) {
+		if hex.EncodeToString(id) == hex.EncodeToString(stored.ID()) {
+			return stored, nil
+		}
+		return nil, nil
+	})
+	defer bouncerServer.SetChunkHandler(nil)
+
+	input, _ := encoding.Marshal(&struct{ ID string }{hex.EncodeToString(stored.ID())})
 	output := []byte{}
-	err = bouncerClient.Call(bouncerNewMessageEndpoint, msgData, &output) //this will return an error (it doesn't have a forwarding server of its own)
-	if err == nil {
-		println("error expected, but none returned")
-		return
-	}
-	assert.Equal(
-		t,
-		"this is an incomplete bouncer server, and cannot forward",
-		err.Error(), "different error returned? %v", err)
+	if err := bouncerClient.Call(BouncerGetCaesarChunkEndpoint, input, &output); err != nil {
+		t.Fatal(err)
+	}
+	var data string
+	if err := encoding.Unmarshal(output, &data); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, hex.EncodeToString(stored.Data), data)
+
+	unknown, _ := encoding.Marshal(&struct{ ID string }{hex.EncodeToString(chunks[1].ID())})
+	assert.Error(t, bouncerClient.Call(BouncerGetCaesarChunkEndpoint, unknown, &output), "unknown chunk returned")
+
+	//a chunk that no longer matches its ID isn't served
+	stored = &utils.CaesarChunk{Data: append([]byte{1}, stored.Data[1:]...)}
+	assert.Error(t, bouncerClient.Call(BouncerGetCaesarChunkEndpoint, input, &output), "tampered chunk returned")
+}
+
+func TestMessageSubscription(t *testing.T) {
+	var feed event.Feed
+	bouncerServer.SetMessageSubscriptions(func(ch chan<- *utils.CaesarMessage) event.Subscription {
+		return feed.Subscribe(ch)
+	})
+	sender, _ := accounts.GenerateAccount()
+	receiver, _ := accounts.GenerateAccount()
+
+	output := []byte{}
+	if err := bouncerClient.Call(BouncerGetSubscriptionChallengeEndpoint, []byte{}, &output); err != nil {
+		t.Fatal(err)
+	}
+	var challenge string
+	if err := encoding.Unmarshal(output, &challenge); err != nil {
+		t.Fatal(err)
+	}
+	signature, err := receiver.Sign(SubscriptionChallengePayload(common.FromHex(challenge)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	input := struct {
+		PublicKey string
+		Challenge string
+		Signature string
+	}{hex.EncodeToString(receiver.PublicKey), challenge, hex.EncodeToString(signature)}
+
+	//only the owner of the account can subscribe to it
+	impostor := input
+	impostor.PublicKey = hex.EncodeToString(sender.PublicKey)
+	impostorData, _ := encoding.Marshal(impostor)
+	assert.Error(t, bouncerClient.Call(BouncerSubscribeMessagesEndpoint, impostorData, &output))
+
+	//that used up the challenge
+	inputData, _ := encoding.Marshal(input)
+	err = bouncerClient.Call(BouncerSubscribeMessagesEndpoint, inputData, &output)
+	if assert.Error(t, err) {
+		assert.Equal(t, ErrInvalidChallenge.Error(), err.Error())
+	}
+
+	if err := bouncerClient.Call(BouncerGetSubscriptionChallengeEndpoint, []byte{}, &output); err != nil {
+		t.Fatal(err)
+	}
+	if err := encoding.Unmarshal(output, &input.Challenge); err != nil {
+		t.Fatal(err)
+	}
+	signature, _ = receiver.Sign(SubscriptionChallengePayload(common.FromHex(input.Challenge)))
+	input.Signature = hex.EncodeToString(signature)
+	inputData, _ = encoding.Marshal(input)
+	if err := bouncerClient.Call(BouncerSubscribeMessagesEndpoint, inputData, &output); err != nil {
+		t.Fatal(err)
+	}
+	var subscriptionID string
+	if err := encoding.Unmarshal(output, &subscriptionID); err != nil {
+		t.Fatal(err)
+	}
+
+	msg, err := utils.NewCaesarMessage(*receiver, *sender, "Hello, world!")
+	if err != nil {
+		t.Fatal(err)
+	}
+	unrelated, _ := utils.NewCaesarMessage(*sender, *sender, "not for the receiver")
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		feed.Send(unrelated)
+		feed.Send(msg)
+	}()
+
+	pollData, _ := encoding.Marshal(struct {
+		SubscriptionID string
+		TimeoutMillis  int64
+	}{subscriptionID, 5000})
+	var received []bouncerMessage
+	for len(received) == 0 {
+		if err := bouncerClient.Call(BouncerPollMessagesEndpoint, pollData, &output); err != nil {
+			t.Fatal(err)
+		}
+		var polled []bouncerMessage
+		if err := encoding.Unmarshal(output, &polled); err != nil {
+			t.Fatal(err)
+		}
+		received = append(received, polled...)
+	}
+	if assert.Equal(t, 1, len(received), "messages for other accounts were pushed") {
+		assert.Equal(t, hex.EncodeToString(msg.Hash()), received[0].Hash)
+		assert.Equal(t, hex.EncodeToString(msg.Message), received[0].Content)
+	}
+
+	unsubscribeData, _ := encoding.Marshal(struct{ SubscriptionID string }{subscriptionID})
+	assert.NoError(t, bouncerClient.Call(BouncerUnsubscribeMessagesEndpoint, unsubscribeData, &output))
+	err = bouncerClient.Call(BouncerPollMessagesEndpoint, pollData, &output)
+	if assert.Error(t, err) {
+		assert.Equal(t, ErrUnknownSubscription.Error(), err.Error())
+	}
+}
+
+func TestSubscriptionLimits(t *testing.T) {
+	bouncer := &BouncerServer{
+		subscriptions: make(map[string]*messageSubscription),
+		challenges:    make(map[string]time.Time),
+	}
+	var feed event.Feed
+	bouncer.SetMessageSubscriptions(func(ch chan<- *utils.CaesarMessage) event.Subscription {
+		return feed.Subscribe(ch)
+	})
+	defer bouncer.messageFeed.Unsubscribe()
+
+	output := []byte{}
+	for i := 0; i < maxOutstandingChallenges; i++ {
+		if err := bouncer.GetSubscriptionChallenge(&[]byte{}, &output); err != nil {
+			t.Fatal(err)
+		}
+	}
+	assert.ErrorIs(t, bouncer.GetSubscriptionChallenge(&[]byte{}, &output), ErrTooManyChallenges)
+	//once they expire, challenges are handed out again
+	for challenge := range bouncer.challenges {
+		bouncer.challenges[challenge] = time.Now().Add(-2 * subscriptionChallengeWindow)
+		break
+	}
+	assert.NoError(t, bouncer.GetSubscriptionChallenge(&[]byte{}, &output))
+
+	for i := 0; i < maxSubscriptions; i++ {
+		bouncer.subscriptions[fmt.Sprint(i)] = &messageSubscription{notify: make(chan struct{}, 1), lastPoll: time.Now()}
+	}
+	var challenge string
+	encoding.Unmarshal(output, &challenge)
+	account, _ := accounts.GenerateAccount()
+	signature, _ := account.Sign(SubscriptionChallengePayload(common.FromHex(challenge)))
+	input, _ := encoding.Marshal(struct {
+		PublicKey string
+		Challenge string
+		Signature string
+	}{hex.EncodeToString(account.PublicKey), challenge, hex.EncodeToString(signature)})
+	assert.ErrorIs(t, bouncer.SubscribeMessages(&input, &output), ErrSubscriptionsFull)
+	//nor is the challenge used up, so it can be answered once an idle subscription is dropped
+	bouncer.subscriptions["0"].lastPoll = time.Now().Add(-2 * subscriptionIdleTimeout)
+	assert.NoError(t, bouncer.SubscribeMessages(&input, &output))
+}
+
+func TestPollWaitsAfterTakingMessages(t *testing.T) {
+	bouncer := &BouncerServer{subscriptions: make(map[string]*messageSubscription)}
+	sub := &messageSubscription{notify: make(chan struct{}, 1), lastPoll: time.Now()}
+	bouncer.subscriptions["sub"] = sub
+	sub.queue = []bouncerMessage{{Content: "first"}, {Content: "second"}}
+	sub.notify <- struct{}{}
+
+	pollData, _ := encoding.Marshal(struct {
+		SubscriptionID string
+		TimeoutMillis  int64
+	}{"sub", 100})
+	output := []byte{}
+	assert.NoError(t, bouncer.PollMessages(&pollData, &output))
+	var polled []bouncerMessage
+	encoding.Unmarshal(output, &polled)
+	assert.Equal(t, 2, len(polled))
+
+	//the signals for the messages already taken don't cut the next poll short
+	start := time.Now()
+	assert.NoError(t, bouncer.PollMessages(&pollData, &output))
+	assert.GreaterOrEqual(t, time.Since(start), 100*time.Millisecond, "the poll returned without waiting")
+	encoding.Unmarshal(output, &polled)
+	assert.Empty(t, polled)
+}
+
+// signs a transfer of 10 from a new account, for a block of that number
+func newTestTransfer(t *testing.T, number int64) *types.Transaction {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stateDB.AddBalance(crypto.PubkeyToAddress(key.PublicKey), big.NewInt(100))
+	transfer := types.NewTransferTransaction(chainConfig.ChainID, 0, common.Address{byte(number)}, big.NewInt(10), big.NewInt(2), 21000)
+	tx, err := types.SignTransaction(transfer, types.MakeSigner(chainConfig, big.NewInt(number)), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tx
+}
+
+// inserts a block of one signed transfer, from a new account, into the chain
+func writeTestTransactionBlock(t *testing.T, number int64) (*types.Transaction, *types.Block) {
+	tx := newTestTransfer(t, number)
+	block, _ := writeTestBlock(t, number, tx)
+	return tx, block
+}
+
+// inserts a block of the transaction into the chain, running it on the state, and returns the block and its receipts
+func writeTestBlock(t *testing.T, number int64, tx *types.Transaction) (*types.Block, types.Receipts) {
+	block := types.NewBlock(&types.BlockHeader{Number: big.NewInt(number)}, []*types.Transaction{tx}, trie.NewStackTrie(nil))
+	receipts, err := bouncerServer.chain.InsertBlock(block, stateDB, VM.GetDefaultConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(receipts) != 1 {
+		t.Fatalf("expected a receipt for the transaction, got %v", len(receipts))
+	}
+	return block, receipts
+}
+
+func TestGetTransactionAndReceipt(t *testing.T) {
+	tx, block := writeTestTransactionBlock(t, 2000)
+	params, _ := encoding.Marshal(struct{ Hash common.Hash }{tx.Hash()})
+
+	output := []byte{}
+	if err := bouncerClient.Call(BouncerGetTransactionByHashEndpoint, params, &output); err != nil {
+		t.Fatal(err)
+	}
+	var included *IncludedTransaction
+	if err := encoding.Unmarshal(output, &included); err != nil {
+		t.Fatal(err)
+	}
+	if assert.NotNil(t, included) {
+		assert.Equal(t, tx.Hash(), included.Hash)
+		assert.Equal(t, types.NORMAL_TX, included.Type)
+		sender, _ := types.Sender(types.MakeSigner(chainConfig, block.Number()), tx)
+		assert.Equal(t, &sender, included.From)
+		assert.Equal(t, chainConfig.ChainID, included.ChainID)
+		assert.Equal(t, uint64(0), included.Nonce)
+		assert.Equal(t, block.Hash(), included.BlockHash)
+		assert.Equal(t, uint64(2000), included.BlockNumber)
+		assert.Equal(t, uint64(0), included.Index)
+	}
+
+	if err := bouncerClient.Call(BouncerGetTransactionReceiptEndpoint, params, &output); err != nil {
+		t.Fatal(err)
+	}
+	var receipt *types.Receipt
+	if err := encoding.Unmarshal(output, &receipt); err != nil {
+		t.Fatal(err)
+	}
+	if assert.NotNil(t, receipt, "the receipt of the processed block wasn't kept") {
+		assert.Equal(t, types.ReceiptStatusSuccessful, receipt.Status)
+		assert.Equal(t, tx.Hash(), receipt.TxHash)
+		assert.Equal(t, block.Hash(), receipt.BlockHash)
+	}
+	assert.Equal(t, big.NewInt(10), stateDB.GetBalance(*tx.To()), "the transfer wasn't run")
+
+	//transactions that aren't in a block are nil, not an error
+	unknown, _ := encoding.Marshal(struct{ Hash common.Hash }{common.Hash{1}})
+	for _, endpoint := range []string{BouncerGetTransactionByHashEndpoint, BouncerGetTransactionReceiptEndpoint} {
+		if err := bouncerClient.Call(endpoint, unknown, &output); err != nil {
+			t.Fatal(err)
+		}
+		var reply interface{}
+		assert.NoError(t, encoding.Unmarshal(output, &reply))
+		assert.Nil(t, reply, "%v found a transaction that doesn't exist", endpoint)
+	}
+}
+
+func TestReplayedTransactionRefused(t *testing.T) {
+	tx, _ := writeTestTransactionBlock(t, 2020)
+	replay := types.NewBlock(&types.BlockHeader{Number: big.NewInt(2021)}, []*types.Transaction{tx}, trie.NewStackTrie(nil))
+	_, err := bouncerServer.chain.InsertBlock(replay, stateDB, VM.GetDefaultConfig())
+	assert.ErrorIs(t, err, core.ErrNonceTooLow, "a block replaying an included transaction was accepted")
+	assert.Equal(t, big.NewInt(10), stateDB.GetBalance(*tx.To()), "the replayed transfer was run")
+	assert.Nil(t, bouncerServer.chain.GetBlockByNumber(big.NewInt(2021)))
+}
+
+func TestImportedBlockReceipts(t *testing.T) {
+	tx := newTestTransfer(t, 3000)
+	block := types.NewBlock(&types.BlockHeader{Number: big.NewInt(3000)}, []*types.Transaction{tx}, trie.NewStackTrie(nil))
+	assert.ErrorIs(t, bouncerServer.chain.WriteBlock(block), blockchain.ErrBlockNotRun, "a block was written without its receipts")
+	if err := bouncerServer.chain.AddImportedBlock(block, stateDB, VM.GetDefaultConfig()); err != nil {
+		t.Fatal(err)
+	}
+
+	params, _ := encoding.Marshal(struct{ Hash common.Hash }{tx.Hash()})
+	output := []byte{}
+	if err := bouncerClient.Call(BouncerGetTransactionReceiptEndpoint, params, &output); err != nil {
+		t.Fatal(err)
+	}
+	var receipt *types.Receipt
+	if err := encoding.Unmarshal(output, &receipt); err != nil {
+		t.Fatal(err)
+	}
+	if assert.NotNil(t, receipt, "the receipt of the imported block wasn't kept") {
+		assert.Equal(t, types.ReceiptStatusSuccessful, receipt.Status)
+		assert.Equal(t, block.Hash(), receipt.BlockHash)
+	}
+	assert.Equal(t, big.NewInt(10), stateDB.GetBalance(*tx.To()), "the imported transfer wasn't run")
+}
+
+// signs a transaction from a new account holding balance, and has the bouncer keep what it propagates
+func newTestSendTransaction(t *testing.T, balance int64, amount int64) (*utils.Transaction, *ecdsa.PrivateKey, *[]ForwardingContent) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	transaction := &utils.Transaction{
+		From:    crypto.PubkeyToAddress(key.PublicKey),
+		To:      testAccounts[2],
+		Amount:  big.NewInt(amount),
+		ChainID: chainConfig.ChainID,
+		Time:    time.Now(),
+	}
+	if err := transaction.Sign(*key); err != nil {
+		t.Fatal(err)
+	}
+	stateDB.AddBalance(transaction.From, big.NewInt(balance))
+
+	propagated := &[]ForwardingContent{}
+	propagator := bouncerServer.propagator
+	bouncerServer.SetHandlers(func(content ForwardingContent, _ *[]byte) error {
+		*propagated = append(*propagated, content)
+		return nil
+	})
+	t.Cleanup(func() { bouncerServer.SetHandlers(propagator) })
+	return transaction, key, propagated
+}
+
+func TestSendTransaction(t *testing.T) {
+	transaction, key, propagated := newTestSendTransaction(t, 15, 10)
+	send := func(transaction *utils.Transaction) (common.Hash, error) {
+		params, _ := encoding.Marshal(transaction)
+		output := []byte{}
+		if err := bouncerClient.Call(BouncerSendTransactionEndpoint, params, &output); err != nil {
+			return common.Hash{}, err
+		}
+		var hash common.Hash
+		err := encoding.Unmarshal(output, &hash)
+		return hash, err
+	}
+
+	hash, err := send(transaction)
+	assert.NoError(t, err)
+	assert.Equal(t, transaction.Hash(), hash)
+	if assert.Len(t, *propagated, 1) {
+		var forwarded *utils.Transaction
+		assert.Equal(t, SendTransactionEndpoint, (*propagated)[0].FinalEndpoint)
+		assert.NoError(t, encoding.Unmarshal((*propagated)[0].FinalParams, &forwarded))
+		assert.True(t, transaction.Equal(*forwarded), "a different transaction was propagated")
+	}
+
+	_, err = send(transaction)
+	assert.EqualError(t, err, txpool.ErrAlreadyKnown.Error())
+
+	//the pending transaction holds 10 of the 15, so another 10 can't be covered
+	again := &utils.Transaction{
+		From:    transaction.From,
+		To:      testAccounts[1],
+		Amount:  big.NewInt(10),
+		Nonce:   1,
+		ChainID: chainConfig.ChainID,
+		Time:    time.Now(),
+	}
+	if err := again.Sign(*key); err != nil {
+		t.Fatal(err)
+	}
+	_, err = send(again)
+	assert.EqualError(t, err, txpool.ErrInsufficientFunds.Error())
+
+	//a pending nonce can only be used again by paying more for it, and one in the state not at all
+	replay := &utils.Transaction{
+		From:    transaction.From,
+		To:      testAccounts[1],
+		Amount:  big.NewInt(1),
+		ChainID: chainConfig.ChainID,
+		Time:    time.Now(),
+	}
+	if err := replay.Sign(*key); err != nil {
+		t.Fatal(err)
+	}
+	_, err = send(replay)
+	assert.EqualError(t, err, txpool.ErrReplaceUnderpriced.Error())
+	used, usedKey, _ := newTestSendTransaction(t, 10, 1)
+	stateDB.SetNonce(used.From, 1)
+	_, err = send(used)
+	assert.EqualError(t, err, utils.ErrNonceUsed.Error())
+
+	otherChain := &utils.Transaction{
+		From:    used.From,
+		To:      testAccounts[1],
+		Amount:  big.NewInt(1),
+		Nonce:   1,
+		ChainID: big.NewInt(1),
+		Time:    time.Now(),
+	}
+	if err := otherChain.Sign(*usedKey); err != nil {
+		t.Fatal(err)
+	}
+	_, err = send(otherChain)
+	assert.EqualError(t, err, utils.ErrWrongChain.Error(), "a transaction signed for another chain was accepted")
+
+	stolen, _, _ := newTestSendTransaction(t, 0, 1)
+	stolen.From = transaction.From
+	_, err = send(stolen)
+	assert.EqualError(t, err, ErrBadSignature.Error(), "the signature was made by another account")
+
+	forged := *transaction
+	forged.Amount = big.NewInt(11)
+	_, err = send(&forged)
+	assert.EqualError(t, err, ErrBadSignature.Error())
+
+	poor, _, propagated := newTestSendTransaction(t, 5, 10)
+	_, err = send(poor)
+	assert.EqualError(t, err, txpool.ErrInsufficientFunds.Error())
+	assert.Empty(t, *propagated, "a rejected transaction was propagated")
+
+	//transactions the network never heard of aren't held
+	failing, _, _ := newTestSendTransaction(t, 10, 10)
+	bouncerServer.SetHandlers(func(ForwardingContent, *[]byte) error { return ErrNotSetupToHandleForwarding })
+	_, err = send(failing)
+	assert.EqualError(t, err, ErrNotPropagated.Error())
+	assert.False(t, bouncerServer.txPool.Has(failing.Hash()))
+}
+
+// a sent transaction is found by the hash it was sent with, once a block has it
+func TestSentTransactionIncluded(t *testing.T) {
+	transaction, _, _ := newTestSendTransaction(t, 20, 10)
+	params, _ := encoding.Marshal(transaction)
+	output := []byte{}
+	if err := bouncerClient.Call(BouncerSendTransactionEndpoint, params, &output); err != nil {
+		t.Fatal(err)
+	}
+	var hash common.Hash
+	if err := encoding.Unmarshal(output, &hash); err != nil {
+		t.Fatal(err)
+	}
+	writeTestBlock(t, 2010, transaction.Canonical())
+	assert.Eventually(t, func() bool {
+		return !bouncerServer.txPool.Has(hash)
+	}, time.Second, 10*time.Millisecond, "the included transaction is still pending")
+
+	params, _ = encoding.Marshal(struct{ Hash common.Hash }{hash})
+	if err := bouncerClient.Call(BouncerGetTransactionByHashEndpoint, params, &output); err != nil {
+		t.Fatal(err)
+	}
+	var included *IncludedTransaction
+	if err := encoding.Unmarshal(output, &included); err != nil {
+		t.Fatal(err)
+	}
+	if assert.NotNil(t, included, "the block's transaction wasn't found by the hash it was sent with") {
+		assert.Equal(t, types.NORMAL_TX, included.Type)
+		assert.Equal(t, &transaction.From, included.From)
+		assert.Equal(t, &transaction.To, included.To)
+		assert.Equal(t, transaction.Amount, included.Amount)
+	}
 }
@@ -0,0 +1,485 @@
+// Package bouncerclient is a typed client for the endpoints of a bouncer server, so callers don't need to marshal
+// requests by hand.
+package bouncerclient
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"net/rpc"
+	"sync"
+	"time"
+
+	"github.com/adamnite/go-adamnite/common"
+	"github.com/adamnite/go-adamnite/core/types"
+	admRpc "github.com/adamnite/go-adamnite/rpc"
+	"github.com/adamnite/go-adamnite/txpool"
+	"github.com/adamnite/go-adamnite/utils"
+	"github.com/adamnite/go-adamnite/utils/accounts"
+
+	log "github.com/sirupsen/logrus"
+	encoding "github.com/vmihailenco/msgpack/v5"
+)
+
+const (
+	clientPreface     = "[Adamnite bouncer client] %v \n"
+	defaultRetries    = 3
+	defaultRetryDelay = 500 * time.Millisecond
+)
+
+var (
+	ErrClientClosed = errors.New("the bouncer client has been closed")
+	ErrBadBalance   = errors.New("the bouncer returned a balance that isn't a number")
+
+	// errors the bouncer can return, so they can be checked with errors.Is once they've crossed the wire
+	serverErrors = []error{
+		admRpc.ErrUnauthorized,
+		admRpc.ErrMethodDisabled,
+		admRpc.ErrRateLimited,
+		admRpc.ErrRequestTooLarge,
+		admRpc.ErrSignatureExpired,
+		admRpc.ErrSignatureReused,
+		admRpc.ErrStateNotSet,
+		admRpc.ErrChainNotSet,
+		admRpc.ErrPreExistingAccount,
+		admRpc.ErrUnknownChunk,
+		admRpc.ErrBadSignature,
+		admRpc.ErrNoSubscriptions,
+		admRpc.ErrInvalidChallenge,
+		admRpc.ErrUnknownSubscription,
+		admRpc.ErrNotPropagated,
+		txpool.ErrInsufficientFunds,
+		txpool.ErrAlreadyKnown,
+		txpool.ErrReplaceUnderpriced,
+		txpool.ErrAccountLimit,
+		txpool.ErrUnderpriced,
+		utils.ErrNegativeAmount,
+		utils.ErrWrongChain,
+		utils.ErrNonceUsed,
+		utils.ErrLegacyProtocol,
+	}
+)
+
+// Message is a Caesar message, as the bouncer hands them out. Keys, content and hash are hex encoded.
+type Message struct {
+	FromPublicKey string `msgpack:"fromPublicKey" json:"fromPublicKey"`
+	ToPublicKey   string `msgpack:"toPublicKey,omitempty" json:"toPublicKey,omitempty"`
+	Timestamp     int64  `msgpack:"timestamp" json:"timestamp"`
+	Content       string `msgpack:"content" json:"content"`
+	Hash          string `msgpack:"hash,omitempty" json:"hash,omitempty"`
+}
+
+// Client calls a bouncer over net/rpc. It redials when the connection is lost, and is safe to use concurrently.
+type Client struct {
+	endpoint   string
+	tlsConfig  *tls.Config
+	retries    int
+	retryDelay time.Duration
+	adminToken string
+	admin      *accounts.Account
+
+	lock   sync.Mutex
+	client *rpc.Client
+	closed bool
+}
+
+type Option func(*Client)
+
+// WithTLS dials the bouncer over TLS. A nil config trusts the system's certificate authorities.
+func WithTLS(config *tls.Config) Option {
+	return func(c *Client) {
+		if config == nil {
+			config = &tls.Config{}
+		}
+		c.tlsConfig = config
+	}
+}
+
+// WithRetries sets how many times a call is tried again after losing the connection, and how long to wait between.
+// Calls that change state are only tried again if they never reached the bouncer.
+func WithRetries(retries int, delay time.Duration) Option {
+	return func(c *Client) {
+		c.retries = retries
+		c.retryDelay = delay
+	}
+}
+
+// WithAdminToken authenticates calls to admin endpoints with a token.
+func WithAdminToken(token string) Option {
+	return func(c *Client) {
+		c.adminToken = token
+	}
+}
+
+// WithAdminAccount authenticates calls to admin endpoints by signing them.
+func WithAdminAccount(admin *accounts.Account) Option {
+	return func(c *Client) {
+		c.admin = admin
+	}
+}
+
+// Dial connects to the bouncer at the endpoint (host:port).
+func Dial(ctx context.Context, endpoint string, options ...Option) (*Client, error) {
+	c := &Client{
+		endpoint:   endpoint,
+		retries:    defaultRetries,
+		retryDelay: defaultRetryDelay,
+	}
+	for _, option := range options {
+		option(c)
+	}
+	if _, err := c.connection(ctx); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *Client) Endpoint() string {
+	return c.endpoint
+}
+
+// Close drops the connection. Calls still running return an error.
+func (c *Client) Close() error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.closed = true
+	if c.client == nil {
+		return nil
+	}
+	err := c.client.Close()
+	c.client = nil
+	return err
+}
+
+// connection returns the current connection, dialing a new one if there is none.
+func (c *Client) connection(ctx context.Context) (*rpc.Client, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.closed {
+		return nil, ErrClientClosed
+	}
+	if c.client != nil {
+		return c.client, nil
+	}
+	var conn net.Conn
+	var err error
+	if c.tlsConfig != nil {
+		conn, err = (&tls.Dialer{Config: c.tlsConfig}).DialContext(ctx, "tcp", c.endpoint)
+	} else {
+		conn, err = (&net.Dialer{}).DialContext(ctx, "tcp", c.endpoint)
+	}
+	if err != nil {
+		return nil, err
+	}
+	c.client = rpc.NewClient(conn)
+	return c.client, nil
+}
+
+// dropConnection forgets the connection, if it's still the current one, so the next call dials again.
+func (c *Client) dropConnection(client *rpc.Client) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.client == client {
+		_ = c.client.Close()
+		c.client = nil
+	}
+}
+
+// call sends the params to the endpoint, and decodes the reply into reply if it isn't nil. Calls that are safe to
+// repeat are tried again whenever the connection is lost, others only if they never went out.
+func (c *Client) call(ctx context.Context, endpoint string, params []byte, reply interface{}, repeatable bool) error {
+	for attempt := 0; ; attempt++ {
+		client, err := c.connection(ctx)
+		if errors.Is(err, ErrClientClosed) {
+			return err
+		}
+		if err == nil {
+			var output []byte
+			err = c.do(ctx, client, endpoint, params, &output)
+			if err == nil {
+				if reply == nil {
+					return nil
+				}
+				return encoding.Unmarshal(output, reply)
+			}
+			var serverErr rpc.ServerError
+			if errors.As(err, &serverErr) {
+				return fromServerError(serverErr)
+			}
+			if ctx.Err() != nil {
+				return err
+			}
+			log.Debugf(clientPreface, fmt.Sprintf("%v lost the connection: %v", endpoint, err))
+			c.dropConnection(client)
+			if err != rpc.ErrShutdown && !repeatable {
+				return err //it may have been carried out
+			}
+		}
+		if attempt >= c.retries {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(c.retryDelay):
+		}
+	}
+}
+
+func (c *Client) do(ctx context.Context, client *rpc.Client, endpoint string, params []byte, output *[]byte) error {
+	call := client.Go(endpoint, params, output, make(chan *rpc.Call, 1))
+	select {
+	case <-call.Done:
+		return call.Error
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// adminParams wraps the params of a call to an admin endpoint in whatever authentication the client has.
+func (c *Client) adminParams(endpoint string, params []byte) ([]byte, error) {
+	switch {
+	case c.admin != nil:
+		return admRpc.NewSignedParams(c.admin, endpoint, params)
+	case c.adminToken != "":
+		return admRpc.NewTokenParams(c.adminToken, params)
+	}
+	return params, nil
+}
+
+func fromServerError(err rpc.ServerError) error {
+	for _, known := range serverErrors {
+		if string(err) == known.Error() {
+			return known
+		}
+	}
+	return err
+}
+
+// ChainID is the version of the chain the bouncer runs.
+func (c *Client) ChainID(ctx context.Context) (string, error) {
+	var chainID string
+	if err := c.call(ctx, admRpc.BouncerGetChainIDEndpoint, []byte{}, &chainID, true); err != nil {
+		return "", err
+	}
+	return chainID, nil
+}
+
+// Balance is the balance of the account, in nites.
+func (c *Client) Balance(ctx context.Context, address common.Address) (*big.Int, error) {
+	params, err := encoding.Marshal(struct{ Address string }{address.Hex()})
+	if err != nil {
+		return nil, err
+	}
+	var balance string
+	if err := c.call(ctx, admRpc.BouncerGetBalanceEndpoint, params, &balance, true); err != nil {
+		return nil, err
+	}
+	amount, ok := new(big.Int).SetString(balance, 0)
+	if !ok {
+		return nil, ErrBadBalance
+	}
+	return amount, nil
+}
+
+// Accounts are the accounts created through the bouncer.
+func (c *Client) Accounts(ctx context.Context) ([]common.Address, error) {
+	var hexAddresses []string
+	if err := c.call(ctx, admRpc.BouncerGetAccountsEndpoint, []byte{}, &hexAddresses, true); err != nil {
+		return nil, err
+	}
+	addresses := make([]common.Address, len(hexAddresses))
+	for i, address := range hexAddresses {
+		addresses[i] = common.HexToAddress(address)
+	}
+	return addresses, nil
+}
+
+// CreateAccount creates the account on the bouncer. It's an admin endpoint by default, see WithAdminToken and WithAdminAccount.
+func (c *Client) CreateAccount(ctx context.Context, address common.Address) error {
+	params, err := encoding.Marshal(struct{ Address string }{address.Hex()})
+	if err != nil {
+		return err
+	}
+	if params, err = c.adminParams(admRpc.BouncerCreateAccountEndpoint, params); err != nil {
+		return err
+	}
+	return c.call(ctx, admRpc.BouncerCreateAccountEndpoint, params, nil, false)
+}
+
+// BlockByHash is the header of the block, or nil if the bouncer doesn't know of it.
+func (c *Client) BlockByHash(ctx context.Context, hash common.Hash) (*types.BlockHeader, error) {
+	params, err := encoding.Marshal(struct{ BlockHash common.Hash }{hash})
+	if err != nil {
+		return nil, err
+	}
+	var header *types.BlockHeader
+	if err := c.call(ctx, admRpc.BouncerGetBlockByHashEndpoint, params, &header, true); err != nil {
+		return nil, err
+	}
+	return header, nil
+}
+
+// BlockByNumber is the header of the block, or nil if the bouncer doesn't know of it.
+func (c *Client) BlockByNumber(ctx context.Context, number *big.Int) (*types.BlockHeader, error) {
+	params, err := encoding.Marshal(&struct{ BlockNumber big.Int }{*number})
+	if err != nil {
+		return nil, err
+	}
+	var header *types.BlockHeader
+	if err := c.call(ctx, admRpc.BouncerGetBlockByNumberEndpoint, params, &header, true); err != nil {
+		return nil, err
+	}
+	return header, nil
+}
+
+// SendTransaction hands the transaction to the bouncer, returning its hash once it is on its way to the network.
+// Transactions the bouncer turns away return why, such as txpool.ErrInsufficientFunds.
+func (c *Client) SendTransaction(ctx context.Context, transaction *utils.Transaction) (common.Hash, error) {
+	params, err := encoding.Marshal(transaction)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	var hash common.Hash
+	if err := c.call(ctx, admRpc.BouncerSendTransactionEndpoint, params, &hash, false); err != nil {
+		return common.Hash{}, err
+	}
+	return hash, nil
+}
+
+// TransactionByHash is the transaction and where it was included, or nil if it isn't in a block.
+func (c *Client) TransactionByHash(ctx context.Context, hash common.Hash) (*admRpc.IncludedTransaction, error) {
+	params, err := encoding.Marshal(struct{ Hash common.Hash }{hash})
+	if err != nil {
+		return nil, err
+	}
+	var transaction *admRpc.IncludedTransaction
+	if err := c.call(ctx, admRpc.BouncerGetTransactionByHashEndpoint, params, &transaction, true); err != nil {
+		return nil, err
+	}
+	return transaction, nil
+}
+
+// TransactionReceipt is the outcome of the transaction, or nil if it isn't in a block or the bouncer has no receipt for it.
+func (c *Client) TransactionReceipt(ctx context.Context, hash common.Hash) (*types.Receipt, error) {
+	params, err := encoding.Marshal(struct{ Hash common.Hash }{hash})
+	if err != nil {
+		return nil, err
+	}
+	var receipt *types.Receipt
+	if err := c.call(ctx, admRpc.BouncerGetTransactionReceiptEndpoint, params, &receipt, true); err != nil {
+		return nil, err
+	}
+	return receipt, nil
+}
+
+// SendMessage hands a signed Caesar message to the bouncer to be sent on.
+func (c *Client) SendMessage(ctx context.Context, msg *utils.CaesarMessage) error {
+	params, err := encoding.Marshal(struct {
+		FromPublicKey    string
+		ToPublicKey      string
+		RawMessage       string
+		SignedMessage    string
+		Version          uint8
+		Protocol         uint8
+		InitialTime      int64
+		HasHostingServer bool
+	}{
+		hex.EncodeToString(msg.From.PublicKey),
+		hex.EncodeToString(msg.To.PublicKey),
+		hex.EncodeToString(msg.Message),
+		hex.EncodeToString(msg.Signature),
+		msg.Version,
+		msg.Protocol,
+		msg.InitialTime,
+		msg.HasHostingServer,
+	})
+	if err != nil {
+		return err
+	}
+	return c.call(ctx, admRpc.BouncerNewMessageEndpoint, params, nil, false)
+}
+
+// Messages are the messages between the two public keys, in both directions.
+func (c *Client) Messages(ctx context.Context, fromPublicKey []byte, toPublicKey []byte) ([]Message, error) {
+	params, err := encoding.Marshal(struct {
+		FromPublicKey string
+		ToPublicKey   string
+	}{hex.EncodeToString(fromPublicKey), hex.EncodeToString(toPublicKey)})
+	if err != nil {
+		return nil, err
+	}
+	var messages []Message
+	if err := c.call(ctx, admRpc.BouncerGetMessagesEndpoint, params, &messages, true); err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+// CaesarChunk is the encrypted data of an attachment chunk (or manifest) the bouncer's node holds.
+func (c *Client) CaesarChunk(ctx context.Context, id []byte) ([]byte, error) {
+	params, err := encoding.Marshal(struct{ ID string }{hex.EncodeToString(id)})
+	if err != nil {
+		return nil, err
+	}
+	var data string
+	if err := c.call(ctx, admRpc.BouncerGetCaesarChunkEndpoint, params, &data, true); err != nil {
+		return nil, err
+	}
+	return hex.DecodeString(data)
+}
+
+// Subscribe proves the account is ours, and returns the ID of a subscription to the messages sent to or from it.
+func (c *Client) Subscribe(ctx context.Context, account *accounts.Account) (string, error) {
+	var challenge string
+	if err := c.call(ctx, admRpc.BouncerGetSubscriptionChallengeEndpoint, []byte{}, &challenge, true); err != nil {
+		return "", err
+	}
+	signature, err := account.Sign(admRpc.SubscriptionChallengePayload(common.FromHex(challenge)))
+	if err != nil {
+		return "", err
+	}
+	params, err := encoding.Marshal(struct {
+		PublicKey string
+		Challenge string
+		Signature string
+	}{hex.EncodeToString(account.PublicKey), challenge, hex.EncodeToString(signature)})
+	if err != nil {
+		return "", err
+	}
+	var id string
+	if err := c.call(ctx, admRpc.BouncerSubscribeMessagesEndpoint, params, &id, false); err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// Poll returns the messages that arrived for the subscription since the last poll, waiting up to timeout for one if
+// there are none. The bouncer caps how long it waits.
+func (c *Client) Poll(ctx context.Context, subscriptionID string, timeout time.Duration) ([]Message, error) {
+	params, err := encoding.Marshal(struct {
+		SubscriptionID string
+		TimeoutMillis  int64
+	}{subscriptionID, timeout.Milliseconds()})
+	if err != nil {
+		return nil, err
+	}
+	var messages []Message
+	if err := c.call(ctx, admRpc.BouncerPollMessagesEndpoint, params, &messages, false); err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+// Unsubscribe drops the subscription.
+func (c *Client) Unsubscribe(ctx context.Context, subscriptionID string) error {
+	params, err := encoding.Marshal(struct{ SubscriptionID string }{subscriptionID})
+	if err != nil {
+		return err
+	}
+	return c.call(ctx, admRpc.BouncerUnsubscribeMessagesEndpoint, params, nil, false)
+}
@@ -0,0 +1,217 @@
+package bouncerclient
+
+import (
+	"context"
+	"encoding/hex"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/adamnite/go-adamnite/blockchain"
+	"github.com/adamnite/go-adamnite/common"
+	"github.com/adamnite/go-adamnite/core/types"
+	"github.com/adamnite/go-adamnite/databaseDeprecated/rawdb"
+	"github.com/adamnite/go-adamnite/databaseDeprecated/statedb"
+	"github.com/adamnite/go-adamnite/dpos"
+	"github.com/adamnite/go-adamnite/params"
+	admRpc "github.com/adamnite/go-adamnite/rpc"
+	"github.com/adamnite/go-adamnite/utils"
+	"github.com/adamnite/go-adamnite/utils/accounts"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestMock(t *testing.T) (*MockServer, *Client) {
+	mock, err := NewMockServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err := Dial(context.Background(), mock.Addr(), WithRetries(3, 10*time.Millisecond), WithAdminToken("token"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		client.Close()
+		mock.Close()
+	})
+	return mock, client
+}
+
+func TestClientChainAndAccounts(t *testing.T) {
+	mock, client := newTestMock(t)
+	ctx := context.Background()
+
+	chainID, err := client.ChainID(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, "0.1.2", chainID)
+
+	mock.SetBalance(common.Address{1}, big.NewInt(1234))
+	balance, err := client.Balance(ctx, common.Address{1})
+	assert.NoError(t, err)
+	assert.Equal(t, big.NewInt(1234), balance)
+
+	mock.AdminToken = "token"
+	assert.NoError(t, client.CreateAccount(ctx, common.Address{2}))
+	assert.ErrorIs(t, client.CreateAccount(ctx, common.Address{2}), admRpc.ErrPreExistingAccount)
+	mock.AdminToken = "another token"
+	assert.ErrorIs(t, client.CreateAccount(ctx, common.Address{3}), admRpc.ErrUnauthorized)
+	addresses, err := client.Accounts(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []common.Address{{2}}, addresses)
+
+	header := &types.BlockHeader{Number: big.NewInt(7), ParentHash: common.Hash{1}}
+	mock.AddBlock(header)
+	byHash, err := client.BlockByHash(ctx, header.Hash())
+	if assert.NoError(t, err) && assert.NotNil(t, byHash) {
+		assert.Equal(t, header.Hash(), byHash.Hash())
+	}
+	byNumber, err := client.BlockByNumber(ctx, big.NewInt(7))
+	if assert.NoError(t, err) && assert.NotNil(t, byNumber) {
+		assert.Equal(t, header.Hash(), byNumber.Hash())
+	}
+	unknown, err := client.BlockByNumber(ctx, big.NewInt(8))
+	assert.NoError(t, err)
+	assert.Nil(t, unknown)
+
+	included := &admRpc.IncludedTransaction{Hash: common.Hash{5}, Amount: big.NewInt(3), BlockHash: header.Hash(), BlockNumber: 7}
+	mock.AddIncludedTransaction(included, &types.Receipt{Status: types.ReceiptStatusSuccessful, TxHash: common.Hash{5}})
+	found, err := client.TransactionByHash(ctx, common.Hash{5})
+	if assert.NoError(t, err) && assert.NotNil(t, found) {
+		assert.Equal(t, header.Hash(), found.BlockHash)
+		assert.Equal(t, big.NewInt(3), found.Amount)
+	}
+	receipt, err := client.TransactionReceipt(ctx, common.Hash{5})
+	if assert.NoError(t, err) && assert.NotNil(t, receipt) {
+		assert.Equal(t, types.ReceiptStatusSuccessful, receipt.Status)
+	}
+	receipt, err = client.TransactionReceipt(ctx, common.Hash{6})
+	assert.NoError(t, err)
+	assert.Nil(t, receipt)
+
+	transaction := &utils.Transaction{From: common.Address{1}, To: common.Address{2}, Amount: big.NewInt(10)}
+	hash, err := client.SendTransaction(ctx, transaction)
+	assert.NoError(t, err)
+	assert.Equal(t, transaction.Hash(), hash)
+	if sent := mock.Transactions(); assert.Len(t, sent, 1) {
+		assert.Equal(t, transaction.Hash(), sent[0].Hash())
+	}
+}
+
+func TestClientMessages(t *testing.T) {
+	mock, client := newTestMock(t)
+	ctx := context.Background()
+
+	sender, _ := accounts.GenerateAccount()
+	recipient, _ := accounts.GenerateAccount()
+	msg, err := utils.NewCaesarMessage(*recipient, *sender, "hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NoError(t, client.SendMessage(ctx, msg))
+	messages, err := client.Messages(ctx, recipient.PublicKey, sender.PublicKey)
+	if assert.NoError(t, err) && assert.Len(t, messages, 1) {
+		assert.Equal(t, hex.EncodeToString(msg.Hash()), messages[0].Hash, "the message was changed on its way")
+	}
+
+	mock.SetChunk([]byte{1, 2}, []byte("chunk"))
+	chunk, err := client.CaesarChunk(ctx, []byte{1, 2})
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("chunk"), chunk)
+	_, err = client.CaesarChunk(ctx, []byte{3})
+	assert.ErrorIs(t, err, admRpc.ErrUnknownChunk)
+
+	id, err := client.Subscribe(ctx, recipient)
+	if !assert.NoError(t, err) {
+		return
+	}
+	mock.PushMessage(Message{Content: "00"})
+	polled, err := client.Poll(ctx, id, time.Second)
+	assert.NoError(t, err)
+	assert.Equal(t, []Message{{Content: "00"}}, polled)
+	assert.NoError(t, client.Unsubscribe(ctx, id))
+	assert.ErrorIs(t, client.Unsubscribe(ctx, id), admRpc.ErrUnknownSubscription)
+	assert.Equal(t, 0, mock.Subscriptions())
+}
+
+func TestClientReconnects(t *testing.T) {
+	mock, client := newTestMock(t)
+	ctx := context.Background()
+
+	mock.DropConnections()
+	chainID, err := client.ChainID(ctx)
+	assert.NoError(t, err, "the client did not reconnect")
+	assert.Equal(t, "0.1.2", chainID)
+
+	//the connection is known to be gone before sending, so it's safe to send again
+	mock.DropConnections()
+	time.Sleep(50 * time.Millisecond)
+	_, err = client.SendTransaction(ctx, &utils.Transaction{Amount: big.NewInt(1)})
+	assert.NoError(t, err)
+	assert.Len(t, mock.Transactions(), 1)
+
+	client.Close()
+	_, err = client.ChainID(ctx)
+	assert.ErrorIs(t, err, ErrClientClosed)
+}
+
+func TestClientContext(t *testing.T) {
+	mock, client := newTestMock(t)
+	mock.SetDelay(time.Second)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := client.ChainID(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), 500*time.Millisecond, "the call outlived its context")
+}
+
+// the client talks to a real bouncer the same as it does to the mock
+func TestClientBouncer(t *testing.T) {
+	db := rawdb.NewMemoryDB()
+	state, _ := statedb.New(common.Hash{}, statedb.NewDatabase(db))
+	state.AddBalance(common.Address{1}, big.NewInt(99))
+	chain, err := blockchain.NewBlockchain(db, params.TestnetChainConfig, dpos.New(params.TestnetChainConfig, db))
+	if err != nil {
+		t.Fatal(err)
+	}
+	bouncer, err := admRpc.NewBouncerServer(state, chain, admRpc.ListenConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bouncer.Close()
+	client, err := Dial(context.Background(), bouncer.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	ctx := context.Background()
+
+	balance, err := client.Balance(ctx, common.Address{1})
+	assert.NoError(t, err)
+	assert.Equal(t, big.NewInt(99), balance)
+
+	block := types.NewBlockWithHeader(&types.BlockHeader{Number: big.NewInt(50)})
+	if err := chain.WriteBlock(block); err != nil {
+		t.Fatal(err)
+	}
+	header, err := client.BlockByNumber(ctx, big.NewInt(50))
+	if assert.NoError(t, err) && assert.NotNil(t, header) {
+		assert.Equal(t, block.Hash(), header.Hash())
+	}
+	header, err = client.BlockByHash(ctx, common.Hash{9})
+	assert.NoError(t, err)
+	assert.Nil(t, header)
+
+	transaction, err := client.TransactionByHash(ctx, common.Hash{9})
+	assert.NoError(t, err)
+	assert.Nil(t, transaction)
+	receipt, err := client.TransactionReceipt(ctx, common.Hash{9})
+	assert.NoError(t, err)
+	assert.Nil(t, receipt)
+
+	_, err = client.SendTransaction(ctx, &utils.Transaction{From: common.Address{1}, Amount: big.NewInt(1), ChainID: params.TestnetChainConfig.ChainID})
+	assert.ErrorIs(t, err, admRpc.ErrBadSignature)
+
+	//CreateAccount is admin only by default
+	assert.ErrorIs(t, client.CreateAccount(ctx, common.Address{2}), admRpc.ErrUnauthorized)
+}
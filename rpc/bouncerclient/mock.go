@@ -0,0 +1,480 @@
+package bouncerclient
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"net"
+	"net/rpc"
+	"sync"
+	"time"
+
+	"github.com/adamnite/go-adamnite/common"
+	"github.com/adamnite/go-adamnite/core/types"
+	admRpc "github.com/adamnite/go-adamnite/rpc"
+	"github.com/adamnite/go-adamnite/utils"
+	"github.com/adamnite/go-adamnite/utils/accounts"
+
+	encoding "github.com/vmihailenco/msgpack/v5"
+)
+
+// MockServer answers the bouncer endpoints from data set on it, for testing code that uses the client without a
+// chain or node behind it. It keeps what it's sent, so tests can check on it.
+type MockServer struct {
+	ChainID    string
+	AdminToken string // if set, CreateAccount needs it
+
+	listener net.Listener
+	conns    map[net.Conn]struct{}
+
+	lock          sync.Mutex
+	balances      map[common.Address]*big.Int
+	accounts      []string
+	blocks        map[common.Hash]*types.BlockHeader
+	transactions  []*utils.Transaction
+	included      map[common.Hash]*admRpc.IncludedTransaction
+	receipts      map[common.Hash]*types.Receipt
+	messages      []*utils.CaesarMessage
+	chunks        map[string][]byte
+	subscriptions map[string][]Message
+	failures      map[string]error
+	delay         time.Duration
+}
+
+// mockService is what's registered, so only the endpoints are exported to net/rpc.
+type mockService struct {
+	m *MockServer
+}
+
+// NewMockServer starts a mock bouncer on a free port of this machine.
+func NewMockServer() (*MockServer, error) {
+	m := &MockServer{
+		ChainID:       "0.1.2",
+		conns:         make(map[net.Conn]struct{}),
+		balances:      make(map[common.Address]*big.Int),
+		blocks:        make(map[common.Hash]*types.BlockHeader),
+		chunks:        make(map[string][]byte),
+		included:      make(map[common.Hash]*admRpc.IncludedTransaction),
+		receipts:      make(map[common.Hash]*types.Receipt),
+		subscriptions: make(map[string][]Message),
+		failures:      make(map[string]error),
+	}
+	server := rpc.NewServer()
+	if err := server.RegisterName("BouncerServer", &mockService{m}); err != nil {
+		return nil, err
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	m.listener = listener
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			m.lock.Lock()
+			m.conns[conn] = struct{}{}
+			m.lock.Unlock()
+			go func() {
+				server.ServeConn(conn)
+				m.lock.Lock()
+				delete(m.conns, conn)
+				m.lock.Unlock()
+			}()
+		}
+	}()
+	return m, nil
+}
+
+func (m *MockServer) Addr() string {
+	return m.listener.Addr().String()
+}
+
+// DropConnections cuts every client off, as if the network went down for a moment.
+func (m *MockServer) DropConnections() {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	for conn := range m.conns {
+		_ = conn.Close()
+	}
+}
+
+func (m *MockServer) Close() {
+	_ = m.listener.Close()
+	m.DropConnections()
+}
+
+func (m *MockServer) SetBalance(address common.Address, balance *big.Int) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.balances[address] = balance
+}
+
+func (m *MockServer) AddBlock(header *types.BlockHeader) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.blocks[header.Hash()] = header
+}
+
+// AddIncludedTransaction makes the transaction, and its receipt if not nil, look included in a block.
+func (m *MockServer) AddIncludedTransaction(transaction *admRpc.IncludedTransaction, receipt *types.Receipt) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.included[transaction.Hash] = transaction
+	if receipt != nil {
+		m.receipts[transaction.Hash] = receipt
+	}
+}
+
+func (m *MockServer) SetChunk(id []byte, data []byte) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.chunks[hex.EncodeToString(id)] = data
+}
+
+// PushMessage queues the message for every subscription.
+func (m *MockServer) PushMessage(msg Message) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	for id := range m.subscriptions {
+		m.subscriptions[id] = append(m.subscriptions[id], msg)
+	}
+}
+
+// Fail makes the endpoint (eg "BouncerServer.GetBalance") return the error, until it's set back to nil.
+func (m *MockServer) Fail(endpoint string, err error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if err == nil {
+		delete(m.failures, endpoint)
+		return
+	}
+	m.failures[endpoint] = err
+}
+
+// SetDelay makes every call take at least this long, like a slow network would.
+func (m *MockServer) SetDelay(delay time.Duration) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.delay = delay
+}
+
+func (m *MockServer) Transactions() []*utils.Transaction {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return append([]*utils.Transaction{}, m.transactions...)
+}
+
+func (m *MockServer) Messages() []*utils.CaesarMessage {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return append([]*utils.CaesarMessage{}, m.messages...)
+}
+
+func (m *MockServer) Subscriptions() int {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return len(m.subscriptions)
+}
+
+// answer waits out the delay and checks for an injected failure, then marshals what handle returns as the reply.
+func (s *mockService) answer(endpoint string, reply *[]byte, handle func() (interface{}, error)) error {
+	s.m.lock.Lock()
+	err := s.m.failures[endpoint]
+	delay := s.m.delay
+	s.m.lock.Unlock()
+	time.Sleep(delay)
+	if err != nil {
+		return err
+	}
+	answer, err := handle()
+	if err != nil {
+		return err
+	}
+	data, err := encoding.Marshal(answer)
+	if err != nil {
+		return err
+	}
+	*reply = data
+	return nil
+}
+
+func (s *mockService) GetChainID(params *[]byte, reply *[]byte) error {
+	return s.answer(admRpc.BouncerGetChainIDEndpoint, reply, func() (interface{}, error) {
+		return s.m.ChainID, nil
+	})
+}
+
+func (s *mockService) GetBalance(params *[]byte, reply *[]byte) error {
+	return s.answer(admRpc.BouncerGetBalanceEndpoint, reply, func() (interface{}, error) {
+		input := struct{ Address string }{}
+		if err := encoding.Unmarshal(*params, &input); err != nil {
+			return nil, err
+		}
+		s.m.lock.Lock()
+		defer s.m.lock.Unlock()
+		if balance, exists := s.m.balances[common.HexToAddress(input.Address)]; exists {
+			return balance.String(), nil
+		}
+		return "0", nil
+	})
+}
+
+func (s *mockService) GetAccounts(params *[]byte, reply *[]byte) error {
+	return s.answer(admRpc.BouncerGetAccountsEndpoint, reply, func() (interface{}, error) {
+		s.m.lock.Lock()
+		defer s.m.lock.Unlock()
+		return s.m.accounts, nil
+	})
+}
+
+func (s *mockService) CreateAccount(params *[]byte, reply *[]byte) error {
+	return s.answer(admRpc.BouncerCreateAccountEndpoint, reply, func() (interface{}, error) {
+		var auth admRpc.AuthenticatedParams
+		_ = encoding.Unmarshal(*params, &auth)
+		if s.m.AdminToken != "" && auth.Token != s.m.AdminToken {
+			return nil, admRpc.ErrUnauthorized
+		}
+		if auth.Params == nil {
+			auth.Params = *params //sent without authentication
+		}
+		input := struct{ Address string }{}
+		if err := encoding.Unmarshal(auth.Params, &input); err != nil {
+			return nil, err
+		}
+		s.m.lock.Lock()
+		defer s.m.lock.Unlock()
+		for _, address := range s.m.accounts {
+			if address == input.Address {
+				return nil, admRpc.ErrPreExistingAccount
+			}
+		}
+		s.m.accounts = append(s.m.accounts, input.Address)
+		return true, nil
+	})
+}
+
+func (s *mockService) GetBlockByHash(params *[]byte, reply *[]byte) error {
+	return s.answer(admRpc.BouncerGetBlockByHashEndpoint, reply, func() (interface{}, error) {
+		input := struct{ BlockHash common.Hash }{}
+		if err := encoding.Unmarshal(*params, &input); err != nil {
+			return nil, err
+		}
+		s.m.lock.Lock()
+		defer s.m.lock.Unlock()
+		return s.m.blocks[input.BlockHash], nil
+	})
+}
+
+func (s *mockService) GetBlockByNumber(params *[]byte, reply *[]byte) error {
+	return s.answer(admRpc.BouncerGetBlockByNumberEndpoint, reply, func() (interface{}, error) {
+		input := struct{ BlockNumber big.Int }{}
+		if err := encoding.Unmarshal(*params, &input); err != nil {
+			return nil, err
+		}
+		s.m.lock.Lock()
+		defer s.m.lock.Unlock()
+		for _, header := range s.m.blocks {
+			if header.Number != nil && header.Number.Cmp(&input.BlockNumber) == 0 {
+				return header, nil
+			}
+		}
+		return nil, nil
+	})
+}
+
+func (s *mockService) SendTransaction(params *[]byte, reply *[]byte) error {
+	return s.answer(admRpc.BouncerSendTransactionEndpoint, reply, func() (interface{}, error) {
+		var transaction *utils.Transaction
+		if err := encoding.Unmarshal(*params, &transaction); err != nil {
+			return nil, err
+		}
+		s.m.lock.Lock()
+		defer s.m.lock.Unlock()
+		s.m.transactions = append(s.m.transactions, transaction)
+		return transaction.Hash(), nil
+	})
+}
+
+func (s *mockService) GetTransactionByHash(params *[]byte, reply *[]byte) error {
+	return s.answer(admRpc.BouncerGetTransactionByHashEndpoint, reply, func() (interface{}, error) {
+		input := struct{ Hash common.Hash }{}
+		if err := encoding.Unmarshal(*params, &input); err != nil {
+			return nil, err
+		}
+		s.m.lock.Lock()
+		defer s.m.lock.Unlock()
+		return s.m.included[input.Hash], nil
+	})
+}
+
+func (s *mockService) GetTransactionReceipt(params *[]byte, reply *[]byte) error {
+	return s.answer(admRpc.BouncerGetTransactionReceiptEndpoint, reply, func() (interface{}, error) {
+		input := struct{ Hash common.Hash }{}
+		if err := encoding.Unmarshal(*params, &input); err != nil {
+			return nil, err
+		}
+		s.m.lock.Lock()
+		defer s.m.lock.Unlock()
+		return s.m.receipts[input.Hash], nil
+	})
+}
+
+func (s *mockService) NewMessage(params *[]byte, reply *[]byte) error {
+	return s.answer(admRpc.BouncerNewMessageEndpoint, reply, func() (interface{}, error) {
+		input := struct {
+			FromPublicKey    string
+			ToPublicKey      string
+			RawMessage       string
+			SignedMessage    string
+			Version          uint8
+			Protocol         uint8
+			InitialTime      int64
+			HasHostingServer bool
+		}{}
+		if err := encoding.Unmarshal(*params, &input); err != nil {
+			return nil, err
+		}
+		m := utils.NewSignedCaesarMessage(
+			accounts.AccountFromPubBytes(common.FromHex(input.ToPublicKey)),
+			accounts.AccountFromPubBytes(common.FromHex(input.FromPublicKey)),
+			common.FromHex(input.RawMessage),
+			common.FromHex(input.SignedMessage))
+		m.Version = input.Version
+		m.Protocol = input.Protocol
+		m.InitialTime = input.InitialTime
+		m.HasHostingServer = input.HasHostingServer
+		if m.Protocol == utils.CaesarProtocolLegacy {
+			return nil, utils.ErrLegacyProtocol
+		}
+		if !m.Verify() {
+			return nil, admRpc.ErrBadSignature
+		}
+		s.m.lock.Lock()
+		defer s.m.lock.Unlock()
+		s.m.messages = append(s.m.messages, m)
+		return true, nil
+	})
+}
+
+func (s *mockService) GetMessages(params *[]byte, reply *[]byte) error {
+	return s.answer(admRpc.BouncerGetMessagesEndpoint, reply, func() (interface{}, error) {
+		input := struct {
+			FromPublicKey string
+			ToPublicKey   string
+		}{}
+		if err := encoding.Unmarshal(*params, &input); err != nil {
+			return nil, err
+		}
+		from := hex.EncodeToString(common.FromHex(input.FromPublicKey))
+		to := hex.EncodeToString(common.FromHex(input.ToPublicKey))
+		s.m.lock.Lock()
+		defer s.m.lock.Unlock()
+		messages := []Message{}
+		for _, m := range s.m.messages {
+			sender := hex.EncodeToString(m.From.PublicKey)
+			recipient := hex.EncodeToString(m.To.PublicKey)
+			if (sender == from && recipient == to) || (sender == to && recipient == from) {
+				messages = append(messages, Message{
+					FromPublicKey: sender,
+					ToPublicKey:   recipient,
+					Timestamp:     m.InitialTime,
+					Content:       hex.EncodeToString(m.Message),
+					Hash:          hex.EncodeToString(m.Hash()),
+				})
+			}
+		}
+		return messages, nil
+	})
+}
+
+func (s *mockService) GetCaesarChunk(params *[]byte, reply *[]byte) error {
+	return s.answer(admRpc.BouncerGetCaesarChunkEndpoint, reply, func() (interface{}, error) {
+		input := struct{ ID string }{}
+		if err := encoding.Unmarshal(*params, &input); err != nil {
+			return nil, err
+		}
+		s.m.lock.Lock()
+		defer s.m.lock.Unlock()
+		data, exists := s.m.chunks[hex.EncodeToString(common.FromHex(input.ID))]
+		if !exists {
+			return nil, admRpc.ErrUnknownChunk
+		}
+		return hex.EncodeToString(data), nil
+	})
+}
+
+// the mock hands out one challenge, and accepts it as often as it's signed.
+var mockChallenge = hex.EncodeToString([]byte("adamnite mock bouncer challenge"))
+
+func (s *mockService) GetSubscriptionChallenge(params *[]byte, reply *[]byte) error {
+	return s.answer(admRpc.BouncerGetSubscriptionChallengeEndpoint, reply, func() (interface{}, error) {
+		return mockChallenge, nil
+	})
+}
+
+func (s *mockService) SubscribeMessages(params *[]byte, reply *[]byte) error {
+	return s.answer(admRpc.BouncerSubscribeMessagesEndpoint, reply, func() (interface{}, error) {
+		input := struct {
+			PublicKey string
+			Challenge string
+			Signature string
+		}{}
+		if err := encoding.Unmarshal(*params, &input); err != nil {
+			return nil, err
+		}
+		if input.Challenge != mockChallenge {
+			return nil, admRpc.ErrInvalidChallenge
+		}
+		account := accounts.AccountFromPubBytes(common.FromHex(input.PublicKey))
+		signature := common.FromHex(input.Signature)
+		if len(account.PublicKey) == 0 || len(signature) < 64 ||
+			!account.Verify(admRpc.SubscriptionChallengePayload(common.FromHex(input.Challenge)), signature) {
+			return nil, admRpc.ErrBadSignature
+		}
+		s.m.lock.Lock()
+		defer s.m.lock.Unlock()
+		id := fmt.Sprintf("mock-%d", len(s.m.subscriptions))
+		s.m.subscriptions[id] = []Message{}
+		return id, nil
+	})
+}
+
+// PollMessages never waits, it returns whatever was pushed since the last poll.
+func (s *mockService) PollMessages(params *[]byte, reply *[]byte) error {
+	return s.answer(admRpc.BouncerPollMessagesEndpoint, reply, func() (interface{}, error) {
+		input := struct {
+			SubscriptionID string
+			TimeoutMillis  int64
+		}{}
+		if err := encoding.Unmarshal(*params, &input); err != nil {
+			return nil, err
+		}
+		s.m.lock.Lock()
+		defer s.m.lock.Unlock()
+		queued, exists := s.m.subscriptions[input.SubscriptionID]
+		if !exists {
+			return nil, admRpc.ErrUnknownSubscription
+		}
+		s.m.subscriptions[input.SubscriptionID] = []Message{}
+		return queued, nil
+	})
+}
+
+func (s *mockService) UnsubscribeMessages(params *[]byte, reply *[]byte) error {
+	return s.answer(admRpc.BouncerUnsubscribeMessagesEndpoint, reply, func() (interface{}, error) {
+		input := struct{ SubscriptionID string }{}
+		if err := encoding.Unmarshal(*params, &input); err != nil {
+			return nil, err
+		}
+		s.m.lock.Lock()
+		defer s.m.lock.Unlock()
+		if _, exists := s.m.subscriptions[input.SubscriptionID]; !exists {
+			return nil, admRpc.ErrUnknownSubscription
+		}
+		delete(s.m.subscriptions, input.SubscriptionID)
+		return true, nil
+	})
+}
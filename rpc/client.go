@@ -1,6 +1,7 @@
 package rpc
 
 import (
+	"crypto/tls"
 	"fmt"
 	"net/rpc"
 
@@ -21,7 +22,7 @@ func (a *AdamniteClient) printError(methodName string, err error) {
 
 type AdamniteClient struct {
 	endpoint          string
-	client            rpc.Client
+	client            *rpc.Client
 	callerAddress     *common.Address
 	hostingServerPort string //the string version of the port that our Server is running on.
 }
@@ -112,7 +113,7 @@ func (a *AdamniteClient) GetBalance(address common.Address) (*string, error) {
 	}
 
 	var reply []byte
-	if err := a.client.Call(getBalanceEndpoint, data, &reply); err != nil {
+	if err := a.client.Call(BouncerGetBalanceEndpoint, data, &reply); err != nil {
 		a.printError("Get balance", err)
 		return nil, err
 	}
@@ -135,6 +136,66 @@ func NewAdamniteClient(endpoint string) (AdamniteClient, error) {
 	}
 	return AdamniteClient{
 		endpoint: endpoint,
-		client:   *client,
+		client:   client,
 	}, nil
 }
+
+// NewAdamniteClientTLS connects to a server listening with TLS. A nil config trusts the system's certificate authorities.
+func NewAdamniteClientTLS(endpoint string, config *tls.Config) (AdamniteClient, error) {
+	conn, err := tls.Dial("tcp", endpoint, config)
+	if err != nil {
+		return AdamniteClient{}, err
+	}
+	return AdamniteClient{
+		endpoint: endpoint,
+		client:   rpc.NewClient(conn),
+	}, nil
+}
+
+// fetch the messages a mailbox node holds for the owner of the request
+func (a *AdamniteClient) FetchCaesarMailbox(request utils.CaesarMailboxRequest) ([]*utils.CaesarMessage, error) {
+	a.print("Fetch Caesar Mailbox")
+	data, err := encoding.Marshal(&request)
+	if err != nil {
+		return nil, err
+	}
+	var reply []byte
+	if err := a.client.Call(fetchCaesarMailboxEndpoint, data, &reply); err != nil {
+		a.printError("Fetch Caesar Mailbox", err)
+		return nil, err
+	}
+	messages := []*utils.CaesarMessage{}
+	if err := encoding.Unmarshal(reply, &messages); err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+// let a mailbox node drop the messages the owner of the request received
+func (a *AdamniteClient) AckCaesarMailbox(request utils.CaesarMailboxRequest) error {
+	a.print("Ack Caesar Mailbox")
+	data, err := encoding.Marshal(&request)
+	if err != nil {
+		return err
+	}
+	return a.client.Call(ackCaesarMailboxEndpoint, data, &[]byte{})
+}
+
+// fetch the chunk with that ID from the node, checking it is the chunk asked for
+func (a *AdamniteClient) GetCaesarChunk(id []byte) (*utils.CaesarChunk, error) {
+	a.print("Get Caesar Chunk")
+	data, err := encoding.Marshal(id)
+	if err != nil {
+		return nil, err
+	}
+	var reply []byte
+	if err := a.client.Call(getCaesarChunkEndpoint, data, &reply); err != nil {
+		a.printError("Get Caesar Chunk", err)
+		return nil, err
+	}
+	var chunk utils.CaesarChunk
+	if err := encoding.Unmarshal(reply, &chunk); err != nil {
+		return nil, err
+	}
+	return &chunk, chunk.Check(id)
+}
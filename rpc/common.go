@@ -41,4 +41,7 @@ var (
 	ErrNotSetupToHandleForwarding = errors.New("this RPC host is not setup to handle message forwarding")
 	ErrAlreadyForwarded           = errors.New("message has already been forwarded")
 	ErrBadForward                 = errors.New("this message has been deemed unfit to be shared further")
+	ErrNotAMailbox                = errors.New("this node does not hold Caesar messages for others")
+	ErrUnknownChunk               = errors.New("this node does not hold the Caesar chunk requested")
+	ErrBadSignature               = errors.New("the signature does not match the message and its sender")
 )
@@ -30,12 +30,14 @@ var (
 		big.NewInt(1),
 		big.NewInt(2),
 	}
-	testDB        = rawdb.NewMemoryDB()
-	stateDB, _    = statedb.New(common.Hash{}, statedb.NewDatabase(testDB))
-	chainConfig   = params.TestnetChainConfig
-	client        AdamniteClient
-	bouncerServer *BouncerServer
-	bouncerClient *rpc.Client
+	testDB         = rawdb.NewMemoryDB()
+	stateDB, _     = statedb.New(common.Hash{}, statedb.NewDatabase(testDB))
+	chainConfig    = params.TestnetChainConfig
+	client         AdamniteClient
+	adamniteServer *AdamniteServer
+	bouncerServer  *BouncerServer
+	bouncerClient  *rpc.Client
+	testAdminToken = "test admin token"
 )
 
 func setup() {
@@ -62,15 +64,21 @@ func setup() {
 	var port uint32 = 12345
 	var bouncerPort uint32 = 12346
 
-	bouncerServer = NewBouncerServer(stateDB, blockchain, bouncerPort)
-	adamniteServer := NewAdamniteServer(port)
+	bouncerServer, err = NewBouncerServer(stateDB, blockchain, LocalListenConfig(bouncerPort))
+	if err != nil {
+		log.Printf("[Adamnite E2E test] Bouncer Error: %s", err)
+		return
+	}
+	policy := DefaultBouncerPolicy()
+	policy.AdminTokens = []string{testAdminToken}
+	bouncerServer.SetPolicy(policy)
+	adamniteServer, err = NewAdamniteServer(LocalListenConfig(port))
+	if err != nil {
+		log.Printf("[Adamnite E2E test] Error: %s", err)
+		return
+	}
 	go adamniteServer.Run()
 
-	defer func() {
-		adamniteServer.Close()
-		bouncerServer.Close()
-	}()
-
 	// setup Adamnite client
 	client, err = NewAdamniteClient(fmt.Sprintf("127.0.0.1:%d", port))
 	if err != nil {
@@ -87,6 +95,8 @@ func setup() {
 func shutdown() {
 	client.Close()
 	bouncerClient.Close()
+	adamniteServer.Close()
+	bouncerServer.Close()
 }
 
 func TestGetVersion(t *testing.T) {
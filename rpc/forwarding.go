@@ -17,8 +17,13 @@ type ForwardingContent struct {
 	FinalParams     []byte          //the params to be passed at the end
 	FinalReply      []byte          //ignored if DestinationNode is nill, otherwise will attempt to link back
 	InitialSender   common.Address  //who started this
+	Hops            uint8           //how many nodes relayed it looking for DestinationNode. Not hashed, it changes on the way
 }
 
+// MaxForwardHops is how many times a message for one node is relayed looking for them, before it is dropped rather
+// than flooding the network.
+const MaxForwardHops = 4
+
 func (fc ForwardingContent) Hash() common.Hash {
 	byteForm := []byte(fc.FinalEndpoint)
 	if fc.DestinationNode != nil {
@@ -44,10 +49,26 @@ func CreateForwardToAll(finalMessage interface{}) (ForwardingContent, error) {
 	switch finalMessage.(type) {
 	case utils.CaesarMessage, *utils.CaesarMessage:
 		forwardAns.FinalEndpoint = newMessageEndpoint
+	case utils.CaesarMailboxRecord, *utils.CaesarMailboxRecord:
+		forwardAns.FinalEndpoint = newMailboxRecordEndpoint
+	case utils.CaesarChunkShare, *utils.CaesarChunkShare:
+		forwardAns.FinalEndpoint = newChunkEndpoint
 	case utils.Candidate, *utils.Candidate:
 		forwardAns.FinalEndpoint = NewCandidateEndpoint
 	case utils.Voter, *utils.Voter:
 		forwardAns.FinalEndpoint = NewVoteEndpoint
+	case utils.Transaction, *utils.Transaction:
+		forwardAns.FinalEndpoint = SendTransactionEndpoint
+	}
+	return forwardAns, nil
+}
+
+// create a forwarding message that is only handled by the destination node
+func CreateForwardTo(finalMessage interface{}, destination common.Address) (ForwardingContent, error) {
+	forwardAns, err := CreateForwardToAll(finalMessage)
+	if err != nil {
+		return ForwardingContent{}, err
 	}
+	forwardAns.DestinationNode = &destination
 	return forwardAns, nil
 }
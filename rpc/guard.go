@@ -0,0 +1,421 @@
+package rpc
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/binary"
+	"encoding/gob"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net"
+	"net/rpc"
+	"sync"
+	"time"
+
+	"github.com/adamnite/go-adamnite/utils/accounts"
+	log "github.com/sirupsen/logrus"
+	encoding "github.com/vmihailenco/msgpack/v5"
+)
+
+// every call to the RPC servers passes through a guard first. The guard limits how often each IP can call, how large
+// requests can be, and only lets admins call the endpoints their Policy marks as such.
+//
+// Over net/rpc, admins wrap the params of those calls with NewTokenParams or NewSignedParams. Over JSON-RPC, they send
+// their token as an "Authorization: Bearer <token>" header.
+
+type AccessLevel uint8
+
+const (
+	AccessPublic   AccessLevel = iota // anyone can call
+	AccessAdmin                       // only with an admin token, or a request signed by an admin key
+	AccessDisabled                    // nobody can call
+)
+
+const (
+	guardServiceName    = "RPCGuard"
+	denyEndpoint        = guardServiceName + ".Deny"
+	signedRequestWindow = time.Minute      // how far a signed request's time can be from ours
+	idleLimiterTimeout  = 10 * time.Minute // rate limits of IPs that haven't called in this long are forgotten
+	requestReadSlack    = 64 << 10         // reads are buffered, so a connection can read a little past a request
+)
+
+var (
+	ErrUnauthorized     = errors.New("this endpoint can only be called by an admin")
+	ErrMethodDisabled   = errors.New("this endpoint has been disabled")
+	ErrRateLimited      = errors.New("too many requests, slow down")
+	ErrRequestTooLarge  = errors.New("the request is larger than this server accepts")
+	ErrSignatureExpired = errors.New("the signed request is too old, or from the future")
+	ErrSignatureReused  = errors.New("the signed request has already been used")
+	authenticationLabel = []byte("adamnite-rpc-admin")
+)
+
+// Policy is who can call which endpoints on a server, and how much.
+type Policy struct {
+	Access         map[string]AccessLevel // by endpoint, eg "BouncerServer.CreateAccount". Endpoints left out are public
+	AdminTokens    []string
+	AdminKeys      [][]byte // public keys, whose signed requests are accepted as admin
+	RateLimit      float64  // requests a second each IP can make, unlimited if 0
+	RateBurst      int      // requests an IP can make at once, before the rate limit applies
+	MaxRequestSize int      // in bytes, unlimited if 0
+}
+
+// DefaultBouncerPolicy is used by bouncers until they're given another. Only admins can create accounts.
+func DefaultBouncerPolicy() *Policy {
+	return &Policy{
+		Access: map[string]AccessLevel{
+			BouncerCreateAccountEndpoint: AccessAdmin,
+		},
+		RateLimit:      100,
+		RateBurst:      200,
+		MaxRequestSize: maxJSONRPCRequestSize,
+	}
+}
+
+// DefaultServerPolicy is used by the servers nodes talk to each other through. Nodes share a lot between them, so they
+// aren't rate limited.
+func DefaultServerPolicy() *Policy {
+	return &Policy{
+		MaxRequestSize: maxJSONRPCRequestSize,
+	}
+}
+
+func (p *Policy) access(endpoint string) AccessLevel {
+	if level, exists := p.Access[endpoint]; exists {
+		return level
+	}
+	return AccessPublic
+}
+
+// AuthenticatedParams wrap the params of admin endpoints called over net/rpc, proving the caller is an admin. Either
+// the Token, or the PublicKey, Time and Signature are set.
+type AuthenticatedParams struct {
+	Token     string
+	PublicKey []byte
+	Time      int64 // unix nanoseconds
+	Signature []byte
+	Params    []byte
+}
+
+// AuthenticationPayload is what an admin signs for a request to the endpoint, sent at the time given.
+func AuthenticationPayload(endpoint string, time int64, params []byte) []byte {
+	paramsHash := sha256.Sum256(params)
+	payload := append([]byte{}, authenticationLabel...)
+	payload = append(payload, []byte(endpoint)...)
+	sentAt := make([]byte, 8)
+	binary.BigEndian.PutUint64(sentAt, uint64(time))
+	payload = append(payload, sentAt...)
+	return append(payload, paramsHash[:]...)
+}
+
+// NewTokenParams wraps the params for an admin endpoint, using an admin token.
+func NewTokenParams(token string, params []byte) ([]byte, error) {
+	return encoding.Marshal(AuthenticatedParams{Token: token, Params: params})
+}
+
+// NewSignedParams wraps the params for a call to the admin endpoint, signed by an admin's account.
+func NewSignedParams(admin *accounts.Account, endpoint string, params []byte) ([]byte, error) {
+	signed := AuthenticatedParams{
+		PublicKey: admin.PublicKey,
+		Time:      time.Now().UnixNano(),
+		Params:    params,
+	}
+	signature, err := admin.Sign(AuthenticationPayload(endpoint, signed.Time, params))
+	if err != nil {
+		return nil, err
+	}
+	signed.Signature = signature
+	return encoding.Marshal(signed)
+}
+
+// tokenBucket refills at the rate limit, and is spent by each request.
+type tokenBucket struct {
+	tokens   float64
+	lastSeen time.Time
+}
+
+type rpcGuard struct {
+	policy       *Policy
+	limiters     map[string]*tokenBucket // by IP
+	lastCleanup  time.Time
+	usedRequests map[string]time.Time // signatures of requests already accepted, until they expire
+	lock         sync.Mutex
+}
+
+func newRPCGuard(policy *Policy) *rpcGuard {
+	return &rpcGuard{
+		policy:       policy,
+		limiters:     make(map[string]*tokenBucket),
+		usedRequests: make(map[string]time.Time),
+	}
+}
+
+func (g *rpcGuard) setPolicy(policy *Policy) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	g.policy = policy
+	g.limiters = make(map[string]*tokenBucket)
+}
+
+func (g *rpcGuard) getPolicy() *Policy {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	return g.policy
+}
+
+// allow spends a request from the IP's rate limit, if it has any left.
+func (g *rpcGuard) allow(ip string) error {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	if g.policy.RateLimit <= 0 {
+		return nil
+	}
+	now := time.Now()
+	if now.Sub(g.lastCleanup) > idleLimiterTimeout {
+		for known, bucket := range g.limiters {
+			if now.Sub(bucket.lastSeen) > idleLimiterTimeout {
+				delete(g.limiters, known)
+			}
+		}
+		g.lastCleanup = now
+	}
+
+	burst := float64(g.policy.RateBurst)
+	if burst < 1 {
+		burst = 1
+	}
+	bucket, exists := g.limiters[ip]
+	if !exists {
+		bucket = &tokenBucket{tokens: burst, lastSeen: now}
+		g.limiters[ip] = bucket
+	}
+	bucket.tokens += now.Sub(bucket.lastSeen).Seconds() * g.policy.RateLimit
+	if bucket.tokens > burst {
+		bucket.tokens = burst
+	}
+	bucket.lastSeen = now
+	if bucket.tokens < 1 {
+		return ErrRateLimited
+	}
+	bucket.tokens--
+	return nil
+}
+
+func (g *rpcGuard) validToken(token string) bool {
+	if token == "" {
+		return false
+	}
+	for _, admin := range g.policy.AdminTokens {
+		if subtle.ConstantTimeCompare([]byte(admin), []byte(token)) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// validSignature checks the request is signed by an admin, and hasn't been used before.
+func (g *rpcGuard) validSignature(endpoint string, auth AuthenticatedParams) error {
+	admin := false
+	for _, key := range g.policy.AdminKeys {
+		if subtle.ConstantTimeCompare(key, auth.PublicKey) == 1 {
+			admin = true
+			break
+		}
+	}
+	if !admin || len(auth.Signature) < 64 {
+		return ErrUnauthorized
+	}
+	account := accounts.AccountFromPubBytes(auth.PublicKey)
+	if !account.Verify(AuthenticationPayload(endpoint, auth.Time, auth.Params), auth.Signature) {
+		return ErrUnauthorized
+	}
+	now := time.Now()
+	sent := time.Unix(0, auth.Time)
+	if sent.Before(now.Add(-signedRequestWindow)) || sent.After(now.Add(signedRequestWindow)) {
+		return ErrSignatureExpired
+	}
+
+	for used, expires := range g.usedRequests {
+		if now.After(expires) {
+			delete(g.usedRequests, used)
+		}
+	}
+	id := hex.EncodeToString(auth.Signature)
+	if _, used := g.usedRequests[id]; used {
+		return ErrSignatureReused
+	}
+	g.usedRequests[id] = sent.Add(signedRequestWindow)
+	return nil
+}
+
+// authorize checks the caller may call the endpoint, returning the params to call it with. Admin endpoints are
+// called with the params unwrapped from their AuthenticatedParams.
+func (g *rpcGuard) authorize(endpoint string, params []byte) ([]byte, error) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	if g.policy.MaxRequestSize > 0 && len(params) > g.policy.MaxRequestSize {
+		return nil, ErrRequestTooLarge
+	}
+	switch g.policy.access(endpoint) {
+	case AccessPublic:
+		return params, nil
+	case AccessAdmin:
+		var auth AuthenticatedParams
+		if err := encoding.Unmarshal(params, &auth); err != nil {
+			return nil, ErrUnauthorized
+		}
+		if g.validToken(auth.Token) {
+			return auth.Params, nil
+		}
+		if err := g.validSignature(endpoint, auth); err != nil {
+			return nil, err
+		}
+		return auth.Params, nil
+	}
+	return nil, ErrMethodDisabled
+}
+
+// authorizeToken checks a caller that sent the token may call the endpoint.
+func (g *rpcGuard) authorizeToken(endpoint string, token string) error {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	switch g.policy.access(endpoint) {
+	case AccessPublic:
+		return nil
+	case AccessAdmin:
+		if g.validToken(token) {
+			return nil
+		}
+		return ErrUnauthorized
+	}
+	return ErrMethodDisabled
+}
+
+// rpcGuardService answers the requests the guard turned away, with the reason they were.
+type rpcGuardService struct{}
+
+func (s *rpcGuardService) Deny(params *[]byte, reply *[]byte) error {
+	return errors.New(string(*params))
+}
+
+// registerGuard readies the server to serve connections through guardedCodecs.
+func registerGuard(server *rpc.Server) {
+	if err := server.RegisterName(guardServiceName, new(rpcGuardService)); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// limitedReader stops a connection from reading more than a request should take.
+type limitedReader struct {
+	r     io.Reader
+	read  int
+	limit int // unlimited if 0
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	n, err := l.r.Read(p)
+	l.read += n
+	if l.limit > 0 && l.read > l.limit {
+		return n, ErrRequestTooLarge
+	}
+	return n, err
+}
+
+// guardedCodec is the gob codec net/rpc uses by default, checking every request with the guard before it's called.
+// Requests the guard turns away are sent to the RPCGuard.Deny endpoint instead.
+type guardedCodec struct {
+	rwc    io.ReadWriteCloser
+	reader *limitedReader
+	dec    *gob.Decoder
+	enc    *gob.Encoder
+	encBuf *bufio.Writer
+	guard  *rpcGuard
+	ip     string
+	params []byte // of the request being read, as it's read along with the header
+	closed bool
+}
+
+func newGuardedCodec(conn net.Conn, guard *rpcGuard) *guardedCodec {
+	ip, _, err := net.SplitHostPort(conn.RemoteAddr().String())
+	if err != nil {
+		ip = conn.RemoteAddr().String()
+	}
+	reader := &limitedReader{r: conn}
+	buf := bufio.NewWriter(conn)
+	return &guardedCodec{
+		rwc:    conn,
+		reader: reader,
+		dec:    gob.NewDecoder(reader),
+		enc:    gob.NewEncoder(buf),
+		encBuf: buf,
+		guard:  guard,
+		ip:     ip,
+	}
+}
+
+func (c *guardedCodec) ReadRequestHeader(r *rpc.Request) error {
+	c.reader.read = 0
+	if maxSize := c.guard.getPolicy().MaxRequestSize; maxSize > 0 {
+		c.reader.limit = maxSize + requestReadSlack
+	} else {
+		c.reader.limit = 0
+	}
+	if err := c.dec.Decode(r); err != nil {
+		return err
+	}
+	var params []byte
+	if err := c.dec.Decode(&params); err != nil {
+		return err
+	}
+
+	err := c.guard.allow(c.ip)
+	if err == nil {
+		params, err = c.guard.authorize(r.ServiceMethod, params)
+	}
+	if err != nil {
+		log.Debugf("[Adamnite RPC guard] %v from %v turned away: %v", r.ServiceMethod, c.ip, err)
+		r.ServiceMethod = denyEndpoint
+		params = []byte(err.Error())
+	}
+	c.params = params
+	return nil
+}
+
+func (c *guardedCodec) ReadRequestBody(body interface{}) error {
+	if body == nil {
+		return nil
+	}
+	params, ok := body.(*[]byte)
+	if !ok {
+		return errors.New("rpc: endpoints only take bytes")
+	}
+	*params = c.params
+	return nil
+}
+
+func (c *guardedCodec) WriteResponse(r *rpc.Response, body interface{}) (err error) {
+	if err = c.enc.Encode(r); err != nil {
+		if c.encBuf.Flush() == nil {
+			log.Println("rpc: gob error encoding response:", err)
+			c.Close()
+		}
+		return
+	}
+	if err = c.enc.Encode(body); err != nil {
+		if c.encBuf.Flush() == nil {
+			log.Println("rpc: gob error encoding body:", err)
+			c.Close()
+		}
+		return
+	}
+	return c.encBuf.Flush()
+}
+
+func (c *guardedCodec) Close() error {
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	return c.rwc.Close()
+}
@@ -0,0 +1,170 @@
+package rpc
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/rpc"
+	"testing"
+
+	"github.com/adamnite/go-adamnite/utils/accounts"
+	"github.com/stretchr/testify/assert"
+	encoding "github.com/vmihailenco/msgpack/v5"
+)
+
+// a bouncer of its own, so the policies tried here don't get in the way of other tests
+func newGuardTestBouncer(t *testing.T, policy *Policy) (*BouncerServer, *rpc.Client) {
+	bouncer, err := NewBouncerServer(stateDB, nil, ListenConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bouncer.SetPolicy(policy)
+	client, err := rpc.Dial("tcp", bouncer.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		client.Close()
+		bouncer.Close()
+	})
+	return bouncer, client
+}
+
+func createAccountParams(t *testing.T) []byte {
+	account, _ := accounts.GenerateAccount()
+	params, err := encoding.Marshal(struct{ Address string }{account.Address.Hex()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return params
+}
+
+func TestGuardAdminToken(t *testing.T) {
+	policy := DefaultBouncerPolicy()
+	policy.AdminTokens = []string{"secret"}
+	_, client := newGuardTestBouncer(t, policy)
+
+	output := []byte{}
+	err := client.Call(BouncerCreateAccountEndpoint, createAccountParams(t), &output)
+	if assert.Error(t, err, "accounts were created without authenticating") {
+		assert.Equal(t, ErrUnauthorized.Error(), err.Error())
+	}
+
+	wrongToken, _ := NewTokenParams("not the secret", createAccountParams(t))
+	assert.Error(t, client.Call(BouncerCreateAccountEndpoint, wrongToken, &output), "the wrong token was accepted")
+
+	token, _ := NewTokenParams("secret", createAccountParams(t))
+	assert.NoError(t, client.Call(BouncerCreateAccountEndpoint, token, &output))
+
+	//public endpoints still don't need anything
+	assert.NoError(t, client.Call(BouncerGetChainIDEndpoint, []byte{}, &output))
+}
+
+func TestGuardSignedRequests(t *testing.T) {
+	admin, _ := accounts.GenerateAccount()
+	other, _ := accounts.GenerateAccount()
+	policy := DefaultBouncerPolicy()
+	policy.AdminKeys = [][]byte{admin.PublicKey}
+	_, client := newGuardTestBouncer(t, policy)
+
+	output := []byte{}
+	signed, err := NewSignedParams(admin, BouncerCreateAccountEndpoint, createAccountParams(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NoError(t, client.Call(BouncerCreateAccountEndpoint, signed, &output))
+
+	err = client.Call(BouncerCreateAccountEndpoint, signed, &output)
+	if assert.Error(t, err, "a signed request was replayed") {
+		assert.Equal(t, ErrSignatureReused.Error(), err.Error())
+	}
+
+	notAdmin, _ := NewSignedParams(other, BouncerCreateAccountEndpoint, createAccountParams(t))
+	assert.Error(t, client.Call(BouncerCreateAccountEndpoint, notAdmin, &output), "a non admin was let in")
+
+	//signatures are only good for the endpoint they were made for
+	otherEndpoint, _ := NewSignedParams(admin, BouncerGetBalanceEndpoint, createAccountParams(t))
+	assert.Error(t, client.Call(BouncerCreateAccountEndpoint, otherEndpoint, &output))
+}
+
+func TestGuardDisabledAndSize(t *testing.T) {
+	policy := DefaultBouncerPolicy()
+	policy.Access[BouncerGetChainIDEndpoint] = AccessDisabled
+	policy.MaxRequestSize = 1 << 10
+	_, client := newGuardTestBouncer(t, policy)
+
+	output := []byte{}
+	err := client.Call(BouncerGetChainIDEndpoint, []byte{}, &output)
+	if assert.Error(t, err) {
+		assert.Equal(t, ErrMethodDisabled.Error(), err.Error())
+	}
+
+	params, _ := encoding.Marshal(struct{ Address string }{string(make([]byte, 2<<10))})
+	err = client.Call(BouncerGetBalanceEndpoint, params, &output)
+	if assert.Error(t, err) {
+		assert.Equal(t, ErrRequestTooLarge.Error(), err.Error())
+	}
+}
+
+func TestGuardRateLimit(t *testing.T) {
+	policy := DefaultBouncerPolicy()
+	policy.RateLimit = 0.001
+	policy.RateBurst = 3
+	bouncer, client := newGuardTestBouncer(t, policy)
+
+	output := []byte{}
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, client.Call(BouncerGetChainIDEndpoint, []byte{}, &output))
+	}
+	err := client.Call(BouncerGetChainIDEndpoint, []byte{}, &output)
+	if assert.Error(t, err, "the rate limit was not applied") {
+		assert.Equal(t, ErrRateLimited.Error(), err.Error())
+	}
+
+	//JSON-RPC is limited the same way, each request in a batch counting
+	server := httptest.NewServer(bouncer)
+	defer server.Close()
+	_, body := postJSONRPC(t, server.URL, `{"jsonrpc":"2.0","id":1,"method":"getChainID"}`)
+	var response testJSONRPCResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		t.Fatal(err)
+	}
+	if assert.NotNil(t, response.Error) {
+		assert.Equal(t, jsonRPCLimitExceeded, response.Error.Code)
+	}
+}
+
+func TestGuardJSONRPCToken(t *testing.T) {
+	policy := DefaultBouncerPolicy()
+	policy.AdminTokens = []string{"secret"}
+	bouncer, _ := newGuardTestBouncer(t, policy)
+	server := httptest.NewServer(bouncer)
+	defer server.Close()
+
+	account, _ := accounts.GenerateAccount()
+	request := fmt.Sprintf(`{"jsonrpc":"2.0","id":1,"method":"createAccount","params":["%v"]}`, account.Address.Hex())
+	_, body := postJSONRPC(t, server.URL, request)
+	var response testJSONRPCResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		t.Fatal(err)
+	}
+	if assert.NotNil(t, response.Error, "accounts were created without authenticating") {
+		assert.Equal(t, jsonRPCUnauthorized, response.Error.Code)
+	}
+
+	req, _ := http.NewRequest(http.MethodPost, server.URL, bytes.NewBufferString(request))
+	req.Header.Set("Authorization", "Bearer secret")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	response = testJSONRPCResponse{}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		t.Fatal(err)
+	}
+	assert.Nil(t, response.Error)
+	assert.Equal(t, "true", string(response.Result))
+}
@@ -0,0 +1,277 @@
+package rpc
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"net/rpc"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// DefaultListenHost keeps a server to this machine unless told otherwise.
+const DefaultListenHost = "127.0.0.1"
+
+// how long Close lets in-flight calls finish before their connections are cut.
+const shutdownTimeout = 5 * time.Second
+
+const selfSignedValidity = 365 * 24 * time.Hour
+
+var (
+	ErrListenFailed     = errors.New("could not listen on")
+	ErrTLSFilesMismatch = errors.New("a TLS certificate and key file must be given together")
+	ErrTLSFileMissing   = errors.New("only one of the TLS certificate and key files exists")
+)
+
+// ListenConfig is where, and how, a server listens.
+type ListenConfig struct {
+	Host       string // interface to bind, DefaultListenHost if empty. "0.0.0.0" (or "::") accepts remote connections
+	Port       uint32 // 0 picks any free port
+	PublicHost string // the host others should reach us at, if it isn't the one we bind
+
+	TLS      bool
+	CertFile string // if TLS is set and the files don't exist, a self-signed certificate is made and saved to them
+	KeyFile  string // leaving both empty keeps the self-signed certificate in memory only
+}
+
+// LocalListenConfig listens on the loopback interface, as servers always used to.
+func LocalListenConfig(port uint32) ListenConfig {
+	return ListenConfig{Host: DefaultListenHost, Port: port}
+}
+
+func (c ListenConfig) host() string {
+	if c.Host == "" {
+		return DefaultListenHost
+	}
+	return c.Host
+}
+
+// Address is the host:port that is bound.
+func (c ListenConfig) Address() string {
+	return net.JoinHostPort(c.host(), fmt.Sprint(c.Port))
+}
+
+// Listen binds the configured address, wrapped in TLS if asked for.
+func (c ListenConfig) Listen() (net.Listener, error) {
+	var tlsConfig *tls.Config
+	if c.TLS {
+		cert, err := c.certificate()
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig = &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+	}
+	listener, err := net.Listen("tcp", c.Address())
+	if err != nil {
+		return nil, fmt.Errorf("%w %v: %v", ErrListenFailed, c.Address(), err)
+	}
+	if tlsConfig != nil {
+		listener = tls.NewListener(listener, tlsConfig)
+	}
+	return listener, nil
+}
+
+// advertised is what others should dial to reach a listener bound with this config.
+func (c ListenConfig) advertised(addr net.Addr) string {
+	tcp, ok := addr.(*net.TCPAddr)
+	if !ok {
+		return addr.String()
+	}
+	host := c.PublicHost
+	if host == "" {
+		if !tcp.IP.IsUnspecified() {
+			return addr.String()
+		}
+		//bound to every interface, so the loopback one is the only one we know works
+		host = DefaultListenHost
+	}
+	return net.JoinHostPort(host, fmt.Sprint(tcp.Port))
+}
+
+// certificate loads the configured key pair, making a self-signed one where there is none. If only one of the
+// files is there, it is left alone rather than overwritten.
+func (c ListenConfig) certificate() (tls.Certificate, error) {
+	if (c.CertFile == "") != (c.KeyFile == "") {
+		return tls.Certificate{}, ErrTLSFilesMismatch
+	}
+	if c.CertFile != "" {
+		_, certErr := os.Stat(c.CertFile)
+		_, keyErr := os.Stat(c.KeyFile)
+		if certErr == nil && keyErr == nil {
+			return tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		}
+		if certErr == nil || keyErr == nil {
+			return tls.Certificate{}, fmt.Errorf("%w: %v, %v", ErrTLSFileMissing, c.CertFile, c.KeyFile)
+		}
+	}
+	certPEM, keyPEM, err := GenerateSelfSignedCert(c.host(), c.PublicHost)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	if c.CertFile != "" {
+		log.Printf("[Adamnite RPC] saving a self-signed certificate to %v", c.CertFile)
+		if err := os.WriteFile(c.CertFile, certPEM, 0644); err != nil {
+			return tls.Certificate{}, err
+		}
+		if err := os.WriteFile(c.KeyFile, keyPEM, 0600); err != nil {
+			return tls.Certificate{}, err
+		}
+	}
+	return tls.X509KeyPair(certPEM, keyPEM)
+}
+
+// GenerateSelfSignedCert makes a PEM encoded certificate and key valid for the hosts given, and for localhost.
+func GenerateSelfSignedCert(hosts ...string) (certPEM []byte, keyPEM []byte, err error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, nil, err
+	}
+	template := x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{Organization: []string{"Adamnite"}},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(selfSignedValidity),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
+		DNSNames:              []string{"localhost"},
+	}
+	for _, host := range hosts {
+		if host == "" {
+			continue
+		}
+		if ip := net.ParseIP(host); ip != nil {
+			if !ip.IsUnspecified() {
+				template.IPAddresses = append(template.IPAddresses, ip)
+			}
+		} else {
+			template.DNSNames = append(template.DNSNames, host)
+		}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	certPEM = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM = pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	return certPEM, keyPEM, nil
+}
+
+// rpcListener serves net/rpc connections through the guard, and lets them finish their calls when closed.
+type rpcListener struct {
+	listener net.Listener
+	server   *rpc.Server
+	guard    *rpcGuard
+	onConn   func(net.Conn)
+
+	conns   map[net.Conn]struct{}
+	serving sync.WaitGroup
+	lock    sync.Mutex
+	closed  bool
+}
+
+func newRPCListener(listener net.Listener, server *rpc.Server, guard *rpcGuard) *rpcListener {
+	return &rpcListener{
+		listener: listener,
+		server:   server,
+		guard:    guard,
+		conns:    make(map[net.Conn]struct{}),
+	}
+}
+
+// serve accepts connections until closed.
+func (l *rpcListener) serve() {
+	for {
+		conn, err := l.listener.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				log.Println("[Listener accept]", err)
+			}
+			return
+		}
+
+		l.lock.Lock()
+		if l.closed {
+			l.lock.Unlock()
+			_ = conn.Close()
+			return
+		}
+		l.conns[conn] = struct{}{}
+		l.serving.Add(1)
+		l.lock.Unlock()
+
+		go func(conn net.Conn) {
+			defer l.serving.Done()
+			defer func() {
+				l.lock.Lock()
+				delete(l.conns, conn)
+				l.lock.Unlock()
+				if err := conn.Close(); err != nil && !strings.Contains(err.Error(), "use of closed network connection") {
+					log.Println(err)
+				}
+			}()
+			if l.onConn != nil {
+				l.onConn(conn)
+			}
+			l.server.ServeCodec(newGuardedCodec(conn, l.guard))
+		}(conn)
+	}
+}
+
+// close stops accepting, stops reading new requests, and waits for the calls already running to answer.
+// Connections still busy after the timeout are cut.
+func (l *rpcListener) close(timeout time.Duration) {
+	l.lock.Lock()
+	if l.closed {
+		l.lock.Unlock()
+		return
+	}
+	l.closed = true
+	_ = l.listener.Close()
+	for conn := range l.conns {
+		//the codec reading fails, after which net/rpc waits for its running calls before closing the codec
+		_ = conn.SetReadDeadline(time.Now())
+	}
+	l.lock.Unlock()
+
+	drained := make(chan struct{})
+	go func() {
+		l.serving.Wait()
+		close(drained)
+	}()
+	select {
+	case <-drained:
+	case <-time.After(timeout):
+		log.Printf("[Adamnite RPC] calls still running after %v, closing their connections", timeout)
+		l.lock.Lock()
+		for conn := range l.conns {
+			_ = conn.Close()
+		}
+		l.lock.Unlock()
+	}
+}
+
+func (l *rpcListener) addr() net.Addr {
+	return l.listener.Addr()
+}
@@ -0,0 +1,144 @@
+package rpc
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/adamnite/go-adamnite/common"
+	"github.com/adamnite/go-adamnite/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListenBusyPort(t *testing.T) {
+	taken, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer taken.Close()
+	port := uint32(taken.Addr().(*net.TCPAddr).Port)
+
+	server, err := NewAdamniteServer(LocalListenConfig(port))
+	assert.Nil(t, server)
+	assert.ErrorIs(t, err, ErrListenFailed)
+	bouncer, err := NewBouncerServer(stateDB, nil, LocalListenConfig(port))
+	assert.Nil(t, bouncer)
+	assert.ErrorIs(t, err, ErrListenFailed)
+}
+
+func TestListenAdvertisedAddr(t *testing.T) {
+	server, err := NewAdamniteServer(ListenConfig{Host: "0.0.0.0", PublicHost: "node.adamnite.org"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	_, port, _ := net.SplitHostPort(server.Addr())
+	assert.Equal(t, "node.adamnite.org:"+port, server.AdvertisedAddr())
+
+	local, err := NewAdamniteServer(ListenConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer local.Close()
+	assert.Equal(t, local.Addr(), local.AdvertisedAddr())
+}
+
+func TestListenTLS(t *testing.T) {
+	dir := t.TempDir()
+	listen := ListenConfig{
+		TLS:      true,
+		CertFile: filepath.Join(dir, "cert.pem"),
+		KeyFile:  filepath.Join(dir, "key.pem"),
+	}
+	server, err := NewAdamniteServer(listen)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	go server.Run()
+
+	//the self-signed certificate was kept, and is what the server presents
+	certPEM, err := os.ReadFile(listen.CertFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	assert.True(t, pool.AppendCertsFromPEM(certPEM))
+
+	_, err = NewAdamniteClientTLS(server.Addr(), &tls.Config{RootCAs: x509.NewCertPool()})
+	assert.Error(t, err, "a certificate nobody trusts was accepted")
+
+	tlsClient, err := NewAdamniteClientTLS(server.Addr(), &tls.Config{RootCAs: pool})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tlsClient.Close()
+	tlsClient.SetAddressAndHostingPort(&common.Address{1}, "")
+	_, err = tlsClient.GetVersion()
+	assert.NoError(t, err)
+
+	//restarting loads the same certificate rather than making another
+	server.Close()
+	again, err := NewAdamniteServer(listen)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer again.Close()
+	reread, _ := os.ReadFile(listen.CertFile)
+	assert.Equal(t, certPEM, reread)
+
+	_, err = NewAdamniteServer(ListenConfig{TLS: true, CertFile: listen.CertFile})
+	assert.ErrorIs(t, err, ErrTLSFilesMismatch)
+
+	//a lone certificate isn't replaced by a new pair
+	if err := os.Remove(listen.KeyFile); err != nil {
+		t.Fatal(err)
+	}
+	_, err = NewAdamniteServer(listen)
+	assert.ErrorIs(t, err, ErrTLSFileMissing)
+	reread, _ = os.ReadFile(listen.CertFile)
+	assert.Equal(t, certPEM, reread)
+	_, err = os.Stat(listen.KeyFile)
+	assert.True(t, os.IsNotExist(err), "the key was written over the lone certificate")
+}
+
+func TestCloseDrainsCalls(t *testing.T) {
+	server, err := NewAdamniteServer(ListenConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	started := make(chan struct{})
+	server.SetHandlers(nil, nil, func(*utils.Transaction, *[]byte) error {
+		close(started)
+		time.Sleep(200 * time.Millisecond)
+		return nil
+	})
+	go server.Run()
+
+	slowClient, err := NewAdamniteClient(server.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer slowClient.Close()
+	callErr := make(chan error)
+	go func() {
+		callErr <- slowClient.SendTransaction(&utils.Transaction{Amount: big.NewInt(1)})
+	}()
+
+	<-started
+	server.Close()
+	select {
+	case err := <-callErr:
+		assert.NoError(t, err, "the call running during shutdown was cut off")
+	case <-time.After(time.Second):
+		t.Fatal("the call never returned")
+	}
+
+	_, err = net.DialTimeout("tcp", server.Addr(), time.Second)
+	assert.Error(t, err, "new connections are still accepted after closing")
+}
@@ -20,9 +20,11 @@ type AdamniteServer struct {
 	hostingNodeID   common.Address
 	seenConnections map[common.Hash]common.Void
 	Version         string
+	guard           *rpcGuard
 
 	GetContactsFunction       func() PassedContacts
-	listener                  net.Listener
+	listener                  *rpcListener
+	listen                    ListenConfig
 	mostRecentReceivedIP      string //TODO: CHECK THIS! Most likely can cause a race condition.
 	timesTestHasBeenCalled    int
 	newConnection             func(string, common.Address)
@@ -31,11 +33,21 @@ type AdamniteServer struct {
 	newCandidateHandler       func(utils.Candidate) error
 	newVoteHandler            func(utils.Voter) error
 	newMessageHandler         func(*utils.CaesarMessage)
+	newMailboxRecordHandler   func(*utils.CaesarMailboxRecord)
+	mailboxFetchHandler       func(utils.CaesarMailboxRequest) ([]*utils.CaesarMessage, error)
+	mailboxAckHandler         func(utils.CaesarMailboxRequest) error
+	newChunkHandler           func(*utils.CaesarChunkShare)
+	chunkFetchHandler         func([]byte) (*utils.CaesarChunk, error)
 	Run                       func()
 }
 
 func (a *AdamniteServer) Addr() string {
-	return a.listener.Addr().String()
+	return a.listener.addr().String()
+}
+
+// AdvertisedAddr is the address other nodes should be given to reach this server.
+func (a *AdamniteServer) AdvertisedAddr() string {
+	return a.listen.advertised(a.listener.addr())
 }
 func (a *AdamniteServer) SetHandlers(
 	newForward func(ForwardingContent, *[]byte) error,
@@ -57,6 +69,11 @@ func (a *AdamniteServer) SetTransactionHandler(handler func(*utils.Transaction,
 	a.newTransactionReceived = handler
 }
 
+// set who can call what on the server
+func (a *AdamniteServer) SetPolicy(policy *Policy) {
+	a.guard.setPolicy(policy)
+}
+
 func (a *AdamniteServer) SetHostingID(id *common.Address) {
 	if id == nil {
 		a.hostingNodeID = common.Address{0}
@@ -69,7 +86,7 @@ func (a *AdamniteServer) Close() {
 	for h := range a.seenConnections {
 		delete(a.seenConnections, h)
 	}
-	_ = a.listener.Close()
+	a.listener.close(shutdownTimeout)
 }
 
 const serverPreface = "[Adamnite RPC server] %v \n"
@@ -144,6 +161,10 @@ func (a *AdamniteServer) callOnSelf(content ForwardingContent) error {
 		return a.TestServer(&content.FinalParams, &content.FinalReply)
 	case newMessageEndpoint:
 		return a.NewCaesarMessage(&content.FinalParams, &[]byte{})
+	case newMailboxRecordEndpoint:
+		return a.NewCaesarMailboxRecord(&content.FinalParams, &[]byte{})
+	case newChunkEndpoint:
+		return a.NewCaesarChunk(&content.FinalParams, &[]byte{})
 	}
 	return nil
 }
@@ -234,7 +255,8 @@ func (a *AdamniteServer) SendTransaction(params *[]byte, reply *[]byte) error {
 
 }
 
-func NewAdamniteServer(port uint32) *AdamniteServer {
+// NewAdamniteServer binds the server as configured. Calls are taken once Run is called.
+func NewAdamniteServer(listen ListenConfig) (*AdamniteServer, error) {
 	rpcServer := rpc.NewServer()
 
 	adamnite := new(AdamniteServer)
@@ -243,33 +265,22 @@ func NewAdamniteServer(port uint32) *AdamniteServer {
 	adamnite.Version = "0.1.2"
 
 	if err := rpcServer.Register(adamnite); err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
+	registerGuard(rpcServer)
+	adamnite.guard = newRPCGuard(DefaultServerPolicy())
 
-	listener, _ := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", port))
+	listener, err := listen.Listen()
+	if err != nil {
+		return nil, err
+	}
 	log.Printf(serverPreface, fmt.Sprint("Endpoint: ", listener.Addr().String()))
 
-	runFunc := func() {
-		for {
-			conn, err := listener.Accept()
-			if err != nil {
-				log.Println("[Listener accept]", err)
-				return
-			}
-
-			go func(conn net.Conn) {
-				defer func() {
-					if err = conn.Close(); err != nil && !strings.Contains(err.Error(), "use of closed network connection") {
-						log.Println(err)
-					}
-				}()
-				adamnite.mostRecentReceivedIP = conn.RemoteAddr().String()
-				// _ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
-				rpcServer.ServeConn(conn)
-			}(conn)
-		}
+	adamnite.listener = newRPCListener(listener, rpcServer, adamnite.guard)
+	adamnite.listener.onConn = func(conn net.Conn) {
+		adamnite.mostRecentReceivedIP = conn.RemoteAddr().String()
 	}
-	adamnite.listener = listener
-	adamnite.Run = runFunc
-	return adamnite
+	adamnite.listen = listen
+	adamnite.Run = adamnite.listener.serve
+	return adamnite, nil
 }
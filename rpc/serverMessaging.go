@@ -27,3 +27,122 @@ func (a *AdamniteServer) NewCaesarMessage(params *[]byte, reply *[]byte) error {
 	go a.newMessageHandler(&msg)
 	return nil
 }
+
+// set the handlers used when acting as a mailbox, holding messages for accounts while they are offline
+func (a *AdamniteServer) SetCaesarMailboxHandlers(
+	recordH func(*utils.CaesarMailboxRecord),
+	fetchH func(utils.CaesarMailboxRequest) ([]*utils.CaesarMessage, error),
+	ackH func(utils.CaesarMailboxRequest) error) {
+	a.newMailboxRecordHandler = recordH
+	a.mailboxFetchHandler = fetchH
+	a.mailboxAckHandler = ackH
+}
+
+const newMailboxRecordEndpoint = "AdamniteServer.NewCaesarMailboxRecord"
+
+func (a *AdamniteServer) NewCaesarMailboxRecord(params *[]byte, reply *[]byte) error {
+	a.print("New Caesar Mailbox Record")
+	if a.newMailboxRecordHandler == nil {
+		return nil //we aren't setup to handle it, just forward it
+	}
+	var record utils.CaesarMailboxRecord
+	if err := encoding.Unmarshal(*params, &record); err != nil {
+		a.printError("New Caesar Mailbox Record", err)
+		return err
+	}
+	if !record.Verify() {
+		return ErrBadSignature
+	}
+	go a.newMailboxRecordHandler(&record)
+	return nil
+}
+
+const fetchCaesarMailboxEndpoint = "AdamniteServer.FetchCaesarMailbox"
+
+// FetchCaesarMailbox returns the messages held for the owner of the request
+func (a *AdamniteServer) FetchCaesarMailbox(params *[]byte, reply *[]byte) error {
+	a.print("Fetch Caesar Mailbox")
+	if a.mailboxFetchHandler == nil {
+		return ErrNotAMailbox
+	}
+	var request utils.CaesarMailboxRequest
+	if err := encoding.Unmarshal(*params, &request); err != nil {
+		a.printError("Fetch Caesar Mailbox", err)
+		return err
+	}
+	messages, err := a.mailboxFetchHandler(request)
+	if err != nil {
+		return err
+	}
+	*reply, err = encoding.Marshal(messages)
+	return err
+}
+
+const ackCaesarMailboxEndpoint = "AdamniteServer.AckCaesarMailbox"
+
+// AckCaesarMailbox drops the messages the owner of the request has received
+func (a *AdamniteServer) AckCaesarMailbox(params *[]byte, reply *[]byte) error {
+	a.print("Ack Caesar Mailbox")
+	if a.mailboxAckHandler == nil {
+		return ErrNotAMailbox
+	}
+	var request utils.CaesarMailboxRequest
+	if err := encoding.Unmarshal(*params, &request); err != nil {
+		a.printError("Ack Caesar Mailbox", err)
+		return err
+	}
+	return a.mailboxAckHandler(request)
+}
+
+// set the handlers used to keep the chunks of attachments sent to us, and to share the chunks we hold
+func (a *AdamniteServer) SetCaesarChunkHandlers(chunkH func(*utils.CaesarChunkShare), fetchH func([]byte) (*utils.CaesarChunk, error)) {
+	a.newChunkHandler = chunkH
+	a.chunkFetchHandler = fetchH
+}
+
+const newChunkEndpoint = "AdamniteServer.NewCaesarChunk"
+
+func (a *AdamniteServer) NewCaesarChunk(params *[]byte, reply *[]byte) error {
+	a.print("New Caesar Chunk")
+	if a.newChunkHandler == nil {
+		return nil //we aren't setup to handle it, just forward it
+	}
+	var share utils.CaesarChunkShare
+	if err := encoding.Unmarshal(*params, &share); err != nil {
+		a.printError("New Caesar Chunk", err)
+		return err
+	}
+	if len(share.Chunk.Data) > utils.MaxCaesarChunkSize {
+		return utils.ErrChunkTooLarge
+	}
+	if !share.Verify() {
+		a.printError("New Caesar Chunk", ErrBadSignature)
+		return ErrBadSignature
+	}
+	go a.newChunkHandler(&share)
+	return nil
+}
+
+const getCaesarChunkEndpoint = "AdamniteServer.GetCaesarChunk"
+
+// GetCaesarChunk returns the chunk with the ID passed
+func (a *AdamniteServer) GetCaesarChunk(params *[]byte, reply *[]byte) error {
+	a.print("Get Caesar Chunk")
+	if a.chunkFetchHandler == nil {
+		return ErrUnknownChunk
+	}
+	var id []byte
+	if err := encoding.Unmarshal(*params, &id); err != nil {
+		a.printError("Get Caesar Chunk", err)
+		return err
+	}
+	chunk, err := a.chunkFetchHandler(id)
+	if err != nil {
+		return err
+	}
+	if chunk == nil {
+		return ErrUnknownChunk
+	}
+	*reply, err = encoding.Marshal(chunk)
+	return err
+}
@@ -64,7 +64,10 @@ func main() {
 	}
 
 	// Create RPC and HTTP servers
-	bouncerServer := admRpc.NewBouncerServer(stateDB, blockchain, 0)
+	bouncerServer, err := admRpc.NewBouncerServer(stateDB, blockchain, admRpc.ListenConfig{})
+	if err != nil {
+		log.Fatal(err)
+	}
 	defer func() {
 		bouncerServer.Close()
 	}()
@@ -108,10 +111,13 @@ func main() {
 		w.Write(reply)
 	})
 
+	mux.Handle("/jsonrpc", bouncerServer) // JSON-RPC 2.0, for clients without msgpack
+
 	handler := cors.Default().Handler(mux)
 	server := http.Server{Addr: "127.0.0.1:3000", Handler: handler}
 	listener, _ := net.Listen("tcp", server.Addr)
 	log.Println("[Adamnite HTTP] Endpoint:", listener.Addr().String()+"/v1/")
+	log.Println("[Adamnite HTTP] JSON-RPC Endpoint:", listener.Addr().String()+"/jsonrpc")
 
 	_ = server.Serve(listener)
 }
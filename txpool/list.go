@@ -0,0 +1,44 @@
+package txpool
+
+import (
+	"sort"
+
+	"github.com/adamnite/go-adamnite/utils"
+)
+
+// txList holds the transactions of one account, by nonce.
+type txList struct {
+	txs map[uint64]*utils.Transaction
+}
+
+func newTxList() *txList {
+	return &txList{txs: make(map[uint64]*utils.Transaction)}
+}
+
+func (l *txList) get(nonce uint64) *utils.Transaction {
+	return l.txs[nonce]
+}
+
+func (l *txList) put(tx *utils.Transaction) {
+	l.txs[tx.Nonce] = tx
+}
+
+func (l *txList) remove(nonce uint64) {
+	delete(l.txs, nonce)
+}
+
+func (l *txList) len() int {
+	return len(l.txs)
+}
+
+// sorted is every transaction in the list, lowest nonce first.
+func (l *txList) sorted() []*utils.Transaction {
+	sorted := make([]*utils.Transaction, 0, len(l.txs))
+	for _, tx := range l.txs {
+		sorted = append(sorted, tx)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Nonce < sorted[j].Nonce
+	})
+	return sorted
+}
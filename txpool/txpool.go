@@ -0,0 +1,454 @@
+package txpool
+
+import (
+	"errors"
+	"math/big"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/adamnite/go-adamnite/blockchain"
+	"github.com/adamnite/go-adamnite/common"
+	"github.com/adamnite/go-adamnite/event"
+	"github.com/adamnite/go-adamnite/params"
+	"github.com/adamnite/go-adamnite/utils"
+)
+
+// The pool holds transactions until they are put in a block. Those that can go in the next block, with every earlier
+// nonce of their sender already used or pending, are pending. Those waiting on an earlier nonce are queued.
+
+var (
+	ErrAlreadyKnown       = errors.New("the transaction is already in the pool")
+	ErrInsufficientFunds  = errors.New("the sender can not cover the amount and fee, along with the rest of what they have held")
+	ErrReplaceUnderpriced = errors.New("a replacement transaction must raise the fee of the one it replaces")
+	ErrUnderpriced        = errors.New("the pool is full, and the fee is too low to take the place of another")
+	ErrAccountLimit       = errors.New("the sender already has as many transactions held as allowed")
+)
+
+// Config sets how much the pool holds.
+type Config struct {
+	AccountSlots uint64        // most transactions held for one account, pending and queued together
+	GlobalSlots  uint64        // most transactions held in all
+	PriceBump    uint64        // percent a replacement must raise the fee of the transaction it replaces by
+	Lifetime     time.Duration // how long a queued transaction is held, waiting on an earlier nonce
+}
+
+var DefaultConfig = Config{
+	AccountSlots: 64,
+	GlobalSlots:  4096,
+	PriceBump:    10,
+	Lifetime:     3 * time.Hour,
+}
+
+// StateReader is the account state transactions are checked against.
+type StateReader interface {
+	GetBalance(common.Address) *big.Int
+	GetNonce(common.Address) uint64
+}
+
+// NewTxsEvent is sent when transactions become pending.
+type NewTxsEvent struct{ Txs []*utils.Transaction }
+
+type pooledTx struct {
+	tx    *utils.Transaction
+	added time.Time
+}
+
+type TxPool struct {
+	config  Config
+	chainID *big.Int
+	state   StateReader
+
+	all     map[common.Hash]*pooledTx
+	pending map[common.Address]*txList // ready for the next block
+	queue   map[common.Address]*txList // waiting on an earlier nonce
+	lock    sync.RWMutex
+
+	txFeed     event.Feed
+	scope      event.SubscriptionScope
+	chainHeads event.Subscription
+}
+
+// New makes a pool taking transactions for the chain configured, checked against state. If chain is given, the pool
+// drops the transactions its blocks use up as they arrive.
+func New(config Config, chainConfig *params.ChainConfig, state StateReader, chain *blockchain.Blockchain) *TxPool {
+	pool := &TxPool{
+		config:  config,
+		chainID: chainConfig.ChainID,
+		state:   state,
+		all:     make(map[common.Hash]*pooledTx),
+		pending: make(map[common.Address]*txList),
+		queue:   make(map[common.Address]*txList),
+	}
+	if chain != nil {
+		heads := make(chan blockchain.ChainHeadEvent, 16)
+		pool.chainHeads = chain.SubscribeChainHeadEvent(heads)
+		go func(sub event.Subscription) {
+			for {
+				select {
+				case <-heads:
+					pool.Reset()
+				case <-sub.Err():
+					return
+				}
+			}
+		}(pool.chainHeads)
+	}
+	return pool
+}
+
+// Stop stops following the chain, and ends every subscription to the pool.
+func (pool *TxPool) Stop() {
+	if pool.chainHeads != nil {
+		pool.chainHeads.Unsubscribe()
+	}
+	pool.scope.Close()
+}
+
+// SubscribeNewTxsEvent tells ch of the transactions that become pending, for block producers to pick up.
+func (pool *TxPool) SubscribeNewTxsEvent(ch chan<- NewTxsEvent) event.Subscription {
+	return pool.scope.Track(pool.txFeed.Subscribe(ch))
+}
+
+// Add checks the transaction against the state and the pool, then holds it.
+func (pool *TxPool) Add(tx *utils.Transaction) error {
+	pool.lock.Lock()
+	promoted, err := pool.add(tx)
+	pool.lock.Unlock()
+
+	if len(promoted) > 0 {
+		pool.txFeed.Send(NewTxsEvent{Txs: promoted})
+	}
+	return err
+}
+
+// add holds the transaction, returning those it made pending. Must be called with the lock held.
+func (pool *TxPool) add(tx *utils.Transaction) ([]*utils.Transaction, error) {
+	hash := tx.Hash()
+	if _, exists := pool.all[hash]; exists {
+		return nil, ErrAlreadyKnown
+	}
+	if err := tx.Validate(pool.chainID, pool.state.GetNonce(tx.From)); err != nil {
+		return nil, err
+	}
+	//the sender's other transactions held count against their balance too, so the same funds can't be sent twice
+	spending := new(big.Int).Add(tx.Cost(), pool.heldCost(tx.From, tx.Nonce))
+	if pool.state.GetBalance(tx.From).Cmp(spending) < 0 {
+		return nil, ErrInsufficientFunds
+	}
+	pool.expire()
+
+	if old := pool.bySenderNonce(tx.From, tx.Nonce); old != nil {
+		//the same nonce can only be used once, so the new one replaces the old if it pays enough more for it. A bump
+		//of a free transaction is still free, so it must always pay more as well
+		bumped := new(big.Int).Mul(fee(old), big.NewInt(int64(100+pool.config.PriceBump)))
+		if new(big.Int).Mul(fee(tx), big.NewInt(100)).Cmp(bumped) < 0 || fee(tx).Cmp(fee(old)) <= 0 {
+			return nil, ErrReplaceUnderpriced
+		}
+		delete(pool.all, old.Hash())
+		pool.all[hash] = &pooledTx{tx: tx, added: time.Now()}
+		if pending := pool.pending[tx.From]; pending != nil && pending.get(tx.Nonce) == old {
+			pending.put(tx)
+			return []*utils.Transaction{tx}, nil
+		}
+		pool.queue[tx.From].put(tx)
+		return nil, nil
+	}
+	if uint64(pool.accountLen(tx.From)) >= pool.config.AccountSlots {
+		return nil, ErrAccountLimit
+	}
+	if uint64(len(pool.all)) >= pool.config.GlobalSlots {
+		cheapest := pool.cheapest()
+		if cheapest == nil || fee(cheapest).Cmp(fee(tx)) >= 0 {
+			return nil, ErrUnderpriced
+		}
+		pool.remove(cheapest.Hash())
+	}
+
+	pool.all[hash] = &pooledTx{tx: tx, added: time.Now()}
+	if pool.queue[tx.From] == nil {
+		pool.queue[tx.From] = newTxList()
+	}
+	pool.queue[tx.From].put(tx)
+	return pool.promote(tx.From), nil
+}
+
+// Reset drops the transactions that can no longer be used against the current state, such as those a new block
+// included, and moves the rest between pending and queued to match.
+func (pool *TxPool) Reset() {
+	pool.lock.Lock()
+	var promoted []*utils.Transaction
+	pool.expire()
+	accounts := make(map[common.Address]struct{})
+	for account := range pool.pending {
+		accounts[account] = struct{}{}
+	}
+	for account := range pool.queue {
+		accounts[account] = struct{}{}
+	}
+	for account := range accounts {
+		nonce := pool.state.GetNonce(account)
+		balance := pool.state.GetBalance(account)
+		for _, list := range []*txList{pool.pending[account], pool.queue[account]} {
+			if list == nil {
+				continue
+			}
+			for _, tx := range list.sorted() {
+				if tx.Nonce < nonce || balance.Cmp(tx.Cost()) < 0 {
+					pool.remove(tx.Hash())
+				}
+			}
+		}
+		pool.demote(account)
+		promoted = append(promoted, pool.promote(account)...)
+	}
+	pool.lock.Unlock()
+
+	if len(promoted) > 0 {
+		pool.txFeed.Send(NewTxsEvent{Txs: promoted})
+	}
+}
+
+// promote moves the queued transactions of the account that follow on from its pending ones into pending, returning
+// those moved. Must be called with the lock held.
+func (pool *TxPool) promote(account common.Address) []*utils.Transaction {
+	queued := pool.queue[account]
+	if queued == nil {
+		return nil
+	}
+	next := pool.state.GetNonce(account)
+	pending := pool.pending[account]
+	if pending != nil {
+		for pending.get(next) != nil {
+			next++
+		}
+	}
+	var promoted []*utils.Transaction
+	for tx := queued.get(next); tx != nil; tx = queued.get(next) {
+		if pending == nil {
+			pending = newTxList()
+			pool.pending[account] = pending
+		}
+		queued.remove(next)
+		pending.put(tx)
+		promoted = append(promoted, tx)
+		next++
+	}
+	if queued.len() == 0 {
+		delete(pool.queue, account)
+	}
+	return promoted
+}
+
+// demote moves the pending transactions of the account that no longer follow on from its nonce back to the queue.
+// Must be called with the lock held.
+func (pool *TxPool) demote(account common.Address) {
+	pending := pool.pending[account]
+	if pending == nil {
+		return
+	}
+	next := pool.state.GetNonce(account)
+	for _, tx := range pending.sorted() {
+		if tx.Nonce == next {
+			next++
+			continue
+		}
+		pending.remove(tx.Nonce)
+		if pool.queue[account] == nil {
+			pool.queue[account] = newTxList()
+		}
+		pool.queue[account].put(tx)
+	}
+	if pending.len() == 0 {
+		delete(pool.pending, account)
+	}
+}
+
+// remove drops the transaction from the pool. Any of the sender's pending transactions after it are queued again,
+// as they can't be used until its nonce is. Must be called with the lock held.
+func (pool *TxPool) remove(hash common.Hash) {
+	pooled, exists := pool.all[hash]
+	if !exists {
+		return
+	}
+	delete(pool.all, hash)
+	tx := pooled.tx
+	if pending := pool.pending[tx.From]; pending != nil && pending.get(tx.Nonce) == tx {
+		pending.remove(tx.Nonce)
+		if pending.len() == 0 {
+			delete(pool.pending, tx.From)
+		}
+		pool.demote(tx.From)
+		return
+	}
+	if queued := pool.queue[tx.From]; queued != nil && queued.get(tx.Nonce) == tx {
+		queued.remove(tx.Nonce)
+		if queued.len() == 0 {
+			delete(pool.queue, tx.From)
+		}
+	}
+}
+
+// expire drops the queued transactions held past their lifetime. Must be called with the lock held.
+func (pool *TxPool) expire() {
+	for _, queued := range pool.queue {
+		for _, tx := range queued.sorted() {
+			if time.Since(pool.all[tx.Hash()].added) > pool.config.Lifetime {
+				pool.remove(tx.Hash())
+			}
+		}
+	}
+}
+
+// cheapest is the transaction with the lowest fee, preferring queued transactions, then the latest nonce.
+func (pool *TxPool) cheapest() *utils.Transaction {
+	var cheapest *utils.Transaction
+	cheapestQueued := false
+	for _, pooled := range pool.all {
+		tx := pooled.tx
+		queued := pool.queue[tx.From] != nil && pool.queue[tx.From].get(tx.Nonce) == tx
+		if cheapest != nil {
+			switch fee(tx).Cmp(fee(cheapest)) {
+			case 1:
+				continue
+			case 0:
+				if cheapestQueued && !queued {
+					continue
+				}
+				if cheapestQueued == queued && tx.Nonce <= cheapest.Nonce {
+					continue
+				}
+			}
+		}
+		cheapest = tx
+		cheapestQueued = queued
+	}
+	return cheapest
+}
+
+func (pool *TxPool) bySenderNonce(account common.Address, nonce uint64) *utils.Transaction {
+	if pending := pool.pending[account]; pending != nil {
+		if tx := pending.get(nonce); tx != nil {
+			return tx
+		}
+	}
+	if queued := pool.queue[account]; queued != nil {
+		return queued.get(nonce)
+	}
+	return nil
+}
+
+// heldCost is what the transactions held for the account cost, leaving out the one using the nonce.
+func (pool *TxPool) heldCost(account common.Address, nonce uint64) *big.Int {
+	cost := new(big.Int)
+	for _, list := range []*txList{pool.pending[account], pool.queue[account]} {
+		if list == nil {
+			continue
+		}
+		for _, tx := range list.sorted() {
+			if tx.Nonce != nonce {
+				cost.Add(cost, tx.Cost())
+			}
+		}
+	}
+	return cost
+}
+
+func (pool *TxPool) accountLen(account common.Address) int {
+	count := 0
+	if pending := pool.pending[account]; pending != nil {
+		count += pending.len()
+	}
+	if queued := pool.queue[account]; queued != nil {
+		count += queued.len()
+	}
+	return count
+}
+
+// Get is the transaction with the hash, or nil if the pool doesn't hold it.
+func (pool *TxPool) Get(hash common.Hash) *utils.Transaction {
+	pool.lock.RLock()
+	defer pool.lock.RUnlock()
+	if pooled, exists := pool.all[hash]; exists {
+		return pooled.tx
+	}
+	return nil
+}
+
+// Remove drops the transaction with the hash from the pool, if it holds it.
+func (pool *TxPool) Remove(hash common.Hash) {
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
+	pool.remove(hash)
+}
+
+// Has is true if the pool holds a transaction with the hash.
+func (pool *TxPool) Has(hash common.Hash) bool {
+	return pool.Get(hash) != nil
+}
+
+// Stats are how many transactions are pending, and queued.
+func (pool *TxPool) Stats() (pending int, queued int) {
+	pool.lock.RLock()
+	defer pool.lock.RUnlock()
+	for _, list := range pool.pending {
+		pending += list.len()
+	}
+	for _, list := range pool.queue {
+		queued += list.len()
+	}
+	return pending, queued
+}
+
+// Pending is every pending transaction, by account, in nonce order.
+func (pool *TxPool) Pending() map[common.Address][]*utils.Transaction {
+	pool.lock.RLock()
+	defer pool.lock.RUnlock()
+	return content(pool.pending)
+}
+
+// Queued is every queued transaction, by account, in nonce order.
+func (pool *TxPool) Queued() map[common.Address][]*utils.Transaction {
+	pool.lock.RLock()
+	defer pool.lock.RUnlock()
+	return content(pool.queue)
+}
+
+// Executable is up to limit pending transactions for a block producer to use, the highest fees first, while keeping
+// the transactions of each account in nonce order.
+func (pool *TxPool) Executable(limit int) []*utils.Transaction {
+	byAccount := pool.Pending()
+	heads := make([]common.Address, 0, len(byAccount))
+	for account := range byAccount {
+		heads = append(heads, account)
+	}
+
+	var picked []*utils.Transaction
+	for len(picked) < limit && len(heads) > 0 {
+		sort.Slice(heads, func(i, j int) bool {
+			return fee(byAccount[heads[i]][0]).Cmp(fee(byAccount[heads[j]][0])) > 0
+		})
+		account := heads[0]
+		picked = append(picked, byAccount[account][0])
+		byAccount[account] = byAccount[account][1:]
+		if len(byAccount[account]) == 0 {
+			heads = heads[1:]
+		}
+	}
+	return picked
+}
+
+func content(lists map[common.Address]*txList) map[common.Address][]*utils.Transaction {
+	content := make(map[common.Address][]*utils.Transaction, len(lists))
+	for account, list := range lists {
+		content[account] = list.sorted()
+	}
+	return content
+}
+
+func fee(tx *utils.Transaction) *big.Int {
+	if tx.Fee == nil {
+		return new(big.Int)
+	}
+	return tx.Fee
+}
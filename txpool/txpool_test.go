@@ -0,0 +1,210 @@
+package txpool
+
+import (
+	"crypto/ecdsa"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/adamnite/go-adamnite/common"
+	"github.com/adamnite/go-adamnite/crypto"
+	"github.com/adamnite/go-adamnite/params"
+	"github.com/adamnite/go-adamnite/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+type testState struct {
+	balances map[common.Address]*big.Int
+	nonces   map[common.Address]uint64
+}
+
+func (s *testState) GetBalance(address common.Address) *big.Int {
+	if balance, exists := s.balances[address]; exists {
+		return balance
+	}
+	return new(big.Int)
+}
+
+func (s *testState) GetNonce(address common.Address) uint64 {
+	return s.nonces[address]
+}
+
+func newTestPool(config Config) (*TxPool, *testState) {
+	state := &testState{balances: make(map[common.Address]*big.Int), nonces: make(map[common.Address]uint64)}
+	return New(config, params.TestnetChainConfig, state, nil), state
+}
+
+// makes an account holding balance
+func newTestAccount(t *testing.T, state *testState, balance int64) *ecdsa.PrivateKey {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	state.balances[crypto.PubkeyToAddress(key.PublicKey)] = big.NewInt(balance)
+	return key
+}
+
+func signedTx(t *testing.T, key *ecdsa.PrivateKey, nonce uint64, fee int64) *utils.Transaction {
+	tx := &utils.Transaction{
+		From:    crypto.PubkeyToAddress(key.PublicKey),
+		To:      common.Address{1},
+		Amount:  big.NewInt(10),
+		Nonce:   nonce,
+		ChainID: params.TestnetChainConfig.ChainID,
+		Fee:     big.NewInt(fee),
+		Time:    time.Now(),
+	}
+	if err := tx.Sign(*key); err != nil {
+		t.Fatal(err)
+	}
+	return tx
+}
+
+func TestPoolNonceOrder(t *testing.T) {
+	pool, state := newTestPool(DefaultConfig)
+	defer pool.Stop()
+	events := make(chan NewTxsEvent, 4)
+	pool.SubscribeNewTxsEvent(events)
+	key := newTestAccount(t, state, 1000)
+
+	second := signedTx(t, key, 1, 1)
+	assert.NoError(t, pool.Add(second))
+	pending, queued := pool.Stats()
+	assert.Equal(t, 0, pending)
+	assert.Equal(t, 1, queued, "a transaction waiting on an earlier nonce should be queued")
+
+	first := signedTx(t, key, 0, 1)
+	assert.NoError(t, pool.Add(first))
+	pending, queued = pool.Stats()
+	assert.Equal(t, 2, pending)
+	assert.Equal(t, 0, queued)
+	assert.Equal(t, []*utils.Transaction{first, second}, pool.Pending()[first.From])
+
+	select {
+	case event := <-events:
+		assert.Equal(t, []*utils.Transaction{first, second}, event.Txs)
+	case <-time.After(time.Second):
+		t.Fatal("no event was sent for the pending transactions")
+	}
+	assert.True(t, pool.Has(first.Hash()))
+	assert.Equal(t, second, pool.Get(second.Hash()))
+}
+
+func TestPoolRejections(t *testing.T) {
+	pool, state := newTestPool(DefaultConfig)
+	defer pool.Stop()
+	key := newTestAccount(t, state, 20)
+	address := crypto.PubkeyToAddress(key.PublicKey)
+
+	tx := signedTx(t, key, 0, 1)
+	assert.NoError(t, pool.Add(tx))
+	assert.ErrorIs(t, pool.Add(tx), ErrAlreadyKnown)
+
+	assert.ErrorIs(t, pool.Add(signedTx(t, key, 1, 11)), ErrInsufficientFunds)
+	//11 is held already, so another 11 can't be covered either
+	assert.ErrorIs(t, pool.Add(signedTx(t, key, 1, 1)), ErrInsufficientFunds)
+
+	state.nonces[address] = 5
+	assert.ErrorIs(t, pool.Add(signedTx(t, key, 4, 1)), utils.ErrNonceUsed)
+
+	otherChain := signedTx(t, key, 5, 1)
+	otherChain.ChainID = big.NewInt(1)
+	if err := otherChain.Sign(*key); err != nil {
+		t.Fatal(err)
+	}
+	assert.ErrorIs(t, pool.Add(otherChain), utils.ErrWrongChain)
+
+	forged := signedTx(t, key, 6, 1)
+	forged.Amount = big.NewInt(9)
+	assert.ErrorIs(t, pool.Add(forged), utils.ErrIncorrectSigner)
+}
+
+func TestPoolReplacement(t *testing.T) {
+	pool, state := newTestPool(DefaultConfig)
+	defer pool.Stop()
+	key := newTestAccount(t, state, 1000)
+
+	original := signedTx(t, key, 0, 100)
+	assert.NoError(t, pool.Add(original))
+	assert.ErrorIs(t, pool.Add(signedTx(t, key, 0, 109)), ErrReplaceUnderpriced)
+
+	replacement := signedTx(t, key, 0, 110)
+	assert.NoError(t, pool.Add(replacement))
+	assert.False(t, pool.Has(original.Hash()), "the replaced transaction is still held")
+	assert.Equal(t, []*utils.Transaction{replacement}, pool.Pending()[replacement.From])
+
+	//a free transaction can't be replaced for free, over and over
+	free := newTestAccount(t, state, 1000)
+	assert.NoError(t, pool.Add(signedTx(t, free, 0, 0)))
+	again := signedTx(t, free, 0, 0)
+	again.Amount = big.NewInt(11)
+	if err := again.Sign(*free); err != nil {
+		t.Fatal(err)
+	}
+	assert.ErrorIs(t, pool.Add(again), ErrReplaceUnderpriced)
+	assert.NoError(t, pool.Add(signedTx(t, free, 0, 1)))
+}
+
+func TestPoolLimits(t *testing.T) {
+	pool, state := newTestPool(Config{AccountSlots: 2, GlobalSlots: 3, PriceBump: 10, Lifetime: time.Hour})
+	defer pool.Stop()
+	rich := newTestAccount(t, state, 1000)
+	assert.NoError(t, pool.Add(signedTx(t, rich, 0, 5)))
+	assert.NoError(t, pool.Add(signedTx(t, rich, 1, 5)))
+	assert.ErrorIs(t, pool.Add(signedTx(t, rich, 2, 5)), ErrAccountLimit)
+
+	cheap := newTestAccount(t, state, 1000)
+	cheapTx := signedTx(t, cheap, 0, 1)
+	assert.NoError(t, pool.Add(cheapTx))
+
+	//full, so the cheapest transaction makes way for one paying more, but not one paying the same
+	other := newTestAccount(t, state, 1000)
+	assert.ErrorIs(t, pool.Add(signedTx(t, other, 0, 1)), ErrUnderpriced)
+	assert.NoError(t, pool.Add(signedTx(t, other, 0, 2)))
+	assert.False(t, pool.Has(cheapTx.Hash()), "the cheapest transaction was not evicted")
+	pending, queued := pool.Stats()
+	assert.Equal(t, 3, pending+queued)
+}
+
+func TestPoolReset(t *testing.T) {
+	pool, state := newTestPool(DefaultConfig)
+	defer pool.Stop()
+	key := newTestAccount(t, state, 1000)
+	address := crypto.PubkeyToAddress(key.PublicKey)
+
+	first := signedTx(t, key, 0, 1)
+	third := signedTx(t, key, 2, 1)
+	assert.NoError(t, pool.Add(first))
+	assert.NoError(t, pool.Add(third))
+
+	//a block used the first two nonces, one of them sent elsewhere
+	state.nonces[address] = 2
+	pool.Reset()
+	assert.False(t, pool.Has(first.Hash()))
+	assert.Equal(t, []*utils.Transaction{third}, pool.Pending()[address])
+	assert.Empty(t, pool.Queued())
+
+	//funds spent elsewhere can't pay for what is held
+	state.balances[address] = big.NewInt(5)
+	pool.Reset()
+	pending, queued := pool.Stats()
+	assert.Equal(t, 0, pending+queued)
+}
+
+func TestPoolExecutable(t *testing.T) {
+	pool, state := newTestPool(DefaultConfig)
+	defer pool.Stop()
+	a := newTestAccount(t, state, 1000)
+	b := newTestAccount(t, state, 1000)
+
+	a0, a1 := signedTx(t, a, 0, 2), signedTx(t, a, 1, 9)
+	b0, b1 := signedTx(t, b, 0, 5), signedTx(t, b, 1, 1)
+	for _, tx := range []*utils.Transaction{a0, a1, b0, b1} {
+		assert.NoError(t, pool.Add(tx))
+	}
+	assert.NoError(t, pool.Add(signedTx(t, b, 3, 100)), "a queued transaction is never executable")
+
+	//the higher fee goes first, but never ahead of an earlier nonce from the same account
+	assert.Equal(t, []*utils.Transaction{b0, a0, a1, b1}, pool.Executable(10))
+	assert.Equal(t, []*utils.Transaction{b0, a0}, pool.Executable(2))
+}
@@ -43,7 +43,7 @@ func AccountFromPrivEcdsa(privKey *ecdsa.PrivateKey) Account {
 	return Account{
 		Address:    createAddress(publicKey.X.Bytes()),
 		PublicKey:  elliptic.Marshal(publicKey, publicKey.X, publicKey.Y),
-		privateKey: privKey.D.Bytes(),
+		privateKey: crypto.FromECDSA(privKey),
 		Balance:    big.NewInt(0),
 	}
 
@@ -162,7 +162,7 @@ func generateKeys() (rawPublicKey, rawPrivateKey []byte, err error) {
 
 	publicKey := privateKey.PublicKey
 
-	rawPrivateKey = privateKey.D.Bytes()
+	rawPrivateKey = crypto.FromECDSA(privateKey)
 	rawPublicKey = elliptic.Marshal(publicKey, publicKey.X, publicKey.Y)
 	return
 }
@@ -0,0 +1,199 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/rand"
+	"fmt"
+
+	"github.com/adamnite/go-adamnite/crypto"
+	"github.com/adamnite/go-adamnite/utils/accounts"
+	encoding "github.com/vmihailenco/msgpack/v5"
+)
+
+const (
+	CaesarChunkSize         = 256 * 1024       // how much of the attachment each chunk holds
+	MaxCaesarAttachmentSize = 32 * 1024 * 1024 // the largest attachment that can be sent
+	caesarChunkOverhead     = 12 + 16          // the GCM nonce and tag added to every chunk
+	MaxCaesarChunkSize      = CaesarChunkSize + caesarChunkOverhead
+)
+
+var (
+	ErrAttachmentTooLarge = fmt.Errorf("attachments can be at most %v bytes", MaxCaesarAttachmentSize)
+	ErrChunkTooLarge      = fmt.Errorf("chunks can be at most %v bytes", MaxCaesarChunkSize)
+	ErrChunkIntegrity     = fmt.Errorf("the chunk does not match its ID")
+	ErrInvalidManifest    = fmt.Errorf("the attachment manifest does not match the attachment")
+)
+
+// CaesarChunk is an encrypted piece of an attachment, or its manifest. Chunks are addressed by the hash of their
+// data, so whoever holds one can't change it without it being noticed.
+type CaesarChunk struct {
+	Data []byte // the nonce followed by the sealed content
+}
+
+// CaesarChunkID is the ID of a chunk with that data.
+func CaesarChunkID(data []byte) []byte {
+	return crypto.Sha512(data)
+}
+
+func (c CaesarChunk) ID() []byte {
+	return CaesarChunkID(c.Data)
+}
+
+// Check returns an error if the chunk is too large, or isn't the chunk with that ID.
+func (c CaesarChunk) Check(id []byte) error {
+	if len(c.Data) > MaxCaesarChunkSize {
+		return ErrChunkTooLarge
+	}
+	if !bytes.Equal(c.ID(), id) {
+		return ErrChunkIntegrity
+	}
+	return nil
+}
+
+// CaesarChunkShare is a chunk sent to a node that didn't ask for it, signed by the account sharing it, so that how much
+// each account has a node store can be limited.
+type CaesarChunkShare struct {
+	Chunk     CaesarChunk
+	From      accounts.Account
+	Signature []byte // of the ID of the chunk
+}
+
+// NewCaesarChunkShare signs the chunk for the account to share it.
+func NewCaesarChunkShare(from *accounts.Account, chunk CaesarChunk) (*CaesarChunkShare, error) {
+	signature, err := from.Sign(chunk.ID())
+	if err != nil {
+		return nil, err
+	}
+	return &CaesarChunkShare{
+		Chunk:     chunk,
+		From:      accounts.AccountFromPubBytes(from.PublicKey),
+		Signature: signature,
+	}, nil
+}
+
+// Verify is true if the chunk was signed by the account it's from.
+func (s CaesarChunkShare) Verify() bool {
+	return verifyOwner(s.From, s.Chunk.ID(), s.Signature)
+}
+
+// CaesarAttachmentManifest lists the chunks of an attachment, in order. It is sent as a chunk itself, encrypted with
+// the attachment key, and its ID is the ID in the attachment reference.
+type CaesarAttachmentManifest struct {
+	Size   uint64
+	Chunks [][]byte // the IDs of the chunks
+}
+
+// sealChunk encrypts the content with the attachment key.
+func sealChunk(key []byte, content []byte) (*CaesarChunk, error) {
+	gcm, err := newMessageCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return &CaesarChunk{Data: gcm.Seal(nonce, nonce, content, nil)}, nil
+}
+
+// openChunk checks the chunk is the one with that ID, and decrypts it with the attachment key.
+func openChunk(key []byte, id []byte, chunk CaesarChunk) ([]byte, error) {
+	if err := chunk.Check(id); err != nil {
+		return nil, err
+	}
+	gcm, err := newMessageCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(chunk.Data) < gcm.NonceSize() {
+		return nil, ErrChunkIntegrity
+	}
+	return gcm.Open(nil, chunk.Data[:gcm.NonceSize()], chunk.Data[gcm.NonceSize():], nil)
+}
+
+// NewCaesarAttachment splits the data into encrypted chunks under a new key. It returns the reference to send in an
+// attachment payload, and the chunks to store, the manifest being the last of them.
+func NewCaesarAttachment(name string, mimeType string, data []byte) (*CaesarAttachmentRef, []*CaesarChunk, error) {
+	if len(data) > MaxCaesarAttachmentSize {
+		return nil, nil, ErrAttachmentTooLarge
+	}
+	key := make([]byte, caesarMessageKeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, nil, err
+	}
+
+	manifest := CaesarAttachmentManifest{Size: uint64(len(data)), Chunks: [][]byte{}}
+	chunks := []*CaesarChunk{}
+	for start := 0; start < len(data); start += CaesarChunkSize {
+		end := start + CaesarChunkSize
+		if end > len(data) {
+			end = len(data)
+		}
+		chunk, err := sealChunk(key, data[start:end])
+		if err != nil {
+			return nil, nil, err
+		}
+		chunks = append(chunks, chunk)
+		manifest.Chunks = append(manifest.Chunks, chunk.ID())
+	}
+
+	packed, err := encoding.Marshal(&manifest)
+	if err != nil {
+		return nil, nil, err
+	}
+	manifestChunk, err := sealChunk(key, packed)
+	if err != nil {
+		return nil, nil, err
+	}
+	ref := CaesarAttachmentRef{
+		ID:       manifestChunk.ID(),
+		Name:     name,
+		MimeType: mimeType,
+		Size:     manifest.Size,
+		Key:      key,
+	}
+	return &ref, append(chunks, manifestChunk), nil
+}
+
+// OpenManifest checks the chunk is the manifest of the attachment, and reads it.
+func (ref CaesarAttachmentRef) OpenManifest(chunk CaesarChunk) (*CaesarAttachmentManifest, error) {
+	packed, err := openChunk(ref.Key, ref.ID, chunk)
+	if err != nil {
+		return nil, err
+	}
+	var manifest CaesarAttachmentManifest
+	if err := encoding.Unmarshal(packed, &manifest); err != nil {
+		return nil, err
+	}
+	if manifest.Size != ref.Size || manifest.Size > MaxCaesarAttachmentSize {
+		return nil, ErrInvalidManifest
+	}
+	if uint64(len(manifest.Chunks)) != (manifest.Size+CaesarChunkSize-1)/CaesarChunkSize {
+		return nil, ErrInvalidManifest
+	}
+	return &manifest, nil
+}
+
+// OpenChunk checks the chunk is the one with that ID, and decrypts the part of the attachment it holds.
+func (ref CaesarAttachmentRef) OpenChunk(id []byte, chunk CaesarChunk) ([]byte, error) {
+	return openChunk(ref.Key, id, chunk)
+}
+
+// Assemble joins the decrypted chunks of the manifest, checking they make up the whole attachment.
+func (m CaesarAttachmentManifest) Assemble(parts [][]byte) ([]byte, error) {
+	if len(parts) != len(m.Chunks) {
+		return nil, ErrInvalidManifest
+	}
+	data := make([]byte, 0, m.Size)
+	for i, part := range parts {
+		//every chunk but the last is full
+		if len(part) > CaesarChunkSize || (i != len(parts)-1 && len(part) != CaesarChunkSize) {
+			return nil, ErrInvalidManifest
+		}
+		data = append(data, part...)
+	}
+	if uint64(len(data)) != m.Size {
+		return nil, ErrInvalidManifest
+	}
+	return data, nil
+}
@@ -0,0 +1,118 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/rand"
+	"testing"
+
+	"github.com/adamnite/go-adamnite/utils/accounts"
+	"github.com/stretchr/testify/assert"
+)
+
+// openAttachment reads the attachment back from its chunks, the way a recipient would.
+func openAttachment(ref CaesarAttachmentRef, chunks []*CaesarChunk) ([]byte, error) {
+	byID := map[string]*CaesarChunk{}
+	for _, chunk := range chunks {
+		byID[string(chunk.ID())] = chunk
+	}
+	manifest, err := ref.OpenManifest(*byID[string(ref.ID)])
+	if err != nil {
+		return nil, err
+	}
+	parts := [][]byte{}
+	for _, id := range manifest.Chunks {
+		part, err := ref.OpenChunk(id, *byID[string(id)])
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, part)
+	}
+	return manifest.Assemble(parts)
+}
+
+func TestAttachmentChunks(t *testing.T) {
+	data := make([]byte, 2*CaesarChunkSize+100)
+	rand.Read(data)
+	ref, chunks, err := NewCaesarAttachment("photo.png", "image/png", data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, chunks, 4, "expected three chunks and a manifest")
+	assert.Equal(t, uint64(len(data)), ref.Size)
+	for _, chunk := range chunks {
+		assert.LessOrEqual(t, len(chunk.Data), MaxCaesarChunkSize)
+		assert.False(t, bytes.Contains(chunk.Data, data[:64]), "chunk is not encrypted")
+	}
+
+	opened, err := openAttachment(*ref, chunks)
+	assert.NoError(t, err)
+	assert.Equal(t, data, opened)
+
+	empty, emptyChunks, err := NewCaesarAttachment("empty", "text/plain", nil)
+	assert.NoError(t, err)
+	opened, err = openAttachment(*empty, emptyChunks)
+	assert.NoError(t, err)
+	assert.Empty(t, opened)
+
+	_, _, err = NewCaesarAttachment("huge", "", make([]byte, MaxCaesarAttachmentSize+1))
+	assert.ErrorIs(t, err, ErrAttachmentTooLarge)
+}
+
+func TestAttachmentIntegrity(t *testing.T) {
+	ref, chunks, err := NewCaesarAttachment("hello.txt", "text/plain", []byte("Hello World!"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	part, manifestChunk := chunks[0], chunks[1]
+	manifest, err := ref.OpenManifest(*manifestChunk)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tampered := CaesarChunk{Data: append([]byte{}, part.Data...)}
+	tampered.Data[len(tampered.Data)-1] ^= 1
+	_, err = ref.OpenChunk(manifest.Chunks[0], tampered)
+	assert.ErrorIs(t, err, ErrChunkIntegrity)
+
+	//swapping in a different chunk that matches its own ID is caught too
+	_, err = ref.OpenChunk(manifest.Chunks[0], *manifestChunk)
+	assert.ErrorIs(t, err, ErrChunkIntegrity)
+
+	//a manifest claiming another size doesn't match the reference
+	lying := *ref
+	lying.Size++
+	_, err = lying.OpenManifest(*manifestChunk)
+	assert.ErrorIs(t, err, ErrInvalidManifest)
+
+	//without the key, nothing can be read
+	wrongKey := *ref
+	wrongKey.Key = make([]byte, len(ref.Key))
+	_, err = wrongKey.OpenChunk(manifest.Chunks[0], *part)
+	assert.Error(t, err)
+
+	//the attachment travels in a typed payload
+	payload := NewAttachmentPayload(*ref)
+	packed, err := payload.Bytes()
+	assert.NoError(t, err)
+	parsed, err := ParseCaesarPayload(packed)
+	assert.NoError(t, err)
+	assert.Equal(t, ref, parsed.Attachment)
+}
+
+func TestChunkShareSigned(t *testing.T) {
+	sharer, _ := accounts.GenerateAccount()
+	other, _ := accounts.GenerateAccount()
+	share, err := NewCaesarChunkShare(sharer, CaesarChunk{Data: []byte{1, 2, 3}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, share.Verify())
+
+	swapped := *share
+	swapped.Chunk = CaesarChunk{Data: []byte{4}}
+	assert.False(t, swapped.Verify(), "the signature was for another chunk")
+
+	claimed := *share
+	claimed.From = accounts.AccountFromPubBytes(other.PublicKey)
+	assert.False(t, claimed.Verify(), "the chunk was shared by another account")
+}
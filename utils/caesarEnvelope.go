@@ -0,0 +1,116 @@
+package utils
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"fmt"
+
+	"github.com/adamnite/go-adamnite/common"
+	"github.com/adamnite/go-adamnite/utils/accounts"
+	encoding "github.com/vmihailenco/msgpack/v5"
+)
+
+const (
+	// CaesarVersionDirect messages are ECIES encrypted straight to the recipient, so only they can read them.
+	CaesarVersionDirect uint8 = iota
+	// CaesarVersionEnvelope messages are sealed with a random message key, which is ECIES wrapped for every reader.
+	CaesarVersionEnvelope
+	// CaesarVersionGroup messages are sent to a group, and sealed with the group key of an epoch.
+	CaesarVersionGroup
+	// CaesarVersionGroupUpdate messages carry a new state of a group, and are readable by anyone.
+	CaesarVersionGroupUpdate
+
+	caesarMessageKeySize = 32
+)
+
+var ErrNotAReader = fmt.Errorf("account is not one of the readers of this message")
+
+// wrappedKey is the message key, encrypted to a single reader.
+type wrappedKey struct {
+	Reader common.Address
+	Key    []byte
+}
+
+// caesarEnvelope is what the Message of a CaesarVersionEnvelope message holds.
+type caesarEnvelope struct {
+	Nonce      []byte
+	Ciphertext []byte
+	Keys       []wrappedKey
+}
+
+// sealEnvelope encrypts the message once, and wraps the key for every reader. Readers are deduplicated, so
+// sending to yourself only wraps the key once.
+func sealEnvelope(message []byte, readers []accounts.Account) ([]byte, error) {
+	messageKey := make([]byte, caesarMessageKeySize)
+	if _, err := rand.Read(messageKey); err != nil {
+		return nil, err
+	}
+	gcm, err := newMessageCipher(messageKey)
+	if err != nil {
+		return nil, err
+	}
+	envelope := caesarEnvelope{Nonce: make([]byte, gcm.NonceSize())}
+	if _, err := rand.Read(envelope.Nonce); err != nil {
+		return nil, err
+	}
+	envelope.Ciphertext = gcm.Seal(nil, envelope.Nonce, message, nil)
+
+	wrapped := map[common.Address]bool{}
+	for _, reader := range readers {
+		if wrapped[reader.Address] {
+			continue
+		}
+		key, err := reader.Encrypt(messageKey)
+		if err != nil {
+			return nil, err
+		}
+		envelope.Keys = append(envelope.Keys, wrappedKey{Reader: reader.Address, Key: key})
+		wrapped[reader.Address] = true
+	}
+	return encoding.Marshal(&envelope)
+}
+
+// openEnvelope unwraps the message key with the reader's private key, and decrypts the message.
+func openEnvelope(sealed []byte, reader accounts.Account) ([]byte, error) {
+	var envelope caesarEnvelope
+	if err := encoding.Unmarshal(sealed, &envelope); err != nil {
+		return nil, err
+	}
+	for _, wrapped := range envelope.Keys {
+		if wrapped.Reader != reader.Address {
+			continue
+		}
+		messageKey, err := reader.Decrypt(wrapped.Key)
+		if err != nil {
+			return nil, err
+		}
+		gcm, err := newMessageCipher(messageKey)
+		if err != nil {
+			return nil, err
+		}
+		return gcm.Open(nil, envelope.Nonce, envelope.Ciphertext, nil)
+	}
+	return nil, ErrNotAReader
+}
+
+// envelopeReaders lists the addresses the message key was wrapped for.
+func envelopeReaders(sealed []byte) ([]common.Address, error) {
+	var envelope caesarEnvelope
+	if err := encoding.Unmarshal(sealed, &envelope); err != nil {
+		return nil, err
+	}
+	readers := []common.Address{}
+	for _, wrapped := range envelope.Keys {
+		readers = append(readers, wrapped.Reader)
+	}
+	return readers, nil
+}
+
+func newMessageCipher(messageKey []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(messageKey)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,306 @@
+package utils
+
+import (
+	"crypto/rand"
+	"fmt"
+	"time"
+
+	"github.com/adamnite/go-adamnite/common"
+	"github.com/adamnite/go-adamnite/crypto"
+	"github.com/adamnite/go-adamnite/utils/accounts"
+	encoding "github.com/vmihailenco/msgpack/v5"
+)
+
+const caesarGroupNonceSize = 16
+
+var (
+	ErrGroupMessage       = fmt.Errorf("group messages are read with the group key")
+	ErrNotAGroupMember    = fmt.Errorf("account is not a member of the group")
+	ErrNotGroupAdmin      = fmt.Errorf("only the group admin can change the members of the group")
+	ErrGroupEpoch         = fmt.Errorf("group update is not for the next epoch of the group")
+	ErrInvalidGroupUpdate = fmt.Errorf("group update is invalid")
+)
+
+// CaesarGroup is the state of a group conversation at one epoch. Every change to the members starts a new epoch,
+// with a new group key, so members that were removed can't read what is sent after they left.
+type CaesarGroup struct {
+	ID      common.Address // derived from the public key of the creator and the nonce
+	Nonce   []byte
+	Name    string
+	Admin   common.Address // the member that adds and removes others, and rotates the key when someone leaves
+	Members []accounts.Account
+	Epoch   uint64
+	Keys    []wrappedKey // the group key of this epoch, wrapped for every member. Empty until the admin rotates it, after a member leaves
+}
+
+// groupID is the ID of a group created by the account with that public key.
+func groupID(creatorPublicKey []byte, nonce []byte) common.Address {
+	return common.BytesToAddress(crypto.Sha512(append(append([]byte{}, creatorPublicKey...), nonce...)))
+}
+
+// NewCaesarGroup creates a group with admin as its admin and first member, and returns it along with its first group key.
+func NewCaesarGroup(name string, admin accounts.Account, members ...accounts.Account) (*CaesarGroup, []byte, error) {
+	nonce := make([]byte, caesarGroupNonceSize)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, nil, err
+	}
+	g := CaesarGroup{
+		ID:    groupID(admin.PublicKey, nonce),
+		Nonce: nonce,
+		Name:  name,
+		Admin: admin.Address,
+	}
+	for _, member := range append([]accounts.Account{admin}, members...) {
+		if !g.IsMember(member.Address) {
+			g.Members = append(g.Members, accounts.AccountFromPubBytes(member.PublicKey))
+		}
+	}
+	key, err := g.Rotate()
+	if err != nil {
+		return nil, nil, err
+	}
+	return &g, key, nil
+}
+
+// Account is what group messages are sent To, so the group history can be looked up by the group ID.
+func (g CaesarGroup) Account() accounts.Account {
+	return accounts.Account{Address: g.ID}
+}
+
+func (g CaesarGroup) IsMember(address common.Address) bool {
+	_, isMember := g.Member(address)
+	return isMember
+}
+
+// Member returns the member with that address.
+func (g CaesarGroup) Member(address common.Address) (accounts.Account, bool) {
+	for _, member := range g.Members {
+		if member.Address == address {
+			return member, true
+		}
+	}
+	return accounts.Account{}, false
+}
+
+// Next returns the group with the next epoch, the members given and no key. The admin is kept if they
+// are still a member, otherwise the first member left takes over.
+func (g CaesarGroup) Next(members []accounts.Account) *CaesarGroup {
+	next := g
+	next.Epoch = g.Epoch + 1
+	next.Members = append([]accounts.Account{}, members...)
+	next.Keys = nil
+	if !next.IsMember(g.Admin) && len(next.Members) != 0 {
+		next.Admin = next.Members[0].Address
+	}
+	return &next
+}
+
+// Without returns the members of the group, apart from the one with that address.
+func (g CaesarGroup) Without(address common.Address) []accounts.Account {
+	members := []accounts.Account{}
+	for _, member := range g.Members {
+		if member.Address != address {
+			members = append(members, member)
+		}
+	}
+	return members
+}
+
+// Rotate creates a new group key for this epoch and wraps it for every member.
+func (g *CaesarGroup) Rotate() ([]byte, error) {
+	key := make([]byte, caesarMessageKeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	g.Keys = []wrappedKey{}
+	for _, member := range g.Members {
+		wrapped, err := member.Encrypt(key)
+		if err != nil {
+			return nil, err
+		}
+		g.Keys = append(g.Keys, wrappedKey{Reader: member.Address, Key: wrapped})
+	}
+	return key, nil
+}
+
+// OpenKey unwraps the group key of this epoch with the private key of the member.
+func (g CaesarGroup) OpenKey(member accounts.Account) ([]byte, error) {
+	for _, wrapped := range g.Keys {
+		if wrapped.Reader == member.Address {
+			return member.Decrypt(wrapped.Key)
+		}
+	}
+	return nil, ErrNotAGroupMember
+}
+
+// checkKeys makes sure the key was wrapped for every member, and no one else.
+func (g CaesarGroup) checkKeys() error {
+	if len(g.Keys) != len(g.Members) {
+		return ErrInvalidGroupUpdate
+	}
+	for i, wrapped := range g.Keys {
+		if wrapped.Reader != g.Members[i].Address {
+			return ErrInvalidGroupUpdate
+		}
+	}
+	return nil
+}
+
+// CheckGenesis returns an error if the group can't be the first epoch of a group created by from.
+func (g CaesarGroup) CheckGenesis(from common.Address) error {
+	if g.Epoch != 0 || g.Admin != from {
+		return ErrInvalidGroupUpdate
+	}
+	admin, isMember := g.Member(from)
+	if !isMember || g.ID != groupID(admin.PublicKey, g.Nonce) {
+		return ErrInvalidGroupUpdate
+	}
+	return g.checkKeys()
+}
+
+// CheckUpdate returns an error if from can't move the group to update. The admin can change the members as they
+// like, while any other member can only leave. Whoever leaves can't choose the key the others use afterwards,
+// so those updates have no key, and the (possibly new) admin rotates it.
+func (g CaesarGroup) CheckUpdate(update CaesarGroup, from common.Address) error {
+	if update.ID != g.ID {
+		return ErrInvalidGroupUpdate
+	}
+	if update.Epoch != g.Epoch+1 {
+		return ErrGroupEpoch
+	}
+	if !g.IsMember(from) {
+		return ErrNotAGroupMember
+	}
+	if len(update.Members) != 0 && !update.IsMember(update.Admin) {
+		return ErrInvalidGroupUpdate
+	}
+	if from != g.Admin {
+		if update.Admin != g.Admin || !sameMembers(update.Members, g.Without(from)) {
+			return ErrNotGroupAdmin
+		}
+	}
+	if !update.IsMember(from) {
+		if len(update.Keys) != 0 {
+			return ErrInvalidGroupUpdate
+		}
+		return nil
+	}
+	return update.checkKeys()
+}
+
+func sameMembers(a, b []accounts.Account) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Address != b[i].Address {
+			return false
+		}
+	}
+	return true
+}
+
+// NewCaesarGroupUpdate creates a signed message sharing the new state of the group with its members.
+func NewCaesarGroupUpdate(group CaesarGroup, from accounts.Account) (*CaesarMessage, error) {
+	packed, err := encoding.Marshal(&group)
+	if err != nil {
+		return nil, err
+	}
+	c := CaesarMessage{
+		To:          group.Account(),
+		From:        from,
+		InitialTime: time.Now().UnixMicro(),
+		Message:     packed,
+		Version:     CaesarVersionGroupUpdate,
+		Protocol:    CaesarProtocolCurrent,
+	}
+	err = c.Sign()
+	return &c, err
+}
+
+// GetGroupUpdate reads the group state carried by a group update message.
+func (cm CaesarMessage) GetGroupUpdate() (*CaesarGroup, error) {
+	if cm.Version != CaesarVersionGroupUpdate {
+		return nil, ErrInvalidGroupUpdate
+	}
+	var group CaesarGroup
+	if err := encoding.Unmarshal(cm.Message, &group); err != nil {
+		return nil, err
+	}
+	if group.ID != cm.To.Address {
+		return nil, ErrInvalidGroupUpdate
+	}
+	return &group, nil
+}
+
+// groupCiphertext is what the Message of a CaesarVersionGroup message holds.
+type groupCiphertext struct {
+	Epoch      uint64 // the epoch of the key it was sealed with
+	Nonce      []byte
+	Ciphertext []byte
+}
+
+// NewCaesarGroupMessage creates a message to the group, sealed with the group key of the epoch given.
+func NewCaesarGroupMessage(group CaesarGroup, epoch uint64, key []byte, from accounts.Account, message interface{}) (*CaesarMessage, error) {
+	messageData, err := messageBytes(message)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := newMessageCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	sealed := groupCiphertext{Epoch: epoch, Nonce: make([]byte, gcm.NonceSize())}
+	if _, err := rand.Read(sealed.Nonce); err != nil {
+		return nil, err
+	}
+	sealed.Ciphertext = gcm.Seal(nil, sealed.Nonce, messageData, nil)
+	packed, err := encoding.Marshal(&sealed)
+	if err != nil {
+		return nil, err
+	}
+
+	c := CaesarMessage{
+		To:          group.Account(),
+		From:        from,
+		InitialTime: time.Now().UnixMicro(),
+		Message:     packed,
+		Version:     CaesarVersionGroup,
+		Protocol:    CaesarProtocolCurrent,
+	}
+	err = c.Sign()
+	return &c, err
+}
+
+// GroupEpoch returns the epoch of the group key a group message was sealed with.
+func (cm CaesarMessage) GroupEpoch() (uint64, error) {
+	sealed, err := cm.groupCiphertext()
+	if err != nil {
+		return 0, err
+	}
+	return sealed.Epoch, nil
+}
+
+// GetGroupMessage decrypts a group message with the group key of its epoch.
+func (cm CaesarMessage) GetGroupMessage(key []byte) ([]byte, error) {
+	sealed, err := cm.groupCiphertext()
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := newMessageCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return gcm.Open(nil, sealed.Nonce, sealed.Ciphertext, nil)
+}
+
+func (cm CaesarMessage) groupCiphertext() (*groupCiphertext, error) {
+	if cm.Version != CaesarVersionGroup {
+		return nil, fmt.Errorf("not a group message")
+	}
+	var sealed groupCiphertext
+	if err := encoding.Unmarshal(cm.Message, &sealed); err != nil {
+		return nil, err
+	}
+	return &sealed, nil
+}
@@ -0,0 +1,80 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/adamnite/go-adamnite/utils/accounts"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGroupUpdates(t *testing.T) {
+	admin, _ := accounts.GenerateAccount()
+	member, _ := accounts.GenerateAccount()
+	outsider, _ := accounts.GenerateAccount()
+
+	group, key, err := NewCaesarGroup("test", *admin, accounts.AccountFromPubBytes(member.PublicKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NoError(t, group.CheckGenesis(admin.Address))
+	assert.Error(t, group.CheckGenesis(member.Address), "only the creator can create the group")
+	memberKey, err := group.OpenKey(*member)
+	assert.NoError(t, err)
+	assert.Equal(t, key, memberKey)
+	_, err = group.OpenKey(*outsider)
+	assert.ErrorIs(t, err, ErrNotAGroupMember)
+
+	//the admin adds someone
+	added := group.Next(append(group.Members, accounts.AccountFromPubBytes(outsider.PublicKey)))
+	_, err = added.Rotate()
+	assert.NoError(t, err)
+	assert.NoError(t, group.CheckUpdate(*added, admin.Address))
+	assert.ErrorIs(t, group.CheckUpdate(*added, member.Address), ErrNotGroupAdmin)
+	assert.ErrorIs(t, group.CheckUpdate(*added, outsider.Address), ErrNotAGroupMember)
+	assert.ErrorIs(t, added.CheckUpdate(*added, admin.Address), ErrGroupEpoch)
+
+	//someone leaves, and has to leave the rotation to the admin
+	left := group.Next(group.Without(member.Address))
+	assert.NoError(t, group.CheckUpdate(*left, member.Address))
+	_, err = left.Rotate()
+	assert.NoError(t, err)
+	assert.ErrorIs(t, group.CheckUpdate(*left, member.Address), ErrInvalidGroupUpdate, "whoever leaves can't pick the next key")
+
+	//the admin leaving hands the group to the next member
+	adminLeft := group.Next(group.Without(admin.Address))
+	assert.Equal(t, member.Address, adminLeft.Admin)
+	assert.NoError(t, group.CheckUpdate(*adminLeft, admin.Address))
+}
+
+func TestGroupMessaging(t *testing.T) {
+	admin, _ := accounts.GenerateAccount()
+	member, _ := accounts.GenerateAccount()
+	group, key, err := NewCaesarGroup("test", *admin, accounts.AccountFromPubBytes(member.PublicKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	update, err := NewCaesarGroupUpdate(*group, *admin)
+	assert.NoError(t, err)
+	assert.True(t, update.Verify())
+	sharedGroup, err := update.GetGroupUpdate()
+	assert.NoError(t, err)
+	assert.Equal(t, group.ID, sharedGroup.ID)
+	assert.NoError(t, sharedGroup.CheckGenesis(update.From.Address))
+
+	msg, err := NewCaesarGroupMessage(*group, group.Epoch, key, *member, "Hello World!")
+	assert.NoError(t, err)
+	assert.True(t, msg.Verify())
+	assert.Equal(t, group.ID, msg.To.Address)
+	_, err = msg.GetMessage(*admin)
+	assert.ErrorIs(t, err, ErrGroupMessage)
+
+	epoch, err := msg.GroupEpoch()
+	assert.NoError(t, err)
+	assert.Equal(t, group.Epoch, epoch)
+	text, err := msg.GetGroupMessage(key)
+	assert.NoError(t, err)
+	assert.Equal(t, "Hello World!", string(text))
+	_, err = msg.GetGroupMessage(make([]byte, len(key)))
+	assert.Error(t, err)
+}
@@ -0,0 +1,124 @@
+package utils
+
+import (
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	"github.com/adamnite/go-adamnite/common"
+	"github.com/adamnite/go-adamnite/utils/accounts"
+)
+
+// MailboxRequestWindow is how far the time of a mailbox request can be from the time of the mailbox node,
+// so a request that was overheard can't be replayed later.
+const MailboxRequestWindow = 5 * time.Minute
+
+var (
+	ErrMailboxRequestExpired = fmt.Errorf("the mailbox request was not signed recently")
+	ErrMailboxRequestInvalid = fmt.Errorf("the mailbox request is not signed by the owner of the mailbox")
+)
+
+// CaesarMailboxRecord advertises the nodes holding messages for an account while it is offline.
+// A newer record from the same owner replaces any older one.
+type CaesarMailboxRecord struct {
+	Owner     accounts.Account
+	Mailboxes []common.Address // the node IDs of the mailbox nodes
+	IssuedAt  int64            // unix micro
+	Signature []byte
+}
+
+// NewCaesarMailboxRecord creates a record, signed by owner, naming the mailboxes as where to leave messages for them.
+func NewCaesarMailboxRecord(owner accounts.Account, mailboxes ...common.Address) (*CaesarMailboxRecord, error) {
+	r := CaesarMailboxRecord{
+		Owner:     owner,
+		Mailboxes: mailboxes,
+		IssuedAt:  time.Now().UnixMicro(),
+	}
+	signature, err := owner.Sign(r.signingPayload())
+	if err != nil {
+		return nil, err
+	}
+	r.Signature = signature
+	return &r, nil
+}
+
+func (r CaesarMailboxRecord) signingPayload() []byte {
+	payload := append([]byte("caesar-mailbox-record"), r.Owner.PublicKey...)
+	payload = binary.BigEndian.AppendUint64(payload, uint64(r.IssuedAt))
+	for _, mailbox := range r.Mailboxes {
+		payload = append(payload, mailbox.Bytes()...)
+	}
+	return payload
+}
+
+// Verify checks the record was signed by its owner.
+func (r CaesarMailboxRecord) Verify() bool {
+	return verifyOwner(r.Owner, r.signingPayload(), r.Signature)
+}
+
+// HasMailbox reports whether the node is one of the mailboxes of the record.
+func (r CaesarMailboxRecord) HasMailbox(node common.Address) bool {
+	for _, mailbox := range r.Mailboxes {
+		if mailbox == node {
+			return true
+		}
+	}
+	return false
+}
+
+// CaesarMailboxRequest is sent by the owner of a mailbox to fetch the messages held for them, or, with the hashes
+// of the messages they received, to let the mailbox node drop them.
+type CaesarMailboxRequest struct {
+	Owner     accounts.Account
+	Time      int64 // unix micro
+	Hashes    [][]byte
+	Signature []byte
+}
+
+// NewCaesarMailboxRequest creates a request signed by owner. Passing no hashes fetches the messages held.
+func NewCaesarMailboxRequest(owner accounts.Account, hashes ...[]byte) (*CaesarMailboxRequest, error) {
+	r := CaesarMailboxRequest{
+		Owner:  owner,
+		Time:   time.Now().UnixMicro(),
+		Hashes: hashes,
+	}
+	signature, err := owner.Sign(r.signingPayload())
+	if err != nil {
+		return nil, err
+	}
+	r.Signature = signature
+	return &r, nil
+}
+
+func (r CaesarMailboxRequest) signingPayload() []byte {
+	payload := append([]byte("caesar-mailbox-request"), r.Owner.PublicKey...)
+	payload = binary.BigEndian.AppendUint64(payload, uint64(r.Time))
+	for _, hash := range r.Hashes {
+		payload = binary.BigEndian.AppendUint32(payload, uint32(len(hash)))
+		payload = append(payload, hash...)
+	}
+	return payload
+}
+
+// Verify checks the request was signed by its owner, within MailboxRequestWindow of now.
+func (r CaesarMailboxRequest) Verify(now time.Time) error {
+	signedAt := time.UnixMicro(r.Time)
+	if signedAt.Before(now.Add(-MailboxRequestWindow)) || signedAt.After(now.Add(MailboxRequestWindow)) {
+		return ErrMailboxRequestExpired
+	}
+	if !verifyOwner(r.Owner, r.signingPayload(), r.Signature) {
+		return ErrMailboxRequestInvalid
+	}
+	return nil
+}
+
+// verifyOwner checks the signature is from the account, and that its address belongs to its public key.
+func verifyOwner(owner accounts.Account, data []byte, signature []byte) bool {
+	if len(signature) < 64 || len(owner.PublicKey) == 0 {
+		return false
+	}
+	if accounts.AccountFromPubBytes(owner.PublicKey).Address != owner.Address {
+		return false
+	}
+	return owner.Verify(data, signature)
+}
@@ -0,0 +1,33 @@
+package utils
+
+import (
+	"testing"
+	"time"
+
+	"github.com/adamnite/go-adamnite/common"
+	"github.com/adamnite/go-adamnite/utils/accounts"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMailboxRequests(t *testing.T) {
+	owner, _ := accounts.GenerateAccount()
+	request, err := NewCaesarMailboxRequest(*owner, []byte{1, 2, 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NoError(t, request.Verify(time.Now()))
+	assert.ErrorIs(t, request.Verify(time.Now().Add(2*MailboxRequestWindow)), ErrMailboxRequestExpired)
+
+	changed := *request
+	changed.Hashes = [][]byte{{1, 2, 4}}
+	assert.ErrorIs(t, changed.Verify(time.Now()), ErrMailboxRequestInvalid)
+
+	record, err := NewCaesarMailboxRecord(*owner, common.Address{1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, record.Verify())
+	assert.True(t, record.HasMailbox(common.Address{1}))
+	record.Mailboxes = append(record.Mailboxes, common.Address{2})
+	assert.False(t, record.Verify())
+}
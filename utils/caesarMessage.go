@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/adamnite/go-adamnite/common"
 	"github.com/adamnite/go-adamnite/crypto"
 	"github.com/adamnite/go-adamnite/utils/accounts"
 )
@@ -15,30 +16,47 @@ type CaesarMessage struct {
 	InitialTime      int64
 	Message          []byte
 	Signature        []byte
-	HasHostingServer bool //is this to a nodeID, who would have a server running directly (instead of needing to be shared to everyone)
+	HasHostingServer bool  //is this to a nodeID, who would have a server running directly (instead of needing to be shared to everyone)
+	Version          uint8 `msgpack:",omitempty"` //how Message is encrypted. Messages from before versioning decode as CaesarVersionDirect
+	Protocol         uint8 `msgpack:",omitempty"` //what the signature and hash cover. Messages from before this decode as CaesarProtocolLegacy
 }
 
-// NewCaesarMessage creates a new Caesar message
-func NewCaesarMessage(to accounts.Account, from accounts.Account, message interface{}) (*CaesarMessage, error) {
+const (
+	// CaesarProtocolLegacy messages only sign the ciphertext, and are hashed by the two public keys and the time.
+	CaesarProtocolLegacy uint8 = iota
+	// CaesarProtocolSigningPayload messages sign and hash the whole SigningPayload.
+	CaesarProtocolSigningPayload
+
+	// CaesarProtocolCurrent is the protocol new messages are created with.
+	CaesarProtocolCurrent = CaesarProtocolSigningPayload
+)
+
+var ErrLegacyProtocol = fmt.Errorf("messages using the legacy protocol only sign their ciphertext, and are no longer accepted")
+
+// flags packed into the signing payload
+const (
+	caesarFlagHostingServer uint8 = 1 << iota
+)
+
+// NewCaesarMessage creates a new Caesar message that both parties can read, along with any extra readers
+// (such as the other devices of either party).
+func NewCaesarMessage(to accounts.Account, from accounts.Account, message interface{}, extraReaders ...accounts.Account) (*CaesarMessage, error) {
 	c := CaesarMessage{
 		To:               to,
 		From:             from,
 		InitialTime:      time.Now().UnixMicro(),
 		HasHostingServer: false,
+		Version:          CaesarVersionEnvelope,
+		Protocol:         CaesarProtocolCurrent,
 	}
 
-	// get the message from a variance of types
-	var messageData []byte
-	switch v := message.(type) {
-	case string:
-		messageData = []byte(v)
-	case []byte:
-		messageData = v
-	default:
-		return nil, fmt.Errorf("Unsupported message type: should be either byte array or string")
+	messageData, err := messageBytes(message)
+	if err != nil {
+		return nil, err
 	}
 
-	encryptedMessage, err := to.Encrypt(messageData)
+	readers := append([]accounts.Account{to, from}, extraReaders...)
+	encryptedMessage, err := sealEnvelope(messageData, readers)
 	if err != nil {
 		return nil, err
 	}
@@ -48,6 +66,19 @@ func NewCaesarMessage(to accounts.Account, from accounts.Account, message interf
 	return &c, err
 }
 
+// get the message from a variance of types
+func messageBytes(message interface{}) ([]byte, error) {
+	switch v := message.(type) {
+	case string:
+		return []byte(v), nil
+	case []byte:
+		return v, nil
+	case CaesarPayload:
+		return v.Bytes()
+	}
+	return nil, fmt.Errorf("Unsupported message type: should be either byte array, string or CaesarPayload")
+}
+
 // NewCaesarMessage creates a new Caesar message with signature set
 func NewSignedCaesarMessage(to accounts.Account, from accounts.Account, message []byte, signature []byte) *CaesarMessage {
 	return &CaesarMessage{
@@ -60,35 +91,98 @@ func NewSignedCaesarMessage(to accounts.Account, from accounts.Account, message
 	}
 }
 
+// SigningPayload is the canonical encoding of everything the signature covers: the protocol, both parties, the time,
+// the flags, the encryption version and the ciphertext. Variable length fields are length prefixed, so no two
+// messages share a payload.
+func (cm CaesarMessage) SigningPayload() []byte {
+	var flags uint8
+	if cm.HasHostingServer {
+		flags |= caesarFlagHostingServer
+	}
+	payload := []byte{cm.Protocol, cm.Version, flags}
+	for _, field := range [][]byte{cm.To.Address.Bytes(), cm.To.PublicKey, cm.From.Address.Bytes(), cm.From.PublicKey} {
+		payload = binary.BigEndian.AppendUint32(payload, uint32(len(field)))
+		payload = append(payload, field...)
+	}
+	payload = binary.BigEndian.AppendUint64(payload, uint64(cm.InitialTime))
+	payload = binary.BigEndian.AppendUint32(payload, uint32(len(cm.Message)))
+	return append(payload, cm.Message...)
+}
+
 // Hash the message
 func (cm CaesarMessage) Hash() []byte {
-	ans := append(cm.To.PublicKey, cm.From.PublicKey...)
-	ans = binary.LittleEndian.AppendUint64(ans, uint64(cm.InitialTime))
-	return crypto.Sha512(ans)
+	if cm.Protocol == CaesarProtocolLegacy {
+		ans := append(append([]byte{}, cm.To.PublicKey...), cm.From.PublicKey...)
+		ans = binary.LittleEndian.AppendUint64(ans, uint64(cm.InitialTime))
+		return crypto.Sha512(ans)
+	}
+	return crypto.Sha512(cm.SigningPayload())
+}
+
+// signedData is what the signature of the message is over, depending on its protocol
+func (cm CaesarMessage) signedData() []byte {
+	if cm.Protocol == CaesarProtocolLegacy {
+		return cm.Message
+	}
+	return cm.SigningPayload()
 }
 
 // Sign the message with the From Account
 func (cm *CaesarMessage) Sign() error {
-	newSignature, err := cm.From.Sign(cm.Message)
+	newSignature, err := cm.From.Sign(cm.signedData())
 	if err == nil {
 		cm.Signature = newSignature
 	}
 	return err
 }
 
+// Verify checks that the message was signed by the sender, and that the sender address belongs to their public key.
 func (cm CaesarMessage) Verify() bool {
-	return cm.From.Verify(cm.Message, cm.Signature)
+	if cm.Protocol > CaesarProtocolCurrent {
+		return false
+	}
+	return verifyOwner(cm.From, cm.signedData(), cm.Signature)
 }
 
-// get the message contents by decrypting it
-func (cm CaesarMessage) GetMessage(recipient accounts.Account) ([]byte, error) {
-	return recipient.Decrypt(cm.Message)
+// VerifyNew is Verify for messages just received. The legacy protocol leaves everything but the ciphertext unsigned,
+// so it is refused, and only read from the history already stored.
+func (cm CaesarMessage) VerifyNew() bool {
+	return cm.Protocol != CaesarProtocolLegacy && cm.Verify()
 }
 
-// get the message contents by decrypting it
+// get the message contents by decrypting it. Either party can do so, as well as any extra reader it was sent to.
+func (cm CaesarMessage) GetMessage(reader accounts.Account) ([]byte, error) {
+	switch cm.Version {
+	case CaesarVersionDirect:
+		return reader.Decrypt(cm.Message)
+	case CaesarVersionEnvelope:
+		return openEnvelope(cm.Message, reader)
+	case CaesarVersionGroup, CaesarVersionGroupUpdate:
+		return nil, ErrGroupMessage
+	}
+	return nil, fmt.Errorf("unsupported Caesar message version %v", cm.Version)
+}
+
+// Readers returns the addresses that can decrypt the message.
+func (cm CaesarMessage) Readers() ([]common.Address, error) {
+	switch cm.Version {
+	case CaesarVersionDirect:
+		return []common.Address{cm.To.Address}, nil
+	case CaesarVersionEnvelope:
+		return envelopeReaders(cm.Message)
+	case CaesarVersionGroup, CaesarVersionGroupUpdate:
+		return nil, ErrGroupMessage
+	}
+	return nil, fmt.Errorf("unsupported Caesar message version %v", cm.Version)
+}
+
+// get the text of the message by decrypting it. Payloads that aren't text or edits have no text.
 func (cm CaesarMessage) GetMessageString(recipient accounts.Account) (string, error) {
-	msg, err := cm.GetMessage(recipient)
-	return string(msg), err
+	payload, err := cm.GetPayload(recipient)
+	if err != nil {
+		return "", err
+	}
+	return payload.Text, nil
 }
 func (cm CaesarMessage) GetTime() time.Time {
 	return time.UnixMicro(cm.InitialTime)
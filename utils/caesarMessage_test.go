@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"testing"
 
+	"github.com/adamnite/go-adamnite/common"
 	"github.com/adamnite/go-adamnite/utils/accounts"
 	"github.com/stretchr/testify/assert"
 )
@@ -77,3 +78,109 @@ func TestLongMessaging(t *testing.T) {
 	}
 	assert.Equal(t, testMessage, ansMsg, "message not fully answered as the same")
 }
+
+func TestSenderAndDevicesCanRead(t *testing.T) {
+	sender, _ := accounts.GenerateAccount()
+	receiver, _ := accounts.GenerateAccount()
+	device, _ := accounts.GenerateAccount()
+	outsider, _ := accounts.GenerateAccount()
+
+	testMessage := "Hello World!"
+	msg, err := NewCaesarMessage(
+		accounts.AccountFromPubBytes(receiver.PublicKey),
+		*sender,
+		testMessage,
+		accounts.AccountFromPubBytes(device.PublicKey),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, CaesarVersionEnvelope, msg.Version)
+	assert.True(t, msg.Verify())
+
+	for _, reader := range []*accounts.Account{sender, receiver, device} {
+		ansMsg, err := msg.GetMessageString(*reader)
+		assert.NoError(t, err)
+		assert.Equal(t, testMessage, ansMsg)
+	}
+	_, err = msg.GetMessageString(*outsider)
+	assert.ErrorIs(t, err, ErrNotAReader)
+
+	readers, err := msg.Readers()
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, readers, []common.Address{sender.Address, receiver.Address, device.Address})
+}
+
+func TestDirectMessagesStillReadable(t *testing.T) {
+	sender, _ := accounts.GenerateAccount()
+	receiver, _ := accounts.GenerateAccount()
+
+	//messages sent before versioning were encrypted straight to the receiver
+	encrypted, err := receiver.Encrypt([]byte("Hello World!"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := NewSignedCaesarMessage(accounts.AccountFromPubBytes(receiver.PublicKey), *sender, encrypted, nil)
+	assert.NoError(t, msg.Sign())
+	assert.Equal(t, CaesarVersionDirect, msg.Version)
+
+	ansMsg, err := msg.GetMessageString(*receiver)
+	assert.NoError(t, err)
+	assert.Equal(t, "Hello World!", ansMsg)
+	_, err = msg.GetMessageString(*sender)
+	assert.Error(t, err)
+}
+
+func TestSignatureCoversMessage(t *testing.T) {
+	sender, _ := accounts.GenerateAccount()
+	receiver, _ := accounts.GenerateAccount()
+	other, _ := accounts.GenerateAccount()
+
+	msg, err := NewCaesarMessage(*receiver, *sender, "Hello World!")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, CaesarProtocolCurrent, msg.Protocol)
+	assert.True(t, msg.Verify())
+
+	tampered := []func(*CaesarMessage){
+		func(m *CaesarMessage) { m.InitialTime++ },
+		func(m *CaesarMessage) { m.To = accounts.AccountFromPubBytes(other.PublicKey) },
+		func(m *CaesarMessage) { m.HasHostingServer = true },
+		func(m *CaesarMessage) { m.Version = CaesarVersionDirect },
+		func(m *CaesarMessage) { m.Message = append([]byte{}, m.Message[1:]...) },
+		func(m *CaesarMessage) { m.From.Address = other.Address },
+		func(m *CaesarMessage) { m.Signature = m.Signature[:10] },
+	}
+	for i, tamper := range tampered {
+		changed := *msg
+		tamper(&changed)
+		assert.False(t, changed.Verify(), "tampering %v was not caught", i)
+	}
+
+	//messages sent in the same microsecond still have their own hash
+	second := *msg
+	second.Message = append([]byte{}, msg.Message...)
+	second.Message[0]++
+	assert.NotEqual(t, msg.Hash(), second.Hash())
+}
+
+func TestLegacySignatureStillVerifies(t *testing.T) {
+	sender, _ := accounts.GenerateAccount()
+	receiver, _ := accounts.GenerateAccount()
+	encrypted, err := receiver.Encrypt([]byte("Hello World!"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	signature, err := sender.Sign(encrypted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := NewSignedCaesarMessage(accounts.AccountFromPubBytes(receiver.PublicKey), accounts.AccountFromPubBytes(sender.PublicKey), encrypted, signature)
+	assert.Equal(t, CaesarProtocolLegacy, msg.Protocol)
+	assert.True(t, msg.Verify())
+	assert.False(t, msg.VerifyNew(), "a legacy message should only be read from history")
+
+	msg.Protocol = CaesarProtocolCurrent
+	assert.False(t, msg.Verify(), "a legacy signature should not pass as the current protocol")
+}
@@ -0,0 +1,126 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/adamnite/go-adamnite/utils/accounts"
+	encoding "github.com/vmihailenco/msgpack/v5"
+)
+
+// CaesarPayloadType is what a Caesar message carries, once decrypted.
+type CaesarPayloadType uint8
+
+const (
+	CaesarPayloadText CaesarPayloadType = iota
+	CaesarPayloadReceipt
+	CaesarPayloadEdit
+	CaesarPayloadDelete
+	CaesarPayloadAttachment
+	CaesarPayloadTyping
+)
+
+// CaesarReceiptStatus is how far a message got. A later status implies the earlier ones.
+type CaesarReceiptStatus uint8
+
+const (
+	CaesarStatusSent CaesarReceiptStatus = iota
+	CaesarStatusDelivered
+	CaesarStatusRead
+)
+
+// caesarPayloadMagic starts every typed payload. Bodies without it are from before payloads were typed, and are text.
+var caesarPayloadMagic = []byte{0xCA, 0xE5, 0x01}
+
+var ErrPayloadTarget = fmt.Errorf("receipts, edits and deletes need the hash of the message they are about")
+
+// CaesarAttachmentRef points to an attachment that is stored and sent apart from the message.
+type CaesarAttachmentRef struct {
+	ID       []byte // the hash of the attachment manifest
+	Name     string
+	MimeType string
+	Size     uint64
+	Key      []byte // the key the attachment chunks are encrypted with
+}
+
+// CaesarPayload is the typed content inside the encrypted body of a Caesar message.
+type CaesarPayload struct {
+	Type       CaesarPayloadType
+	Text       string               `msgpack:",omitempty"` // the text of text messages and edits
+	Target     []byte               `msgpack:",omitempty"` // the hash of the message a receipt, edit or delete is about
+	Status     CaesarReceiptStatus  `msgpack:",omitempty"`
+	Attachment *CaesarAttachmentRef `msgpack:",omitempty"`
+}
+
+func NewTextPayload(text string) CaesarPayload {
+	return CaesarPayload{Type: CaesarPayloadText, Text: text}
+}
+func NewReceiptPayload(target []byte, status CaesarReceiptStatus) CaesarPayload {
+	return CaesarPayload{Type: CaesarPayloadReceipt, Target: target, Status: status}
+}
+func NewEditPayload(target []byte, text string) CaesarPayload {
+	return CaesarPayload{Type: CaesarPayloadEdit, Target: target, Text: text}
+}
+func NewDeletePayload(target []byte) CaesarPayload {
+	return CaesarPayload{Type: CaesarPayloadDelete, Target: target}
+}
+func NewAttachmentPayload(ref CaesarAttachmentRef) CaesarPayload {
+	return CaesarPayload{Type: CaesarPayloadAttachment, Attachment: &ref}
+}
+func NewTypingPayload() CaesarPayload {
+	return CaesarPayload{Type: CaesarPayloadTyping}
+}
+
+// Bytes encodes the payload to be encrypted as the body of a message.
+func (p CaesarPayload) Bytes() ([]byte, error) {
+	if err := p.check(); err != nil {
+		return nil, err
+	}
+	packed, err := encoding.Marshal(&p)
+	if err != nil {
+		return nil, err
+	}
+	return append(append([]byte{}, caesarPayloadMagic...), packed...), nil
+}
+
+func (p CaesarPayload) check() error {
+	switch p.Type {
+	case CaesarPayloadReceipt, CaesarPayloadEdit, CaesarPayloadDelete:
+		if len(p.Target) == 0 {
+			return ErrPayloadTarget
+		}
+	case CaesarPayloadAttachment:
+		if p.Attachment == nil {
+			return fmt.Errorf("attachment payloads need an attachment")
+		}
+	case CaesarPayloadText, CaesarPayloadTyping:
+	default:
+		return fmt.Errorf("unknown Caesar payload type %v", p.Type)
+	}
+	return nil
+}
+
+// ParseCaesarPayload reads the decrypted body of a message. Bodies from before payloads were typed are read as text.
+func ParseCaesarPayload(body []byte) (*CaesarPayload, error) {
+	if !bytes.HasPrefix(body, caesarPayloadMagic) {
+		payload := NewTextPayload(string(body))
+		return &payload, nil
+	}
+	var payload CaesarPayload
+	if err := encoding.Unmarshal(body[len(caesarPayloadMagic):], &payload); err != nil {
+		return nil, err
+	}
+	if err := payload.check(); err != nil {
+		return nil, err
+	}
+	return &payload, nil
+}
+
+// GetPayload decrypts the message and reads its typed payload.
+func (cm CaesarMessage) GetPayload(reader accounts.Account) (*CaesarPayload, error) {
+	body, err := cm.GetMessage(reader)
+	if err != nil {
+		return nil, err
+	}
+	return ParseCaesarPayload(body)
+}
@@ -0,0 +1,51 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/adamnite/go-adamnite/utils/accounts"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPayloadRoundTrip(t *testing.T) {
+	sender, _ := accounts.GenerateAccount()
+	receiver, _ := accounts.GenerateAccount()
+	target := []byte{1, 2, 3}
+
+	payloads := []CaesarPayload{
+		NewTextPayload("Hello World!"),
+		NewReceiptPayload(target, CaesarStatusRead),
+		NewEditPayload(target, "Hello Again!"),
+		NewDeletePayload(target),
+		NewAttachmentPayload(CaesarAttachmentRef{ID: target, Name: "a.png", MimeType: "image/png", Size: 42, Key: []byte{4}}),
+		NewTypingPayload(),
+	}
+	for _, payload := range payloads {
+		msg, err := NewCaesarMessage(accounts.AccountFromPubBytes(receiver.PublicKey), *sender, payload)
+		if err != nil {
+			t.Fatal(err)
+		}
+		read, err := msg.GetPayload(*receiver)
+		assert.NoError(t, err)
+		assert.Equal(t, payload, *read)
+	}
+
+	_, err := NewCaesarMessage(*receiver, *sender, CaesarPayload{Type: CaesarPayloadEdit, Text: "no target"})
+	assert.ErrorIs(t, err, ErrPayloadTarget)
+}
+
+func TestUntypedBodiesAreText(t *testing.T) {
+	payload, err := ParseCaesarPayload([]byte("Hello World!"))
+	assert.NoError(t, err)
+	assert.Equal(t, NewTextPayload("Hello World!"), *payload)
+
+	sender, _ := accounts.GenerateAccount()
+	receiver, _ := accounts.GenerateAccount()
+	msg, err := NewCaesarMessage(*receiver, *sender, "Hello World!")
+	if err != nil {
+		t.Fatal(err)
+	}
+	text, err := msg.GetMessageString(*receiver)
+	assert.NoError(t, err)
+	assert.Equal(t, "Hello World!", text)
+}
@@ -1,62 +1,163 @@
 package utils
 
 import (
-	"bytes"
 	"crypto/ecdsa"
 	"fmt"
 	"math/big"
-	"math/rand"
 	"time"
 
 	"github.com/adamnite/go-adamnite/common"
+	"github.com/adamnite/go-adamnite/core/types"
 	"github.com/adamnite/go-adamnite/crypto"
 )
 
+// Transaction is a transfer as it is sent to, and passed around, the network. It is signed and hashed as its
+// canonical form, a types.TransferTransaction, so it is the same transaction once it is in a block.
 type Transaction struct {
 	From      common.Address
 	To        common.Address
 	Amount    *big.Int
+	Nonce     uint64   // the sender's transactions are taken in nonce order, each nonce used once
+	ChainID   *big.Int // the chain the transaction is for, see params.ChainConfig
+	Fee       *big.Int // paid to have the transaction included, pools prefer higher fees
 	Time      time.Time
 	Signature []byte
 }
 
+// transfers run no code, so they buy a single ate, priced at the transaction's fee
+const transferAte = 1
+
 var (
 	ErrNegativeAmount  = fmt.Errorf("attempt to send negative funds")
 	ErrIncorrectSigner = fmt.Errorf("attempt to sign from a different account")
+	ErrUnsigned        = fmt.Errorf("the transaction is not signed")
+	ErrWrongChain      = fmt.Errorf("the transaction is for another chain")
+	ErrNonceUsed       = fmt.Errorf("the nonce has already been used by the sender")
+	ErrNotTransfer     = fmt.Errorf("the transaction is not a transfer")
 )
 
-// signs the transaction. Resets the time to now after signing.
-func (t *Transaction) Sign(key ecdsa.PrivateKey) (err error) { //TODO: replace with our key library
-	hash := t.Hash()
-	t.Signature, err = key.Sign(rand.New(rand.NewSource(t.Time.Unix())), hash.Bytes(), nil)
-	t.Time = time.Now()
-	return err
+// NewTransactionFromCanonical is the transfer as a Transaction, recovering who it is from by its signature.
+func NewTransactionFromCanonical(tx *types.Transaction) (*Transaction, error) {
+	if tx.Type() != types.NORMAL_TX {
+		return nil, ErrNotTransfer
+	}
+	from, err := types.Sender(types.NewAdamniteSigner(tx.ChainID()), tx)
+	if err != nil {
+		return nil, err
+	}
+	return &Transaction{
+		From:      from,
+		To:        *tx.To(),
+		Amount:    tx.Amount(),
+		Nonce:     tx.Nonce(),
+		ChainID:   tx.ChainID(),
+		Fee:       new(big.Int).Mul(new(big.Int).SetUint64(tx.ATEMax()), tx.ATEPrice()),
+		Time:      tx.Time(),
+		Signature: tx.Signature(),
+	}, nil
 }
 
-// does not use the time in the hash value, as that is used for the random source value.
-func (t *Transaction) Hash() common.Hash {
-	data := append(t.From.Bytes(), t.To.Bytes()...)
-	data = append(data, t.Amount.Bytes()...)
+// Canonical is the transaction as blocks carry and process it. A signature that can't be read is left off.
+func (t *Transaction) Canonical() *types.Transaction {
+	fee := t.Fee
+	if fee == nil {
+		fee = new(big.Int)
+	}
+	tx := types.NewTransferTransaction(t.ChainID, t.Nonce, t.To, t.Amount, fee, transferAte)
+	if signed, err := tx.WithSignature(t.signer(), t.Signature); err == nil {
+		return signed
+	}
+	return tx
+}
+
+func (t *Transaction) signer() types.Signer {
+	return types.NewAdamniteSigner(t.ChainID)
+}
+
+// signs the transaction, timing it now if it has no time yet.
+func (t *Transaction) Sign(key ecdsa.PrivateKey) error { //TODO: replace with our key library
+	if t.Time.IsZero() {
+		t.Time = time.Now()
+	}
+	signed, err := types.SignTransaction(t.Canonical(), t.signer(), &key)
+	if err != nil {
+		return err
+	}
+	t.Signature = signed.Signature()
+	return nil
+}
 
-	return common.BytesToHash(crypto.Sha512(data))
+// Hash identifies the transaction, the same as its canonical form does in a block. The nonce and chain ID are
+// signed, so a signed transaction can't be replayed. The time is left out, it's only informative.
+func (t *Transaction) Hash() common.Hash {
+	return t.Canonical().Hash()
 }
 
 // Verify that the signature used in the transaction is correct
 func (t *Transaction) VerifySignature(key ecdsa.PublicKey) (ok bool, err error) {
-	if t.Amount.Sign() != 1 {
+	if t.Amount == nil || t.Amount.Sign() != 1 {
 		return false, ErrNegativeAmount
 	}
 	if t.From != crypto.PubkeyToAddress(key) {
 		return false, ErrIncorrectSigner
 	}
-	ok = ecdsa.VerifyASN1(&key, t.Hash().Bytes(), t.Signature)
-	return ok, nil
+	signer, err := t.Signer()
+	return err == nil && signer == t.From, nil
+}
+
+// Signer recovers the account that signed the transaction, so it can be verified knowing only who it is from.
+func (t *Transaction) Signer() (common.Address, error) {
+	if len(t.Signature) != crypto.SignatureLength {
+		return common.Address{}, ErrUnsigned
+	}
+	signer, err := types.Sender(t.signer(), t.Canonical())
+	if err != nil {
+		return common.Address{}, ErrIncorrectSigner
+	}
+	return signer, nil
+}
+
+// Verify checks the transaction was signed by who it is from, without needing their key.
+func (t *Transaction) Verify() error {
+	signer, err := t.Signer()
+	if err != nil {
+		return err
+	}
+	if t.Amount == nil || t.Amount.Sign() != 1 {
+		return ErrNegativeAmount
+	}
+	if signer != t.From {
+		return ErrIncorrectSigner
+	}
+	return nil
+}
+
+// Validate checks the transaction is signed by its sender, is for this chain, and follows on from the nonce the
+// sender's account is at.
+func (t *Transaction) Validate(chainID *big.Int, accountNonce uint64) error {
+	if t.ChainID == nil || chainID == nil || t.ChainID.Cmp(chainID) != 0 {
+		return ErrWrongChain
+	}
+	if t.Nonce < accountNonce {
+		return ErrNonceUsed
+	}
+	return t.Verify()
+}
+
+// Cost is the most the transaction takes from the sender, the amount and its fee.
+func (t *Transaction) Cost() *big.Int {
+	cost := new(big.Int)
+	if t.Amount != nil {
+		cost.Set(t.Amount)
+	}
+	if t.Fee != nil {
+		cost.Add(cost, t.Fee)
+	}
+	return cost
 }
 
 // Test equality between two transactions
 func (a Transaction) Equal(b Transaction) bool {
-	return bytes.Equal(a.From.Bytes(), b.From.Bytes()) &&
-		bytes.Equal(a.To.Bytes(), b.To.Bytes()) &&
-		a.Amount.Cmp(b.Amount) == 0 &&
-		bytes.Equal(a.Signature, b.Signature)
+	//the hash covers everything signed, and the signature
+	return a.From == b.From && a.Hash() == b.Hash()
 }
@@ -0,0 +1,91 @@
+package utils
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/adamnite/go-adamnite/common"
+	"github.com/adamnite/go-adamnite/core/types"
+	"github.com/adamnite/go-adamnite/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTransactionVerify(t *testing.T) {
+	for i := 0; i < 20; i++ {
+		key, _ := crypto.GenerateKey()
+		tx := &Transaction{From: crypto.PubkeyToAddress(key.PublicKey), To: common.Address{1}, Amount: big.NewInt(5), Time: time.Now()}
+		assert.ErrorIs(t, tx.Verify(), ErrUnsigned)
+		if err := tx.Sign(*key); err != nil {
+			t.Fatal(err)
+		}
+		assert.NoError(t, tx.Verify())
+		tx.From = common.Address{2}
+		assert.ErrorIs(t, tx.Verify(), ErrIncorrectSigner)
+	}
+}
+
+func TestTransactionReplayProtection(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	sent := time.Now().Add(-time.Minute)
+	tx := &Transaction{
+		From:    crypto.PubkeyToAddress(key.PublicKey),
+		To:      common.Address{1},
+		Amount:  big.NewInt(5),
+		Nonce:   3,
+		ChainID: big.NewInt(889),
+		Time:    sent,
+	}
+	if err := tx.Sign(*key); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, sent, tx.Time, "signing changed the time")
+
+	assert.NoError(t, tx.Validate(big.NewInt(889), 3))
+	assert.ErrorIs(t, tx.Validate(big.NewInt(889), 4), ErrNonceUsed)
+	assert.ErrorIs(t, tx.Validate(big.NewInt(1), 3), ErrWrongChain)
+
+	//moving the signed transaction to another chain, or nonce, breaks the signature
+	replayed := *tx
+	replayed.ChainID = big.NewInt(1)
+	assert.ErrorIs(t, replayed.Validate(big.NewInt(1), 3), ErrIncorrectSigner)
+	replayed = *tx
+	replayed.Nonce = 4
+	assert.ErrorIs(t, replayed.Validate(big.NewInt(889), 4), ErrIncorrectSigner)
+	assert.False(t, tx.Equal(replayed))
+}
+
+// a transaction is the same one in its canonical form, as blocks carry it
+func TestTransactionCanonical(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	tx := &Transaction{
+		From:    crypto.PubkeyToAddress(key.PublicKey),
+		To:      common.Address{1},
+		Amount:  big.NewInt(5),
+		Nonce:   2,
+		ChainID: big.NewInt(889),
+		Fee:     big.NewInt(3),
+		Time:    time.Now(),
+	}
+	if err := tx.Sign(*key); err != nil {
+		t.Fatal(err)
+	}
+
+	canonical := tx.Canonical()
+	assert.Equal(t, types.NORMAL_TX, canonical.Type())
+	assert.Equal(t, tx.Hash(), canonical.Hash())
+	assert.Equal(t, tx.Cost(), canonical.Cost())
+	sender, err := types.Sender(types.NewAdamniteSigner(big.NewInt(889)), canonical)
+	assert.NoError(t, err)
+	assert.Equal(t, tx.From, sender)
+
+	back, err := NewTransactionFromCanonical(canonical)
+	if assert.NoError(t, err) {
+		assert.True(t, tx.Equal(*back), "the transaction changed going through its canonical form")
+		assert.NoError(t, back.Validate(big.NewInt(889), 2))
+	}
+
+	vote := types.NewVoteTransaction(big.NewInt(889), 0, common.Address{2}, big.NewInt(1), 1)
+	_, err = NewTransactionFromCanonical(vote)
+	assert.ErrorIs(t, err, ErrNotTransfer)
+}